@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/check"
+	"github.com/eslam/depman/pkg/output"
+	"github.com/eslam/depman/pkg/parser"
+	"github.com/eslam/depman/pkg/pip"
+)
+
+// runCheck compares the dependency file with the environment and exits
+// non-zero when any configured rule is violated.
+func runCheck(s *session, args []string) error {
+	fs := newFlagSet("check")
+	format := formatFlag(fs)
+	missing := fs.Bool("missing", s.Config.Check.Missing, "fail when a declared package is not installed")
+	undeclared := fs.Bool("undeclared", s.Config.Check.Undeclared, "fail when an installed package is not declared")
+	outdated := fs.String("outdated", diffName(s.Config.Check.Outdated), "fail on updates of this severity or worse: patch, minor, major or none")
+	ignore := fs.String("ignore", "", "comma-separated package names to skip")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	f, err := output.ParseFormat(*format)
+	if err != nil {
+		return usageError("check: %v", err)
+	}
+
+	rules := check.RulesFromConfig(s.Config.Check)
+	rules.Missing = *missing
+	rules.Undeclared = *undeclared
+	if err := rules.Outdated.UnmarshalText([]byte(*outdated)); err != nil {
+		return usageError("check: --outdated: %v", err)
+	}
+	if rules.Outdated == config.DiffUnknown {
+		return usageError("check: --outdated must be patch, minor, major or none")
+	}
+	if *ignore != "" {
+		rules.Ignore = append(rules.Ignore, strings.Split(*ignore, ",")...)
+	}
+
+	if !s.Project.Detected() {
+		return fmt.Errorf("check: no pyproject.toml or requirements.txt found in %s", s.Project.Dir)
+	}
+	if err := s.requireManager(); err != nil {
+		return err
+	}
+
+	// Packages declared in any group, extras and dev groups included
+	groups, err := parser.ReadDependencyGroups(s.Project)
+	if err != nil {
+		return err
+	}
+	declared := parser.AllRequirements(groups)
+	installed, err := s.installedPackages()
+	if err != nil {
+		return err
+	}
+	var outdatedPkgs []pip.Package
+	if rules.Outdated != config.DiffNone {
+		if outdatedPkgs, err = s.outdatedPackages(); err != nil {
+			return err
+		}
+	}
+
+	report := check.Run(declared, installed, outdatedPkgs, rules)
+
+	if f != output.FormatTable {
+		if err := output.Write(os.Stdout, f, output.KindCheck, report.Findings); err != nil {
+			return err
+		}
+	} else if err := printCheckReport(report); err != nil {
+		return err
+	}
+
+	return checkExitError(report)
+}
+
+func printCheckReport(report check.Report) error {
+	if !report.Failed() {
+		fmt.Fprintln(os.Stdout, "✓ dependencies match the environment")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tPACKAGE\tDECLARED\tINSTALLED\tLATEST")
+	for _, f := range report.Findings {
+		latest := f.Latest
+		if latest != "" {
+			latest += " (" + config.DiffLabel(f.DiffType) + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.Class, f.Package, dash(f.Declared), dash(f.Installed), dash(latest))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "\n%d problem(s): %d missing, %d undeclared, %d outdated\n",
+		len(report.Findings),
+		report.Count(check.ClassMissing),
+		report.Count(check.ClassUndeclared),
+		report.Count(check.ClassOutdated))
+	return nil
+}
+
+// checkExitError combines the exit code bits of every failing class.
+func checkExitError(report check.Report) error {
+	code := 0
+	if report.Count(check.ClassMissing) > 0 {
+		code |= ExitCheckMissing
+	}
+	if report.Count(check.ClassUndeclared) > 0 {
+		code |= ExitCheckUndeclared
+	}
+	if report.Count(check.ClassOutdated) > 0 {
+		code |= ExitCheckOutdated
+	}
+	if code == 0 {
+		return nil
+	}
+	return &ExitError{Code: code}
+}
+
+func diffName(d config.DiffType) string {
+	text, err := d.MarshalText()
+	if err != nil {
+		return "none"
+	}
+	return string(text)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
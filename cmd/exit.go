@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Process exit codes returned by the non-interactive subcommands.
+const (
+	// ExitOK means the command completed successfully
+	ExitOK = 0
+
+	// ExitFailure means the package manager or an I/O operation failed
+	ExitFailure = 1
+
+	// ExitUsage means the command line could not be parsed
+	ExitUsage = 2
+
+	// ExitSyncFailed means the package operation succeeded but the
+	// dependency file could not be rewritten afterwards
+	ExitSyncFailed = 3
+)
+
+// Exit code bits set by `depman check`, one per failure class. When several
+// classes fail the bits are combined, e.g. 8|32 = 40 for missing and outdated.
+const (
+	ExitCheckMissing    = 8
+	ExitCheckUndeclared = 16
+	ExitCheckOutdated   = 32
+)
+
+// ExitError carries a process exit code alongside the underlying error.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the process exit code for an error returned by Execute.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return ExitFailure
+}
+
+// usageError wraps err as a command-line usage failure.
+func usageError(format string, args ...any) error {
+	return &ExitError{Code: ExitUsage, Err: fmt.Errorf(format, args...)}
+}
+
+// syncError wraps err as a dependency file sync failure.
+func syncError(err error) error {
+	return &ExitError{Code: ExitSyncFailed, Err: fmt.Errorf("sync dependency file: %w", err)}
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eslam/depman/pkg/parser"
+	"github.com/eslam/depman/pkg/pip"
+	"github.com/eslam/depman/pkg/pypi"
+)
+
+// runExport writes the installed packages, or those the selected groups
+// need, as pinned requirements, a constraints file or a PEP 751
+// pylock.toml. Hashes and lock file entries come from the PyPI JSON API,
+// except for hashes a Pipfile.lock already records.
+func runExport(s *session, args []string) error {
+	fs := newFlagSet("export")
+	to := fs.String("to", string(parser.ExportRequirements), "format to write: requirements, constraints or pylock")
+	groupRefs := fs.String("group", "", "comma-separated groups to export, e.g. main,dev (default: every installed package)")
+	withHashes := fs.Bool("hash", false, "add --hash options for every package (requirements only)")
+	var out string
+	fs.StringVar(&out, "o", "", "write to this file instead of standard output")
+	fs.StringVar(&out, "output", "", "write to this file instead of standard output")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usageError("export: unexpected argument %q", rest[0])
+	}
+	format, err := parser.ParseExportFormat(*to)
+	if err != nil {
+		return usageError("export: --to must be requirements, constraints or pylock, got %q", *to)
+	}
+	if *withHashes && format != parser.ExportRequirements {
+		return usageError("export: --hash only applies to --to requirements")
+	}
+	if format == parser.ExportPylock && out != "" && !parser.ValidPylockName(filepath.Base(out)) {
+		return usageError("export: a lock file must be named pylock.toml or pylock.<name>.toml, got %q", filepath.Base(out))
+	}
+
+	var selected []parser.Group
+	var groups []parser.DependencyGroup
+	for _, ref := range strings.Split(*groupRefs, ",") {
+		if strings.TrimSpace(ref) == "" {
+			continue
+		}
+		var g parser.Group
+		if g, groups, err = s.resolveGroup(ref); err != nil {
+			return err
+		}
+		selected = append(selected, g)
+	}
+	if err := s.requireManager(); err != nil {
+		return err
+	}
+
+	packages, err := s.installedPackages()
+	if err != nil {
+		return err
+	}
+	var requires map[string][]string
+	if len(selected) > 0 {
+		requires = pip.InstalledRequirements(s.Venv)
+	}
+	sel, err := parser.SelectExport(packages, groups, selected, requires)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	for _, name := range sel.Editable {
+		fmt.Fprintf(os.Stderr, "warning: skipped %s: an editable install has no release to pin\n", name)
+	}
+	for _, name := range sel.Missing {
+		fmt.Fprintf(os.Stderr, "warning: skipped %s: it is declared but not installed\n", name)
+	}
+
+	client := pypi.NewClient(s.Config.PyPI.Mirror)
+	var content string
+	switch {
+	case format == parser.ExportPylock:
+		files, err := s.releaseFiles(client, sel.Packages)
+		if err != nil {
+			return err
+		}
+		if content, err = parser.FormatPylock(sel.Packages, files, client.BaseURL+"/simple"); err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+	case *withHashes:
+		hashes := make(map[string][]string)
+		var fetch []pip.Package
+		for _, p := range sel.Packages {
+			if len(p.Hashes) > 0 {
+				hashes[parser.NormalizeName(p.Name)] = p.Hashes
+			} else {
+				fetch = append(fetch, p)
+			}
+		}
+		files, err := s.releaseFiles(client, fetch)
+		if err != nil {
+			return err
+		}
+		var unhashed []string
+		for _, p := range fetch {
+			name := parser.NormalizeName(p.Name)
+			if hashes[name] = parser.ReleaseHashes(files[name]); len(hashes[name]) == 0 {
+				unhashed = append(unhashed, p.Name+"=="+p.InstalledVersion)
+			}
+		}
+		if len(unhashed) > 0 {
+			return fmt.Errorf("export: %s has no release files to hash for %s", client.BaseURL, strings.Join(unhashed, ", "))
+		}
+		content = parser.FormatPinned(format, sel.Packages, hashes)
+	default:
+		content = parser.FormatPinned(format, sel.Packages, nil)
+	}
+
+	if out == "" {
+		_, err := os.Stdout.WriteString(content)
+		return err
+	}
+	if err := os.WriteFile(out, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	fmt.Fprintf(os.Stdout, "wrote %s (%d packages)\n", out, len(sel.Packages))
+	return nil
+}
+
+// releaseFiles fetches the files of the installed release of each package
+// from PyPI, by normalized name.
+func (s *session) releaseFiles(client *pypi.Client, packages []pip.Package) (map[string][]pypi.ReleaseFile, error) {
+	releases := make([]pypi.Release, 0, len(packages))
+	for _, p := range packages {
+		releases = append(releases, pypi.Release{Name: p.Name, Version: p.InstalledVersion})
+	}
+	fetched, err := client.FetchReleaseFiles(s.ctx, releases)
+	if err != nil {
+		return nil, fmt.Errorf("export: %w", err)
+	}
+	files := make(map[string][]pypi.ReleaseFile, len(fetched))
+	for r, f := range fetched {
+		files[parser.NormalizeName(r.Name)] = f
+	}
+	return files, nil
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eslam/depman/pkg/audit"
+	"github.com/eslam/depman/pkg/output"
+)
+
+// runHistory prints the audit journal of the current project, newest
+// last.
+func runHistory(s *session, args []string) error {
+	fs := newFlagSet("history")
+	format := formatFlag(fs)
+	all := fs.Bool("all", false, "show every project, not just the current one")
+	pkg := fs.String("package", "", "only operations that touched this package")
+	action := fs.String("action", "", "only this action: install, uninstall, upgrade, venv-create, venv-recreate, rewrite or restore")
+	since := fs.String("since", "", "only operations since a date, duration or number of days, e.g. 2024-05-01, 12h or 7d")
+	limit := fs.Int("limit", 20, "show at most this many of the newest operations (0 for all)")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	f, err := output.ParseFormat(*format)
+	if err != nil {
+		return usageError("history: %v", err)
+	}
+	if *limit < 0 {
+		return usageError("history: --limit must not be negative")
+	}
+
+	filter := audit.Filter{Action: audit.Action(*action), Package: *pkg, Limit: *limit}
+	switch filter.Action {
+	case "", audit.ActionInstall, audit.ActionUninstall, audit.ActionUpgrade,
+		audit.ActionVenvCreate, audit.ActionVenvRecreate, audit.ActionRewrite, audit.ActionRestore, audit.ActionSync:
+	default:
+		return usageError("history: unknown --action %q", *action)
+	}
+	if *since != "" {
+		if filter.Since, err = audit.ParseSince(*since, time.Now()); err != nil {
+			return usageError("history: --since: %v", err)
+		}
+	}
+	if !*all {
+		if filter.Project, err = filepath.Abs(s.Project.Dir); err != nil {
+			return fmt.Errorf("history: resolve project path: %w", err)
+		}
+	}
+
+	path, err := audit.DefaultPath()
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	records, skipped, err := audit.Read(path)
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "warning: skipped %d unreadable line(s) in %s\n", skipped, path)
+	}
+	records = filter.Apply(records)
+
+	if f != output.FormatTable {
+		return output.Write(os.Stdout, f, output.KindHistory, records)
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(os.Stdout, "no recorded operations")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "TIME\tACTION\tTARGET\tSTATUS\tDURATION\tCHANGES"
+	if *all {
+		header = "PROJECT\t" + header
+	}
+	fmt.Fprintln(tw, header)
+	for _, r := range records {
+		if *all {
+			fmt.Fprintf(tw, "%s\t", filepath.Base(r.Project))
+		}
+		target := r.Target
+		if r.Action == audit.ActionRewrite || r.Action == audit.ActionRestore || r.Action == audit.ActionSync || r.Action == audit.ActionVenvCreate || r.Action == audit.ActionVenvRecreate {
+			target = relPath(r.Project, target)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Time.Local().Format(time.DateTime), r.Action, target, status(r),
+			time.Duration(r.DurationMS)*time.Millisecond, shorten(r.Summary(), historySummaryWidth))
+	}
+	return tw.Flush()
+}
+
+// historySummaryWidth is the widest CHANGES cell in the history table; use
+// --format json for the full list.
+const historySummaryWidth = 80
+
+// shorten truncates s to at most n runes, marking the cut with an ellipsis.
+func shorten(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// status describes the outcome of a recorded operation.
+func status(r audit.Record) string {
+	switch {
+	case r.OK():
+		return "ok"
+	case r.ExitCode > 0:
+		return fmt.Sprintf("failed (exit %d)", r.ExitCode)
+	default:
+		return "failed"
+	}
+}
+
+// relPath shortens path relative to the project directory when it is
+// inside it.
+func relPath(project, path string) string {
+	if rel, err := filepath.Rel(project, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/output"
+	"github.com/eslam/depman/pkg/pypi"
+)
+
+// runList prints the installed packages.
+func runList(s *session, args []string) error {
+	fs := newFlagSet("list")
+	format := formatFlag(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	f, err := output.ParseFormat(*format)
+	if err != nil {
+		return usageError("list: %v", err)
+	}
+	if err := s.requireManager(); err != nil {
+		return err
+	}
+
+	packages, err := s.installedPackages()
+	if err != nil {
+		return err
+	}
+	if f != output.FormatTable {
+		return output.Write(os.Stdout, f, output.KindInstalled, packages)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tVERSION")
+	for _, p := range packages {
+		fmt.Fprintf(tw, "%s\t%s\n", p.Name, p.InstalledVersion)
+	}
+	return tw.Flush()
+}
+
+// runOutdated prints the outdated packages with their update severity.
+func runOutdated(s *session, args []string) error {
+	fs := newFlagSet("outdated")
+	format := formatFlag(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	f, err := output.ParseFormat(*format)
+	if err != nil {
+		return usageError("outdated: %v", err)
+	}
+	if err := s.requireManager(); err != nil {
+		return err
+	}
+
+	packages, err := s.outdatedPackages()
+	if err != nil {
+		return err
+	}
+	if f != output.FormatTable {
+		return output.Write(os.Stdout, f, output.KindOutdated, packages)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tINSTALLED\tLATEST\tTYPE")
+	for _, p := range packages {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Name, p.InstalledVersion, p.LatestVersion, config.DiffLabel(p.DiffType))
+	}
+	return tw.Flush()
+}
+
+// runSearch queries PyPI and prints the matching packages.
+func runSearch(s *session, args []string) error {
+	fs := newFlagSet("search")
+	format := formatFlag(fs)
+	terms, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(terms) != 1 {
+		return usageError("search: exactly one query is required")
+	}
+	f, err := output.ParseFormat(*format)
+	if err != nil {
+		return usageError("search: %v", err)
+	}
+
+	client := pypi.NewClient(s.Config.PyPI.Mirror)
+	results, err := client.Search(terms[0])
+	if err != nil {
+		return fmt.Errorf("search: %w", err)
+	}
+	if f != output.FormatTable {
+		return output.Write(os.Stdout, f, output.KindSearch, results)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tVERSION\tSUMMARY")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, r.Version, r.Description)
+	}
+	return tw.Flush()
+}
+
+// formatFlag registers the shared --format flag.
+func formatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", string(output.FormatTable), "output format: table, json or ndjson")
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/eslam/depman/pkg/parser"
+)
+
+// runMigrate converts the project's dependency file to pyproject.toml, or
+// exports it to requirements files, and reports anything that could not
+// be translated. The source file is kept unless it is rewritten in place.
+func runMigrate(s *session, args []string) error {
+	fs := newFlagSet("migrate")
+	to := fs.String("to", "", "format to convert to: pyproject or requirements")
+	dryRun := fs.Bool("dry-run", false, "print the files that would be written and stop")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	target := parser.MigrationTarget(*to)
+	if target != parser.ToPyproject && target != parser.ToRequirements {
+		return usageError("migrate: --to must be pyproject or requirements, got %q", *to)
+	}
+	if !s.Project.Detected() {
+		return fmt.Errorf("migrate: no dependency file found in %s", s.Project.Dir)
+	}
+
+	m, err := parser.MigrateProject(s.Project, target)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	if *dryRun {
+		for _, f := range m.Files {
+			fmt.Fprintf(os.Stdout, "==> %s <==\n%s\n", relPath(s.Project.Dir, f.Path), f.Content)
+		}
+		printNotes(os.Stdout, m.Notes)
+		return nil
+	}
+	if err := m.Write(); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	kept := true
+	for _, f := range m.Files {
+		verb := "updated"
+		if f.New {
+			verb = "wrote"
+		}
+		fmt.Fprintf(os.Stdout, "%s %s\n", verb, relPath(s.Project.Dir, f.Path))
+		kept = kept && f.Path != m.Source
+	}
+	if kept {
+		fmt.Fprintf(os.Stdout, "kept %s: remove it once you've checked the result\n", relPath(s.Project.Dir, m.Source))
+	}
+	printNotes(os.Stdout, m.Notes)
+	return nil
+}
+
+// printNotes lists what a migration could not translate.
+func printNotes(w io.Writer, notes []string) {
+	if len(notes) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Not translated:")
+	for _, n := range notes {
+		fmt.Fprintf(w, "  ! %s\n", n)
+	}
+}
@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/log"
+	"github.com/eslam/depman/pkg/parser"
+	"github.com/eslam/depman/pkg/pip"
+	"github.com/eslam/depman/pkg/queue"
+)
+
+// runAdd installs each package spec, then syncs the dependency file. The
+// packages are recorded in --group, or by default in the group that already
+// declares them, else the main group.
+func runAdd(s *session, args []string) error {
+	fs := newFlagSet("add")
+	noSync := fs.Bool("no-sync", false, "do not rewrite the dependency file")
+	groupRef := fs.String("group", "", "dependency group to add to: a name, optional:<name>, group:<name>, env:<name> or main")
+	dryRun := fs.Bool("dry-run", false, "show what would be installed and stop")
+	specs, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(specs) == 0 {
+		return usageError("add: at least one package is required")
+	}
+	group, groups, err := s.resolveGroup(*groupRef)
+	if err != nil {
+		return err
+	}
+	if err := s.requireManager(); err != nil {
+		return err
+	}
+	if *dryRun {
+		p, err := s.Runner.PreviewInstall(specs...)
+		return preview("add", p, err)
+	}
+	s.snapshot("add " + strings.Join(specs, " "))
+
+	changed := 0
+	var failed error
+	for _, spec := range specs {
+		target := group
+		if g, ok := parser.GroupOf(groups, spec); ok && *groupRef == "" {
+			// Re-adding a declared package updates it where it is declared
+			target = g
+		}
+		result := s.runQueued(spec, func() pip.RunResult { return s.Runner.Add(spec, target.String()) })
+		if result.Err != nil {
+			failed = packageError("install", spec, result)
+			break
+		}
+		parser.NoteAdded(s.Project, spec, target)
+		changed++
+		fmt.Fprintf(os.Stdout, "installed %s\n", spec)
+	}
+	return s.finishChanges(changed, *noSync, failed)
+}
+
+// runRemove uninstalls each package, then syncs the dependency file. With
+// --group the package is only dropped from that group, and stays installed
+// while another group still declares it.
+func runRemove(s *session, args []string) error {
+	fs := newFlagSet("remove")
+	noSync := fs.Bool("no-sync", false, "do not rewrite the dependency file")
+	groupRef := fs.String("group", "", "only remove from this dependency group")
+	dryRun := fs.Bool("dry-run", false, "show what would be uninstalled and stop")
+	names, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return usageError("remove: at least one package is required")
+	}
+	group, groups, err := s.resolveGroup(*groupRef)
+	if err != nil {
+		return err
+	}
+	if err := s.requireManager(); err != nil {
+		return err
+	}
+	if *dryRun {
+		var uninstall []string
+		for _, name := range names {
+			if _, kept := parser.DeclaringGroup(groups, name, group); *groupRef != "" && kept {
+				continue
+			}
+			uninstall = append(uninstall, name)
+		}
+		p, err := s.Runner.PreviewUninstall(uninstall...)
+		return preview("remove", p, err)
+	}
+	s.snapshot("remove " + strings.Join(names, " "))
+
+	changed := 0
+	var failed error
+	for _, name := range names {
+		target := group
+		if *groupRef != "" {
+			parser.NoteRemovedFrom(s.Project, name, group)
+			if other, ok := parser.DeclaringGroup(groups, name, group); ok {
+				if s.Runner.ManagesProject() {
+					// uv keeps the package installed for the other group itself
+					if result := s.runQueued(name, func() pip.RunResult { return s.Runner.Remove(name, group.String()) }); result.Err != nil {
+						failed = packageError("remove", name, result)
+						break
+					}
+				}
+				changed++
+				fmt.Fprintf(os.Stdout, "removed %s from %s (kept installed for %s)\n", name, group, other)
+				continue
+			}
+		} else if g, ok := parser.GroupOf(groups, name); ok {
+			target = g
+		}
+		result := s.runQueued(name, func() pip.RunResult { return s.Runner.Remove(name, target.String()) })
+		if result.Err != nil {
+			failed = packageError("uninstall", name, result)
+			break
+		}
+		if *groupRef == "" {
+			parser.NoteRemoved(s.Project, name)
+		}
+		changed++
+		fmt.Fprintf(os.Stdout, "uninstalled %s\n", name)
+	}
+	return s.finishChanges(changed, *noSync, failed)
+}
+
+// finishChanges syncs the dependency file once changed packages were
+// installed or removed, even when failed stopped the rest, so the file
+// keeps up with the environment. It returns failed, joined with the sync
+// error if the sync failed too.
+func (s *session) finishChanges(changed int, noSync bool, failed error) error {
+	if changed == 0 {
+		return failed
+	}
+	err := s.syncAfterChange(noSync)
+	switch {
+	case failed == nil:
+		return err
+	case err != nil:
+		return &ExitError{Code: ExitFailure, Err: errors.Join(failed, err)}
+	}
+	return failed
+}
+
+// runUpgrade upgrades the named packages, or every outdated package with
+// --all. Upgrades run one at a time on the environment's queue; failures
+// are collected and reported after the remaining packages have been
+// attempted, unless [queue] stop_on_error is set.
+func runUpgrade(s *session, args []string) error {
+	fs := newFlagSet("upgrade")
+	noSync := fs.Bool("no-sync", false, "do not rewrite the dependency file")
+	all := fs.Bool("all", false, "upgrade every outdated package")
+	dryRun := fs.Bool("dry-run", false, "show what would be upgraded and stop")
+	names, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *all && len(names) > 0 {
+		return usageError("upgrade: --all cannot be combined with package names")
+	}
+	if !*all && len(names) == 0 {
+		return usageError("upgrade: a package name or --all is required")
+	}
+	if err := s.requireManager(); err != nil {
+		return err
+	}
+
+	if *all {
+		outdated, err := s.outdatedPackages()
+		if err != nil {
+			return err
+		}
+		for _, p := range outdated {
+			names = append(names, p.Name)
+		}
+		if len(names) == 0 {
+			fmt.Fprintln(os.Stdout, "all packages up to date")
+			return nil
+		}
+	}
+	if *dryRun {
+		p, err := s.Runner.PreviewUpgrade(names...)
+		return preview("upgrade", p, err)
+	}
+	if *all {
+		s.snapshot("upgrade --all")
+	} else {
+		s.snapshot("upgrade " + strings.Join(names, " "))
+	}
+
+	jobs := make([]queue.Job, len(names))
+	for i, name := range names {
+		jobs[i] = queue.Job{Name: name, Run: func(context.Context) error {
+			result := s.Runner.Upgrade(name)
+			if result.Err != nil {
+				err := packageError("upgrade", name, result)
+				fmt.Fprintln(os.Stderr, err)
+				return err
+			}
+			fmt.Fprintf(os.Stdout, "upgraded %s\n", name)
+			return nil
+		}}
+	}
+	// Ctrl+C stops the running upgrade and skips the rest
+	r := s.Runner.Queue().Run(s.ctx, jobs, queue.Options{StopOnError: s.Config.Queue.StopOnError})
+
+	if len(r.Succeeded) == 0 {
+		return upgradeError(r)
+	}
+	if err := s.syncAfterChange(*noSync); err != nil {
+		return err
+	}
+	if len(r.Failed) > 0 || len(r.Skipped) > 0 {
+		return upgradeError(r)
+	}
+	return nil
+}
+
+// upgradeError describes the packages of a batch upgrade that failed or
+// were skipped.
+func upgradeError(r queue.Result) error {
+	var parts []string
+	if len(r.Failed) > 0 {
+		parts = append(parts, "failed: "+strings.Join(r.FailedNames(), ", "))
+	}
+	if len(r.Skipped) > 0 {
+		reason := "skipped after a failure"
+		if r.Cancelled {
+			reason = "cancelled"
+		}
+		parts = append(parts, reason+": "+strings.Join(r.Skipped, ", "))
+	}
+	return &ExitError{Code: ExitFailure, Err: fmt.Errorf("upgrade %s", strings.Join(parts, "; "))}
+}
+
+// installedPackages returns the packages installed in the environment.
+// In a pipenv project each package also carries the hashes Pipfile.lock
+// records for its installed version.
+func (s *session) installedPackages() ([]pip.Package, error) {
+	result := s.Runner.List()
+	if result.Err != nil {
+		return nil, packageError("list", "packages", result)
+	}
+	packages, err := pip.ParsePackageList(result.Stdout)
+	if err != nil {
+		return nil, fmt.Errorf("pip: parse package list: %w", err)
+	}
+	if s.Project.Tool == detector.ToolPipenv {
+		lock, err := parser.ReadPipfileLock(s.Project.LockPath())
+		if err != nil {
+			log.Warn("hashes unavailable", "error", err)
+			return packages, nil
+		}
+		for i, p := range packages {
+			packages[i].Hashes = lock.Hashes(p.Name, p.InstalledVersion)
+		}
+	}
+	return packages, nil
+}
+
+// outdatedPackages returns the installed packages with a newer release.
+func (s *session) outdatedPackages() ([]pip.Package, error) {
+	result := s.Runner.Outdated()
+	if result.Err != nil {
+		return nil, packageError("list", "outdated packages", result)
+	}
+	packages, err := pip.ParseOutdatedList(result.Stdout)
+	if err != nil {
+		return nil, fmt.Errorf("pip: parse outdated list: %w", err)
+	}
+	return packages, nil
+}
+
+// runQueued runs a single package operation on the environment's queue,
+// so it waits for any other operation on the same environment. If Ctrl+C
+// cancels it before it starts, the result carries the context's error.
+func (s *session) runQueued(name string, op func() pip.RunResult) pip.RunResult {
+	var result pip.RunResult
+	err := s.Runner.Queue().Do(s.ctx, queue.Job{Name: name, Run: func(context.Context) error {
+		result = op()
+		return result.Err
+	}})
+	if result.Err == nil {
+		result.Err = err
+	}
+	return result
+}
+
+// packageError builds an error for a failed package manager invocation,
+// including the last line of its stderr when available.
+func packageError(action, target string, result pip.RunResult) error {
+	if detail := result.Detail(); detail != "" {
+		return fmt.Errorf("%s %s: %w: %s", action, target, result.Err, detail)
+	}
+	return fmt.Errorf("%s %s: %w", action, target, result.Err)
+}
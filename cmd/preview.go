@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/eslam/depman/pkg/pip"
+)
+
+// printPreview writes the changes an operation would make, one line per
+// distribution, followed by a warning for each dependency the resolver
+// would downgrade.
+func printPreview(w io.Writer, action string, p pip.Preview) {
+	if p.Empty() {
+		fmt.Fprintf(w, "%s: nothing would change\n", action)
+		return
+	}
+	fmt.Fprintf(w, "%s: %s\n", action, p.Summary())
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range p.Changes {
+		note := c.Kind().String()
+		if !c.Requested {
+			note += " (dependency)"
+		}
+		fmt.Fprintf(tw, "  %s %s\t%s\t%s\n", c.Kind().Symbol(), c.Name, c.Versions(), note)
+	}
+	tw.Flush()
+
+	if downgrades := p.TransitiveDowngrades(); len(downgrades) > 0 {
+		var names []string
+		for _, c := range downgrades {
+			names = append(names, c.Name+" "+c.Versions())
+		}
+		fmt.Fprintf(w, "warning: dependencies would be downgraded: %s\n", strings.Join(names, ", "))
+	}
+}
+
+// preview prints the result of a dry run, or fails with the package
+// manager's error.
+func preview(action string, p pip.Preview, err error) error {
+	if err != nil {
+		return fmt.Errorf("%s --dry-run: %w", action, err)
+	}
+	printPreview(os.Stdout, action, p)
+	return nil
+}
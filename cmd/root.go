@@ -1,19 +1,49 @@
 package cmd
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 
 	"github.com/eslam/depman/config"
-	"github.com/eslam/depman/pkg/detector"
-	"github.com/eslam/depman/pkg/env"
 	"github.com/eslam/depman/pkg/log"
 	"github.com/eslam/depman/tui"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+// command describes a non-interactive subcommand.
+type command struct {
+	Name    string
+	Usage   string
+	Summary string
+	Run     func(s *session, args []string) error
+}
+
+// commands lists the subcommands in the order they are shown in help output.
+var commands = []command{
+	{"add", "add [--no-sync] [--dry-run] [--group name] <package[==version]>...", "Install packages and record them in the dependency file", runAdd},
+	{"remove", "remove [--no-sync] [--dry-run] [--group name] <package>...", "Uninstall packages and drop them from the dependency file", runRemove},
+	{"upgrade", "upgrade [--no-sync] [--dry-run] [--all] [package]...", "Upgrade packages to their latest version", runUpgrade},
+	{"list", "list [--format table|json|ndjson]", "List installed packages", runList},
+	{"outdated", "outdated [--format table|json|ndjson]", "List packages with a newer version on PyPI", runOutdated},
+	{"search", "search [--format table|json|ndjson] <query>", "Search PyPI for packages", runSearch},
+	{"check", "check [--missing] [--undeclared] [--outdated patch|minor|major|none] [--ignore a,b]", "Fail when the environment drifts from the dependency file", runCheck},
+	{"sync", "sync [--mode declared|all] [--lock-file file]", "Rewrite the dependency file from the installed packages", runSync},
+	{"export", "export [--to requirements|constraints|pylock] [--group a,b] [--hash] [-o file]", "Pin the installed packages as requirements, constraints or a pylock.toml", runExport},
+	{"migrate", "migrate --to pyproject|requirements [--dry-run]", "Convert the dependency file to pyproject.toml or requirements files", runMigrate},
+	{"undo", "undo [--yes] [--dry-run] [--list]", "Restore the packages and dependency file from before the last change", runUndo},
+	{"history", "history [--all] [--package name] [--action a] [--since 7d] [--limit n] [--format table|json|ndjson]", "Show the audit journal of package and file changes", runHistory},
+}
+
 // Execute is the main entrypoint called from main.go.
+// With no arguments it launches the TUI; otherwise it runs a subcommand.
 func Execute() error {
 	// Load user config
 	cfg, err := config.Load()
@@ -23,20 +53,52 @@ func Execute() error {
 		cfg = config.DefaultConfig()
 	}
 
-	// Initialize logger with configured log level
-	log.Init(cfg.LogLevel)
-	log.Info("depman starting", "log_level", cfg.LogLevel)
+	args := os.Args[1:]
+	if len(args) == 0 {
+		return runTUI(cfg)
+	}
+
+	switch args[0] {
+	case "help", "-h", "--help":
+		printUsage(os.Stdout)
+		return nil
+	}
 
-	// Detect project dependency file
-	project := detector.DetectProject(".")
+	for _, c := range commands {
+		if c.Name == args[0] {
+			// Keep stdout clean for command output: logs go to the log
+			// file, with warnings and errors echoed to stderr
+			if err := log.Setup(logOptions(cfg, os.Stderr)); err != nil {
+				log.Warn("logging to file disabled", "error", err)
+			}
+			defer log.Close()
+			log.Debug("running subcommand", "command", c.Name, "args", strings.Join(args[1:], " "))
+			// Ctrl+C kills the running package manager command, whose
+			// process group does not get the terminal's signal
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return c.Run(newSession(ctx, cfg), args[1:])
+		}
+	}
+
+	printUsage(os.Stderr)
+	return usageError("unknown command %q", args[0])
+}
+
+// runTUI starts the interactive Bubble Tea program.
+func runTUI(cfg config.Config) error {
+	// Log to the file only: anything written to the terminal would corrupt
+	// the TUI
+	if err := log.Setup(logOptions(cfg, nil)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	defer log.Close()
+	log.Info("depman starting", "log_level", cfg.LogLevel, "log_file", log.Path())
 
-	// Detect virtualenv
-	venv := env.DetectVirtualenv(".")
+	s := newSession(context.Background(), cfg)
 
-	// Detect package manager
-	mgr := env.DetectPackageManager(cfg.PackageManager.Preferred)
 	// Build initial app state
-	state := tui.NewAppState(project, venv, mgr, cfg)
+	state := tui.NewAppState(s.Project, s.Venv, s.Manager, cfg)
 
 	// Create and run the Bubble Tea program
 	p := tea.NewProgram(tui.NewModel(state), tea.WithAltScreen())
@@ -55,3 +117,57 @@ func Execute() error {
 
 	return nil
 }
+
+// logOptions builds the logger options from the user config. console, if
+// non-nil, also receives warnings and errors.
+func logOptions(cfg config.Config, console io.Writer) log.Options {
+	return log.Options{
+		Level:    cfg.LogLevel,
+		Path:     cfg.Log.Path,
+		Format:   cfg.Log.Format,
+		MaxSize:  int64(cfg.Log.MaxSizeMB) << 20,
+		MaxFiles: cfg.Log.MaxFiles,
+		Console:  console,
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: depman [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run without a command to start the interactive TUI.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.Usage, c.Summary)
+	}
+	tw.Flush()
+}
+
+// newFlagSet creates a flag set for a subcommand that reports errors
+// instead of exiting.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("depman "+name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// parseFlags parses args allowing flags and positional arguments to be
+// interleaved, and returns the positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return nil, &ExitError{Code: ExitOK}
+			}
+			return nil, &ExitError{Code: ExitUsage, Err: err}
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
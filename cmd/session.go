@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/audit"
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/env"
+	"github.com/eslam/depman/pkg/log"
+	"github.com/eslam/depman/pkg/parser"
+	"github.com/eslam/depman/pkg/pip"
+	"github.com/eslam/depman/pkg/snapshot"
+)
+
+// session bundles the detected project state shared by every subcommand.
+type session struct {
+	Config  config.Config
+	Project detector.Project
+	Venv    env.Virtualenv
+	Manager env.PackageManager
+	Runner  *pip.Runner
+
+	ctx context.Context // cancelled on interrupt
+}
+
+// newSession detects the project, environment and package manager in the
+// current directory. Package manager commands are killed when ctx is done.
+func newSession(ctx context.Context, cfg config.Config) *session {
+	project := detector.DetectProject(".")
+	venv := env.DetectVirtualenv(".")
+	tool := project.Tool
+	if project.FileType == detector.FilePipfile {
+		// pipenv keeps the virtualenv of a Pipfile whether or not it is locked
+		tool = detector.ToolPipenv
+	}
+	if v, ok := env.DetectToolVirtualenv(tool.String(), project.Dir); ok {
+		venv = v
+	}
+	if project.FileType == detector.FileEnvironmentYML {
+		if v, ok := env.DetectCondaEnvironment(condaEnvironmentName(project)); ok {
+			venv = v
+		}
+	}
+	mgr := env.DetectPackageManager(cfg.PackageManager.Preferred)
+	runner := pip.NewBackendRunner(pip.NewEnvBackend(mgr, project, venv), venv).WithContext(ctx)
+	runner.Timeout = time.Duration(cfg.PackageManager.Timeout)
+	journal, err := audit.Open(project.Dir)
+	if err != nil {
+		log.Warn("audit journal disabled", "error", err)
+	}
+	runner.Journal = journal
+	return &session{
+		Config:  cfg,
+		Project: project,
+		Venv:    venv,
+		Manager: mgr,
+		Runner:  runner,
+		ctx:     ctx,
+	}
+}
+
+// requireManager fails when no package manager could be found on PATH.
+func (s *session) requireManager() error {
+	if s.Manager.Type == env.ManagerNone {
+		return fmt.Errorf("no package manager found (install uv or pip)")
+	}
+	if s.Venv.IsBroken {
+		return fmt.Errorf("virtualenv at %s is broken: python interpreter missing", s.Venv.Path)
+	}
+	return nil
+}
+
+// syncAfterChange rewrites the dependency file when sync-on-change is
+// enabled and a project file was detected.
+func (s *session) syncAfterChange(noSync bool) error {
+	if noSync || !s.Config.Sync.OnChange || !s.Project.Detected() {
+		return nil
+	}
+	if err := parser.SyncDependencyFile(s.Project, s.Runner, s.syncOptions()); err != nil {
+		return syncError(err)
+	}
+	return nil
+}
+
+// syncOptions returns the sync options from the config. The hashes of new
+// pins in a hashed requirements file are read from the configured index.
+func (s *session) syncOptions() parser.SyncOptions {
+	opts := parser.SyncOptionsFromConfig(s.Config.Sync)
+	opts.Hashes = parser.PyPIHashes(s.ctx, s.Config.PyPI.Mirror)
+	return opts
+}
+
+// resolveGroup resolves a --group flag value against the project's
+// dependency groups, which it also returns. Groups other than the main one
+// need a pyproject.toml, except for a Pipfile's dev packages, an
+// environment.yml's pip: subsection and the extras of setuptools files.
+func (s *session) resolveGroup(ref string) (parser.Group, []parser.DependencyGroup, error) {
+	var groups []parser.DependencyGroup
+	if s.Project.Detected() {
+		var err error
+		if groups, err = parser.ReadDependencyGroups(s.Project); err != nil {
+			return parser.Group{}, nil, err
+		}
+	}
+	if ref == "" {
+		return parser.MainGroup, groups, nil
+	}
+	if !s.Project.Detected() {
+		return parser.Group{}, nil, fmt.Errorf("--group: no pyproject.toml found in %s", s.Project.Dir)
+	}
+	g, err := parser.ResolveGroup(groups, ref)
+	if err != nil {
+		return parser.Group{}, nil, usageError("--group: %v", err)
+	}
+	switch s.Project.FileType {
+	case detector.FilePyprojectTOML:
+	case detector.FilePipfile:
+		if g.Kind != parser.GroupMain && !g.Is(parser.PipfileDevGroup) {
+			return parser.Group{}, nil, usageError("--group: Pipfile only has packages and dev packages (--group dev)")
+		}
+	case detector.FileEnvironmentYML:
+		if g.Kind != parser.GroupMain && !g.Is(parser.CondaPipGroup) {
+			return parser.Group{}, nil, usageError("--group: environment.yml only has conda packages and pip packages (--group pip)")
+		}
+	case detector.FileSetupCfg, detector.FileSetupPy:
+		if g.Kind != parser.GroupMain && g.Kind != parser.GroupOptional {
+			return parser.Group{}, nil, usageError("--group: %s only has install_requires and extras (--group optional:<extra>)", s.Project.FileType)
+		}
+	default:
+		if g.Kind != parser.GroupMain {
+			return parser.Group{}, nil, usageError("--group: %s only has main dependencies", s.Project.FileType)
+		}
+	}
+	return g, groups, nil
+}
+
+// condaEnvironmentName returns the environment name declared in the
+// project's environment.yml file, or "" if it cannot be read.
+func condaEnvironmentName(project detector.Project) string {
+	content, err := os.ReadFile(project.FilePath)
+	if err != nil {
+		return ""
+	}
+	return parser.CondaEnvironmentName(string(content))
+}
+
+// snapshot saves the installed packages and dependency files before
+// action, so `depman undo` can restore them. Failures are logged, not
+// fatal: they only cost the ability to undo.
+func (s *session) snapshot(action string) {
+	if _, err := snapshot.Take(s.Project, s.Runner, action, s.Config.Sync.LockFile); err != nil {
+		log.Warn("snapshot failed, undo will not be available", "action", action, "error", err)
+	}
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/eslam/depman/pkg/parser"
+)
+
+// runSync rewrites the dependency file from the installed packages. In a
+// project whose package manager owns the dependency file, such as a uv
+// project, it syncs the environment to the lock file instead.
+func runSync(s *session, args []string) error {
+	opts := s.syncOptions()
+	fs := newFlagSet("sync")
+	mode := fs.String("mode", string(opts.Mode), "packages to write: declared or all")
+	fs.StringVar(&opts.LockFile, "lock-file", opts.LockFile, "also pin every installed package in this file")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	m, err := parser.ParseSyncMode(*mode)
+	if err != nil {
+		return usageError("sync: --mode must be declared or all, got %q", *mode)
+	}
+	opts.Mode = m
+	if !s.Project.Detected() {
+		return fmt.Errorf("sync: no pyproject.toml or requirements.txt found in %s", s.Project.Dir)
+	}
+	if err := s.requireManager(); err != nil {
+		return err
+	}
+	s.snapshot("sync")
+
+	if s.Runner.ManagesProject() {
+		lock := s.Project.LockPath()
+		if result := s.Runner.Sync(lock); result.Err != nil {
+			return packageError("sync", lock, result)
+		}
+		fmt.Fprintf(os.Stdout, "synced environment to %s\n", lock)
+		return nil
+	}
+	if err := parser.SyncDependencyFile(s.Project, s.Runner, opts); err != nil {
+		return syncError(err)
+	}
+	fmt.Fprintf(os.Stdout, "synced %s\n", s.Project.FilePath)
+	return nil
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eslam/depman/pkg/snapshot"
+)
+
+// runUndo restores the packages and dependency files saved before the most
+// recent change. Snapshots that already match the current state, such as
+// those of a failed action, are skipped and discarded.
+func runUndo(s *session, args []string) error {
+	fs := newFlagSet("undo")
+	yes := fs.Bool("yes", false, "apply without asking for confirmation")
+	dryRun := fs.Bool("dry-run", false, "show what would be restored and stop")
+	list := fs.Bool("list", false, "list the saved snapshots, newest first")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	snapshots, err := snapshot.List(s.Project.Dir)
+	if err != nil {
+		return fmt.Errorf("undo: %w", err)
+	}
+	if *list {
+		for _, snap := range snapshots {
+			fmt.Fprintf(os.Stdout, "%s  %s\n", snap.Time.Local().Format(time.DateTime), snap.Action)
+		}
+		return nil
+	}
+	if err := s.requireManager(); err != nil {
+		return err
+	}
+
+	installed, err := s.installedPackages()
+	if err != nil {
+		return err
+	}
+	var plan *snapshot.Plan
+	for _, snap := range snapshots {
+		p, err := snapshot.NewPlan(snap, installed)
+		if err != nil {
+			return fmt.Errorf("undo: %w", err)
+		}
+		if !p.Empty() {
+			plan = p
+			break
+		}
+		if !*dryRun {
+			snapshot.Discard(s.Project.Dir, snap.ID)
+		}
+	}
+	if plan == nil {
+		fmt.Fprintln(os.Stdout, "nothing to undo")
+		return nil
+	}
+
+	printPlan(os.Stdout, s.Project.Dir, plan)
+	if *dryRun {
+		return nil
+	}
+	if !*yes {
+		ok, err := confirm(os.Stdin, os.Stdout, "Apply? [y/N] ")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintln(os.Stdout, "cancelled")
+			return nil
+		}
+	}
+
+	if err := plan.Apply(s.Runner); err != nil {
+		return fmt.Errorf("undo %s: %w", plan.Snapshot.Action, err)
+	}
+	if err := snapshot.Discard(s.Project.Dir, plan.Snapshot.ID); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "undid %s\n", plan.Snapshot.Action)
+	return nil
+}
+
+// printPlan writes the package changes and file diffs of an undo.
+func printPlan(w io.Writer, projectDir string, plan *snapshot.Plan) {
+	fmt.Fprintf(w, "Undo %q from %s:\n", plan.Snapshot.Action, plan.Snapshot.Time.Local().Format(time.DateTime))
+	for _, c := range plan.Packages {
+		switch {
+		case c.After == "":
+			fmt.Fprintf(w, "  - %s %s\n", c.Name, c.Before)
+		case c.Before == "":
+			fmt.Fprintf(w, "  + %s %s\n", c.Name, c.After)
+		default:
+			fmt.Fprintf(w, "  ~ %s %s → %s\n", c.Name, c.Before, c.After)
+		}
+	}
+	for _, f := range plan.Files {
+		name := relPath(projectDir, f.Path)
+		switch {
+		case f.Previous == nil:
+			fmt.Fprintf(w, "%s: delete\n", name)
+			continue
+		case f.Current == nil:
+			fmt.Fprintf(w, "%s: recreate\n", name)
+		default:
+			fmt.Fprintf(w, "%s:\n", name)
+		}
+		for _, l := range f.Diff(2) {
+			if l.Op == '…' {
+				fmt.Fprintln(w, "    …")
+				continue
+			}
+			fmt.Fprintf(w, "  %c %s\n", l.Op, l.Text)
+		}
+	}
+	if plan.Intents {
+		fmt.Fprintln(w, "pending adds and removes not yet synced are restored")
+	}
+}
+
+// confirm asks a yes/no question on a terminal. Without a terminal there is
+// nobody to answer, so it fails and asks for --yes.
+func confirm(in *os.File, out io.Writer, prompt string) (bool, error) {
+	if info, err := in.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false, usageError("not a terminal: pass --yes to apply")
+	}
+	fmt.Fprint(out, prompt)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("read answer: %w", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	toml "github.com/pelletier/go-toml/v2"
 )
@@ -13,12 +15,33 @@ type Config struct {
 	PackageManager PackageManagerConfig `toml:"package_manager"`
 	PyPI           PyPIConfig           `toml:"pypi"`
 	Theme          ThemeConfig          `toml:"theme"`
+	Sync           SyncConfig           `toml:"sync"`
+	Check          CheckConfig          `toml:"check"`
+	Queue          QueueConfig          `toml:"queue"`
 	LogLevel       string               `toml:"log_level"` // "debug" | "info" | "warn" | "error"
+	Log            LogConfig            `toml:"log"`
 }
 
 // PackageManagerConfig specifies the preferred package manager.
 type PackageManagerConfig struct {
-	Preferred string `toml:"preferred"` // "uv" | "pip" | "pip3" | "" (auto)
+	Preferred string   `toml:"preferred"` // "uv" | "pip" | "pip3" | "" (auto)
+	Timeout   Duration `toml:"timeout"`   // kill a command that runs longer, e.g. "30m"; "0" disables the limit (default: "30m")
+}
+
+// Duration is a time.Duration written as a string such as "30m" or "90s".
+type Duration time.Duration
+
+// UnmarshalText decodes a Duration from a Go duration string.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("config: invalid duration %q: %w", string(text), err)
+	}
+	if v < 0 {
+		return fmt.Errorf("config: negative duration %q", string(text))
+	}
+	*d = Duration(v)
+	return nil
 }
 
 // PyPIConfig specifies PyPI connection settings.
@@ -31,11 +54,42 @@ type ThemeConfig struct {
 	Name string `toml:"name"` // default: "tokyo-night"
 }
 
+// SyncConfig controls how the dependency file follows the environment.
+type SyncConfig struct {
+	OnChange bool   `toml:"on_change"` // rewrite the dependency file after each package action (default: true)
+	Mode     string `toml:"mode"`      // "declared" (direct dependencies only) | "all" (every installed package) (default: "declared")
+	LockFile string `toml:"lock_file"` // also pin every installed package in this file, e.g. "constraints.txt" (default: "" = off)
+}
+
+// CheckConfig sets the rules enforced by `depman check`.
+type CheckConfig struct {
+	Missing    bool     `toml:"missing"`    // fail when a declared package is not installed (default: true)
+	Undeclared bool     `toml:"undeclared"` // fail when an installed package is not declared (default: false)
+	Outdated   DiffType `toml:"outdated"`   // fail on updates of this severity or worse: "patch" | "minor" | "major" | "none" (default: "major")
+	Ignore     []string `toml:"ignore"`     // package names that are never reported
+}
+
+// QueueConfig controls batches of package operations, such as upgrading
+// every outdated package.
+type QueueConfig struct {
+	StopOnError bool `toml:"stop_on_error"` // skip the remaining operations after the first failure (default: false)
+}
+
+// LogConfig controls the log file. Logs never go to stdout, where they
+// would corrupt the TUI.
+type LogConfig struct {
+	Path      string `toml:"path"`        // default: $XDG_STATE_HOME/depman/depman.log
+	Format    string `toml:"format"`      // "text" | "json" (default: "text")
+	MaxSizeMB int    `toml:"max_size_mb"` // rotate the file once it exceeds this size (default: 5)
+	MaxFiles  int    `toml:"max_files"`   // rotated files to keep (default: 3)
+}
+
 // DefaultConfig returns the default configuration values.
 func DefaultConfig() Config {
 	return Config{
 		PackageManager: PackageManagerConfig{
 			Preferred: "", // auto-detect
+			Timeout:   Duration(30 * time.Minute),
 		},
 		PyPI: PyPIConfig{
 			Mirror: "https://pypi.org",
@@ -43,7 +97,20 @@ func DefaultConfig() Config {
 		Theme: ThemeConfig{
 			Name: "tokyo-night",
 		},
+		Sync: SyncConfig{
+			OnChange: true,
+			Mode:     "declared",
+		},
+		Check: CheckConfig{
+			Missing:  true,
+			Outdated: DiffMajor,
+		},
 		LogLevel: "info", // default log level
+		Log: LogConfig{
+			Format:    "text",
+			MaxSizeMB: 5,
+			MaxFiles:  3,
+		},
 	}
 }
 
@@ -87,5 +154,24 @@ func Load() (Config, error) {
 	if cfg.LogLevel == "" {
 		cfg.LogLevel = "info"
 	}
+	if cfg.Log.Format == "" {
+		cfg.Log.Format = "text"
+	}
+	if cfg.Log.Path != "" {
+		cfg.Log.Path = expandHome(cfg.Log.Path)
+	}
 	return cfg, nil
 }
+
+// expandHome replaces a leading "~/" in path with the user's home directory.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -24,6 +25,13 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.LogLevel != "info" {
 		t.Errorf("LogLevel = %q; want %q", cfg.LogLevel, "info")
 	}
+
+	if !cfg.Sync.OnChange {
+		t.Errorf("Sync.OnChange = false; want true")
+	}
+	if cfg.Sync.Mode != "declared" {
+		t.Errorf("Sync.Mode = %q; want %q", cfg.Sync.Mode, "declared")
+	}
 }
 
 func TestLoadConfig_ValidFile(t *testing.T) {
@@ -113,6 +121,147 @@ name = "tokyo-night"`,
 	}
 }
 
+func TestLoadConfig_SyncOnChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{"section absent keeps default", `[theme]
+name = "tokyo-night"`, true},
+		{"explicitly disabled", `[sync]
+on_change = false`, false},
+		{"explicitly enabled", `[sync]
+on_change = true`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			depmanDir := filepath.Join(tmpDir, "depman")
+			if err := os.MkdirAll(depmanDir, 0755); err != nil {
+				t.Fatalf("failed to create depman dir: %v", err)
+			}
+			configFile := filepath.Join(depmanDir, "config.toml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write test config: %v", err)
+			}
+			t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v; want nil", err)
+			}
+			if cfg.Sync.OnChange != tt.expected {
+				t.Errorf("Sync.OnChange = %v; want %v", cfg.Sync.OnChange, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_SyncMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	depmanDir := filepath.Join(tmpDir, "depman")
+	if err := os.MkdirAll(depmanDir, 0755); err != nil {
+		t.Fatalf("failed to create depman dir: %v", err)
+	}
+	content := `[sync]
+mode = "all"
+lock_file = "constraints.txt"`
+	if err := os.WriteFile(filepath.Join(depmanDir, "config.toml"), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v; want nil", err)
+	}
+	if cfg.Sync.Mode != "all" {
+		t.Errorf("Sync.Mode = %q; want %q", cfg.Sync.Mode, "all")
+	}
+	if cfg.Sync.LockFile != "constraints.txt" {
+		t.Errorf("Sync.LockFile = %q; want %q", cfg.Sync.LockFile, "constraints.txt")
+	}
+	if !cfg.Sync.OnChange {
+		t.Errorf("Sync.OnChange = false; want default true")
+	}
+}
+
+func TestLoadConfig_CheckRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	depmanDir := filepath.Join(tmpDir, "depman")
+	if err := os.MkdirAll(depmanDir, 0755); err != nil {
+		t.Fatalf("failed to create depman dir: %v", err)
+	}
+	content := `[check]
+undeclared = true
+outdated = "minor"
+ignore = ["pip", "setuptools"]`
+	configFile := filepath.Join(depmanDir, "config.toml")
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v; want nil", err)
+	}
+	if !cfg.Check.Missing {
+		t.Errorf("Check.Missing = false; want default true")
+	}
+	if !cfg.Check.Undeclared {
+		t.Errorf("Check.Undeclared = false; want true")
+	}
+	if cfg.Check.Outdated != DiffMinor {
+		t.Errorf("Check.Outdated = %v; want %v", cfg.Check.Outdated, DiffMinor)
+	}
+	if len(cfg.Check.Ignore) != 2 {
+		t.Errorf("Check.Ignore = %v; want 2 entries", cfg.Check.Ignore)
+	}
+}
+
+func TestLoadConfig_Log(t *testing.T) {
+	tmpDir := t.TempDir()
+	depmanDir := filepath.Join(tmpDir, "depman")
+	if err := os.MkdirAll(depmanDir, 0755); err != nil {
+		t.Fatalf("failed to create depman dir: %v", err)
+	}
+	content := `log_level = "debug"
+
+[log]
+path = "~/logs/depman.log"
+format = "json"
+max_files = 1`
+	configFile := filepath.Join(depmanDir, "config.toml")
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v; want nil", err)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("LogLevel = %q; want %q", cfg.LogLevel, "debug")
+	}
+	if want := filepath.Join(tmpDir, "logs", "depman.log"); cfg.Log.Path != want {
+		t.Errorf("Log.Path = %q; want %q", cfg.Log.Path, want)
+	}
+	if cfg.Log.Format != "json" {
+		t.Errorf("Log.Format = %q; want %q", cfg.Log.Format, "json")
+	}
+	if cfg.Log.MaxSizeMB != 5 {
+		t.Errorf("Log.MaxSizeMB = %d; want default 5", cfg.Log.MaxSizeMB)
+	}
+	if cfg.Log.MaxFiles != 1 {
+		t.Errorf("Log.MaxFiles = %d; want 1", cfg.Log.MaxFiles)
+	}
+}
+
 func TestLoadConfig_MissingFile(t *testing.T) {
 	tmpDir := t.TempDir()
 	t.Setenv("XDG_CONFIG_HOME", tmpDir)
@@ -196,3 +345,45 @@ func TestConfigPath(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadConfig_Timeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{name: "default", content: "", expected: 30 * time.Minute},
+		{name: "set", content: "[package_manager]\ntimeout = \"90s\"", expected: 90 * time.Second},
+		{name: "disabled", content: "[package_manager]\ntimeout = \"0\"", expected: 0},
+		{name: "invalid", content: "[package_manager]\ntimeout = \"soon\"", wantErr: true},
+		{name: "negative", content: "[package_manager]\ntimeout = \"-1m\"", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			depmanDir := filepath.Join(tmpDir, "depman")
+			if err := os.MkdirAll(depmanDir, 0755); err != nil {
+				t.Fatalf("failed to create depman dir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(depmanDir, "config.toml"), []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write test config: %v", err)
+			}
+			t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Load() error = nil; want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v; want nil", err)
+			}
+			if got := time.Duration(cfg.PackageManager.Timeout); got != tt.expected {
+				t.Errorf("PackageManager.Timeout = %v; want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package config
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
 
 // Tokyo Night color palette
 var (
@@ -60,3 +64,32 @@ func DiffLabel(d DiffType) string {
 		return "unknown"
 	}
 }
+
+// diffNames are the stable identifiers used when a DiffType is serialized.
+var diffNames = map[DiffType]string{
+	DiffNone:    "none",
+	DiffPatch:   "patch",
+	DiffMinor:   "minor",
+	DiffMajor:   "major",
+	DiffUnknown: "unknown",
+}
+
+// MarshalText encodes a DiffType as its stable identifier, e.g. "minor".
+func (d DiffType) MarshalText() ([]byte, error) {
+	name, ok := diffNames[d]
+	if !ok {
+		return nil, fmt.Errorf("config: invalid diff type %d", int(d))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText decodes a DiffType from its stable identifier.
+func (d *DiffType) UnmarshalText(text []byte) error {
+	for k, v := range diffNames {
+		if v == string(text) {
+			*d = k
+			return nil
+		}
+	}
+	return fmt.Errorf("config: unknown diff type %q", string(text))
+}
@@ -0,0 +1,289 @@
+// Package audit keeps an append-only journal of every change depman makes
+// to an environment or a dependency file, one JSON record per line.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eslam/depman/pkg/pyname"
+	"github.com/eslam/depman/pkg/store"
+)
+
+// Action names the kind of change a record describes.
+type Action string
+
+const (
+	ActionInstall      Action = "install"
+	ActionUninstall    Action = "uninstall"
+	ActionUpgrade      Action = "upgrade"
+	ActionVenvCreate   Action = "venv-create"
+	ActionVenvRecreate Action = "venv-recreate"
+	ActionRewrite      Action = "rewrite" // a dependency or lock file was rewritten
+	ActionRestore      Action = "restore" // a file was put back by undo
+	ActionSync         Action = "sync"    // the environment was synced to a lock file
+)
+
+// Change is one package whose version changed. For a rewrite, Before and
+// After are the requirement as written in the file rather than a version.
+// An empty Before means the package was added, an empty After that it was
+// removed.
+type Change struct {
+	Name   string `json:"name"`
+	Group  string `json:"group,omitempty"` // dependency group, for rewrites of pyproject.toml
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// Record is one journal entry.
+type Record struct {
+	Time       time.Time `json:"time"`
+	Action     Action    `json:"action"`
+	Target     string    `json:"target,omitempty"` // package spec, or file path for a rewrite
+	Project    string    `json:"project,omitempty"`
+	Venv       string    `json:"venv,omitempty"`
+	Manager    string    `json:"manager,omitempty"`
+	Argv       []string  `json:"argv,omitempty"`
+	ExitCode   int       `json:"exit_code"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Changes    []Change  `json:"changes,omitempty"`
+}
+
+// OK reports whether the recorded operation succeeded.
+func (r Record) OK() bool {
+	return r.ExitCode == 0 && r.Error == ""
+}
+
+// DefaultPath returns the journal shared by every project, audit.jsonl in
+// the state directory.
+func DefaultPath() (string, error) {
+	dir, err := store.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "audit.jsonl"), nil
+}
+
+// Journal appends records for one project to a journal file. A nil
+// *Journal discards records, so callers need not check for one.
+type Journal struct {
+	Path    string
+	Project string // absolute project directory stamped on each record
+
+	mu sync.Mutex
+}
+
+// Open returns a journal for the project rooted at projectDir, writing to
+// DefaultPath.
+func Open(projectDir string) (*Journal, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(projectDir)
+	if err != nil {
+		return nil, fmt.Errorf("audit: resolve project path: %w", err)
+	}
+	return &Journal{Path: path, Project: abs}, nil
+}
+
+// Append writes rec as one line, filling in the time and project when they
+// are unset.
+func (j *Journal) Append(rec Record) error {
+	if j == nil {
+		return nil
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	rec.Time = rec.Time.UTC()
+	if rec.Project == "" {
+		rec.Project = j.Project
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("audit: encode record: %w", err)
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(j.Path), 0o755); err != nil {
+		return fmt.Errorf("audit: create journal directory: %w", err)
+	}
+	f, err := os.OpenFile(j.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("audit: open journal: %w", err)
+	}
+	// A single write of the whole line keeps records from different
+	// processes from interleaving
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("audit: write journal: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("audit: close journal: %w", err)
+	}
+	return nil
+}
+
+// Read returns the records in the journal at path, oldest first. A missing
+// journal has no records. Lines that are not valid records, such as one
+// cut short by a crash, are skipped and counted in skipped.
+func Read(path string) (records []Record, skipped int, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("audit: open journal: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil || rec.Action == "" {
+			skipped++
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return records, skipped, fmt.Errorf("audit: read journal: %w", err)
+	}
+	return records, skipped, nil
+}
+
+// Filter selects journal records.
+type Filter struct {
+	Project string    // absolute project directory; empty matches every project
+	Action  Action    // empty matches every action
+	Package string    // package name in the target or changes; empty matches all
+	Since   time.Time // zero matches all
+	Limit   int       // keep only the newest Limit matches; 0 keeps all
+}
+
+// Apply returns the records matching f, oldest first.
+func (f Filter) Apply(records []Record) []Record {
+	var out []Record
+	for _, r := range records {
+		if f.match(r) {
+			out = append(out, r)
+		}
+	}
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out
+}
+
+func (f Filter) match(r Record) bool {
+	if f.Project != "" && r.Project != f.Project {
+		return false
+	}
+	if f.Action != "" && r.Action != f.Action {
+		return false
+	}
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if f.Package == "" {
+		return true
+	}
+	pkg := pyname.Normalize(f.Package)
+	for _, c := range r.Changes {
+		if pyname.Normalize(c.Name) == pkg {
+			return true
+		}
+	}
+	return r.Action != ActionRewrite && r.Action != ActionRestore && r.Action != ActionSync && pyname.Normalize(pyname.FromSpec(r.Target)) == pkg
+}
+
+// Diff returns the packages whose version differs between two
+// name → version maps, sorted by name.
+func Diff(before, after map[string]string) []Change {
+	var changes []Change
+	for name, v := range before {
+		if after[name] != v {
+			changes = append(changes, Change{Name: name, Before: v, After: after[name]})
+		}
+	}
+	for name, v := range after {
+		if _, ok := before[name]; !ok {
+			changes = append(changes, Change{Name: name, After: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Group != changes[j].Group {
+			return changes[i].Group < changes[j].Group
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// Summary describes the changes in a few words, e.g. "requests 2.30.0 →
+// 2.31.0, +urllib3 2.1.0". For a rewrite or restore, whose changes hold
+// requirements rather than versions, it reads "+urllib3==2.1.0".
+func (r Record) Summary() string {
+	requirements := r.Action == ActionRewrite || r.Action == ActionRestore
+	var parts []string
+	for _, c := range r.Changes {
+		prefix := ""
+		if c.Group != "" {
+			prefix = c.Group + "/"
+		}
+		before, after := c.Name+" "+c.Before, c.Name+" "+c.After
+		if requirements {
+			before, after = c.Before, c.After
+		}
+		switch {
+		case c.Before == "":
+			parts = append(parts, "+"+prefix+after)
+		case c.After == "":
+			parts = append(parts, "-"+prefix+before)
+		case requirements:
+			parts = append(parts, prefix+c.Before+" → "+c.After)
+		default:
+			parts = append(parts, prefix+c.Name+" "+c.Before+" → "+c.After)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseSince parses a --since value relative to now: a date such as
+// "2024-05-01", an RFC 3339 time, a Go duration such as "36h", or a number
+// of days such as "7d".
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("audit: invalid time %q (want a date, an RFC 3339 time, a duration or a number of days such as 7d)", s)
+}
@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "audit.jsonl")
+	j := &Journal{Path: path, Project: "/work/demo"}
+
+	records := []Record{
+		{Action: ActionInstall, Target: "requests==2.31.0", Argv: []string{"uv", "pip", "install", "requests==2.31.0"},
+			Changes: []Change{{Name: "requests", After: "2.31.0"}}},
+		{Action: ActionUpgrade, Target: "flask", ExitCode: 1, Error: "exit status 1"},
+		{Action: ActionRewrite, Target: "/work/other/pyproject.toml", Project: "/work/other"},
+	}
+	for _, r := range records {
+		if err := j.Append(r); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	// A line cut short by a crash is skipped, not fatal
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"time":"2024-01-01T00:00:00Z","act` + "\n")
+	f.Close()
+
+	got, skipped, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if skipped != 1 {
+		t.Errorf("Read() skipped = %d, want 1", skipped)
+	}
+	if len(got) != 3 {
+		t.Fatalf("Read() returned %d records, want 3", len(got))
+	}
+	if got[0].Project != "/work/demo" || got[0].Time.IsZero() || got[0].Time.Location() != time.UTC {
+		t.Errorf("record not stamped: %+v", got[0])
+	}
+	if got[2].Project != "/work/other" {
+		t.Errorf("explicit project overwritten: %q", got[2].Project)
+	}
+	if !got[0].OK() || got[1].OK() {
+		t.Errorf("OK() = %v, %v; want true, false", got[0].OK(), got[1].OK())
+	}
+	if got[0].Summary() != "+requests 2.31.0" {
+		t.Errorf("Summary() = %q", got[0].Summary())
+	}
+
+	if records, _, err := Read(filepath.Join(t.TempDir(), "missing.jsonl")); err != nil || records != nil {
+		t.Errorf("Read() of a missing journal = %v, %v; want no records", records, err)
+	}
+
+	var nilJournal *Journal
+	if err := nilJournal.Append(Record{Action: ActionInstall}); err != nil {
+		t.Errorf("nil Journal Append() error = %v", err)
+	}
+}
+
+func TestJournal_ConcurrentAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	j := &Journal{Path: path}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j.Append(Record{Action: ActionUpgrade, Target: strings.Repeat("x", 4096)})
+		}()
+	}
+	wg.Wait()
+
+	got, skipped, err := Read(path)
+	if err != nil || skipped != 0 || len(got) != 20 {
+		t.Errorf("Read() = %d records, %d skipped, %v; want 20, 0, nil", len(got), skipped, err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Time: now.Add(-72 * time.Hour), Action: ActionInstall, Target: "Requests[socks]>=2", Project: "/a"},
+		{Time: now.Add(-48 * time.Hour), Action: ActionUpgrade, Target: "flask", Project: "/a",
+			Changes: []Change{{Name: "flask", Before: "2.3.0", After: "3.0.0"}, {Name: "werkzeug", Before: "2.3.0", After: "3.0.1"}}},
+		{Time: now.Add(-24 * time.Hour), Action: ActionRewrite, Target: "/a/requests.txt", Project: "/a"},
+		{Time: now, Action: ActionInstall, Target: "requests", Project: "/b"},
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []int
+	}{
+		{"everything", Filter{}, []int{0, 1, 2, 3}},
+		{"project", Filter{Project: "/a"}, []int{0, 1, 2}},
+		{"action", Filter{Action: ActionInstall}, []int{0, 3}},
+		{"package in target", Filter{Package: "requests"}, []int{0, 3}},
+		{"package in changes", Filter{Package: "Werkzeug"}, []int{1}},
+		{"since", Filter{Since: now.Add(-36 * time.Hour)}, []int{2, 3}},
+		{"limit keeps newest", Filter{Project: "/a", Limit: 2}, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filter.Apply(records)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Apply() returned %d records, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, idx := range tt.want {
+				if got[i].Target != records[idx].Target || got[i].Project != records[idx].Project {
+					t.Errorf("Apply()[%d] = %+v, want record %d", i, got[i], idx)
+				}
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := map[string]string{"flask": "2.3.0", "itsdangerous": "2.1.2", "click": "8.1.7"}
+	after := map[string]string{"flask": "3.0.0", "click": "8.1.7", "blinker": "1.7.0"}
+
+	got := Diff(before, after)
+	want := []Change{
+		{Name: "blinker", After: "1.7.0"},
+		{Name: "flask", Before: "2.3.0", After: "3.0.0"},
+		{Name: "itsdangerous", Before: "2.1.2"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Diff() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Diff()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if s := (Record{Changes: got}).Summary(); s != "+blinker 1.7.0, flask 2.3.0 → 3.0.0, -itsdangerous 2.1.2" {
+		t.Errorf("Summary() = %q", s)
+	}
+
+	rewrite := Record{Action: ActionRewrite, Changes: []Change{
+		{Name: "requests", Before: "requests==2.30.0", After: "requests==2.31.0"},
+		{Name: "rich", Group: "group:dev", After: "rich==13.7.0"},
+	}}
+	if s := rewrite.Summary(); s != "requests==2.30.0 → requests==2.31.0, +group:dev/rich==13.7.0" {
+		t.Errorf("rewrite Summary() = %q", s)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2024-05-01", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2024-05-01T08:30:00Z", time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC), false},
+		{"7d", now.AddDate(0, 0, -7), false},
+		{"36h", now.Add(-36 * time.Hour), false},
+		{"yesterday", time.Time{}, true},
+		{"-3d", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSince(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSince(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseSince(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Package check compares the declared dependencies of a project with the
+// packages installed in its environment.
+package check
+
+import (
+	"sort"
+
+	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/parser"
+	"github.com/eslam/depman/pkg/pip"
+)
+
+// Class is the kind of drift a finding reports.
+type Class string
+
+const (
+	ClassMissing    Class = "missing"    // declared but not installed
+	ClassUndeclared Class = "undeclared" // installed but not declared
+	ClassOutdated   Class = "outdated"   // declared and behind the latest release
+)
+
+// Rules selects which classes of drift are reported.
+type Rules struct {
+	Missing    bool
+	Undeclared bool
+	// Outdated reports declared packages whose update is at least this
+	// severe. DiffNone disables the rule. Updates of unknown severity are
+	// always reported when the rule is enabled.
+	Outdated config.DiffType
+	Ignore   []string
+}
+
+// RulesFromConfig converts the user's [check] configuration into Rules.
+func RulesFromConfig(cfg config.CheckConfig) Rules {
+	return Rules{
+		Missing:    cfg.Missing,
+		Undeclared: cfg.Undeclared,
+		Outdated:   cfg.Outdated,
+		Ignore:     cfg.Ignore,
+	}
+}
+
+// Finding is a single dependency that violates a rule.
+type Finding struct {
+	Class     Class           `json:"class"`
+	Package   string          `json:"package"`
+	Declared  string          `json:"declared,omitempty"`
+	Installed string          `json:"installed,omitempty"`
+	Latest    string          `json:"latest,omitempty"`
+	DiffType  config.DiffType `json:"diff_type,omitempty"`
+}
+
+// Report is the result of a check run, sorted by class then package.
+type Report struct {
+	Findings []Finding
+}
+
+// Failed returns true if any rule was violated.
+func (r Report) Failed() bool {
+	return len(r.Findings) > 0
+}
+
+// Count returns the number of findings of the given class.
+func (r Report) Count(c Class) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Class == c {
+			n++
+		}
+	}
+	return n
+}
+
+// Run checks the declared dependencies against the installed and outdated
+// package lists.
+func Run(declared []parser.Requirement, installed, outdated []pip.Package, rules Rules) Report {
+	ignored := make(map[string]bool)
+	for _, name := range rules.Ignore {
+		ignored[parser.NormalizeName(name)] = true
+	}
+
+	declaredByName := make(map[string]parser.Requirement)
+	for _, d := range declared {
+		declaredByName[d.Key()] = d
+	}
+	installedByName := make(map[string]pip.Package)
+	for _, p := range installed {
+		installedByName[parser.NormalizeName(p.Name)] = p
+	}
+
+	var findings []Finding
+
+	if rules.Missing {
+		for key, d := range declaredByName {
+			if ignored[key] {
+				continue
+			}
+			if _, ok := installedByName[key]; !ok {
+				findings = append(findings, Finding{
+					Class:    ClassMissing,
+					Package:  d.Name,
+					Declared: declaredSpec(d),
+				})
+			}
+		}
+	}
+
+	if rules.Undeclared {
+		for key, p := range installedByName {
+			if ignored[key] || parser.IsTooling(key) || p.Editable {
+				continue
+			}
+			if _, ok := declaredByName[key]; !ok {
+				findings = append(findings, Finding{
+					Class:     ClassUndeclared,
+					Package:   p.Name,
+					Installed: p.InstalledVersion,
+				})
+			}
+		}
+	}
+
+	if rules.Outdated != config.DiffNone {
+		for _, p := range outdated {
+			key := parser.NormalizeName(p.Name)
+			d, ok := declaredByName[key]
+			if !ok || ignored[key] || !exceeds(p.DiffType, rules.Outdated) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Class:     ClassOutdated,
+				Package:   p.Name,
+				Declared:  declaredSpec(d),
+				Installed: p.InstalledVersion,
+				Latest:    p.LatestVersion,
+				DiffType:  p.DiffType,
+			})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Class != findings[j].Class {
+			return classOrder(findings[i].Class) < classOrder(findings[j].Class)
+		}
+		return parser.NormalizeName(findings[i].Package) < parser.NormalizeName(findings[j].Package)
+	})
+
+	return Report{Findings: findings}
+}
+
+// declaredSpec describes how a requirement constrains its version, in the
+// dependency file's own notation.
+func declaredSpec(r parser.Requirement) string {
+	if r.URL != "" {
+		return "@ " + r.URL
+	}
+	return r.ConstraintString()
+}
+
+// exceeds reports whether diff is at least as severe as threshold.
+func exceeds(diff, threshold config.DiffType) bool {
+	switch diff {
+	case config.DiffNone:
+		return false
+	case config.DiffUnknown:
+		return true
+	default:
+		return diff >= threshold
+	}
+}
+
+func classOrder(c Class) int {
+	switch c {
+	case ClassMissing:
+		return 0
+	case ClassUndeclared:
+		return 1
+	default:
+		return 2
+	}
+}
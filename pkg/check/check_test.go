@@ -0,0 +1,122 @@
+package check
+
+import (
+	"testing"
+
+	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/parser"
+	"github.com/eslam/depman/pkg/pip"
+	"github.com/eslam/depman/pkg/version"
+)
+
+func TestRun(t *testing.T) {
+	declared := []parser.Requirement{
+		parser.Pinned("requests", "2.31.0"),
+		{Name: "django", Specifiers: version.SpecifierSet{{Op: ">=", Version: "4.2"}}},
+		parser.Pinned("PyYAML", "6.0"),
+		parser.Pinned("missing-pkg", "1.0.0"),
+	}
+	installed := []pip.Package{
+		{Name: "requests", InstalledVersion: "2.31.0"},
+		{Name: "Django", InstalledVersion: "4.2.0"},
+		{Name: "pyyaml", InstalledVersion: "6.0"},
+		{Name: "urllib3", InstalledVersion: "2.0.7"},
+		{Name: "pip", InstalledVersion: "23.0"},
+		{Name: "myproject", InstalledVersion: "0.1.0", Editable: true},
+	}
+	outdated := []pip.Package{
+		{Name: "requests", InstalledVersion: "2.31.0", LatestVersion: "2.32.0", DiffType: config.DiffMinor, IsOutdated: true},
+		{Name: "Django", InstalledVersion: "4.2.0", LatestVersion: "5.0.1", DiffType: config.DiffMajor, IsOutdated: true},
+		{Name: "urllib3", InstalledVersion: "2.0.7", LatestVersion: "3.0.0", DiffType: config.DiffMajor, IsOutdated: true},
+	}
+
+	tests := []struct {
+		name     string
+		rules    Rules
+		expected []Finding
+	}{
+		{
+			name:  "missing only",
+			rules: Rules{Missing: true},
+			expected: []Finding{
+				{Class: ClassMissing, Package: "missing-pkg", Declared: "==1.0.0"},
+			},
+		},
+		{
+			name:  "undeclared skips tooling packages and editable installs",
+			rules: Rules{Undeclared: true},
+			expected: []Finding{
+				{Class: ClassUndeclared, Package: "urllib3", Installed: "2.0.7"},
+			},
+		},
+		{
+			name:  "outdated major threshold ignores undeclared packages",
+			rules: Rules{Outdated: config.DiffMajor},
+			expected: []Finding{
+				{Class: ClassOutdated, Package: "Django", Declared: ">=4.2", Installed: "4.2.0", Latest: "5.0.1", DiffType: config.DiffMajor},
+			},
+		},
+		{
+			name:  "outdated minor threshold includes major",
+			rules: Rules{Outdated: config.DiffMinor},
+			expected: []Finding{
+				{Class: ClassOutdated, Package: "Django", Declared: ">=4.2", Installed: "4.2.0", Latest: "5.0.1", DiffType: config.DiffMajor},
+				{Class: ClassOutdated, Package: "requests", Declared: "==2.31.0", Installed: "2.31.0", Latest: "2.32.0", DiffType: config.DiffMinor},
+			},
+		},
+		{
+			name:  "all rules sorted by class",
+			rules: Rules{Missing: true, Undeclared: true, Outdated: config.DiffMajor},
+			expected: []Finding{
+				{Class: ClassMissing, Package: "missing-pkg", Declared: "==1.0.0"},
+				{Class: ClassUndeclared, Package: "urllib3", Installed: "2.0.7"},
+				{Class: ClassOutdated, Package: "Django", Declared: ">=4.2", Installed: "4.2.0", Latest: "5.0.1", DiffType: config.DiffMajor},
+			},
+		},
+		{
+			name:  "ignore list uses normalized names",
+			rules: Rules{Missing: true, Outdated: config.DiffMajor, Ignore: []string{"Missing_Pkg", "django"}},
+		},
+		{
+			name:  "no rules",
+			rules: Rules{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Run(declared, installed, outdated, tt.rules)
+			if len(report.Findings) != len(tt.expected) {
+				t.Fatalf("Run() returned %d findings, want %d: %+v", len(report.Findings), len(tt.expected), report.Findings)
+			}
+			for i := range tt.expected {
+				if report.Findings[i] != tt.expected[i] {
+					t.Errorf("Findings[%d] = %+v, want %+v", i, report.Findings[i], tt.expected[i])
+				}
+			}
+			if report.Failed() != (len(tt.expected) > 0) {
+				t.Errorf("Failed() = %v, want %v", report.Failed(), len(tt.expected) > 0)
+			}
+		})
+	}
+}
+
+func TestExceeds(t *testing.T) {
+	tests := []struct {
+		diff      config.DiffType
+		threshold config.DiffType
+		expected  bool
+	}{
+		{config.DiffPatch, config.DiffPatch, true},
+		{config.DiffPatch, config.DiffMinor, false},
+		{config.DiffMajor, config.DiffMinor, true},
+		{config.DiffUnknown, config.DiffMajor, true},
+		{config.DiffNone, config.DiffPatch, false},
+	}
+
+	for _, tt := range tests {
+		if got := exceeds(tt.diff, tt.threshold); got != tt.expected {
+			t.Errorf("exceeds(%v, %v) = %v, want %v", tt.diff, tt.threshold, got, tt.expected)
+		}
+	}
+}
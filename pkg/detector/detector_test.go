@@ -0,0 +1,210 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectProject(t *testing.T) {
+	const (
+		projectTable = "[project]\nname = \"app\"\ndependencies = [\"requests\"]\n"
+		toolsOnly    = "[tool.black]\nline-length = 100\n"
+	)
+	tests := []struct {
+		name     string
+		files    map[string]string
+		file     string // dependency file, relative to the project
+		fileType FileType
+		tool     Tool
+	}{
+		{
+			name:     "nothing",
+			files:    map[string]string{"main.py": ""},
+			fileType: FileNone,
+		},
+		{
+			name:     "pyproject",
+			files:    map[string]string{"pyproject.toml": projectTable},
+			file:     "pyproject.toml",
+			fileType: FilePyprojectTOML,
+		},
+		{
+			name:     "tools-only pyproject alone",
+			files:    map[string]string{"pyproject.toml": toolsOnly},
+			file:     "pyproject.toml",
+			fileType: FilePyprojectTOML,
+		},
+		{
+			name:     "pyproject with [project] beats Pipfile",
+			files:    map[string]string{"pyproject.toml": projectTable, "Pipfile": "[packages]\n"},
+			file:     "pyproject.toml",
+			fileType: FilePyprojectTOML,
+		},
+		{
+			name:     "pyproject without [project] loses to Pipfile",
+			files:    map[string]string{"pyproject.toml": toolsOnly, "Pipfile": "[packages]\n"},
+			file:     "Pipfile",
+			fileType: FilePipfile,
+		},
+		{
+			name:     "Pipfile.lock means pipenv",
+			files:    map[string]string{"Pipfile": "[packages]\n", "Pipfile.lock": "{}"},
+			file:     "Pipfile",
+			fileType: FilePipfile,
+			tool:     ToolPipenv,
+		},
+		{
+			name:     "pyproject without [project] loses to environment.yml",
+			files:    map[string]string{"pyproject.toml": toolsOnly, "environment.yml": "dependencies:\n  - numpy\n"},
+			file:     "environment.yml",
+			fileType: FileEnvironmentYML,
+		},
+		{
+			name:     "environment.yaml",
+			files:    map[string]string{"environment.yaml": "dependencies:\n  - numpy\n", "requirements.txt": "requests\n"},
+			file:     "environment.yaml",
+			fileType: FileEnvironmentYML,
+		},
+		{
+			name:     "pyproject without [project] loses to setup.py",
+			files:    map[string]string{"pyproject.toml": "[build-system]\nrequires = [\"setuptools\"]\n", "setup.py": "setup(install_requires=[])\n"},
+			file:     "setup.py",
+			fileType: FileSetupPy,
+		},
+		{
+			name:     "uv.lock",
+			files:    map[string]string{"pyproject.toml": projectTable, "uv.lock": ""},
+			file:     "pyproject.toml",
+			fileType: FilePyprojectTOML,
+			tool:     ToolUV,
+		},
+		{
+			name:     "uv.lock beats [tool.poetry]",
+			files:    map[string]string{"pyproject.toml": "[tool.poetry.dependencies]\npython = \"^3.10\"\n", "uv.lock": ""},
+			file:     "pyproject.toml",
+			fileType: FilePyprojectTOML,
+			tool:     ToolUV,
+		},
+		{
+			name:     "poetry.lock",
+			files:    map[string]string{"pyproject.toml": projectTable, "poetry.lock": ""},
+			file:     "pyproject.toml",
+			fileType: FilePyprojectTOML,
+			tool:     ToolPoetry,
+		},
+		{
+			name:     "poetry group table",
+			files:    map[string]string{"pyproject.toml": "[tool.poetry.group.dev.dependencies]\npytest = \"*\"\n"},
+			file:     "pyproject.toml",
+			fileType: FilePyprojectTOML,
+			tool:     ToolPoetry,
+		},
+		{
+			name:     "managed pyproject without [project] beats Pipfile",
+			files:    map[string]string{"pyproject.toml": "[tool.poetry.dependencies]\n", "Pipfile": "[packages]\n"},
+			file:     "pyproject.toml",
+			fileType: FilePyprojectTOML,
+			tool:     ToolPoetry,
+		},
+		{
+			name:     "pdm table",
+			files:    map[string]string{"pyproject.toml": projectTable + "\n[tool.pdm]\n"},
+			file:     "pyproject.toml",
+			fileType: FilePyprojectTOML,
+			tool:     ToolPDM,
+		},
+		{
+			name:     "hatch environments",
+			files:    map[string]string{"pyproject.toml": projectTable + "\n[tool.hatch.envs.test]\ndependencies = [\"pytest\"]\n"},
+			file:     "pyproject.toml",
+			fileType: FilePyprojectTOML,
+			tool:     ToolHatch,
+		},
+		{
+			name:     "hatch build config only",
+			files:    map[string]string{"pyproject.toml": projectTable + "\n[tool.hatch.build]\n"},
+			file:     "pyproject.toml",
+			fileType: FilePyprojectTOML,
+		},
+		{
+			name:     "requirements.txt beats setup.cfg",
+			files:    map[string]string{"requirements.txt": "requests\n", "setup.cfg": "[options]\ninstall_requires = requests\n"},
+			file:     "requirements.txt",
+			fileType: FileRequirementsTXT,
+		},
+		{
+			name:     "requirements directory",
+			files:    map[string]string{"requirements/base.txt": "requests\n"},
+			file:     "requirements/base.txt",
+			fileType: FileRequirementsTXT,
+		},
+		{
+			name:     "setup.cfg",
+			files:    map[string]string{"setup.cfg": "[metadata]\nname = app\n", "setup.py": "setup()\n"},
+			file:     "setup.cfg",
+			fileType: FileSetupCfg,
+		},
+		{
+			name:     "flake8-only setup.cfg loses to setup.py",
+			files:    map[string]string{"setup.cfg": "[flake8]\nmax-line-length = 100\n", "setup.py": "setup()\n"},
+			file:     "setup.py",
+			fileType: FileSetupPy,
+		},
+		{
+			name:     "setup.py declares install_requires",
+			files:    map[string]string{"setup.cfg": "[metadata]\nname = app\n", "setup.py": "setup(install_requires=[\"requests\"])\n"},
+			file:     "setup.py",
+			fileType: FileSetupPy,
+		},
+		{
+			name:     "both declare install_requires",
+			files:    map[string]string{"setup.cfg": "[options]\ninstall_requires = requests\n", "setup.py": "setup(install_requires=[\"requests\"])\n"},
+			file:     "setup.cfg",
+			fileType: FileSetupCfg,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			p := DetectProject(dir)
+			file := ""
+			if tt.file != "" {
+				file = filepath.Join(dir, filepath.FromSlash(tt.file))
+			}
+			if p.FilePath != file || p.FileType != tt.fileType || p.Tool != tt.tool || p.Dir != dir {
+				t.Errorf("DetectProject() = %s %s %s in %s, want %s %s %s", p.FilePath, p.FileType, p.Tool, p.Dir, file, tt.fileType, tt.tool)
+			}
+		})
+	}
+}
+
+func TestProject(t *testing.T) {
+	tests := []struct {
+		project  Project
+		managed  bool
+		lock     string
+		readOnly bool
+	}{
+		{Project{Dir: "/src/app", FileType: FilePyprojectTOML, Tool: ToolUV}, true, "/src/app/uv.lock", false},
+		{Project{Dir: "/src/app", FileType: FilePipfile, Tool: ToolPipenv}, true, "/src/app/Pipfile.lock", false},
+		{Project{Dir: "/src/app", FileType: FilePyprojectTOML, Tool: ToolHatch}, false, "", false},
+		{Project{Dir: "/src/app", FileType: FileSetupPy}, false, "", true},
+	}
+	for _, tt := range tests {
+		p := tt.project
+		if p.Managed() != tt.managed || p.LockPath() != filepath.FromSlash(tt.lock) || p.ReadOnly() != tt.readOnly {
+			t.Errorf("%s %s: Managed() = %v, LockPath() = %q, ReadOnly() = %v", p.FileType, p.Tool, p.Managed(), p.LockPath(), p.ReadOnly())
+		}
+	}
+}
@@ -3,6 +3,8 @@ package detector
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 )
 
 // FileType represents the type of dependency file found.
@@ -12,6 +14,10 @@ const (
 	FileNone FileType = iota
 	FilePyprojectTOML
 	FileRequirementsTXT
+	FilePipfile
+	FileEnvironmentYML // a conda environment.yml or environment.yaml
+	FileSetupCfg       // a setuptools setup.cfg
+	FileSetupPy        // a setuptools setup.py, which is only read
 )
 
 // String returns the human-readable name of the file type.
@@ -21,16 +27,95 @@ func (f FileType) String() string {
 		return "pyproject.toml"
 	case FileRequirementsTXT:
 		return "requirements.txt"
+	case FilePipfile:
+		return "Pipfile"
+	case FileEnvironmentYML:
+		return "environment.yml"
+	case FileSetupCfg:
+		return "setup.cfg"
+	case FileSetupPy:
+		return "setup.py"
 	default:
 		return "none"
 	}
 }
 
+// Tool is the project manager that owns a project's dependency file and
+// lock file, if any.
+type Tool int
+
+const (
+	ToolNone   Tool = iota
+	ToolUV          // a pyproject.toml next to uv.lock
+	ToolPoetry      // a pyproject.toml with Poetry dependency tables, or next to poetry.lock
+	ToolPDM         // a pyproject.toml with a [tool.pdm] table, or next to pdm.lock
+	ToolHatch       // a pyproject.toml declaring Hatch environments
+	ToolPipenv      // a Pipfile next to Pipfile.lock
+)
+
+// String returns the tool's command name.
+func (t Tool) String() string {
+	switch t {
+	case ToolUV:
+		return "uv"
+	case ToolPoetry:
+		return "poetry"
+	case ToolPDM:
+		return "pdm"
+	case ToolHatch:
+		return "hatch"
+	case ToolPipenv:
+		return "pipenv"
+	default:
+		return "none"
+	}
+}
+
+// LockFile returns the name of the lock file the tool keeps in the project
+// directory, or "" for ToolNone and Hatch, which keeps none.
+func (t Tool) LockFile() string {
+	switch t {
+	case ToolUV:
+		return "uv.lock"
+	case ToolPoetry:
+		return "poetry.lock"
+	case ToolPDM:
+		return "pdm.lock"
+	case ToolPipenv:
+		return "Pipfile.lock"
+	default:
+		return ""
+	}
+}
+
 // Project holds information about a detected Python project.
 type Project struct {
 	FilePath string   // Absolute path to the dependency file
 	FileType FileType // Type of file detected
 	Dir      string   // Project root directory
+	Tool     Tool     // Project manager that owns the files, if any
+}
+
+// Managed returns true if a project manager such as uv owns the dependency
+// file and lock file, so depman must not rewrite them itself. Hatch keeps
+// no lock file, and depman edits its environments' dependencies directly.
+func (p Project) Managed() bool {
+	return p.Tool.LockFile() != ""
+}
+
+// LockPath returns the absolute path to the managing tool's lock file, or
+// "" if the project is not managed.
+func (p Project) LockPath() string {
+	if name := p.Tool.LockFile(); name != "" {
+		return filepath.Join(p.Dir, name)
+	}
+	return ""
+}
+
+// ReadOnly returns true if depman can read the project's dependency file
+// but not rewrite it: a setup.py is a program, not a list.
+func (p Project) ReadOnly() bool {
+	return p.FileType == FileSetupPy
 }
 
 // Detected returns true if a project file was found.
@@ -39,7 +124,15 @@ func (p Project) Detected() bool {
 }
 
 // DetectProject scans the given directory for Python dependency files.
-// Detection priority: pyproject.toml → requirements.txt → requirements/*.txt
+// Detection priority: pyproject.toml → Pipfile → environment.yml →
+// requirements.txt → requirements/*.txt → setup.cfg → setup.py, except
+// that the other files win over a pyproject.toml without a [project]
+// table, which in a pipenv, conda or setuptools project usually only
+// configures tools and the build. A setup.cfg is skipped when it declares
+// no install_requires and a setup.py does. The project manager is
+// recognized by its lock file next to pyproject.toml (uv.lock, poetry.lock,
+// pdm.lock), else by the tables it declares in pyproject.toml (see
+// toolTables). A Pipfile next to Pipfile.lock is managed by pipenv.
 func DetectProject(dir string) Project {
 	absDir, err := filepath.Abs(dir)
 	if err != nil {
@@ -48,15 +141,44 @@ func DetectProject(dir string) Project {
 
 	// 1. Check pyproject.toml
 	pyproject := filepath.Join(absDir, "pyproject.toml")
+	pipfile := filepath.Join(absDir, "Pipfile")
+	envFile := environmentFile(absDir)
+	setupFile, setupType := setuptoolsFile(absDir)
 	if fileExists(pyproject) {
-		return Project{
+		project := Project{
 			FilePath: pyproject,
 			FileType: FilePyprojectTOML,
 			Dir:      absDir,
 		}
+		project.Tool = detectTool(absDir, pyproject)
+		if project.Tool != ToolNone || !fileExists(pipfile) && envFile == "" && setupFile == "" || slices.Contains(tableHeaders(pyproject), "project") {
+			return project
+		}
+	}
+
+	// 2. Check Pipfile
+	if fileExists(pipfile) {
+		project := Project{
+			FilePath: pipfile,
+			FileType: FilePipfile,
+			Dir:      absDir,
+		}
+		if fileExists(filepath.Join(absDir, ToolPipenv.LockFile())) {
+			project.Tool = ToolPipenv
+		}
+		return project
+	}
+
+	// 3. Check environment.yml
+	if envFile != "" {
+		return Project{
+			FilePath: envFile,
+			FileType: FileEnvironmentYML,
+			Dir:      absDir,
+		}
 	}
 
-	// 2. Check requirements.txt
+	// 4. Check requirements.txt
 	reqtxt := filepath.Join(absDir, "requirements.txt")
 	if fileExists(reqtxt) {
 		return Project{
@@ -66,7 +188,7 @@ func DetectProject(dir string) Project {
 		}
 	}
 
-	// 3. Check requirements/*.txt (use first found)
+	// 5. Check requirements/*.txt (use first found)
 	reqDir := filepath.Join(absDir, "requirements")
 	if dirExists(reqDir) {
 		entries, err := os.ReadDir(reqDir)
@@ -83,9 +205,108 @@ func DetectProject(dir string) Project {
 		}
 	}
 
+	// 6. Check setup.cfg and setup.py
+	if setupFile != "" {
+		return Project{
+			FilePath: setupFile,
+			FileType: setupType,
+			Dir:      absDir,
+		}
+	}
+
 	return Project{Dir: absDir}
 }
 
+// setuptoolsFile returns the path and type of the file in dir that
+// declares a setuptools project's dependencies: setup.cfg, unless only
+// setup.py declares install_requires, then setup.py. A setup.cfg without
+// [metadata] or [options], which only configures tools such as flake8,
+// does not count. path is "" if there is neither.
+func setuptoolsFile(dir string) (path string, fileType FileType) {
+	cfg, py := filepath.Join(dir, "setup.cfg"), filepath.Join(dir, "setup.py")
+	headers := tableHeaders(cfg)
+	isProject := slices.Contains(headers, "metadata") || slices.Contains(headers, "options")
+	switch {
+	case isProject && (!fileExists(py) || fileContains(cfg, "install_requires") || !fileContains(py, "install_requires")):
+		return cfg, FileSetupCfg
+	case fileExists(py):
+		return py, FileSetupPy
+	}
+	return "", FileNone
+}
+
+// fileContains reports whether the file at path contains s.
+func fileContains(path, s string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.Contains(string(data), s)
+}
+
+// environmentFile returns the path of the conda environment file in dir,
+// environment.yml or environment.yaml, or "" if there is none.
+func environmentFile(dir string) string {
+	for _, name := range []string{"environment.yml", "environment.yaml"} {
+		if path := filepath.Join(dir, name); fileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// toolTables lists, in order of precedence, the pyproject.toml tables that
+// identify a project manager when there is no lock file. A name ending in
+// "." matches every table below it.
+var toolTables = []struct {
+	tool   Tool
+	tables []string
+}{
+	{ToolPoetry, []string{"tool.poetry.dependencies", "tool.poetry.dev-dependencies", "tool.poetry.group."}},
+	{ToolPDM, []string{"tool.pdm", "tool.pdm.dev-dependencies"}},
+	{ToolHatch, []string{"tool.hatch.envs."}},
+}
+
+// detectTool returns the project manager of the project in dir, whose
+// pyproject.toml is at pyproject.
+func detectTool(dir, pyproject string) Tool {
+	for _, tool := range []Tool{ToolUV, ToolPoetry, ToolPDM} {
+		if fileExists(filepath.Join(dir, tool.LockFile())) {
+			return tool
+		}
+	}
+	headers := tableHeaders(pyproject)
+	for _, t := range toolTables {
+		for _, table := range t.tables {
+			for _, h := range headers {
+				if h == table || strings.HasSuffix(table, ".") && strings.HasPrefix(h, table) {
+					return t.tool
+				}
+			}
+		}
+	}
+	return ToolNone
+}
+
+// tableHeaders returns the names of the tables declared in the TOML file
+// at path, such as "tool.poetry.dependencies" for
+// [tool.poetry.dependencies], or of the sections of an INI file.
+func tableHeaders(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var headers []string
+	for _, line := range strings.Split(string(data), "\n") {
+		table := strings.TrimSpace(line)
+		if !strings.HasPrefix(table, "[") {
+			continue
+		}
+		if i := strings.IndexByte(table, '#'); i >= 0 {
+			table = table[:i]
+		}
+		headers = append(headers, strings.Trim(table, "[] \t"))
+	}
+	return headers
+}
+
 func fileExists(path string) bool {
 	info, err := os.Stat(path)
 	return err == nil && !info.IsDir()
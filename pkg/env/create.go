@@ -5,36 +5,79 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"time"
+
+	"github.com/eslam/depman/pkg/audit"
+	"github.com/eslam/depman/pkg/log"
 )
 
 // CreateVirtualenv creates a new .venv in the given project directory.
 // It prefers `uv venv` if available, falling back to `python -m venv`.
-func CreateVirtualenv(dir string) (Virtualenv, error) {
+// The creation is recorded in journal, which may be nil.
+func CreateVirtualenv(dir string, journal *audit.Journal) (Virtualenv, error) {
+	return createVirtualenv(dir, audit.ActionVenvCreate, journal)
+}
+
+// RecreateVirtualenv removes and recreates the virtualenv.
+func RecreateVirtualenv(dir string, venvPath string, journal *audit.Journal) (Virtualenv, error) {
+	if err := os.RemoveAll(venvPath); err != nil {
+		err = fmt.Errorf("env: remove venv: %w", err)
+		record(journal, audit.Record{Action: audit.ActionVenvRecreate, Target: venvPath, Venv: venvPath, ExitCode: -1, Error: err.Error()})
+		return Virtualenv{}, err
+	}
+	return createVirtualenv(dir, audit.ActionVenvRecreate, journal)
+}
+
+func createVirtualenv(dir string, action audit.Action, journal *audit.Journal) (Virtualenv, error) {
 	venvPath := filepath.Join(dir, ".venv")
 
+	var argv []string
+	var tool string
 	// Try uv first
 	if uvPath, err := exec.LookPath("uv"); err == nil {
-		cmd := exec.Command(uvPath, "venv", venvPath)
-		cmd.Dir = dir
-		if _, err := cmd.CombinedOutput(); err != nil {
-			return Virtualenv{}, fmt.Errorf("env: create venv with uv: %w", err)
-		}
+		argv, tool = []string{uvPath, "venv", venvPath}, "uv"
 	} else {
 		// Fall back to python -m venv
 		pythonBin := findPython()
 		if pythonBin == "" {
 			return Virtualenv{}, fmt.Errorf("env: no python interpreter found")
 		}
-		cmd := exec.Command(pythonBin, "-m", "venv", venvPath)
-		cmd.Dir = dir
-		if _, err := cmd.CombinedOutput(); err != nil {
-			return Virtualenv{}, fmt.Errorf("env: create venv with python: %w", err)
-		}
+		argv, tool = []string{pythonBin, "-m", "venv", venvPath}, "python"
+	}
+
+	start := time.Now()
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = dir
+	_, err := cmd.CombinedOutput()
+	if err != nil {
+		err = fmt.Errorf("env: create venv with %s: %w", tool, err)
 	}
 
 	pythonBin := filepath.Join(venvPath, "bin", "python")
-	if !fileExecutable(pythonBin) {
-		return Virtualenv{}, fmt.Errorf("env: python binary not found: %s", pythonBin)
+	if err == nil && !fileExecutable(pythonBin) {
+		err = fmt.Errorf("env: python binary not found: %s", pythonBin)
+	}
+
+	rec := audit.Record{
+		Time:       start,
+		Action:     action,
+		Target:     venvPath,
+		Venv:       venvPath,
+		Manager:    filepath.Base(argv[0]),
+		Argv:       argv,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		// ExitCode is -1 when the process did not start; a zero status
+		// means it ran but left no interpreter behind
+		if rec.ExitCode = cmd.ProcessState.ExitCode(); rec.ExitCode == 0 {
+			rec.ExitCode = -1
+		}
+		rec.Error = err.Error()
+	}
+	record(journal, rec)
+	if err != nil {
+		return Virtualenv{}, err
 	}
 
 	return Virtualenv{
@@ -44,12 +87,12 @@ func CreateVirtualenv(dir string) (Virtualenv, error) {
 	}, nil
 }
 
-// RecreateVirtualenv removes and recreates the virtualenv.
-func RecreateVirtualenv(dir string, venvPath string) (Virtualenv, error) {
-	if err := os.RemoveAll(venvPath); err != nil {
-		return Virtualenv{}, fmt.Errorf("env: remove venv: %w", err)
+// record appends rec to journal, logging rather than failing on error: the
+// environment has already changed.
+func record(journal *audit.Journal, rec audit.Record) {
+	if err := journal.Append(rec); err != nil {
+		log.Warn("audit journal append failed", "action", rec.Action, "target", rec.Target, "error", err)
 	}
-	return CreateVirtualenv(dir)
 }
 
 func findPython() string {
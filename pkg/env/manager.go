@@ -1,7 +1,9 @@
 package env
 
 import (
+	"os"
 	"os/exec"
+	"slices"
 
 	"github.com/eslam/depman/pkg/log"
 )
@@ -15,7 +17,8 @@ const (
 	ManagerPip
 )
 
-// PackageManager holds info about the detected package manager.
+// PackageManager holds info about the detected package manager. The
+// commands it runs are built by the matching pip.Backend.
 type PackageManager struct {
 	Type    ManagerType
 	BinPath string
@@ -33,60 +36,48 @@ func (m PackageManager) String() string {
 	}
 }
 
-// InstallCmd returns the install command for a package.
-func (m PackageManager) InstallCmd(pkg string) (string, []string) {
-	switch m.Type {
-	case ManagerUV:
-		return m.BinPath, []string{"pip", "install", pkg}
-	default: // pip
-		return m.BinPath, []string{"install", pkg}
-	}
-}
-
-// UninstallCmd returns the uninstall command for a package.
-func (m PackageManager) UninstallCmd(pkg string) (string, []string) {
-	switch m.Type {
-	case ManagerUV:
-		return m.BinPath, []string{"pip", "uninstall", pkg}
-	default:
-		return m.BinPath, []string{"uninstall", pkg, "-y"}
-	}
-}
-
-// UpgradeCmd returns the upgrade command for a package.
-func (m PackageManager) UpgradeCmd(pkg string) (string, []string) {
-	switch m.Type {
-	case ManagerUV:
-		return m.BinPath, []string{"pip", "install", "--upgrade", pkg}
-	default:
-		return m.BinPath, []string{"install", "--upgrade", pkg}
-	}
-}
-
-// ListCmd returns the command to list installed packages.
-func (m PackageManager) ListCmd() (string, []string) {
-	switch m.Type {
-	case ManagerUV:
-		return m.BinPath, []string{"pip", "list", "--format", "json"}
-	default:
-		return m.BinPath, []string{"list", "--format", "json"}
+// projectManagers are tools that manage a project rather than install
+// packages into an environment. They are recognized from the project's
+// files, and drive the package manager's environment themselves.
+var projectManagers = map[string]bool{"poetry": true, "pdm": true, "hatch": true, "pipenv": true}
+
+// condaTools are the installers of conda packages, in order of preference.
+// mamba and micromamba are faster reimplementations of conda.
+var condaTools = []string{"mamba", "micromamba", "conda"}
+
+// FindCondaTool returns the path of the installer for conda environments:
+// mamba, micromamba or conda on PATH, else the one that conda's or
+// micromamba's shell activation recorded in $CONDA_EXE or $MAMBA_EXE.
+func FindCondaTool() (string, bool) {
+	for _, name := range condaTools {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, true
+		}
 	}
-}
-
-// OutdatedCmd returns the command to list outdated packages.
-func (m PackageManager) OutdatedCmd() (string, []string) {
-	switch m.Type {
-	case ManagerUV:
-		return m.BinPath, []string{"pip", "list", "--outdated", "--format", "json"}
-	default:
-		return m.BinPath, []string{"list", "--outdated", "--format", "json"}
+	for _, key := range []string{"MAMBA_EXE", "CONDA_EXE"} {
+		if path := os.Getenv(key); path != "" && fileExecutable(path) {
+			return path, true
+		}
 	}
+	return "", false
 }
 
 // DetectPackageManager finds the available package manager.
 // Priority: uv (preferred) → pip → pip3.
-// If preferred is set and available, use it regardless.
+// If preferred is set and available, use it regardless. A project manager
+// such as pdm is not a package manager, so naming one auto-detects, as
+// does naming a conda installer, which conda environments use alongside
+// the package manager (see FindCondaTool).
 func DetectPackageManager(preferred string) PackageManager {
+	if projectManagers[preferred] {
+		log.Warn("preferred names a project manager, which is detected from the project; auto-detecting the package manager", "preferred", preferred)
+		preferred = ""
+	}
+	if slices.Contains(condaTools, preferred) {
+		log.Warn("preferred names a conda installer, which is used in conda environments; auto-detecting the package manager", "preferred", preferred)
+		preferred = ""
+	}
+
 	// If user has a preference, try it first
 	if preferred != "" {
 		if path, err := exec.LookPath(preferred); err == nil {
@@ -1,9 +1,15 @@
 package env
 
 import (
+	"context"
+	"encoding/json"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eslam/depman/pkg/log"
 )
 
 // EnvType represents the type of Python environment.
@@ -13,6 +19,7 @@ const (
 	EnvNotFound EnvType = iota
 	EnvVirtualenv
 	EnvSystem
+	EnvConda // a conda environment, e.g. from $CONDA_PREFIX
 )
 const (
 	FilePermExecutable = 0111
@@ -23,14 +30,14 @@ type Virtualenv struct {
 	Type      EnvType
 	Path      string // Path to the virtualenv or system Python dir
 	PythonBin string // Path to the python binary
-	IsActive  bool   // True if from $VIRTUAL_ENV
+	IsActive  bool   // True if from $VIRTUAL_ENV or $CONDA_PREFIX
 	IsBroken  bool   // True if venv exists but interpreter is missing
 }
 
 // Name returns a short display name for the environment.
 func (v Virtualenv) Name() string {
 	switch v.Type {
-	case EnvVirtualenv:
+	case EnvVirtualenv, EnvConda:
 		return filepath.Base(v.Path)
 	case EnvSystem:
 		return "system"
@@ -40,7 +47,9 @@ func (v Virtualenv) Name() string {
 }
 
 // DetectVirtualenv resolves the active Python environment.
-// Priority: $VIRTUAL_ENV → .venv/ → venv/ → system Python.
+// Priority: $VIRTUAL_ENV → active conda environment → .venv/ → venv/ →
+// conda base environment → system Python. Shells often activate conda's
+// base environment on startup, so a project's own virtualenv wins over it.
 func DetectVirtualenv(dir string) Virtualenv {
 	absDir, _ := filepath.Abs(dir)
 
@@ -64,22 +73,146 @@ func DetectVirtualenv(dir string) Virtualenv {
 		}
 	}
 
-	// 2. Check .venv/
+	// 2. Check an active conda environment other than base
+	conda, base := activeConda()
+	if conda.Type != EnvNotFound && !base {
+		return conda
+	}
+
+	// 3. Check .venv/
 	dotVenv := filepath.Join(absDir, ".venv")
 	if v := checkLocalVenv(dotVenv); v.Type != EnvNotFound {
 		return v
 	}
 
-	// 3. Check venv/
+	// 4. Check venv/
 	venv := filepath.Join(absDir, "venv")
 	if v := checkLocalVenv(venv); v.Type != EnvNotFound {
 		return v
 	}
 
-	// 4. Fall back to system Python
+	// 5. Check conda's base environment
+	if conda.Type != EnvNotFound {
+		return conda
+	}
+
+	// 6. Fall back to system Python
 	return detectSystemPython()
 }
 
+// activeConda returns the conda environment activated in $CONDA_PREFIX, if
+// any, and whether it is the base environment, per $CONDA_DEFAULT_ENV.
+func activeConda() (v Virtualenv, base bool) {
+	prefix := os.Getenv("CONDA_PREFIX")
+	if prefix == "" {
+		return Virtualenv{}, false
+	}
+	v = condaEnv(prefix)
+	v.IsActive = true
+	return v, os.Getenv("CONDA_DEFAULT_ENV") == "base"
+}
+
+// condaEnv returns the conda environment at prefix. An environment without
+// a Python interpreter, which conda allows, is broken for depman.
+func condaEnv(prefix string) Virtualenv {
+	pythonBin := filepath.Join(prefix, "bin", "python")
+	if fileExecutable(pythonBin) {
+		return Virtualenv{Type: EnvConda, Path: prefix, PythonBin: pythonBin}
+	}
+	return Virtualenv{Type: EnvConda, Path: prefix, IsBroken: true}
+}
+
+// DetectCondaEnvironment finds the conda environment called name, such as
+// the one an environment.yml file declares, with `conda env list`. ok is
+// false if no conda installer is found or no environment has that name.
+// An active $VIRTUAL_ENV or conda environment other than base still takes
+// precedence.
+func DetectCondaEnvironment(name string) (v Virtualenv, ok bool) {
+	if name == "" || os.Getenv("VIRTUAL_ENV") != "" {
+		return Virtualenv{}, false
+	}
+	if active, base := activeConda(); active.Type != EnvNotFound && !base {
+		return Virtualenv{}, false
+	}
+	bin, found := FindCondaTool()
+	if !found {
+		return Virtualenv{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), toolEnvTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, bin, "env", "list", "--json").Output()
+	if err != nil {
+		log.Debug("no conda environments", "tool", bin, "error", err)
+		return Virtualenv{}, false
+	}
+	var list struct {
+		Envs []string `json:"envs"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		log.Debug("unreadable conda environment list", "tool", bin, "error", err)
+		return Virtualenv{}, false
+	}
+	for _, prefix := range list.Envs {
+		// Named environments live in <root>/envs/<name>
+		if filepath.Base(prefix) == name && filepath.Base(filepath.Dir(prefix)) == "envs" {
+			return condaEnv(prefix), true
+		}
+	}
+	return Virtualenv{}, false
+}
+
+// toolEnvTimeout bounds the command that asks a project manager for its
+// virtualenv, which may have to start a Python interpreter.
+const toolEnvTimeout = 10 * time.Second
+
+// toolEnvCommands lists, by project manager, the arguments of the command
+// that prints the project's virtualenv. PDM prints the interpreter instead.
+var toolEnvCommands = map[string][]string{
+	"poetry": {"env", "info", "--path"},
+	"pdm":    {"info", "--python"},
+	"hatch":  {"env", "find"},
+	"pipenv": {"--venv"},
+}
+
+// DetectToolVirtualenv asks a project manager (poetry, pdm, hatch or
+// pipenv) for the virtualenv of the project in dir, since these tools may
+// keep it outside the project. ok is false for other tools, if the tool is
+// not on PATH, or if the project has no virtualenv yet. An active
+// $VIRTUAL_ENV still takes precedence.
+func DetectToolVirtualenv(tool, dir string) (v Virtualenv, ok bool) {
+	args, known := toolEnvCommands[tool]
+	if !known || os.Getenv("VIRTUAL_ENV") != "" {
+		return Virtualenv{}, false
+	}
+	bin, err := exec.LookPath(tool)
+	if err != nil {
+		return Virtualenv{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), toolEnvTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		log.Debug("no project virtualenv", "tool", tool, "dir", dir, "error", err)
+		return Virtualenv{}, false
+	}
+	path := strings.TrimSpace(string(out))
+	if path == "" {
+		return Virtualenv{}, false
+	}
+	if tool == "pdm" {
+		// <venv>/bin/python; an interpreter outside a virtualenv, such as
+		// a PEP 582 project's, has no pyvenv.cfg above it
+		path = filepath.Dir(filepath.Dir(path))
+		if _, err := os.Stat(filepath.Join(path, "pyvenv.cfg")); err != nil {
+			return Virtualenv{}, false
+		}
+	}
+	v = checkLocalVenv(path)
+	return v, v.Type != EnvNotFound
+}
+
 func checkLocalVenv(path string) Virtualenv {
 	pythonBin := filepath.Join(path, "bin", "python")
 	info, err := os.Stat(path)
@@ -1,38 +1,163 @@
 package log
 
 import (
+	"context"
+	"errors"
+	"io"
 	"log/slog"
-	"os"
+	"path/filepath"
 	"strings"
+
+	"github.com/eslam/depman/pkg/store"
 )
 
 // Logger is the package-level logger instance
 var Logger *slog.Logger
 
-// Init initializes the global logger with the specified log level
-func Init(level string) {
-	var slogLevel slog.Level
+// Default rotation settings used when Options leaves them unset.
+const (
+	DefaultMaxSize  = 5 << 20 // bytes
+	DefaultMaxFiles = 3
+)
+
+// Options configures Setup.
+type Options struct {
+	Level    string    // "debug" | "info" | "warn" | "error"
+	Path     string    // log file; empty means DefaultPath()
+	Format   string    // "json", or anything else for text
+	MaxSize  int64     // rotate the file past this many bytes; 0 means DefaultMaxSize
+	MaxFiles int       // rotated files to keep; 0 means DefaultMaxFiles
+	Console  io.Writer // if set, also receives warnings and errors
+}
+
+// DefaultPath returns the default log file, depman.log in the state
+// directory.
+func DefaultPath() (string, error) {
+	dir, err := store.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "depman.log"), nil
+}
+
+// file is the log file opened by Setup, if any.
+var file *RotatingFile
+
+// Setup installs the global logger. Records at or above the configured
+// level go to the rotating log file and to the in-memory buffer read by
+// Recent; warnings and errors are also written to opts.Console. If the log
+// file cannot be opened the logger still runs without it and the error is
+// returned so the caller can report it.
+func Setup(opts Options) error {
+	level := ParseLevel(opts.Level)
+	handlers := []slog.Handler{&recentHandler{level: level}}
+
+	if opts.Console != nil {
+		handlers = append(handlers, slog.NewTextHandler(opts.Console, &slog.HandlerOptions{Level: max(level, slog.LevelWarn)}))
+	}
+
+	Close()
+	path := opts.Path
+	var err error
+	if path == "" {
+		path, err = DefaultPath()
+	}
+	if err == nil {
+		maxSize, maxFiles := opts.MaxSize, opts.MaxFiles
+		if maxSize == 0 {
+			maxSize = DefaultMaxSize
+		}
+		if maxFiles == 0 {
+			maxFiles = DefaultMaxFiles
+		}
+		file, err = OpenRotatingFile(path, maxSize, maxFiles)
+	}
+	if err == nil {
+		handlers = append(handlers, newHandler(opts.Format, file, level))
+	}
+
+	Logger = slog.New(fanout(handlers))
+	slog.SetDefault(Logger)
+	return err
+}
+
+// Close closes the log file opened by Setup.
+func Close() error {
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file = nil
+	return err
+}
+
+// Path returns the log file opened by Setup, or "" if there is none.
+func Path() string {
+	if file == nil {
+		return ""
+	}
+	return file.Path()
+}
 
+// ParseLevel converts a configured level name to a slog level. Unknown
+// names mean info.
+func ParseLevel(level string) slog.Level {
 	switch strings.ToLower(level) {
 	case "debug":
-		slogLevel = slog.LevelDebug
-	case "info":
-		slogLevel = slog.LevelInfo
+		return slog.LevelDebug
 	case "warn", "warning":
-		slogLevel = slog.LevelWarn
+		return slog.LevelWarn
 	case "error":
-		slogLevel = slog.LevelError
+		return slog.LevelError
 	default:
-		slogLevel = slog.LevelInfo
+		return slog.LevelInfo
 	}
+}
 
-	opts := &slog.HandlerOptions{
-		Level: slogLevel,
+func newHandler(format string, w io.Writer, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.NewJSONHandler(w, opts)
 	}
+	return slog.NewTextHandler(w, opts)
+}
 
-	handler := slog.NewTextHandler(os.Stdout, opts)
-	Logger = slog.New(handler)
-	slog.SetDefault(Logger)
+// fanout sends each record to every handler that accepts its level.
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
 }
 
 // Debug logs a debug message with structured attributes
@@ -0,0 +1,162 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "depman.log")
+
+	r, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write(%q) error = %v", line, err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// A write that would take the file past 10 bytes starts a new file; only
+	// two old files are kept
+	expected := map[string]string{
+		"depman.log":   "four\nfive\n",
+		"depman.log.1": "three\n",
+		"depman.log.2": "one\ntwo\n",
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != len(expected) {
+		t.Errorf("log directory has %d files, want %d", len(entries), len(expected))
+	}
+	for name, want := range expected {
+		got, err := os.ReadFile(filepath.Join(dir, "logs", name))
+		if err != nil {
+			t.Errorf("read %s: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// Reopening counts the existing size, and the oldest file is dropped
+	r, err = OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	r.Write([]byte("six\n"))
+	r.Close()
+	if got, _ := os.ReadFile(path); string(got) != "six\n" {
+		t.Errorf("after reopen = %q, want %q", got, "six\n")
+	}
+	if got, _ := os.ReadFile(path + ".2"); string(got) != "three\n" {
+		t.Errorf("after reopen depman.log.2 = %q, want %q", got, "three\n")
+	}
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		check  func(t *testing.T, content string)
+	}{
+		{
+			name:   "text",
+			format: "text",
+			check: func(t *testing.T, content string) {
+				if !strings.Contains(content, `msg="package installed" name=requests`) {
+					t.Errorf("text log = %q", content)
+				}
+			},
+		},
+		{
+			name:   "json",
+			format: "json",
+			check: func(t *testing.T, content string) {
+				lines := strings.Split(strings.TrimSpace(content), "\n")
+				var rec map[string]any
+				if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+					t.Fatalf("json log line %q: %v", lines[0], err)
+				}
+				if rec["msg"] != "package installed" || rec["name"] != "requests" {
+					t.Errorf("json record = %v", rec)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recent.reset()
+			path := filepath.Join(t.TempDir(), "depman.log")
+			var console bytes.Buffer
+			if err := Setup(Options{Level: "info", Path: path, Format: tt.format, Console: &console}); err != nil {
+				t.Fatalf("Setup() error = %v", err)
+			}
+			defer Close()
+
+			Debug("hidden")
+			Info("package installed", "name", "requests")
+			Logger.With("project", "demo").Warn("sync failed", "error", "boom")
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(content), "hidden") {
+				t.Errorf("debug record written at info level: %q", content)
+			}
+			tt.check(t, string(content))
+
+			// Only warnings reach the console
+			if c := console.String(); strings.Contains(c, "package installed") || !strings.Contains(c, "sync failed") {
+				t.Errorf("console = %q", c)
+			}
+
+			entries := Recent(0)
+			if len(entries) != 2 {
+				t.Fatalf("Recent() returned %d entries, want 2: %v", len(entries), entries)
+			}
+			if e := entries[1]; e.Message != "sync failed" || e.Attrs != "project=demo error=boom" {
+				t.Errorf("Recent()[1] = %+v", e)
+			}
+			if Path() != path {
+				t.Errorf("Path() = %q, want %q", Path(), path)
+			}
+		})
+	}
+}
+
+func TestRecent(t *testing.T) {
+	r := &ring{entries: make([]Entry, 3)}
+	for _, msg := range []string{"a", "b", "c", "d"} {
+		r.add(Entry{Message: msg})
+	}
+
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "b c d"},
+		{2, "c d"},
+		{10, "b c d"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, e := range r.last(tt.n) {
+			got = append(got, e.Message)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("last(%d) = %v, want %s", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecentCapacity is the number of entries kept in memory for Recent.
+const RecentCapacity = 500
+
+// Entry is one log record kept in memory for the TUI's log viewer.
+type Entry struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   string // attributes formatted as key=value pairs
+}
+
+// ring is a fixed-size buffer of the most recent entries.
+type ring struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+var recent = &ring{entries: make([]Entry, RecentCapacity)}
+
+func (r *ring) add(e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *ring) last(n int) []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := r.next
+	if r.full {
+		count = len(r.entries)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+	out := make([]Entry, n)
+	start := r.next - n
+	for i := range out {
+		out[i] = r.entries[(start+i+len(r.entries))%len(r.entries)]
+	}
+	return out
+}
+
+func (r *ring) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next = 0
+	r.full = false
+}
+
+// Recent returns up to n of the most recent log entries of this process,
+// oldest first. n <= 0 returns every entry kept.
+func Recent(n int) []Entry {
+	return recent.last(n)
+}
+
+// recentHandler records entries into the recent buffer. Attributes added
+// with WithAttrs are formatted once and prefixed to each record's own.
+type recentHandler struct {
+	level  slog.Leveler
+	prefix string // attributes from WithAttrs, already formatted
+	group  string // dotted group prefix from WithGroup
+}
+
+func (h *recentHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *recentHandler) Handle(_ context.Context, rec slog.Record) error {
+	var b strings.Builder
+	b.WriteString(h.prefix)
+	rec.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.group, a)
+		return true
+	})
+	recent.add(Entry{Time: rec.Time, Level: rec.Level, Message: rec.Message, Attrs: b.String()})
+	return nil
+}
+
+func (h *recentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.prefix)
+	for _, a := range attrs {
+		appendAttr(&b, h.group, a)
+	}
+	return &recentHandler{level: h.level, prefix: b.String(), group: h.group}
+}
+
+func (h *recentHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &recentHandler{level: h.level, prefix: h.prefix, group: h.group + name + "."}
+}
+
+func appendAttr(b *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		prefix := group
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(b, prefix, ga)
+		}
+		return
+	}
+	if b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	b.WriteString(group + a.Key + "=")
+	v := a.Value.String()
+	if v == "" || strings.ContainsAny(v, " \t\"=") {
+		v = strconv.Quote(v)
+	}
+	b.WriteString(v)
+}
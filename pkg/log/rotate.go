@@ -0,0 +1,116 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile is an io.Writer that appends to a log file and rotates it
+// once it grows past a size limit: path becomes path.1, path.1 becomes
+// path.2, and so on, keeping at most MaxFiles old files.
+type RotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenRotatingFile opens path for appending, creating it and its parent
+// directory if needed. A maxSize of 0 disables rotation.
+func OpenRotatingFile(path string, maxSize int64, maxFiles int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("log: create log directory: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("log: open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("log: stat log file: %w", err)
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating first if p would push the file past
+// its size limit. Each slog record is written with a single call, so
+// records are never split across files.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return 0, fmt.Errorf("log: write to closed log file")
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the old files up by one and starts a new file.
+func (r *RotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return fmt.Errorf("log: close log file: %w", err)
+	}
+	r.f = nil
+
+	if r.maxFiles <= 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("log: rotate: %w", err)
+		}
+		return r.open()
+	}
+
+	os.Remove(r.backup(r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(r.backup(i), r.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("log: rotate: %w", err)
+		}
+	}
+	if err := os.Rename(r.path, r.backup(1)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("log: rotate: %w", err)
+	}
+	return r.open()
+}
+
+func (r *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
+
+// Path returns the path of the current log file.
+func (r *RotatingFile) Path() string {
+	return r.path
+}
+
+// Close closes the current log file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
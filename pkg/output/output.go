@@ -0,0 +1,82 @@
+// Package output renders command results in machine-readable formats.
+//
+// JSON output is a single document:
+//
+//	{"schema_version": 1, "kind": "outdated", "items": [...]}
+//
+// NDJSON output writes one item per line with no envelope, so streams can be
+// processed with line-oriented tools. The item shapes are documented in the
+// README and only change in a backwards-compatible way within a schema version.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SchemaVersion is bumped whenever a field is removed or changes meaning.
+const SchemaVersion = 1
+
+// Format selects how command results are written.
+type Format string
+
+const (
+	FormatTable  Format = "table"
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+)
+
+// Kind identifies the type of items in a document.
+type Kind string
+
+const (
+	KindInstalled Kind = "installed"
+	KindOutdated  Kind = "outdated"
+	KindSearch    Kind = "search"
+	KindCheck     Kind = "check"
+	KindHistory   Kind = "history"
+)
+
+// Document is the top-level JSON object written in FormatJSON.
+type Document[T any] struct {
+	SchemaVersion int  `json:"schema_version"`
+	Kind          Kind `json:"kind"`
+	Items         []T  `json:"items"`
+}
+
+// ParseFormat validates a --format flag value.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatTable, FormatJSON, FormatNDJSON:
+		return f, nil
+	case "":
+		return FormatTable, nil
+	default:
+		return "", fmt.Errorf("output: unknown format %q (want table, json or ndjson)", s)
+	}
+}
+
+// Write encodes items as a JSON document or NDJSON stream.
+// FormatTable is not handled here; callers render tables themselves.
+func Write[T any](w io.Writer, format Format, kind Kind, items []T) error {
+	if items == nil {
+		items = []T{}
+	}
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(Document[T]{SchemaVersion: SchemaVersion, Kind: kind, Items: items})
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, item := range items {
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("output: format %q is not machine-readable", format)
+	}
+}
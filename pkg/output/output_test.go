@@ -0,0 +1,85 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+type item struct {
+	Name string `json:"name"`
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Format
+		wantErr  bool
+	}{
+		{"", FormatTable, false},
+		{"table", FormatTable, false},
+		{"json", FormatJSON, false},
+		{"ndjson", FormatNDJSON, false},
+		{"yaml", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			f, err := ParseFormat(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFormat(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if f != tt.expected {
+				t.Errorf("ParseFormat(%q) = %q, want %q", tt.input, f, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	items := []item{{Name: "requests"}, {Name: "httpx"}}
+
+	tests := []struct {
+		name     string
+		format   Format
+		items    []item
+		expected string
+	}{
+		{
+			name:     "json document",
+			format:   FormatJSON,
+			items:    items,
+			expected: "{\n  \"schema_version\": 1,\n  \"kind\": \"installed\",\n  \"items\": [\n    {\n      \"name\": \"requests\"\n    },\n    {\n      \"name\": \"httpx\"\n    }\n  ]\n}\n",
+		},
+		{
+			name:     "json document with no items",
+			format:   FormatJSON,
+			items:    nil,
+			expected: "{\n  \"schema_version\": 1,\n  \"kind\": \"installed\",\n  \"items\": []\n}\n",
+		},
+		{
+			name:     "ndjson stream",
+			format:   FormatNDJSON,
+			items:    items,
+			expected: "{\"name\":\"requests\"}\n{\"name\":\"httpx\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.format, KindInstalled, tt.items); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Write() = %q, want %q", buf.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestWrite_TableFormatRejected(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatTable, KindInstalled, []item{}); err == nil {
+		t.Error("Write() with FormatTable: want error, got nil")
+	}
+}
@@ -0,0 +1,447 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/eslam/depman/pkg/version"
+)
+
+// CondaPipGroup is the group of the "pip:" subsection of a conda
+// environment.yml's dependencies, which conda installs with pip. The main
+// group is the conda packages.
+var CondaPipGroup = Group{Kind: GroupDependency, Name: "pip"}
+
+// condaDocument locates the parts of an environment.yml file that depman
+// reads and edits. It understands the block style conda itself writes:
+//
+//	name: myenv
+//	dependencies:
+//	  - python=3.11
+//	  - pip
+//	  - pip:
+//	    - requests==2.31.0
+//
+// Flow-style lists such as "dependencies: [numpy]" are reported as errors.
+type condaDocument struct {
+	name    string
+	deps    yamlSequence // the dependencies: list, without its "- pip:" item
+	pip     yamlSequence // the list under the "- pip:" item
+	pipItem textLine     // the "- pip:" item, if pip.found
+	depsKey textLine     // the "dependencies:" line, if deps.found
+}
+
+// yamlSequence is a block sequence of scalars.
+type yamlSequence struct {
+	found  bool
+	indent int // column of the dashes, -1 while the list is empty
+	items  []lineItem
+	end    int // offset past the last line of the list, where items are appended
+}
+
+// textLine is the extent of one line, including its line break.
+type textLine struct {
+	start, end int
+	indent     int
+}
+
+// lineItem is an item of a list written one item per line, such as a
+// "- value" item of a YAML block sequence.
+type lineItem struct {
+	line       textLine
+	start, end int    // the value, including any quotes
+	value      string // the value without quotes
+	quote      byte   // the quote character, or 0
+}
+
+// scanCondaEnvironment locates the name and the dependency lists of an
+// environment.yml file.
+func scanCondaEnvironment(src string) (*condaDocument, error) {
+	doc := &condaDocument{deps: yamlSequence{indent: -1}, pip: yamlSequence{indent: -1}}
+	inDeps, inPip := false, false
+	for start := 0; start < len(src); {
+		end := strings.IndexByte(src[start:], '\n')
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += start + 1
+		}
+		line := textLine{start: start, end: end}
+		start = end
+
+		text := strings.TrimRight(src[line.start:line.end], "\r\n")
+		body := strings.TrimLeft(text, " ")
+		line.indent = len(text) - len(body)
+		if body == "" || strings.HasPrefix(body, "#") {
+			continue
+		}
+		if strings.HasPrefix(body, "\t") {
+			return nil, yamlError(src, line.start, "tabs are not allowed in indentation")
+		}
+		dash := body == "-" || strings.HasPrefix(body, "- ")
+
+		if line.indent == 0 && !dash {
+			// A top-level key
+			inDeps, inPip = false, false
+			key, value, _ := strings.Cut(body, ":")
+			value = strings.TrimSpace(stripYAMLComment(value))
+			switch strings.TrimSpace(key) {
+			case "name":
+				doc.name = unquoteYAML(value)
+			case "dependencies":
+				if value != "" {
+					return nil, yamlError(src, line.start, "dependencies must be a block list")
+				}
+				doc.deps.found, doc.depsKey, inDeps = true, line, true
+				doc.deps.end = line.end
+			}
+			continue
+		}
+		if !inDeps {
+			continue
+		}
+		if !dash {
+			// A value continued on the next line, or a nested mapping's key
+			doc.deps.end = line.end
+			if inPip {
+				doc.pip.end = line.end
+			}
+			continue
+		}
+
+		if doc.deps.indent < 0 {
+			doc.deps.indent = line.indent
+		}
+		switch {
+		case line.indent == doc.deps.indent:
+			inPip = false
+			doc.deps.end = line.end
+			item := parseYAMLItem(src, line)
+			if key, value, ok := strings.Cut(item.value, ":"); ok && item.quote == 0 && strings.TrimSpace(key) == "pip" {
+				if strings.TrimSpace(value) != "" {
+					return nil, yamlError(src, line.start, "pip: must be a block list")
+				}
+				doc.pip.found, doc.pipItem, inPip = true, line, true
+				doc.pip.end = line.end
+				continue
+			}
+			doc.deps.items = append(doc.deps.items, item)
+		case line.indent > doc.deps.indent && inPip:
+			if doc.pip.indent < 0 {
+				doc.pip.indent = line.indent
+			}
+			doc.deps.end, doc.pip.end = line.end, line.end
+			doc.pip.items = append(doc.pip.items, parseYAMLItem(src, line))
+		default:
+			// A list nested in some other item
+			doc.deps.end = line.end
+		}
+	}
+	return doc, nil
+}
+
+// parseYAMLItem reads the value of the sequence item on line.
+func parseYAMLItem(src string, line textLine) lineItem {
+	text := src[line.start:line.end]
+	start := line.start + line.indent + 1
+	for start < line.end && src[start] == ' ' {
+		start++
+	}
+	value := strings.TrimRight(stripYAMLComment(text[start-line.start:]), " \r\n")
+	item := lineItem{line: line, start: start, end: start + len(value), value: value}
+	if n := len(value); n >= 2 && (value[0] == '"' || value[0] == '\'') && value[n-1] == value[0] {
+		item.quote, item.value = value[0], unquoteYAML(value)
+	}
+	return item
+}
+
+// stripYAMLComment removes a trailing comment: a "#" at the start or after
+// a space, outside quotes.
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// unquoteYAML removes the quotes around a scalar.
+func unquoteYAML(s string) string {
+	n := len(s)
+	if n < 2 || s[0] != s[n-1] {
+		return s
+	}
+	switch s[0] {
+	case '\'':
+		return strings.ReplaceAll(s[1:n-1], "''", "'")
+	case '"':
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s[1 : n-1])
+	}
+	return s
+}
+
+// quoteLineItem quotes s in the same style as item.
+func quoteLineItem(item lineItem, s string) string {
+	switch item.quote {
+	case '\'':
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	case '"':
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	return s
+}
+
+func yamlError(src string, offset int, format string, args ...any) error {
+	line := strings.Count(src[:offset], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// CondaEnvironmentName returns the name an environment.yml file gives its
+// environment, or "" if it has none.
+func CondaEnvironmentName(content string) string {
+	doc, err := scanCondaEnvironment(content)
+	if err != nil {
+		return ""
+	}
+	return doc.name
+}
+
+// ParseCondaEnvironment extracts the dependency groups of a conda
+// environment.yml file: the conda packages as the main group, then the
+// pip: subsection, if present, as the "pip" dependency group. Conda match
+// specs are converted to PEP 440 clauses, keeping the original in
+// Requirement.Constraint; entries that cannot be converted, such as pip
+// options, are skipped. It returns nil if the file uses YAML that depman
+// does not understand.
+func ParseCondaEnvironment(content string) []DependencyGroup {
+	doc, err := scanCondaEnvironment(content)
+	if err != nil {
+		return nil
+	}
+	groups := []DependencyGroup{{Group: MainGroup, Requirements: condaRequirements(doc.deps.items, ParseCondaSpec)}}
+	if doc.pip.found {
+		groups = append(groups, DependencyGroup{Group: CondaPipGroup, Requirements: condaRequirements(doc.pip.items, ParseRequirement)})
+	}
+	return groups
+}
+
+func condaRequirements(items []lineItem, parse func(string) (Requirement, error)) []Requirement {
+	var reqs []Requirement
+	for _, item := range items {
+		if r, err := parse(item.value); err == nil {
+			reqs = append(reqs, r)
+		}
+	}
+	sortRequirements(reqs)
+	return reqs
+}
+
+var (
+	condaNamePattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*`)
+	condaVersionPattern = regexp.MustCompile(`^[0-9][A-Za-z0-9.*+!_-]*$`)
+)
+
+// ParseCondaSpec converts a conda match spec such as "numpy=1.26",
+// "conda-forge::numpy>=1.26,<2" or "numpy 1.26.4 py311_0" to a
+// requirement. The channel and build string are dropped; "=1.26" becomes
+// "==1.26.*" and a bare version an exact pin. The version part is kept in
+// Constraint as written.
+func ParseCondaSpec(spec string) (Requirement, error) {
+	s := strings.TrimSpace(spec)
+	if i := strings.LastIndex(s, "::"); i >= 0 {
+		s = s[i+2:]
+	}
+	name := condaNamePattern.FindString(s)
+	if name == "" {
+		return Requirement{}, fmt.Errorf("parser: conda spec %q: missing package name", spec)
+	}
+	r := Requirement{Name: name}
+	rest := strings.TrimSpace(s[len(name):])
+	if rest == "" {
+		return r, nil
+	}
+	if strings.ContainsAny(rest, "[|") {
+		return Requirement{}, fmt.Errorf("parser: conda spec %q: unsupported syntax", spec)
+	}
+
+	var clauses string
+	switch fields := strings.Fields(rest); {
+	case strings.HasPrefix(rest, "=") && !strings.HasPrefix(rest, "=="):
+		// name=version[=build]: a fuzzy version, unless a build is given
+		ver, _, exact := strings.Cut(rest[1:], "=")
+		if ver = strings.TrimSpace(ver); !condaVersionPattern.MatchString(ver) {
+			return Requirement{}, fmt.Errorf("parser: conda spec %q: invalid version %q", spec, ver)
+		}
+		if !exact && !strings.HasSuffix(ver, "*") {
+			ver += ".*"
+		}
+		clauses = "==" + ver
+	case condaVersionPattern.MatchString(fields[0]):
+		// name version [build]: an exact version, or a wildcard
+		clauses = "==" + fields[0]
+	default:
+		clauses = strings.Join(fields, "")
+	}
+	specs, err := version.ParseSpecifierSet(clauses)
+	if err != nil {
+		return Requirement{}, fmt.Errorf("parser: conda spec %q: %w", spec, err)
+	}
+	r.Specifiers = specs
+	r.Constraint = rest
+	return r, nil
+}
+
+// CondaSpec formats r as a conda match spec: "==1.26.*" is written
+// "=1.26", other clauses as they are.
+func CondaSpec(r Requirement) string {
+	if len(r.Specifiers) == 1 && r.Specifiers[0].Op == "==" {
+		if prefix, ok := strings.CutSuffix(r.Specifiers[0].Version, ".*"); ok {
+			return r.Name + "=" + prefix
+		}
+	}
+	return r.Name + r.SpecifierString()
+}
+
+// RewriteCondaEnvironment updates the dependencies of an environment.yml
+// file to match groups, the main group and CondaPipGroup, with the
+// smallest possible edit: unchanged entries are kept byte-for-byte, a
+// changed one keeps its line, indentation, quotes and comment, removed
+// ones lose their line, and new ones are appended to their list with its
+// indentation. A pip: subsection is created when needed, together with a
+// "pip" conda package to install it, and dropped when it ends up empty.
+// The name, channels and anything else in the file are preserved.
+func RewriteCondaEnvironment(content string, groups []DependencyGroup) (string, error) {
+	doc, err := scanCondaEnvironment(content)
+	if err != nil {
+		return "", fmt.Errorf("parser: environment.yml: %w", err)
+	}
+	nl := newline(content)
+
+	var edits, pipEdits []textEdit
+	var added, pipAdded []string
+	var deps, pip []Requirement
+	hasPip := false
+	for _, g := range groups {
+		switch {
+		case g.Kind == GroupMain:
+			deps = g.Requirements
+			edits, added = lineListEdits(doc.deps.items, deps, ParseCondaSpec, CondaSpec)
+		case g.Is(CondaPipGroup):
+			pip, hasPip = g.Requirements, true
+			pipEdits, pipAdded = lineListEdits(doc.pip.items, pip, ParseRequirement, Requirement.String)
+		default:
+			return "", fmt.Errorf("parser: environment.yml: no list for group %s (want main or %s)", g.Group, CondaPipGroup)
+		}
+	}
+	edits = append(edits, pipEdits...)
+
+	if !doc.deps.found {
+		if len(added) == 0 && len(pipAdded) == 0 {
+			return content, nil
+		}
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += nl
+		}
+		content += "dependencies:" + nl
+		doc, _ = scanCondaEnvironment(content)
+	}
+
+	indent := strings.Repeat(" ", doc.deps.indent)
+	if doc.deps.indent < 0 {
+		indent = "  "
+	}
+	var text strings.Builder
+	for _, spec := range added {
+		text.WriteString(indent + "- " + spec + nl)
+	}
+	if len(added) > 0 {
+		at := doc.depsKey.end
+		if n := len(doc.deps.items); n > 0 {
+			at = doc.deps.items[n-1].line.end
+		}
+		edits = append(edits, textEdit{start: at, end: at, text: text.String()})
+	}
+
+	switch {
+	case len(pipAdded) > 0 && doc.pip.found:
+		pipIndent := strings.Repeat(" ", doc.pip.indent)
+		if doc.pip.indent < 0 {
+			pipIndent = strings.Repeat(" ", doc.pipItem.indent+2)
+		}
+		var b strings.Builder
+		for _, spec := range pipAdded {
+			b.WriteString(pipIndent + "- " + spec + nl)
+		}
+		edits = append(edits, textEdit{start: doc.pip.end, end: doc.pip.end, text: b.String()})
+	case len(pipAdded) > 0:
+		var b strings.Builder
+		if !slices.ContainsFunc(deps, func(r Requirement) bool { return r.Key() == "pip" }) && !declaresConda(doc.deps.items, "pip") {
+			b.WriteString(indent + "- pip" + nl)
+		}
+		b.WriteString(indent + "- pip:" + nl)
+		for _, spec := range pipAdded {
+			b.WriteString(indent + "  - " + spec + nl)
+		}
+		edits = append(edits, textEdit{start: doc.deps.end, end: doc.deps.end, text: b.String()})
+	case hasPip && doc.pip.found && len(pip) == 0 && len(pipEdits) == len(doc.pip.items):
+		// Every pip package was removed: drop the empty subsection
+		edits = append(edits, textEdit{start: doc.pipItem.start, end: doc.pipItem.end})
+	}
+	return applyEdits(content, edits), nil
+}
+
+// lineListEdits returns the edits that turn the items of a list written
+// one per line into reqs, and the specs of the requirements that match no
+// item. Items that parse cannot read are kept.
+func lineListEdits(items []lineItem, reqs []Requirement, parse func(string) (Requirement, error), format func(Requirement) string) ([]textEdit, []string) {
+	pending := make(map[string]Requirement, len(reqs))
+	for _, r := range reqs {
+		pending[r.Key()] = r
+	}
+
+	var edits []textEdit
+	for _, item := range items {
+		old, err := parse(item.value)
+		if err != nil {
+			continue
+		}
+		want, ok := pending[old.Key()]
+		if !ok {
+			edits = append(edits, textEdit{start: item.line.start, end: item.line.end})
+			continue
+		}
+		delete(pending, old.Key())
+		if want.String() != old.String() {
+			edits = append(edits, textEdit{start: item.start, end: item.end, text: quoteLineItem(item, format(want))})
+		}
+	}
+
+	var added []string
+	for _, r := range reqs {
+		if _, ok := pending[r.Key()]; ok {
+			added = append(added, format(r))
+		}
+	}
+	return edits, added
+}
+
+// declaresConda reports whether items include the conda package name.
+func declaresConda(items []lineItem, name string) bool {
+	for _, item := range items {
+		if r, err := ParseCondaSpec(item.value); err == nil && r.Key() == NormalizeName(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+const testEnvironmentYML = `name: science
+channels:
+  - conda-forge
+  - defaults
+dependencies:
+  - python=3.11
+  - numpy==1.26.4  # pinned
+  - "pandas>=2"
+  - conda-forge::scipy
+  - libgdal 3.8.*
+  - pip
+  - pip:
+    - requests==2.30.0
+    - -e .
+`
+
+func TestParseCondaSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected string
+		wantErr  bool
+	}{
+		{"numpy", "numpy", false},
+		{"numpy=1.26", "numpy==1.26.*", false},
+		{"numpy=1.26.*", "numpy==1.26.*", false},
+		{"numpy=1.26.4=py311h64a7726_0", "numpy==1.26.4", false},
+		{"numpy==1.26.4", "numpy==1.26.4", false},
+		{"numpy>=1.26,<2", "numpy>=1.26,<2", false},
+		{"numpy >=1.26", "numpy>=1.26", false},
+		{"numpy 1.26.4 py311h64a7726_0", "numpy==1.26.4", false},
+		{"conda-forge::numpy=1.26", "numpy==1.26.*", false},
+		{"numpy[version='>=1.26']", "", true},
+		{"numpy 1.26|1.27", "", true},
+		{"=1.26", "", true},
+	}
+	for _, tt := range tests {
+		r, err := ParseCondaSpec(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCondaSpec(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if err == nil && r.String() != tt.expected {
+			t.Errorf("ParseCondaSpec(%q) = %s, want %s", tt.spec, r, tt.expected)
+		}
+	}
+
+	r, _ := ParseCondaSpec("numpy=1.26")
+	if r.ConstraintString() != "=1.26" || CondaSpec(r) != "numpy=1.26" {
+		t.Errorf("ParseCondaSpec(numpy=1.26): constraint %q, spec %q", r.ConstraintString(), CondaSpec(r))
+	}
+}
+
+func TestParseCondaEnvironment(t *testing.T) {
+	groups := ParseCondaEnvironment(testEnvironmentYML)
+	var got []string
+	for _, g := range groups {
+		var reqs []string
+		for _, r := range g.Requirements {
+			reqs = append(reqs, r.String())
+		}
+		got = append(got, g.String()+": "+strings.Join(reqs, ", "))
+	}
+	expected := []string{
+		"main: libgdal==3.8.*, numpy==1.26.4, pandas>=2, pip, python==3.11.*, scipy",
+		"group:pip: requests==2.30.0",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("ParseCondaEnvironment() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+	if name := CondaEnvironmentName(testEnvironmentYML); name != "science" {
+		t.Errorf("CondaEnvironmentName() = %q, want science", name)
+	}
+	if groups := ParseCondaEnvironment("dependencies: [numpy]\n"); groups != nil {
+		t.Errorf("ParseCondaEnvironment(flow list) = %+v, want nil", groups)
+	}
+}
+
+func TestRewriteCondaEnvironment(t *testing.T) {
+	groups := ParseCondaEnvironment(testEnvironmentYML)
+	var main []Requirement
+	for _, r := range groups[0].Requirements {
+		switch r.Key() {
+		case "scipy":
+			continue // removed
+		case "numpy":
+			r = Pinned(r.Name, "1.26.5")
+		case "pandas":
+			r.Specifiers = Pinned(r.Name, "2.2.0").Specifiers
+		}
+		main = append(main, r)
+	}
+	matplotlib, _ := ParseCondaSpec("matplotlib=3.8")
+	main = append(main, matplotlib)
+	rich, _ := ParseRequirement("rich>=13")
+
+	got, err := RewriteCondaEnvironment(testEnvironmentYML, []DependencyGroup{
+		{Group: MainGroup, Requirements: main},
+		{Group: CondaPipGroup, Requirements: []Requirement{rich}},
+	})
+	if err != nil {
+		t.Fatalf("RewriteCondaEnvironment() error = %v", err)
+	}
+	expected := `name: science
+channels:
+  - conda-forge
+  - defaults
+dependencies:
+  - python=3.11
+  - numpy==1.26.5  # pinned
+  - "pandas==2.2.0"
+  - libgdal 3.8.*
+  - pip
+  - matplotlib=3.8
+  - pip:
+    - -e .
+    - rich>=13
+`
+	if got != expected {
+		t.Errorf("RewriteCondaEnvironment() =\n%s\nwant:\n%s", got, expected)
+	}
+
+	// A pip: subsection is created with the pip package that installs it
+	got, err = RewriteCondaEnvironment("name: app\ndependencies:\n- python=3.12\n", []DependencyGroup{
+		{Group: MainGroup, Requirements: ParseCondaEnvironment("dependencies:\n- python=3.12\n")[0].Requirements},
+		{Group: CondaPipGroup, Requirements: []Requirement{rich}},
+	})
+	if err != nil {
+		t.Fatalf("RewriteCondaEnvironment(new pip list) error = %v", err)
+	}
+	if expected := "name: app\ndependencies:\n- python=3.12\n- pip\n- pip:\n  - rich>=13\n"; got != expected {
+		t.Errorf("RewriteCondaEnvironment(new pip list) =\n%s\nwant:\n%s", got, expected)
+	}
+
+	// An emptied pip: subsection is dropped
+	got, err = RewriteCondaEnvironment("dependencies:\n  - pip\n  - pip:\n      - rich\n", []DependencyGroup{
+		{Group: MainGroup, Requirements: []Requirement{{Name: "pip"}}},
+		{Group: CondaPipGroup},
+	})
+	if err != nil || got != "dependencies:\n  - pip\n" {
+		t.Errorf("RewriteCondaEnvironment(empty pip list) = %q, %v", got, err)
+	}
+
+	if _, err := RewriteCondaEnvironment(testEnvironmentYML, []DependencyGroup{{Group: Group{Kind: GroupDependency, Name: "dev"}}}); err == nil {
+		t.Error("RewriteCondaEnvironment(dev group): expected an error")
+	}
+}
@@ -0,0 +1,267 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eslam/depman/pkg/pip"
+	"github.com/eslam/depman/pkg/pypi"
+)
+
+// ExportFormat is a file format `depman export` writes.
+type ExportFormat string
+
+const (
+	ExportRequirements ExportFormat = "requirements" // requirements.txt pins, optionally with --hash options
+	ExportConstraints  ExportFormat = "constraints"  // pins for `pip install -c`
+	ExportPylock       ExportFormat = "pylock"       // a PEP 751 pylock.toml
+)
+
+// ParseExportFormat validates an export format name.
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch f := ExportFormat(s); f {
+	case ExportRequirements, ExportConstraints, ExportPylock:
+		return f, nil
+	}
+	return "", fmt.Errorf("parser: unknown export format %q (want requirements, constraints or pylock)", s)
+}
+
+// pylockNamePattern matches the file names PEP 751 allows for a lock file.
+var pylockNamePattern = regexp.MustCompile(`^pylock(\.[^.]+)?\.toml$`)
+
+// ValidPylockName reports whether base is a valid lock file name:
+// pylock.toml or pylock.<name>.toml.
+func ValidPylockName(base string) bool {
+	return pylockNamePattern.MatchString(base)
+}
+
+// ExportSelection is the set of installed packages an export pins.
+type ExportSelection struct {
+	Packages []pip.Package // sorted by normalized name
+	Editable []string      // editable installs, which have no release to pin
+	Missing  []string      // declared by a selected group but not installed
+}
+
+// markerExtraPattern matches the `extra == "name"` clause of the marker of
+// a requirement that only applies to one extra.
+var markerExtraPattern = regexp.MustCompile(`\bextra\s*==\s*["']([^"']+)["']`)
+
+// SelectExport picks the installed packages to export. With no selected
+// groups that is every installed package except installer tooling. Otherwise
+// it is the packages the selected groups declare, with the groups they
+// include, and everything those require in turn, as recorded in requires
+// (see pip.InstalledRequirements). A requirement's environment marker is
+// not evaluated: a package it rules out is not installed, so it is not
+// found. Only its extra is checked, against the extras requested.
+func SelectExport(installed []pip.Package, groups []DependencyGroup, selected []Group, requires map[string][]string) (ExportSelection, error) {
+	var sel ExportSelection
+	byName := make(map[string]pip.Package, len(installed))
+	for _, p := range installed {
+		byName[NormalizeName(p.Name)] = p
+	}
+	take := func(p pip.Package) {
+		if p.Editable {
+			sel.Editable = append(sel.Editable, p.Name)
+		} else {
+			sel.Packages = append(sel.Packages, p)
+		}
+	}
+
+	if len(selected) == 0 {
+		for _, p := range installed {
+			if !IsTooling(p.Name) {
+				take(p)
+			}
+		}
+		sortExport(&sel)
+		return sel, nil
+	}
+	if requires == nil {
+		return sel, fmt.Errorf("parser: selecting groups needs the dependency metadata of the installed packages, which is only read from a virtualenv or conda environment")
+	}
+
+	seen := make(map[string]bool) // name[extra] pairs visited
+	missing := make(map[string]bool)
+	var visit func(r Requirement, direct bool)
+	visit = func(r Requirement, direct bool) {
+		key := NormalizeName(r.Name)
+		p, ok := byName[key]
+		if !ok {
+			if direct && !missing[key] {
+				missing[key] = true
+				sel.Missing = append(sel.Missing, r.Name)
+			}
+			return
+		}
+		for _, extra := range append([]string{""}, r.Extras...) {
+			extra = NormalizeName(extra)
+			if seen[key+"["+extra+"]"] {
+				continue
+			}
+			seen[key+"["+extra+"]"] = true
+			if extra == "" {
+				take(p)
+			}
+			for _, entry := range requires[key] {
+				dep, err := ParseRequirement(entry)
+				if err != nil || markerExtra(dep.Marker) != extra {
+					continue
+				}
+				visit(dep, false)
+			}
+		}
+	}
+
+	included := make(map[string]bool)
+	var visitGroup func(g DependencyGroup)
+	visitGroup = func(g DependencyGroup) {
+		for _, r := range g.Requirements {
+			visit(r, true)
+		}
+		for _, inc := range g.Includes {
+			ref := Group{Kind: GroupDependency, Name: inc}
+			if i := FindGroup(groups, ref); i >= 0 && !included[NormalizeName(inc)] {
+				included[NormalizeName(inc)] = true
+				visitGroup(groups[i])
+			}
+		}
+	}
+	for _, g := range selected {
+		i := FindGroup(groups, g)
+		if i < 0 {
+			return ExportSelection{}, fmt.Errorf("parser: the project declares no group %s", g)
+		}
+		visitGroup(groups[i])
+	}
+	sortExport(&sel)
+	return sel, nil
+}
+
+// markerExtra returns the normalized extra a marker restricts its
+// requirement to, or "" for a requirement of the package itself.
+func markerExtra(marker string) string {
+	if m := markerExtraPattern.FindStringSubmatch(marker); m != nil {
+		return NormalizeName(m[1])
+	}
+	return ""
+}
+
+func sortExport(sel *ExportSelection) {
+	sort.Slice(sel.Packages, func(i, j int) bool {
+		return NormalizeName(sel.Packages[i].Name) < NormalizeName(sel.Packages[j].Name)
+	})
+	sort.Strings(sel.Editable)
+}
+
+// FormatPinned formats packages as requirements.txt pins, each followed by
+// the --hash options hashes lists for its normalized name, if any. The
+// constraints format never carries hashes.
+func FormatPinned(format ExportFormat, packages []pip.Package, hashes map[string][]string) string {
+	var b strings.Builder
+	b.WriteString("# Generated by depman export from the installed packages — do not edit manually\n")
+	if format == ExportConstraints {
+		b.WriteString("# Use with: pip install -c <this file>\n")
+	}
+	for _, p := range packages {
+		b.WriteString(Pinned(p.Name, p.InstalledVersion).String())
+		if format != ExportConstraints {
+			for _, h := range hashes[NormalizeName(p.Name)] {
+				b.WriteString(" \\\n    --hash=" + h)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// ReleaseHashes returns the --hash values of a release's files, sorted.
+func ReleaseHashes(files []pypi.ReleaseFile) []string {
+	var hashes []string
+	for _, f := range files {
+		if f.SHA256 != "" {
+			hashes = append(hashes, "sha256:"+f.SHA256)
+		}
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+// PyPIHashes returns a HashFunc that reads the hashes of a release's files
+// from the PyPI JSON API at baseURL, such as the configured mirror.
+func PyPIHashes(ctx context.Context, baseURL string) HashFunc {
+	client := pypi.NewClient(baseURL)
+	return func(name, version string) ([]string, error) {
+		files, err := client.GetReleaseFilesWithContext(ctx, name, version)
+		if err != nil {
+			return nil, err
+		}
+		return ReleaseHashes(files), nil
+	}
+}
+
+// FormatPylock formats packages as a PEP 751 lock file, with the wheels
+// and sdist files lists for each normalized name. index is the simple
+// repository API URL the files were found on. Every package needs at least
+// one file with a hash.
+func FormatPylock(packages []pip.Package, files map[string][]pypi.ReleaseFile, index string) (string, error) {
+	var b strings.Builder
+	b.WriteString("# Generated by depman export from the installed packages — do not edit manually\n")
+	b.WriteString("lock-version = \"1.0\"\n")
+	b.WriteString("created-by = \"depman\"\n")
+
+	var unlocked []string
+	for _, p := range packages {
+		name := NormalizeName(p.Name)
+		var sdist, wheels []pypi.ReleaseFile
+		for _, f := range files[name] {
+			switch {
+			case f.SHA256 == "":
+			case f.Sdist:
+				sdist = append(sdist, f)
+			case strings.HasSuffix(f.Filename, ".whl"):
+				wheels = append(wheels, f)
+			}
+		}
+		if len(sdist) == 0 && len(wheels) == 0 {
+			unlocked = append(unlocked, p.Name+"=="+p.InstalledVersion)
+			continue
+		}
+
+		b.WriteString("\n[[packages]]\n")
+		b.WriteString("name = " + tomlString(name) + "\n")
+		b.WriteString("version = " + tomlString(p.InstalledVersion) + "\n")
+		if index != "" {
+			b.WriteString("index = " + tomlString(index) + "\n")
+		}
+		if len(sdist) > 0 {
+			b.WriteString("\n[packages.sdist]\n")
+			writePylockFile(&b, sdist[0])
+		}
+		for _, f := range wheels {
+			b.WriteString("\n[[packages.wheels]]\n")
+			writePylockFile(&b, f)
+		}
+	}
+	if len(unlocked) > 0 {
+		return "", fmt.Errorf("parser: no release files with a hash for %s", strings.Join(unlocked, ", "))
+	}
+	return b.String(), nil
+}
+
+// writePylockFile writes the keys of a [packages.sdist] or
+// [[packages.wheels]] table.
+func writePylockFile(b *strings.Builder, f pypi.ReleaseFile) {
+	b.WriteString("name = " + tomlString(f.Filename) + "\n")
+	if !f.UploadTime.IsZero() {
+		b.WriteString("upload-time = " + f.UploadTime.UTC().Format(time.RFC3339Nano) + "\n")
+	}
+	b.WriteString("url = " + tomlString(f.URL) + "\n")
+	if f.Size > 0 {
+		fmt.Fprintf(b, "size = %d\n", f.Size)
+	}
+	b.WriteString("hashes = {sha256 = " + tomlString(f.SHA256) + "}\n")
+}
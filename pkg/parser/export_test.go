@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eslam/depman/pkg/pip"
+	"github.com/eslam/depman/pkg/pypi"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+func TestSelectExport(t *testing.T) {
+	installed := []pip.Package{
+		{Name: "requests", InstalledVersion: "2.31.0"},
+		{Name: "idna", InstalledVersion: "3.6"},
+		{Name: "PySocks", InstalledVersion: "1.7.1"},
+		{Name: "pytest", InstalledVersion: "8.0.0"},
+		{Name: "pluggy", InstalledVersion: "1.4.0"},
+		{Name: "coverage", InstalledVersion: "7.4.1"},
+		{Name: "app", InstalledVersion: "0.1.0", Editable: true},
+		{Name: "pip", InstalledVersion: "24.0"},
+	}
+	requires := map[string][]string{
+		"requests": {"idna<4,>=2.5", `PySocks!=1.5.7,>=1.5.6; extra == "socks"`},
+		"pytest":   {"pluggy<2.0,>=1.3.0", `coverage; extra == "cov"`},
+	}
+	groups := ParsePyprojectGroups(`[project]
+dependencies = ["requests", "app", "attrs"]
+
+[project.optional-dependencies]
+socks = ["requests[socks]"]
+
+[dependency-groups]
+test = ["pytest"]
+dev = [{include-group = "test"}]
+`)
+
+	tests := []struct {
+		name     string
+		selected []Group
+		expected string
+	}{
+		{
+			name:     "everything installed",
+			expected: "coverage idna pluggy PySocks pytest requests | editable app | missing ",
+		},
+		{
+			name:     "main",
+			selected: []Group{MainGroup},
+			expected: "idna requests | editable app | missing attrs",
+		},
+		{
+			name:     "extra",
+			selected: []Group{{Kind: GroupOptional, Name: "socks"}},
+			expected: "idna PySocks requests | editable  | missing ",
+		},
+		{
+			name:     "included group",
+			selected: []Group{{Kind: GroupDependency, Name: "dev"}},
+			expected: "pluggy pytest | editable  | missing ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := SelectExport(installed, groups, tt.selected, requires)
+			if err != nil {
+				t.Fatalf("SelectExport() error = %v", err)
+			}
+			var names []string
+			for _, p := range sel.Packages {
+				names = append(names, p.Name)
+			}
+			got := strings.Join(names, " ") + " | editable " + strings.Join(sel.Editable, " ") + " | missing " + strings.Join(sel.Missing, " ")
+			if got != tt.expected {
+				t.Errorf("SelectExport() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := SelectExport(installed, groups, []Group{MainGroup}, nil); err == nil {
+		t.Error("SelectExport(no metadata): expected an error")
+	}
+	if _, err := SelectExport(installed, groups, []Group{{Kind: GroupDependency, Name: "docs"}}, requires); err == nil {
+		t.Error("SelectExport(unknown group): expected an error")
+	}
+}
+
+func TestFormatPinned(t *testing.T) {
+	packages := []pip.Package{{Name: "idna", InstalledVersion: "3.6"}, {Name: "six", InstalledVersion: "1.16.0"}}
+	hashes := map[string][]string{"idna": {"sha256:aaa", "sha256:bbb"}}
+
+	expected := `# Generated by depman export from the installed packages — do not edit manually
+idna==3.6 \
+    --hash=sha256:aaa \
+    --hash=sha256:bbb
+six==1.16.0
+`
+	if got := FormatPinned(ExportRequirements, packages, hashes); got != expected {
+		t.Errorf("FormatPinned(requirements) =\n%s\nwant:\n%s", got, expected)
+	}
+
+	expected = `# Generated by depman export from the installed packages — do not edit manually
+# Use with: pip install -c <this file>
+idna==3.6
+six==1.16.0
+`
+	if got := FormatPinned(ExportConstraints, packages, hashes); got != expected {
+		t.Errorf("FormatPinned(constraints) =\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestFormatPylock(t *testing.T) {
+	uploaded := time.Date(2023, 11, 25, 15, 40, 52, 604064000, time.UTC)
+	files := map[string][]pypi.ReleaseFile{
+		"typing-extensions": {
+			{Filename: "typing_extensions-4.9.0-py3-none-any.whl", URL: "https://files.example.com/t.whl", Size: 32806, UploadTime: uploaded, SHA256: "aaa"},
+			{Filename: "typing_extensions-4.9.0.tar.gz", URL: "https://files.example.com/t.tar.gz", Size: 76778, SHA256: "bbb", Sdist: true},
+			{Filename: "typing_extensions-4.9.0.exe", URL: "https://files.example.com/t.exe", SHA256: "ccc"},
+		},
+	}
+	packages := []pip.Package{{Name: "typing_extensions", InstalledVersion: "4.9.0"}}
+
+	got, err := FormatPylock(packages, files, "https://pypi.org/simple")
+	if err != nil {
+		t.Fatalf("FormatPylock() error = %v", err)
+	}
+	expected := `# Generated by depman export from the installed packages — do not edit manually
+lock-version = "1.0"
+created-by = "depman"
+
+[[packages]]
+name = "typing-extensions"
+version = "4.9.0"
+index = "https://pypi.org/simple"
+
+[packages.sdist]
+name = "typing_extensions-4.9.0.tar.gz"
+url = "https://files.example.com/t.tar.gz"
+size = 76778
+hashes = {sha256 = "bbb"}
+
+[[packages.wheels]]
+name = "typing_extensions-4.9.0-py3-none-any.whl"
+upload-time = 2023-11-25T15:40:52.604064Z
+url = "https://files.example.com/t.whl"
+size = 32806
+hashes = {sha256 = "aaa"}
+`
+	if got != expected {
+		t.Errorf("FormatPylock() =\n%s\nwant:\n%s", got, expected)
+	}
+	var lock struct {
+		LockVersion string `toml:"lock-version"`
+		Packages    []struct {
+			Name   string
+			Wheels []struct {
+				UploadTime time.Time `toml:"upload-time"`
+			}
+		}
+	}
+	if err := toml.Unmarshal([]byte(got), &lock); err != nil || lock.LockVersion != "1.0" || len(lock.Packages) != 1 || !lock.Packages[0].Wheels[0].UploadTime.Equal(uploaded) {
+		t.Errorf("FormatPylock() is not the expected TOML: %+v, %v", lock, err)
+	}
+
+	packages = append(packages, pip.Package{Name: "private-lib", InstalledVersion: "1.0"})
+	if _, err := FormatPylock(packages, files, ""); err == nil || !strings.Contains(err.Error(), "private-lib==1.0") {
+		t.Errorf("FormatPylock(unknown release) error = %v, want one naming private-lib==1.0", err)
+	}
+}
+
+func TestValidPylockName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"pylock.toml":        true,
+		"pylock.docker.toml": true,
+		"pylock.a.b.toml":    false,
+		"lock.toml":          false,
+		"pylock.toml.bak":    false,
+	} {
+		if got := ValidPylockName(name); got != valid {
+			t.Errorf("ValidPylockName(%q) = %v, want %v", name, got, valid)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/eslam/depman/pkg/detector"
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// GroupKind says where a dependency group is declared.
+type GroupKind int
+
+const (
+	GroupMain       GroupKind = iota // [project] dependencies, or requirements.txt
+	GroupOptional                    // [project.optional-dependencies], installed as extras
+	GroupDependency                  // PEP 735 [dependency-groups]
+	GroupEnv                         // a Hatch environment's dependencies, [tool.hatch.envs.<name>]
+)
+
+var groupKindNames = map[GroupKind]string{
+	GroupMain:       "main",
+	GroupOptional:   "optional",
+	GroupDependency: "group",
+	GroupEnv:        "env",
+}
+
+func (k GroupKind) String() string {
+	if s, ok := groupKindNames[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("GroupKind(%d)", int(k))
+}
+
+// MarshalText encodes the kind by name.
+func (k GroupKind) MarshalText() ([]byte, error) {
+	s, ok := groupKindNames[k]
+	if !ok {
+		return nil, fmt.Errorf("parser: unknown group kind %d", int(k))
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalText decodes a kind name.
+func (k *GroupKind) UnmarshalText(text []byte) error {
+	for kind, name := range groupKindNames {
+		if name == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("parser: unknown group kind %q", text)
+}
+
+// Group identifies one list of dependencies in a project.
+type Group struct {
+	Kind GroupKind `json:"kind"`
+	Name string    `json:"name,omitempty"` // empty for GroupMain
+}
+
+// MainGroup is the project's main dependency list.
+var MainGroup = Group{Kind: GroupMain}
+
+// String returns the group reference accepted by ResolveGroup: "main",
+// "optional:<name>", "group:<name>" or "env:<name>".
+func (g Group) String() string {
+	if g.Kind == GroupMain {
+		return "main"
+	}
+	return g.Kind.String() + ":" + g.Name
+}
+
+// Is reports whether g and other name the same group. Group names compare
+// in PEP 503 normalized form.
+func (g Group) Is(other Group) bool {
+	return g.Kind == other.Kind && NormalizeName(g.Name) == NormalizeName(other.Name)
+}
+
+// parent returns the TOML path of the table holding the group's array and
+// the array's key within it.
+func (g Group) parent() (table, key string) {
+	switch g.Kind {
+	case GroupOptional:
+		return "project.optional-dependencies", g.Name
+	case GroupDependency:
+		return "dependency-groups", g.Name
+	case GroupEnv:
+		return joinKey("tool.hatch.envs", g.Name), "dependencies"
+	default:
+		return "project", "dependencies"
+	}
+}
+
+// path returns the full TOML path of the group's array.
+func (g Group) path() string {
+	table, key := g.parent()
+	return joinKey(table, key)
+}
+
+// DependencyGroup is a group together with its requirements.
+type DependencyGroup struct {
+	Group
+	Requirements []Requirement
+	Includes     []string // PEP 735 include-group references
+}
+
+// pyprojectData is used for TOML unmarshaling of the relevant sections.
+type pyprojectData struct {
+	Project struct {
+		Dependencies         []string            `toml:"dependencies"`
+		OptionalDependencies map[string][]string `toml:"optional-dependencies"`
+	} `toml:"project"`
+	DependencyGroups map[string][]any `toml:"dependency-groups"`
+}
+
+// ParsePyprojectGroups extracts every dependency group from a
+// pyproject.toml file: the main group first, then optional dependencies and
+// PEP 735 dependency groups, each sorted by name. Entries that are not
+// valid PEP 508 requirements are skipped.
+func ParsePyprojectGroups(content string) []DependencyGroup {
+	var data pyprojectData
+	if err := toml.Unmarshal([]byte(content), &data); err != nil {
+		return nil
+	}
+
+	groups := []DependencyGroup{{Group: MainGroup, Requirements: parseEntries(data.Project.Dependencies)}}
+
+	for _, name := range sortedKeys(data.Project.OptionalDependencies) {
+		groups = append(groups, DependencyGroup{
+			Group:        Group{Kind: GroupOptional, Name: name},
+			Requirements: parseEntries(data.Project.OptionalDependencies[name]),
+		})
+	}
+
+	for _, name := range sortedKeys(data.DependencyGroups) {
+		g := DependencyGroup{Group: Group{Kind: GroupDependency, Name: name}}
+		var entries []string
+		for _, item := range data.DependencyGroups[name] {
+			switch v := item.(type) {
+			case string:
+				entries = append(entries, v)
+			case map[string]any:
+				if inc, ok := v["include-group"].(string); ok {
+					g.Includes = append(g.Includes, inc)
+				}
+			}
+		}
+		g.Requirements = parseEntries(entries)
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// ReadDependencyGroups parses every dependency group declared in the
+// project's dependency file. A requirements.txt file has only the main
+// group, a Pipfile also its dev packages, an environment.yml file its
+// pip: subsection and a setup.cfg or setup.py file its extras; a Poetry
+// project's groups include its Poetry tables.
+func ReadDependencyGroups(project detector.Project) ([]DependencyGroup, error) {
+	switch project.FileType {
+	case detector.FilePyprojectTOML, detector.FilePipfile, detector.FileEnvironmentYML, detector.FileSetupCfg, detector.FileSetupPy:
+	default:
+		reqs, err := ReadDependencyFile(project)
+		if err != nil {
+			return nil, err
+		}
+		return []DependencyGroup{{Group: MainGroup, Requirements: reqs}}, nil
+	}
+	content, err := os.ReadFile(project.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("parser: read file: %w", err)
+	}
+	switch project.FileType {
+	case detector.FilePipfile:
+		return ParsePipfile(string(content)), nil
+	case detector.FileEnvironmentYML:
+		return ParseCondaEnvironment(string(content)), nil
+	case detector.FileSetupCfg:
+		return ParseSetupCfg(string(content)), nil
+	case detector.FileSetupPy:
+		return ParseSetupPy(string(content)), nil
+	}
+	return parseProjectGroups(project.Tool, string(content)), nil
+}
+
+// parseProjectGroups parses the groups of a pyproject.toml file, including
+// those declared in the tables of the project manager tool.
+func parseProjectGroups(tool detector.Tool, content string) []DependencyGroup {
+	switch tool {
+	case detector.ToolPoetry:
+		return ParsePoetryGroups(content)
+	case detector.ToolPDM:
+		return ParsePDMGroups(content)
+	case detector.ToolHatch:
+		return ParseHatchGroups(content)
+	default:
+		return ParsePyprojectGroups(content)
+	}
+}
+
+// mergeGroup appends reqs to the group g in groups, adding the group if it
+// is not there yet.
+func mergeGroup(groups []DependencyGroup, g Group, reqs []Requirement) []DependencyGroup {
+	if i := FindGroup(groups, g); i >= 0 {
+		groups[i].Requirements = append(groups[i].Requirements, reqs...)
+		return groups
+	}
+	return append(groups, DependencyGroup{Group: g, Requirements: reqs})
+}
+
+// AllRequirements returns the requirements of every group, in order.
+func AllRequirements(groups []DependencyGroup) []Requirement {
+	var reqs []Requirement
+	for _, g := range groups {
+		reqs = append(reqs, g.Requirements...)
+	}
+	return reqs
+}
+
+// FindGroup returns the index of the group in groups, or -1.
+func FindGroup(groups []DependencyGroup, g Group) int {
+	for i := range groups {
+		if groups[i].Is(g) {
+			return i
+		}
+	}
+	return -1
+}
+
+// GroupOf returns the first group that declares name, which may be a
+// requirement spec such as "pytest>=8".
+func GroupOf(groups []DependencyGroup, name string) (Group, bool) {
+	key := NormalizeName(name)
+	if r, err := ParseRequirement(name); err == nil {
+		key = r.Key()
+	}
+	for _, g := range groups {
+		for _, r := range g.Requirements {
+			if r.Key() == key {
+				return g.Group, true
+			}
+		}
+	}
+	return Group{}, false
+}
+
+// DeclaringGroup returns a group other than except that declares name.
+func DeclaringGroup(groups []DependencyGroup, name string, except Group) (Group, bool) {
+	key := NormalizeName(name)
+	for _, g := range groups {
+		if g.Is(except) {
+			continue
+		}
+		for _, r := range g.Requirements {
+			if r.Key() == key {
+				return g.Group, true
+			}
+		}
+	}
+	return Group{}, false
+}
+
+// groupNamePattern is the PEP 685/735 group name syntax, the same as a
+// project name.
+var groupNamePattern = regexp.MustCompile(`(?i)^([a-z0-9]|[a-z0-9][a-z0-9._-]*[a-z0-9])$`)
+
+// ResolveGroup parses a group reference against the groups declared in a
+// project. "" and "main" select the main group, "optional:<name>",
+// "group:<name>" and "env:<name>" select a group of that kind, and a bare
+// name selects the existing dependency group, optional dependency group or
+// Hatch environment of that name, in that order; an unknown bare name is a
+// new PEP 735 dependency group. A group that already exists resolves to its
+// name as spelled in the file.
+func ResolveGroup(groups []DependencyGroup, ref string) (Group, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || ref == "main" {
+		return MainGroup, nil
+	}
+
+	kinds := []GroupKind{GroupDependency, GroupOptional, GroupEnv}
+	if kind, name, ok := strings.Cut(ref, ":"); ok {
+		var k GroupKind
+		if err := k.UnmarshalText([]byte(kind)); err != nil || k == GroupMain {
+			return Group{}, fmt.Errorf("parser: group %q: kind must be optional, group or env", ref)
+		}
+		kinds = []GroupKind{k}
+		ref = name
+	}
+	if !groupNamePattern.MatchString(ref) {
+		return Group{}, fmt.Errorf("parser: invalid group name %q", ref)
+	}
+
+	for _, kind := range kinds {
+		if i := FindGroup(groups, Group{Kind: kind, Name: ref}); i >= 0 {
+			return groups[i].Group, nil
+		}
+	}
+	return Group{Kind: kinds[0], Name: ref}, nil
+}
+
+func parseEntries(entries []string) []Requirement {
+	var reqs []Requirement
+	for _, entry := range entries {
+		r, err := ParseRequirement(entry)
+		if err != nil {
+			continue
+		}
+		reqs = append(reqs, r)
+	}
+	return reqs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
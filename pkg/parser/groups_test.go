@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/eslam/depman/pkg/pip"
+)
+
+const groupsPyproject = `[project]
+name = "demo"
+dependencies = ["requests>=2"]
+
+[project.optional-dependencies]
+docs = ["sphinx==7.2.6", "furo"]
+Postgres = ["psycopg[binary]>=3"]
+
+[dependency-groups]
+test = ["pytest==8.0.0"]
+dev = [{include-group = "test"}, "ruff", "not a requirement!"]
+`
+
+func TestParsePyprojectGroups(t *testing.T) {
+	groups := ParsePyprojectGroups(groupsPyproject)
+
+	expected := []struct {
+		group    string
+		reqs     []string
+		includes []string
+	}{
+		{"main", []string{"requests>=2"}, nil},
+		{"optional:Postgres", []string{"psycopg[binary]>=3"}, nil},
+		{"optional:docs", []string{"sphinx==7.2.6", "furo"}, nil},
+		{"group:dev", []string{"ruff"}, []string{"test"}},
+		{"group:test", []string{"pytest==8.0.0"}, nil},
+	}
+	if len(groups) != len(expected) {
+		t.Fatalf("ParsePyprojectGroups() returned %d groups, want %d: %v", len(groups), len(expected), groups)
+	}
+	for i, want := range expected {
+		g := groups[i]
+		if g.String() != want.group {
+			t.Errorf("group[%d] = %s, want %s", i, g, want.group)
+		}
+		if len(g.Requirements) != len(want.reqs) {
+			t.Errorf("%s has %d requirements, want %d", g, len(g.Requirements), len(want.reqs))
+			continue
+		}
+		for j, r := range g.Requirements {
+			if r.String() != want.reqs[j] {
+				t.Errorf("%s requirement[%d] = %q, want %q", g, j, r.String(), want.reqs[j])
+			}
+		}
+		if len(g.Includes) != len(want.includes) {
+			t.Errorf("%s includes = %v, want %v", g, g.Includes, want.includes)
+		}
+	}
+
+	if got := ParsePyprojectTOML(groupsPyproject); len(got) != 1 || got[0].Name != "requests" {
+		t.Errorf("ParsePyprojectTOML() = %v, want only the main dependencies", got)
+	}
+}
+
+func TestResolveGroup(t *testing.T) {
+	groups := append(ParsePyprojectGroups(groupsPyproject),
+		DependencyGroup{Group: Group{Kind: GroupOptional, Name: "lint"}},
+		DependencyGroup{Group: Group{Kind: GroupOptional, Name: "dev"}},
+		DependencyGroup{Group: Group{Kind: GroupEnv, Name: "lint"}},
+		DependencyGroup{Group: Group{Kind: GroupEnv, Name: "types"}},
+	)
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{"", "main", false},
+		{"main", "main", false},
+		{"test", "group:test", false},
+		{"postgres", "optional:Postgres", false},
+		{"lint", "optional:lint", false},
+		{"DEV", "group:dev", false},
+		{"optional:dev", "optional:dev", false},
+		{"new", "group:new", false},
+		{"optional:new", "optional:new", false},
+		{"group:docs", "group:docs", false},
+		{"types", "env:types", false},
+		{"env:lint", "env:lint", false},
+		{"env:new", "env:new", false},
+		{"main:x", "", true},
+		{"bad name!", "", true},
+		{"-dev", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ResolveGroup(groups, tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveGroup(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("ResolveGroup(%q) = %s, want %s", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupRequirements(t *testing.T) {
+	dev := Group{Kind: GroupDependency, Name: "dev"}
+	docs := Group{Kind: GroupOptional, Name: "docs"}
+	groups := []DependencyGroup{
+		{Group: MainGroup, Requirements: []Requirement{mustParse(t, "requests==2.30.0")}},
+		{Group: dev, Requirements: []Requirement{mustParse(t, "pytest==7.4.0"), mustParse(t, "mypy")}},
+	}
+	packages := []pip.Package{
+		{Name: "requests", InstalledVersion: "2.31.0"},
+		{Name: "pytest", InstalledVersion: "8.0.0"},
+		{Name: "ruff", InstalledVersion: "0.3.0"},
+		{Name: "sphinx", InstalledVersion: "7.2.6"},
+		{Name: "urllib3", InstalledVersion: "2.1.0"},
+	}
+	intents := Intents{
+		Added:   []Intent{{Spec: "ruff", Group: &dev}, {Spec: "sphinx", Group: &docs}},
+		Removed: []Intent{{Spec: "mypy", Group: &dev}},
+	}
+
+	tests := []struct {
+		mode     SyncMode
+		expected map[string][]string
+	}{
+		{SyncDeclared, map[string][]string{
+			"main":          {"requests==2.31.0"},
+			"group:dev":     {"pytest==8.0.0", "ruff==0.3.0"},
+			"optional:docs": {"sphinx==7.2.6"},
+		}},
+		{SyncAll, map[string][]string{
+			"main":          {"requests==2.31.0", "urllib3==2.1.0"},
+			"group:dev":     {"pytest==8.0.0", "ruff==0.3.0"},
+			"optional:docs": {"sphinx==7.2.6"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			got := groupRequirements(groups, packages, tt.mode, intents)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("groupRequirements() returned %d groups, want %d", len(got), len(tt.expected))
+			}
+			for _, g := range got {
+				want := tt.expected[g.String()]
+				if len(g.Requirements) != len(want) {
+					t.Errorf("%s = %v, want %v", g, g.Requirements, want)
+					continue
+				}
+				for i, r := range g.Requirements {
+					if r.String() != want[i] {
+						t.Errorf("%s[%d] = %q, want %q", g, i, r.String(), want[i])
+					}
+				}
+			}
+		})
+	}
+
+	// The input groups are not modified
+	if groups[1].Requirements[0].String() != "pytest==7.4.0" {
+		t.Errorf("groupRequirements() modified its input: %v", groups[1].Requirements)
+	}
+}
@@ -0,0 +1,35 @@
+package parser
+
+import toml "github.com/pelletier/go-toml/v2"
+
+// hatchData matches the Hatch environment tables of a pyproject.toml file.
+type hatchData struct {
+	Tool struct {
+		Hatch struct {
+			Envs map[string]struct {
+				Dependencies []string `toml:"dependencies"`
+			} `toml:"envs"`
+		} `toml:"hatch"`
+	} `toml:"tool"`
+}
+
+// ParseHatchGroups extracts every dependency group from the pyproject.toml
+// file of a Hatch project: the groups of [project] and [dependency-groups],
+// then the dependencies of each environment in [tool.hatch.envs], sorted by
+// name. Environments that declare no dependencies of their own are
+// included, so packages can be added to them.
+func ParseHatchGroups(content string) []DependencyGroup {
+	groups := ParsePyprojectGroups(content)
+	var data hatchData
+	if err := toml.Unmarshal([]byte(content), &data); err != nil || groups == nil {
+		return nil
+	}
+	envs := data.Tool.Hatch.Envs
+	for _, name := range sortedKeys(envs) {
+		groups = append(groups, DependencyGroup{
+			Group:        Group{Kind: GroupEnv, Name: name},
+			Requirements: parseEntries(envs[name].Dependencies),
+		})
+	}
+	return groups
+}
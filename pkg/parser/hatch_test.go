@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/pip"
+)
+
+const hatchPyproject = `[project]
+name = "demo"
+dependencies = ["requests>=2.31"]
+
+[tool.hatch.envs.default]
+dependencies = [
+    "pytest>=8",
+]
+
+[tool.hatch.envs.lint]
+detached = true
+
+[tool.hatch.envs.lint.scripts]
+check = "ruff check ."
+`
+
+func TestParseHatchGroups(t *testing.T) {
+	groups := ParseHatchGroups(hatchPyproject)
+	var got []string
+	for _, g := range groups {
+		var reqs []string
+		for _, r := range g.Requirements {
+			reqs = append(reqs, r.String())
+		}
+		got = append(got, g.String()+": "+strings.Join(reqs, ", "))
+	}
+	expected := []string{
+		"main: requests>=2.31",
+		"env:default: pytest>=8",
+		"env:lint: ",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("ParseHatchGroups() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestWriteDependencyFile_HatchEnvs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pyproject.toml")
+	if err := os.WriteFile(path, []byte(hatchPyproject), 0644); err != nil {
+		t.Fatal(err)
+	}
+	project := detector.Project{FilePath: path, FileType: detector.FilePyprojectTOML, Dir: dir, Tool: detector.ToolHatch}
+	lint := Group{Kind: GroupEnv, Name: "lint"}
+	intents := Intents{
+		Added:   []Intent{{Spec: "ruff>=0.4", Group: &lint}},
+		Removed: []Intent{{Spec: "pytest"}},
+	}
+	packages := []pip.Package{
+		{Name: "requests", InstalledVersion: "2.32.3"},
+		{Name: "ruff", InstalledVersion: "0.4.8"},
+	}
+	if err := WriteDependencyFile(project, packages, SyncDeclared, intents, nil); err != nil {
+		t.Fatalf("WriteDependencyFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[project]
+name = "demo"
+dependencies = ["requests>=2.31"]
+
+[tool.hatch.envs.default]
+dependencies = [
+]
+
+[tool.hatch.envs.lint]
+detached = true
+dependencies = [
+    # Generated by depman
+    "ruff>=0.4",
+]
+
+[tool.hatch.envs.lint.scripts]
+check = "ruff check ."
+`
+	if string(data) != expected {
+		t.Errorf("pyproject.toml after write =\n%s\nwant:\n%s", data, expected)
+	}
+}
@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/log"
+	"github.com/eslam/depman/pkg/store"
+)
+
+// Intents are the dependency changes the user explicitly asked for through
+// depman since the dependency file was last synced. In declared mode they
+// decide which installed packages are direct dependencies, and in which
+// group.
+type Intents struct {
+	Added   []Intent `json:"added,omitempty"`
+	Removed []Intent `json:"removed,omitempty"`
+}
+
+// Intent is a single recorded addition or removal.
+type Intent struct {
+	Spec string `json:"spec"` // requirement spec, e.g. "requests>=2"; the project name for removals
+	// Group is the group the change applies to. A nil group means the main
+	// group for additions and every group for removals.
+	Group *Group `json:"group,omitempty"`
+}
+
+// appliesTo reports whether the intent targets g.
+func (i Intent) appliesTo(g Group, removal bool) bool {
+	if i.Group == nil {
+		return removal || g.Kind == GroupMain
+	}
+	return i.Group.Is(g)
+}
+
+// ForGroup returns the intents that apply to g: additions to g, and
+// removals from g or from every group.
+func (in Intents) ForGroup(g Group) Intents {
+	var out Intents
+	for _, i := range in.Added {
+		if i.appliesTo(g, false) {
+			out.Added = append(out.Added, i)
+		}
+	}
+	for _, i := range in.Removed {
+		if i.appliesTo(g, true) {
+			out.Removed = append(out.Removed, i)
+		}
+	}
+	return out
+}
+
+// AddedGroups returns the distinct groups that additions target.
+func (in Intents) AddedGroups() []Group {
+	var groups []Group
+	for _, i := range in.Added {
+		g := MainGroup
+		if i.Group != nil {
+			g = *i.Group
+		}
+		seen := false
+		for _, other := range groups {
+			if other.Is(g) {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+const intentsFile = "intents.json"
+
+// LoadIntents reads the pending intents for the project in projectDir.
+// A project without recorded intents yields empty Intents.
+func LoadIntents(projectDir string) (Intents, error) {
+	var in Intents
+	path, err := intentsPath(projectDir)
+	if err != nil {
+		return in, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return in, nil
+	}
+	if err != nil {
+		return in, fmt.Errorf("parser: read intents: %w", err)
+	}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return in, fmt.Errorf("parser: parse intents: %w", err)
+	}
+	return in, nil
+}
+
+// RecordAdded records that the user explicitly installed spec into group.
+// A later add of the same project to the same group replaces the earlier
+// spec and cancels a pending removal from that group.
+func RecordAdded(projectDir, spec string, group Group) error {
+	r, err := ParseRequirement(spec)
+	if err != nil {
+		return err
+	}
+	return updateIntents(projectDir, func(in *Intents) {
+		in.Added = append(without(in.Added, r.Key(), &group), Intent{Spec: r.String(), Group: &group})
+		in.Removed = without(in.Removed, r.Key(), &group)
+	})
+}
+
+// RecordRemoved records that the user explicitly uninstalled name, which
+// removes it from every group.
+func RecordRemoved(projectDir, name string) error {
+	key := NormalizeName(name)
+	return updateIntents(projectDir, func(in *Intents) {
+		in.Added = without(in.Added, key, nil)
+		in.Removed = append(without(in.Removed, key, nil), Intent{Spec: name})
+	})
+}
+
+// RecordRemovedFrom records that the user removed name from group only.
+func RecordRemovedFrom(projectDir, name string, group Group) error {
+	key := NormalizeName(name)
+	return updateIntents(projectDir, func(in *Intents) {
+		in.Added = without(in.Added, key, &group)
+		in.Removed = append(without(in.Removed, key, &group), Intent{Spec: name, Group: &group})
+	})
+}
+
+// NoteAdded calls RecordAdded for a detected project. It is called once
+// the package is installed, so a failure is logged rather than returned.
+func NoteAdded(project detector.Project, spec string, group Group) {
+	if !project.Detected() {
+		return
+	}
+	if err := RecordAdded(project.Dir, spec, group); err != nil {
+		log.Warn("record added package", "package", spec, "group", group, "error", err)
+	}
+}
+
+// NoteRemoved calls RecordRemoved for a detected project, logging a failure.
+func NoteRemoved(project detector.Project, name string) {
+	if !project.Detected() {
+		return
+	}
+	if err := RecordRemoved(project.Dir, name); err != nil {
+		log.Warn("record removed package", "package", name, "error", err)
+	}
+}
+
+// NoteRemovedFrom calls RecordRemovedFrom for a detected project, logging a
+// failure.
+func NoteRemovedFrom(project detector.Project, name string, group Group) {
+	if !project.Detected() {
+		return
+	}
+	if err := RecordRemovedFrom(project.Dir, name, group); err != nil {
+		log.Warn("record removed package", "package", name, "group", group, "error", err)
+	}
+}
+
+// ClearIntents discards the pending intents once they have been written to
+// the dependency file.
+func ClearIntents(projectDir string) error {
+	path, err := intentsPath(projectDir)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("parser: clear intents: %w", err)
+	}
+	return nil
+}
+
+// SaveIntents replaces the pending intents, clearing them when in is empty.
+// It is used to restore them from a snapshot.
+func SaveIntents(projectDir string, in Intents) error {
+	if len(in.Added) == 0 && len(in.Removed) == 0 {
+		return ClearIntents(projectDir)
+	}
+	return updateIntents(projectDir, func(cur *Intents) { *cur = in })
+}
+
+func updateIntents(projectDir string, update func(*Intents)) error {
+	in, err := LoadIntents(projectDir)
+	if err != nil {
+		return err
+	}
+	update(&in)
+
+	path, err := intentsPath(projectDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("parser: create state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return fmt.Errorf("parser: encode intents: %w", err)
+	}
+	return atomicWrite(path, append(data, '\n'))
+}
+
+func intentsPath(projectDir string) (string, error) {
+	dir, err := store.ProjectDir(projectDir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, intentsFile), nil
+}
+
+// without returns intents minus any entry for the normalized name key in
+// group, or in any group if group is nil.
+func without(intents []Intent, key string, group *Group) []Intent {
+	var out []Intent
+	for _, i := range intents {
+		r, err := ParseRequirement(i.Spec)
+		if err == nil && r.Key() == key && (group == nil || i.Group != nil && i.Group.Is(*group)) {
+			continue
+		}
+		out = append(out, i)
+	}
+	return out
+}
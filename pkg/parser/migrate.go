@@ -0,0 +1,724 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/eslam/depman/pkg/detector"
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// MigrationTarget is the file format a migration converts a project to.
+type MigrationTarget string
+
+const (
+	// ToPyproject declares the dependencies in PEP 621 [project] and PEP
+	// 735 [dependency-groups] tables of pyproject.toml.
+	ToPyproject MigrationTarget = "pyproject"
+	// ToRequirements exports them to requirements.txt, and each other
+	// group to requirements-<group>.txt.
+	ToRequirements MigrationTarget = "requirements"
+)
+
+// ProjectMetadata is the core metadata a [project] table declares.
+type ProjectMetadata struct {
+	Name           string
+	Version        string // empty when DynamicVersion is set
+	Description    string
+	RequiresPython string
+	DynamicVersion bool   // the version is computed when building
+	VersionAttr    string // setup.cfg's "attr:" version source, e.g. "pkg.__version__"
+	VersionFile    string // setup.cfg's "file:" version source
+}
+
+// Migration is a project's dependency declarations converted to another
+// file format, ready to be written.
+type Migration struct {
+	Source string         // the dependency file migrated from
+	Files  []MigratedFile // the files to write
+	Notes  []string       // what could not be translated exactly
+}
+
+// MigratedFile is a file a Migration writes.
+type MigratedFile struct {
+	Path    string
+	Content string // the file's whole new content
+	New     bool   // the file does not exist yet
+}
+
+// Write writes the migrated files. The source file is left as it is,
+// unless it is one of them.
+func (m Migration) Write() error {
+	for _, f := range m.Files {
+		if err := atomicWrite(f.Path, []byte(f.Content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrationSource is what a migration reads from a project.
+type migrationSource struct {
+	meta        ProjectMetadata
+	groups      []DependencyGroup
+	notes       []string
+	buildSystem string // the [build-system] table of a new pyproject.toml, if any
+	guessedMeta bool   // meta was made up from the project's directory
+	// pyprojectNotes only matter when migrating to pyproject.toml
+	pyprojectNotes []string
+	// drop matches the tables and keys of the project's own pyproject.toml
+	// that the [project] and [dependency-groups] tables replace; nil when
+	// the project is not declared in pyproject.toml.
+	drop func(path string) bool
+}
+
+// MigrateProject converts the dependency declarations of project to
+// target. Constraints are converted to PEP 440, so Poetry's "^1.2" becomes
+// ">=1.2,<2.0" and a Pipfile's "*" no constraint at all. Dev groups become
+// PEP 735 dependency groups. Anything that has no equivalent in the target,
+// such as package indexes or editable installs, is dropped and reported in
+// the migration's notes. Nothing is written until Migration.Write.
+func MigrateProject(project detector.Project, target MigrationTarget) (Migration, error) {
+	if target != ToPyproject && target != ToRequirements {
+		return Migration{}, fmt.Errorf("parser: unknown migration target %q (want pyproject or requirements)", target)
+	}
+	var src migrationSource
+	var err error
+	switch project.FileType {
+	case detector.FileRequirementsTXT:
+		if target == ToRequirements {
+			return Migration{}, fmt.Errorf("parser: %s is already a requirements file", filepath.Base(project.FilePath))
+		}
+		src, err = readRequirementsSource(project)
+	case detector.FilePipfile:
+		src, err = readPipfileSource(project)
+	case detector.FileSetupCfg, detector.FileSetupPy:
+		src, err = readSetuptoolsSource(project)
+	case detector.FilePyprojectTOML:
+		src, err = readPyprojectSource(project)
+		if err == nil && src.drop == nil && target == ToPyproject {
+			return Migration{}, fmt.Errorf("parser: pyproject.toml already declares its dependencies in [project]")
+		}
+	case detector.FileEnvironmentYML:
+		return Migration{}, fmt.Errorf("parser: environment.yml lists conda packages, whose names and versions differ from PyPI's; it cannot be migrated")
+	default:
+		return Migration{}, fmt.Errorf("parser: no dependency file to migrate")
+	}
+	if err != nil {
+		return Migration{}, err
+	}
+
+	m := Migration{Source: project.FilePath, Notes: src.notes}
+	if target == ToPyproject {
+		err = migrateToPyproject(&m, project, src)
+	} else {
+		err = exportRequirements(&m, project, src)
+	}
+	if err != nil {
+		return Migration{}, err
+	}
+	return m, nil
+}
+
+// migrateToPyproject adds to m the pyproject.toml that declares src. The
+// [project] table is appended to an existing file, which must not have one
+// yet, unless the file is the source itself: then the tool tables src
+// replaces are removed, and an existing [project] table is updated.
+func migrateToPyproject(m *Migration, project detector.Project, src migrationSource) error {
+	file := MigratedFile{Path: filepath.Join(project.Dir, "pyproject.toml")}
+	m.Notes = append(m.Notes, src.pyprojectNotes...)
+	table := func() string {
+		if src.guessedMeta {
+			m.Notes = append(m.Notes, fmt.Sprintf("name %q and version %q are made up: check them", src.meta.Name, src.meta.Version))
+		}
+		return ProjectTable(src.meta, src.groups)
+	}
+	existing, err := os.ReadFile(file.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		file.New = true
+		file.Content = table()
+		if src.buildSystem != "" {
+			file.Content = src.buildSystem + "\n" + file.Content
+		}
+		m.Files = append(m.Files, file)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("parser: read file: %w", err)
+	}
+	doc, err := scanTOML(string(existing))
+	if err != nil {
+		return fmt.Errorf("parser: pyproject.toml: %w", err)
+	}
+
+	content := string(existing)
+	switch {
+	case src.drop != nil:
+		content = removeTOMLPaths(doc, src.drop)
+		if hasProjectTable(doc) {
+			if content, err = RewritePyprojectGroups(content, src.groups); err != nil {
+				return err
+			}
+			break
+		}
+		content = appendTOML(content, table())
+	case hasProjectTable(doc):
+		return fmt.Errorf("parser: pyproject.toml already has a [project] table")
+	default:
+		content = appendTOML(content, table())
+	}
+	file.Content = content
+	m.Files = append(m.Files, file)
+	return nil
+}
+
+// exportRequirements adds to m a requirements.txt file with the main
+// group, and a requirements-<group>.txt file for each other group. Extras
+// include requirements.txt, as they add to the main dependencies.
+func exportRequirements(m *Migration, project detector.Project, src migrationSource) error {
+	names := make(map[Group]string)
+	used := make(map[string]bool)
+	for _, g := range src.groups {
+		name := "requirements.txt"
+		if g.Kind != GroupMain {
+			name = "requirements-" + g.Name + ".txt"
+			if used[name] {
+				name = "requirements-" + g.Kind.String() + "-" + g.Name + ".txt"
+			}
+		}
+		names[g.Group], used[name] = name, true
+	}
+
+	source := filepath.Base(project.FilePath)
+	for _, g := range src.groups {
+		if g.Kind != GroupMain && len(g.Requirements) == 0 && len(g.Includes) == 0 {
+			continue
+		}
+		file := MigratedFile{Path: filepath.Join(project.Dir, names[g.Group]), New: true}
+		if _, err := os.Stat(file.Path); err == nil {
+			return fmt.Errorf("parser: %s already exists", names[g.Group])
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "# Exported from %s by depman\n", source)
+		if g.Kind == GroupOptional {
+			b.WriteString("-r requirements.txt\n")
+		}
+		for _, inc := range g.Includes {
+			b.WriteString("-r " + names[Group{Kind: GroupDependency, Name: inc}] + "\n")
+		}
+		for _, r := range g.Requirements {
+			b.WriteString(r.String() + "\n")
+		}
+		file.Content = b.String()
+		m.Files = append(m.Files, file)
+	}
+	if src.meta.RequiresPython != "" {
+		m.Notes = append(m.Notes, fmt.Sprintf("requires-python %q has no requirements.txt form", src.meta.RequiresPython))
+	}
+	return nil
+}
+
+// ProjectTable formats the PEP 621 [project] table that declares meta and
+// the main group, followed by [project.optional-dependencies] for extras
+// and [dependency-groups] for PEP 735 groups. A version setup.cfg reads
+// from an attribute or a file is kept dynamic with a
+// [tool.setuptools.dynamic] table.
+func ProjectTable(meta ProjectMetadata, groups []DependencyGroup) string {
+	var b strings.Builder
+	b.WriteString("[project]\n")
+	b.WriteString("name = " + tomlString(meta.Name) + "\n")
+	if meta.DynamicVersion {
+		b.WriteString("dynamic = [\"version\"]\n")
+	} else if meta.Version != "" {
+		b.WriteString("version = " + tomlString(meta.Version) + "\n")
+	}
+	if meta.Description != "" {
+		b.WriteString("description = " + tomlString(meta.Description) + "\n")
+	}
+	if meta.RequiresPython != "" {
+		b.WriteString("requires-python = " + tomlString(meta.RequiresPython) + "\n")
+	}
+
+	writeArray := func(key string, g DependencyGroup) {
+		if len(g.Requirements) == 0 && len(g.Includes) == 0 {
+			b.WriteString(key + " = []\n")
+			return
+		}
+		b.WriteString(key + " = [\n")
+		for _, inc := range g.Includes {
+			b.WriteString("    {include-group = " + tomlString(inc) + "},\n")
+		}
+		for _, r := range g.Requirements {
+			b.WriteString("    " + tomlString(r.String()) + ",\n")
+		}
+		b.WriteString("]\n")
+	}
+	var extras, depGroups []DependencyGroup
+	for _, g := range groups {
+		switch g.Kind {
+		case GroupMain:
+			writeArray("dependencies", g)
+		case GroupOptional:
+			extras = append(extras, g)
+		case GroupDependency:
+			depGroups = append(depGroups, g)
+		}
+	}
+	if len(extras) > 0 {
+		b.WriteString("\n[project.optional-dependencies]\n")
+		for _, g := range extras {
+			writeArray(tomlKey(g.Name), g)
+		}
+	}
+	if len(depGroups) > 0 {
+		b.WriteString("\n[dependency-groups]\n")
+		for _, g := range depGroups {
+			writeArray(tomlKey(g.Name), g)
+		}
+	}
+
+	switch {
+	case meta.VersionAttr != "":
+		b.WriteString("\n[tool.setuptools.dynamic]\nversion = {attr = " + tomlString(meta.VersionAttr) + "}\n")
+	case meta.VersionFile != "":
+		b.WriteString("\n[tool.setuptools.dynamic]\nversion = {file = " + tomlString(meta.VersionFile) + "}\n")
+	}
+	return b.String()
+}
+
+// readRequirementsSource reads the requirements file of project as the
+// main group. Other requirements files next to it, such as
+// requirements-dev.txt, dev-requirements.txt or requirements/test.txt,
+// become dependency groups; their -r includes of each other become
+// include-group entries.
+func readRequirementsSource(project detector.Project) (migrationSource, error) {
+	main, err := ReadRequirementsFile(project.FilePath)
+	if err != nil {
+		return migrationSource{}, err
+	}
+	src := migrationSource{meta: directoryMetadata(project.Dir), guessedMeta: true}
+	src.groups = []DependencyGroup{{Group: MainGroup, Requirements: main.Requirements()}}
+	src.notes = append(src.notes, requirementsNotes(main)...)
+
+	groupFiles := requirementsGroupFiles(project)
+	groupOf := make(map[string]string) // path → group name
+	for _, path := range groupFiles {
+		groupOf[path] = requirementsGroupName(path)
+	}
+	mainPath, _ := filepath.Abs(project.FilePath)
+	for _, path := range groupFiles {
+		f, err := ReadRequirementsFile(path)
+		if err != nil {
+			return migrationSource{}, err
+		}
+		g := DependencyGroup{Group: Group{Kind: GroupDependency, Name: groupOf[path]}}
+		for _, l := range f.Lines {
+			switch l.Kind {
+			case ReqLineRequirement:
+				g.Requirements = append(g.Requirements, l.Requirement)
+			case ReqLineInclude:
+				if l.File == nil {
+					continue
+				}
+				included, _ := filepath.Abs(l.File.Path)
+				switch {
+				case included == mainPath:
+					src.notes = append(src.notes, fmt.Sprintf("%s includes %s: dependency group %s does not include the main dependencies", filepath.Base(path), filepath.Base(project.FilePath), g.Name))
+				case groupOf[included] != "":
+					g.Includes = append(g.Includes, groupOf[included])
+				default:
+					g.Requirements = append(g.Requirements, l.File.Requirements()...)
+				}
+			}
+		}
+		src.groups = append(src.groups, g)
+		src.notes = append(src.notes, requirementsNotes(f)...)
+	}
+	return src, nil
+}
+
+// requirementsGroupFiles returns the absolute paths of the requirements
+// files next to the project's that declare other groups, sorted. Lock and
+// constraints files are not groups.
+func requirementsGroupFiles(project detector.Project) []string {
+	mainPath, _ := filepath.Abs(project.FilePath)
+	var patterns []string
+	if filepath.Base(filepath.Dir(mainPath)) == "requirements" {
+		patterns = append(patterns, filepath.Join(filepath.Dir(mainPath), "*.txt"))
+	}
+	for _, p := range []string{"requirements-*.txt", "requirements_*.txt", "*-requirements.txt", "*_requirements.txt"} {
+		patterns = append(patterns, filepath.Join(project.Dir, p))
+	}
+	seen := map[string]bool{mainPath: true}
+	var paths []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			name := requirementsGroupName(path)
+			if seen[path] || name == "" || strings.Contains(name, "lock") || strings.Contains(name, "constraint") {
+				continue
+			}
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// requirementsGroupName returns the group a requirements file declares:
+// "dev" for requirements-dev.txt, dev-requirements.txt or
+// requirements/dev.txt.
+func requirementsGroupName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), ".txt")
+	for _, affix := range []string{"requirements-", "requirements_"} {
+		name = strings.TrimPrefix(name, affix)
+	}
+	for _, affix := range []string{"-requirements", "_requirements"} {
+		name = strings.TrimSuffix(name, affix)
+	}
+	return NormalizeName(name)
+}
+
+// requirementsNotes reports the lines of a requirements file, not those
+// of the files it includes, that have no pyproject.toml form.
+func requirementsNotes(f *RequirementsFile) []string {
+	var notes []string
+	name := filepath.Base(f.Path)
+	hashes := false
+	for _, l := range f.Lines {
+		switch l.Kind {
+		case ReqLineEditable:
+			notes = append(notes, fmt.Sprintf("%s:%d: editable install %q dropped; install it with pip install -e", name, l.Number, l.Value))
+		case ReqLineConstraint:
+			notes = append(notes, fmt.Sprintf("%s:%d: constraints file %q dropped", name, l.Number, l.Value))
+		case ReqLineOption:
+			notes = append(notes, fmt.Sprintf("%s:%d: option %q dropped; configure it in your package manager", name, l.Number, l.Text))
+		case ReqLineInvalid:
+			notes = append(notes, fmt.Sprintf("%s:%d: %q is not a requirement", name, l.Number, l.Text))
+		case ReqLineRequirement:
+			hashes = hashes || len(l.Hashes()) > 0
+		}
+	}
+	if hashes {
+		notes = append(notes, fmt.Sprintf("%s: --hash options dropped; lock files record hashes", name))
+	}
+	return notes
+}
+
+// pipfileSourceData matches the tables of a Pipfile a migration reads.
+type pipfileSourceData struct {
+	Source []struct {
+		Name string `toml:"name"`
+		URL  string `toml:"url"`
+	} `toml:"source"`
+	Requires struct {
+		PythonVersion     string `toml:"python_version"`
+		PythonFullVersion string `toml:"python_full_version"`
+	} `toml:"requires"`
+	Packages    map[string]any `toml:"packages"`
+	DevPackages map[string]any `toml:"dev-packages"`
+}
+
+// readPipfileSource reads a Pipfile: [packages] as the main group and
+// [dev-packages] as the dev dependency group. [requires] gives
+// requires-python.
+func readPipfileSource(project detector.Project) (migrationSource, error) {
+	content, err := os.ReadFile(project.FilePath)
+	if err != nil {
+		return migrationSource{}, fmt.Errorf("parser: read file: %w", err)
+	}
+	var data pipfileSourceData
+	if err := toml.Unmarshal(content, &data); err != nil {
+		return migrationSource{}, fmt.Errorf("parser: Pipfile: %w", err)
+	}
+	src := migrationSource{meta: directoryMetadata(project.Dir), guessedMeta: true, groups: ParsePipfile(string(content))}
+	switch {
+	case data.Requires.PythonFullVersion != "":
+		src.meta.RequiresPython = "==" + data.Requires.PythonFullVersion
+	case data.Requires.PythonVersion != "":
+		src.meta.RequiresPython = ">=" + data.Requires.PythonVersion
+		src.notes = append(src.notes, fmt.Sprintf("[requires] python_version %q became requires-python %q", data.Requires.PythonVersion, src.meta.RequiresPython))
+	}
+	for _, s := range data.Source {
+		if !strings.Contains(s.URL, "pypi.org") {
+			src.notes = append(src.notes, fmt.Sprintf("[[source]] %s (%s) dropped; configure the index in your package manager", s.Name, s.URL))
+		}
+	}
+	src.notes = append(src.notes, pipfileNotes("[packages]", data.Packages)...)
+	src.notes = append(src.notes, pipfileNotes("[dev-packages]", data.DevPackages)...)
+	return src, nil
+}
+
+// pipfileNotes reports the entries of a Pipfile table, and the keys of
+// their tables, that have no PEP 508 form.
+func pipfileNotes(table string, packages map[string]any) []string {
+	var notes []string
+	for _, name := range sortedKeys(packages) {
+		if _, err := parsePipfilePackage(name, packages[name]); err != nil {
+			notes = append(notes, fmt.Sprintf("%s %s dropped: %v", table, name, err))
+			continue
+		}
+		v, _ := packages[name].(map[string]any)
+		for _, key := range sortedKeys(v) {
+			switch key {
+			case "version", "extras", "markers", "git", "ref", "file":
+			case "path":
+				notes = append(notes, pathNote(table, name, v[key]))
+			case "editable":
+				notes = append(notes, fmt.Sprintf("%s %s: editable install dropped", table, name))
+			case "index":
+				notes = append(notes, fmt.Sprintf("%s %s: index %v dropped; configure it in your package manager", table, name, v[key]))
+			default:
+				notes = append(notes, fmt.Sprintf("%s %s: %s = %v dropped; add it to the marker by hand", table, name, key, v[key]))
+			}
+		}
+	}
+	return notes
+}
+
+// pyprojectSourceData matches the tables of a pyproject.toml file a
+// migration reads.
+type pyprojectSourceData struct {
+	Project struct {
+		Name           string `toml:"name"`
+		Version        string `toml:"version"`
+		Description    string `toml:"description"`
+		RequiresPython string `toml:"requires-python"`
+	} `toml:"project"`
+	BuildSystem struct {
+		BuildBackend string `toml:"build-backend"`
+	} `toml:"build-system"`
+	Tool struct {
+		Poetry struct {
+			Name            string         `toml:"name"`
+			Version         string         `toml:"version"`
+			Description     string         `toml:"description"`
+			Dependencies    map[string]any `toml:"dependencies"`
+			DevDependencies map[string]any `toml:"dev-dependencies"`
+			Group           map[string]struct {
+				Dependencies map[string]any `toml:"dependencies"`
+			} `toml:"group"`
+			Extras map[string][]string `toml:"extras"`
+		} `toml:"poetry"`
+		PDM struct {
+			DevDependencies map[string][]string `toml:"dev-dependencies"`
+		} `toml:"pdm"`
+	} `toml:"tool"`
+}
+
+// readPyprojectSource reads the groups of a pyproject.toml file. Those of a
+// Poetry project's dependency tables and of PDM's dev-dependencies are
+// the ones to migrate; other projects already use the standard tables.
+func readPyprojectSource(project detector.Project) (migrationSource, error) {
+	content, err := os.ReadFile(project.FilePath)
+	if err != nil {
+		return migrationSource{}, fmt.Errorf("parser: read file: %w", err)
+	}
+	var data pyprojectSourceData
+	if err := toml.Unmarshal(content, &data); err != nil {
+		return migrationSource{}, fmt.Errorf("parser: pyproject.toml: %w", err)
+	}
+	meta := data.Project
+	src := migrationSource{
+		meta:   ProjectMetadata{Name: meta.Name, Version: meta.Version, Description: meta.Description, RequiresPython: meta.RequiresPython},
+		groups: parseProjectGroups(project.Tool, string(content)),
+	}
+	switch project.Tool {
+	case detector.ToolPoetry:
+		poetry := data.Tool.Poetry
+		src.meta = mergeMetadata(src.meta, ProjectMetadata{Name: poetry.Name, Version: poetry.Version, Description: poetry.Description})
+		if python, ok := poetry.Dependencies["python"].(string); ok && src.meta.RequiresPython == "" {
+			specs, err := ParsePoetryConstraint(python)
+			if err != nil || strings.Contains(python, "||") {
+				src.notes = append(src.notes, fmt.Sprintf("python %q has no requires-python form", python))
+			} else {
+				src.meta.RequiresPython = specs.String()
+			}
+		}
+		src.notes = append(src.notes, poetryNotes("[tool.poetry.dependencies]", poetry.Dependencies)...)
+		for _, name := range sortedKeys(poetry.Group) {
+			src.notes = append(src.notes, poetryNotes("[tool.poetry.group."+name+".dependencies]", poetry.Group[name].Dependencies)...)
+		}
+		src.notes = append(src.notes, poetryNotes("[tool.poetry.dev-dependencies]", poetry.DevDependencies)...)
+		src.groups = poetryExtras(src.groups, poetry.Dependencies, poetry.Extras)
+		if strings.HasPrefix(data.BuildSystem.BuildBackend, "poetry.core") {
+			src.pyprojectNotes = append(src.pyprojectNotes, "[build-system] poetry-core reads [project] from version 2.0; require poetry-core>=2")
+		}
+		src.drop = func(path string) bool {
+			switch path {
+			case "tool.poetry.dependencies", "tool.poetry.dev-dependencies", "tool.poetry.group", "tool.poetry.extras":
+				return true
+			}
+			return strings.HasPrefix(path, "tool.poetry.group.")
+		}
+	case detector.ToolPDM:
+		for _, name := range sortedKeys(data.Tool.PDM.DevDependencies) {
+			for _, entry := range data.Tool.PDM.DevDependencies[name] {
+				if _, err := ParseRequirement(entry); err != nil {
+					src.notes = append(src.notes, fmt.Sprintf("[tool.pdm.dev-dependencies] %s: %q dropped; it is not a requirement", name, entry))
+				}
+			}
+		}
+		if len(data.Tool.PDM.DevDependencies) > 0 {
+			src.drop = func(path string) bool {
+				return path == "tool.pdm.dev-dependencies" || strings.HasPrefix(path, "tool.pdm.dev-dependencies.")
+			}
+		}
+	}
+	if src.drop == nil {
+		if doc, err := scanTOML(string(content)); err == nil && !hasProjectTable(doc) {
+			return migrationSource{}, fmt.Errorf("parser: pyproject.toml declares no dependencies to migrate")
+		}
+	}
+	if src.meta.Name == "" {
+		src.meta, src.guessedMeta = mergeMetadata(src.meta, directoryMetadata(project.Dir)), true
+	}
+	return src, nil
+}
+
+// poetryNotes reports the entries of a Poetry dependency table, and the
+// keys of their tables, that have no PEP 508 form.
+func poetryNotes(table string, deps map[string]any) []string {
+	var notes []string
+	for _, name := range sortedKeys(deps) {
+		if strings.EqualFold(name, "python") {
+			continue
+		}
+		value := deps[name]
+		if _, err := parsePoetryDependency(name, value); err != nil {
+			notes = append(notes, fmt.Sprintf("%s %s dropped: %v", table, name, err))
+			continue
+		}
+		if list, ok := value.([]any); ok {
+			notes = append(notes, fmt.Sprintf("%s %s: only the first of %d per-environment constraints kept", table, name, len(list)))
+			value = list[0]
+		}
+		constraint, _ := value.(string)
+		v, _ := value.(map[string]any)
+		if c, ok := v["version"].(string); ok {
+			constraint = c
+		}
+		if strings.Contains(constraint, "||") {
+			notes = append(notes, fmt.Sprintf("%s %s: constraint %q has no PEP 440 form; left unconstrained", table, name, constraint))
+		}
+		for _, key := range sortedKeys(v) {
+			switch key {
+			case "version", "extras", "markers", "git", "rev", "tag", "branch", "url", "optional":
+			case "path":
+				notes = append(notes, pathNote(table, name, v[key]))
+			case "develop":
+				notes = append(notes, fmt.Sprintf("%s %s: editable install dropped", table, name))
+			case "source":
+				notes = append(notes, fmt.Sprintf("%s %s: source %v dropped; configure the index in your package manager", table, name, v[key]))
+			default:
+				notes = append(notes, fmt.Sprintf("%s %s: %s = %v dropped; add it to the marker by hand", table, name, key, v[key]))
+			}
+		}
+	}
+	return notes
+}
+
+// pathNote reports a dependency on a local directory, which PEP 508 can
+// only refer to with an absolute file:// URL.
+func pathNote(table, name string, path any) string {
+	return fmt.Sprintf("%s %s: path %v written as a file: URL; PEP 508 needs an absolute file:// URL", table, name, path)
+}
+
+// poetryExtras moves the optional dependencies of the main group, which
+// Poetry only installs for the [tool.poetry.extras] that list them, to an
+// optional group per extra.
+func poetryExtras(groups []DependencyGroup, deps map[string]any, extras map[string][]string) []DependencyGroup {
+	optional := make(map[string]Requirement)
+	for name, value := range deps {
+		if v, ok := value.(map[string]any); ok && v["optional"] == true {
+			optional[NormalizeName(name)] = Requirement{}
+		}
+	}
+	if len(optional) == 0 {
+		return groups
+	}
+	main := FindGroup(groups, MainGroup)
+	var kept []Requirement
+	for _, r := range groups[main].Requirements {
+		if _, ok := optional[r.Key()]; ok {
+			optional[r.Key()] = r
+			continue
+		}
+		kept = append(kept, r)
+	}
+	groups[main].Requirements = kept
+	for _, extra := range sortedKeys(extras) {
+		var reqs []Requirement
+		for _, name := range extras[extra] {
+			if r, ok := optional[NormalizeName(name)]; ok && r.Name != "" {
+				reqs = append(reqs, r)
+			}
+		}
+		groups = mergeGroup(groups, Group{Kind: GroupOptional, Name: extra}, reqs)
+	}
+	return groups
+}
+
+// directoryMetadata returns metadata for a project that declares none: a
+// name made from its directory and version 0.1.0.
+func directoryMetadata(dir string) ProjectMetadata {
+	name := strings.Trim(invalidNameChars.ReplaceAllString(filepath.Base(dir), "-"), "-._")
+	if name == "" {
+		name = "project"
+	}
+	return ProjectMetadata{Name: NormalizeName(name), Version: "0.1.0"}
+}
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// hasProjectTable reports whether doc declares a [project] table, with a
+// header or dotted keys.
+func hasProjectTable(doc *tomlDocument) bool {
+	if _, ok := doc.tables["project"]; ok {
+		return true
+	}
+	for path := range doc.keys {
+		if path == "project" || strings.HasPrefix(path, "project.") {
+			return true
+		}
+	}
+	return false
+}
+
+// removeTOMLPaths removes from the document the tables whose path drop
+// matches, with the blank lines after them, and the keys of other tables
+// whose full path it matches.
+func removeTOMLPaths(doc *tomlDocument, drop func(path string) bool) string {
+	var edits []textEdit
+	for path, tbl := range doc.tables {
+		if path == "" || !drop(path) {
+			continue
+		}
+		end := tbl.end
+		for end < len(doc.src) && (doc.src[end] == '\n' || strings.HasPrefix(doc.src[end:], "\r\n")) {
+			end = nextLine(doc.src, end)
+		}
+		edits = append(edits, textEdit{start: tbl.start, end: end})
+	}
+	for _, e := range doc.entries {
+		if !drop(e.table) && drop(joinKey(e.table, e.key)) {
+			edits = append(edits, textEdit{start: e.start, end: e.end})
+		}
+	}
+	return applyEdits(doc.src, edits)
+}
+
+// appendTOML appends the tables of text to content after a blank line.
+func appendTOML(content, text string) string {
+	nl := newline(content)
+	if strings.TrimSpace(content) == "" {
+		return strings.ReplaceAll(text, "\n", nl)
+	}
+	return strings.TrimRight(content, "\r\n") + nl + nl + strings.ReplaceAll(text, "\n", nl)
+}
@@ -0,0 +1,208 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eslam/depman/pkg/detector"
+)
+
+// writeProject writes files into a new project directory and detects it.
+func writeProject(t *testing.T, files map[string]string) detector.Project {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return detector.DetectProject(dir)
+}
+
+// migratedFiles formats the files of m by base name, for comparisons.
+func migratedFiles(m Migration) string {
+	var b strings.Builder
+	for _, f := range m.Files {
+		b.WriteString("==> " + filepath.Base(f.Path) + "\n" + f.Content)
+	}
+	return b.String()
+}
+
+func TestMigratePoetry(t *testing.T) {
+	project := writeProject(t, map[string]string{"pyproject.toml": `[tool.poetry]
+name = "app"
+version = "1.2.0"
+
+[tool.poetry.dependencies]
+python = "^3.10"
+requests = "^2.31"
+rich = { version = "*", optional = true }
+either = "^1 || ^2"
+
+[tool.poetry.group.dev.dependencies]
+pytest = "~7.4"
+
+[tool.poetry.extras]
+fancy = ["rich"]
+
+[tool.black]
+line-length = 100
+`})
+
+	m, err := MigrateProject(project, ToPyproject)
+	if err != nil {
+		t.Fatalf("MigrateProject() error = %v", err)
+	}
+	expected := `==> pyproject.toml
+[tool.poetry]
+name = "app"
+version = "1.2.0"
+
+[tool.black]
+line-length = 100
+
+[project]
+name = "app"
+version = "1.2.0"
+requires-python = ">=3.10,<4.0"
+dependencies = [
+    "either",
+    "requests>=2.31,<3.0",
+]
+
+[project.optional-dependencies]
+fancy = [
+    "rich",
+]
+
+[dependency-groups]
+dev = [
+    "pytest>=7.4,<7.5",
+]
+`
+	if got := migratedFiles(m); got != expected {
+		t.Errorf("MigrateProject(pyproject) =\n%s\nwant:\n%s", got, expected)
+	}
+	if len(m.Notes) != 1 || !strings.Contains(m.Notes[0], `"^1 || ^2"`) {
+		t.Errorf("MigrateProject(pyproject) notes = %q", m.Notes)
+	}
+
+	m, err = MigrateProject(project, ToRequirements)
+	if err != nil {
+		t.Fatalf("MigrateProject(requirements) error = %v", err)
+	}
+	expected = `==> requirements.txt
+# Exported from pyproject.toml by depman
+either
+requests>=2.31,<3.0
+==> requirements-dev.txt
+# Exported from pyproject.toml by depman
+pytest>=7.4,<7.5
+==> requirements-fancy.txt
+# Exported from pyproject.toml by depman
+-r requirements.txt
+rich
+`
+	if got := migratedFiles(m); got != expected {
+		t.Errorf("MigrateProject(requirements) =\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestMigratePipfile(t *testing.T) {
+	project := writeProject(t, map[string]string{"Pipfile": `[[source]]
+name = "internal"
+url = "https://pkgs.example.com/simple"
+
+[packages]
+requests = "*"
+flask = {version = ">=2", extras = ["async"], index = "internal"}
+
+[dev-packages]
+pytest = "==8.0.0"
+
+[requires]
+python_full_version = "3.11.4"
+`})
+
+	m, err := MigrateProject(project, ToPyproject)
+	if err != nil {
+		t.Fatalf("MigrateProject() error = %v", err)
+	}
+	name := filepath.Base(project.Dir)
+	expected := `==> pyproject.toml
+[project]
+name = "` + NormalizeName(name) + `"
+version = "0.1.0"
+requires-python = "==3.11.4"
+dependencies = [
+    "flask[async]>=2",
+    "requests",
+]
+
+[dependency-groups]
+dev = [
+    "pytest==8.0.0",
+]
+`
+	if got := migratedFiles(m); got != expected {
+		t.Errorf("MigrateProject() =\n%s\nwant:\n%s", got, expected)
+	}
+	notes := strings.Join(m.Notes, "\n")
+	for _, want := range []string{"[[source]] internal", "flask: index internal", "made up"} {
+		if !strings.Contains(notes, want) {
+			t.Errorf("MigrateProject() notes = %q, want one about %q", m.Notes, want)
+		}
+	}
+}
+
+func TestMigrateRequirements(t *testing.T) {
+	project := writeProject(t, map[string]string{
+		"requirements.txt":      "--index-url https://example.com/simple\nrequests>=2\n-e ./lib\n",
+		"requirements-dev.txt":  "-r requirements-test.txt\nblack\n",
+		"requirements-test.txt": "pytest\n",
+		"requirements-lock.txt": "requests==2.31.0\n",
+	})
+
+	m, err := MigrateProject(project, ToPyproject)
+	if err != nil {
+		t.Fatalf("MigrateProject() error = %v", err)
+	}
+	content := m.Files[0].Content
+	expected := `[project]
+name = "` + NormalizeName(filepath.Base(project.Dir)) + `"
+version = "0.1.0"
+dependencies = [
+    "requests>=2",
+]
+
+[dependency-groups]
+dev = [
+    {include-group = "test"},
+    "black",
+]
+test = [
+    "pytest",
+]
+`
+	if content != expected {
+		t.Errorf("MigrateProject() =\n%s\nwant:\n%s", content, expected)
+	}
+	if len(m.Notes) != 3 {
+		t.Errorf("MigrateProject() notes = %q, want the index, the editable install and the name", m.Notes)
+	}
+
+	if _, err := MigrateProject(project, ToRequirements); err == nil {
+		t.Error("MigrateProject(requirements to requirements): expected an error")
+	}
+
+	tools := writeProject(t, map[string]string{"pyproject.toml": "[tool.ruff]\nline-length = 100\n"})
+	if _, err := MigrateProject(tools, ToRequirements); err == nil {
+		t.Error("MigrateProject(no dependencies): expected an error")
+	}
+}
@@ -0,0 +1,31 @@
+package parser
+
+import toml "github.com/pelletier/go-toml/v2"
+
+// pdmData matches the PDM tables of a pyproject.toml file.
+type pdmData struct {
+	Tool struct {
+		PDM struct {
+			DevDependencies map[string][]string `toml:"dev-dependencies"`
+		} `toml:"pdm"`
+	} `toml:"tool"`
+}
+
+// ParsePDMGroups extracts every dependency group from the pyproject.toml
+// file of a PDM project: the groups of [project] and [dependency-groups],
+// merged with each [tool.pdm.dev-dependencies] list in a dependency group
+// of that name, as PDM itself treats them. Entries that are not valid PEP
+// 508 requirements, such as editable installs ("-e file:///..."), are
+// skipped.
+func ParsePDMGroups(content string) []DependencyGroup {
+	groups := ParsePyprojectGroups(content)
+	var data pdmData
+	if err := toml.Unmarshal([]byte(content), &data); err != nil || groups == nil {
+		return nil
+	}
+	dev := data.Tool.PDM.DevDependencies
+	for _, name := range sortedKeys(dev) {
+		groups = mergeGroup(groups, Group{Kind: GroupDependency, Name: name}, parseEntries(dev[name]))
+	}
+	return groups
+}
@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePDMGroups(t *testing.T) {
+	content := `[project]
+name = "demo"
+dependencies = ["requests>=2.31"]
+
+[project.optional-dependencies]
+cli = ["rich>=13"]
+
+[tool.pdm]
+distribution = true
+
+[tool.pdm.dev-dependencies]
+test = ["pytest>=8", "-e file:///${PROJECT_ROOT}/libs/helper"]
+lint = ["ruff"]
+
+[dependency-groups]
+lint = ["mypy"]
+`
+	var got []string
+	for _, g := range ParsePDMGroups(content) {
+		var reqs []string
+		for _, r := range g.Requirements {
+			reqs = append(reqs, r.String())
+		}
+		got = append(got, g.String()+": "+strings.Join(reqs, ", "))
+	}
+	expected := []string{
+		"main: requests>=2.31",
+		"optional:cli: rich>=13",
+		"group:lint: mypy, ruff",
+		"group:test: pytest>=8",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("ParsePDMGroups() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	if groups := ParsePDMGroups("[tool.pdm"); groups != nil {
+		t.Errorf("ParsePDMGroups(invalid) = %+v, want nil", groups)
+	}
+}
@@ -0,0 +1,300 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/eslam/depman/pkg/pyname"
+	"github.com/eslam/depman/pkg/version"
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// PipfileDevGroup is the group of a Pipfile's [dev-packages] table. The
+// main group is its [packages] table.
+var PipfileDevGroup = Group{Kind: GroupDependency, Name: "dev"}
+
+// pipfileTable returns the Pipfile table that holds g.
+func pipfileTable(g Group) (string, bool) {
+	switch {
+	case g.Kind == GroupMain:
+		return "packages", true
+	case g.Is(PipfileDevGroup):
+		return "dev-packages", true
+	}
+	return "", false
+}
+
+// pipfileData matches the package tables of a Pipfile.
+type pipfileData struct {
+	Packages    map[string]any `toml:"packages"`
+	DevPackages map[string]any `toml:"dev-packages"`
+}
+
+// ParsePipfile extracts the dependency groups of a Pipfile: [packages] as
+// the main group, then [dev-packages], if present, as the "dev" dependency
+// group, each sorted by name. Entries that cannot be converted to a PEP
+// 508 requirement are skipped. It returns nil if content is not valid
+// TOML.
+func ParsePipfile(content string) []DependencyGroup {
+	var data pipfileData
+	if err := toml.Unmarshal([]byte(content), &data); err != nil {
+		return nil
+	}
+	groups := []DependencyGroup{{Group: MainGroup, Requirements: parsePipfilePackages(data.Packages)}}
+	if data.DevPackages != nil {
+		groups = append(groups, DependencyGroup{Group: PipfileDevGroup, Requirements: parsePipfilePackages(data.DevPackages)})
+	}
+	return groups
+}
+
+func parsePipfilePackages(packages map[string]any) []Requirement {
+	var reqs []Requirement
+	for _, name := range sortedKeys(packages) {
+		r, err := parsePipfilePackage(name, packages[name])
+		if err != nil {
+			continue
+		}
+		reqs = append(reqs, r)
+	}
+	return reqs
+}
+
+// parsePipfilePackage converts one Pipfile entry, which is either a version
+// specifier such as "==2.31.0" or "*", or a table such as {version = ">=2",
+// extras = ["socks"], markers = "..."} or {git = "...", ref = "v1.0"}.
+func parsePipfilePackage(name string, value any) (Requirement, error) {
+	if pyname.FromSpec(name) != name {
+		return Requirement{}, fmt.Errorf("parser: Pipfile package %q: invalid name", name)
+	}
+	r := Requirement{Name: name}
+	var spec string
+	switch v := value.(type) {
+	case string:
+		spec = v
+	case map[string]any:
+		spec, _ = v["version"].(string)
+		r.Extras = anyStrings(v["extras"])
+		r.Marker, _ = v["markers"].(string)
+		switch {
+		case v["git"] != nil:
+			r.URL = "git+" + fmt.Sprint(v["git"])
+			if ref, ok := v["ref"].(string); ok {
+				r.URL += "@" + ref
+			}
+		case v["file"] != nil:
+			r.URL = fmt.Sprint(v["file"])
+		case v["path"] != nil:
+			r.URL = "file:" + fmt.Sprint(v["path"])
+		}
+	default:
+		return Requirement{}, fmt.Errorf("parser: Pipfile package %q: unexpected %T", name, value)
+	}
+	if r.URL != "" || spec == "*" {
+		return r, nil
+	}
+	specs, err := version.ParseSpecifierSet(spec)
+	if err != nil {
+		return Requirement{}, fmt.Errorf("parser: Pipfile package %q: %w", name, err)
+	}
+	r.Specifiers = specs
+	return r, nil
+}
+
+// RewritePipfile updates the [packages] and [dev-packages] tables of a
+// Pipfile to match groups with the smallest possible edit: entries whose
+// requirement is unchanged are kept byte-for-byte, a changed version keeps
+// the entry's quoting and any other keys of its table, removed entries
+// lose their line, and new ones are appended to the table, which is
+// created at the end of the file if needed. Comments, [source] and
+// [requires] are preserved.
+func RewritePipfile(content string, groups []DependencyGroup) (string, error) {
+	doc, err := scanTOML(content)
+	if err != nil {
+		return "", fmt.Errorf("parser: Pipfile: %w", err)
+	}
+
+	var edits []textEdit
+	var missing []string
+	for _, g := range groups {
+		table, ok := pipfileTable(g.Group)
+		if !ok {
+			return "", fmt.Errorf("parser: Pipfile: no table for group %s (want main or %s)", g.Group, PipfileDevGroup)
+		}
+		tableEdits, added := pipfileTableEdits(doc, table, g.Requirements)
+		edits = append(edits, tableEdits...)
+		if added == "" {
+			continue
+		}
+		if tbl, ok := doc.tables[table]; ok {
+			edits = append(edits, textEdit{start: tbl.end, end: tbl.end, text: added})
+		} else {
+			missing = append(missing, "["+table+"]"+newline(content)+added)
+		}
+	}
+	content = applyEdits(content, edits)
+
+	for _, table := range missing {
+		if content != "" {
+			if !strings.HasSuffix(content, "\n") {
+				content += newline(content)
+			}
+			content += newline(content)
+		}
+		content += table
+	}
+	return content, nil
+}
+
+// pipfileTableEdits returns the edits that turn the entries of table into
+// reqs, and the lines of the requirements that match no entry.
+func pipfileTableEdits(doc *tomlDocument, table string, reqs []Requirement) ([]textEdit, string) {
+	pending := make(map[string]Requirement, len(reqs))
+	for _, r := range reqs {
+		pending[r.Key()] = r
+	}
+
+	var edits []textEdit
+	for _, e := range doc.entries {
+		if e.table != table {
+			continue
+		}
+		old, err := pipfileEntryRequirement(doc.src, e)
+		if err != nil {
+			continue
+		}
+		want, ok := pending[old.Key()]
+		if !ok {
+			edits = append(edits, textEdit{start: e.start, end: e.end})
+			continue
+		}
+		delete(pending, old.Key())
+		if want.String() != old.String() {
+			edits = append(edits, pipfileUpdate(doc, e, old, want))
+		}
+	}
+
+	var added strings.Builder
+	for _, r := range reqs {
+		if _, ok := pending[r.Key()]; ok {
+			added.WriteString(tomlKey(r.Name) + " = " + pipfileValue(r) + newline(doc.src))
+		}
+	}
+	return edits, added.String()
+}
+
+// pipfileEntryRequirement decodes the entry e of a package table.
+func pipfileEntryRequirement(src string, e tomlEntry) (Requirement, error) {
+	var v struct{ V any }
+	if err := toml.Unmarshal([]byte("V = "+src[e.value.start:e.value.end]), &v); err != nil {
+		return Requirement{}, fmt.Errorf("parser: Pipfile package %q: %w", e.key, err)
+	}
+	return parsePipfilePackage(e.key, v.V)
+}
+
+// pipfileUpdate returns the edit that turns entry e from old into want.
+// When only the version changed, just the version string is replaced.
+func pipfileUpdate(doc *tomlDocument, e tomlEntry, old, want Requirement) textEdit {
+	respecified := old
+	respecified.Specifiers = want.Specifiers
+	if respecified.String() == want.String() {
+		item, ok := e.value, e.value.str
+		if !ok {
+			item, ok = doc.strs[joinKey(joinKey(e.table, e.key), "version")]
+		}
+		if ok {
+			return textEdit{start: item.start, end: item.end, text: quoteLike(item, pipfileVersion(want))}
+		}
+	}
+	return textEdit{start: e.value.start, end: e.value.end, text: pipfileValue(want)}
+}
+
+// pipfileVersion returns the version specifier of r as a Pipfile writes
+// it, with "*" for any version.
+func pipfileVersion(r Requirement) string {
+	if spec := r.SpecifierString(); spec != "" {
+		return spec
+	}
+	return "*"
+}
+
+// pipfileValue formats r as the value of a Pipfile entry: a version
+// specifier, or an inline table when r has extras, a marker or a URL.
+func pipfileValue(r Requirement) string {
+	if len(r.Extras) == 0 && r.Marker == "" && r.URL == "" {
+		return tomlString(pipfileVersion(r))
+	}
+	var fields []string
+	switch {
+	case strings.HasPrefix(r.URL, "git+"):
+		repo, ref := strings.TrimPrefix(r.URL, "git+"), ""
+		// A ref follows the last "@", unless that is the user of an ssh URL
+		if i := strings.LastIndexByte(repo, '@'); i >= 0 && !strings.ContainsAny(repo[i:], "/:") {
+			repo, ref = repo[:i], repo[i+1:]
+		}
+		fields = append(fields, "git = "+tomlString(repo))
+		if ref != "" {
+			fields = append(fields, "ref = "+tomlString(ref))
+		}
+	case strings.HasPrefix(r.URL, "file:") && !strings.HasPrefix(r.URL, "file://"):
+		fields = append(fields, "path = "+tomlString(strings.TrimPrefix(r.URL, "file:")))
+	case r.URL != "":
+		fields = append(fields, "file = "+tomlString(r.URL))
+	default:
+		fields = append(fields, "version = "+tomlString(pipfileVersion(r)))
+	}
+	if len(r.Extras) > 0 {
+		extras := make([]string, len(r.Extras))
+		for i, e := range r.Extras {
+			extras[i] = tomlString(e)
+		}
+		fields = append(fields, "extras = ["+strings.Join(extras, ", ")+"]")
+	}
+	if r.Marker != "" {
+		fields = append(fields, "markers = "+tomlString(r.Marker))
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// PipfileLock is the part of a Pipfile.lock that pins packages: the
+// [packages] of the Pipfile under "default", and [dev-packages] under
+// "develop".
+type PipfileLock struct {
+	Default map[string]LockedPackage `json:"default"`
+	Develop map[string]LockedPackage `json:"develop"`
+}
+
+// LockedPackage is one package pinned in a Pipfile.lock.
+type LockedPackage struct {
+	Version string   `json:"version"` // an exact pin, e.g. "==2.31.0"; empty for VCS and path entries
+	Hashes  []string `json:"hashes"`  // e.g. "sha256:..."
+}
+
+// ReadPipfileLock parses the Pipfile.lock at path.
+func ReadPipfileLock(path string) (PipfileLock, error) {
+	var lock PipfileLock
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lock, fmt.Errorf("parser: read Pipfile.lock: %w", err)
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return lock, fmt.Errorf("parser: parse Pipfile.lock: %w", err)
+	}
+	return lock, nil
+}
+
+// Hashes returns the hashes the lock records for name at version, or nil
+// if it pins name to another version or not at all, since the hashes of
+// another version say nothing about what is installed.
+func (l PipfileLock) Hashes(name, ver string) []string {
+	key := NormalizeName(name)
+	for _, packages := range []map[string]LockedPackage{l.Default, l.Develop} {
+		for locked, p := range packages {
+			if NormalizeName(locked) == key && strings.TrimPrefix(p.Version, "==") == ver {
+				return p.Hashes
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPipfile = `[[source]]
+url = "https://pypi.org/simple"
+verify_ssl = true
+name = "pypi"
+
+[packages]
+requests = {version = "==2.30.0", extras = ["socks"]}
+django = '==4.2.0'  # LTS
+flask = "*"
+mylib = {git = "https://github.com/me/mylib.git", ref = "v1.0"}
+legacy = {version = "==1.0", index = "internal"}
+
+[requires]
+python_version = "3.11"
+`
+
+func TestParsePipfile(t *testing.T) {
+	groups := ParsePipfile(testPipfile + "\n[dev-packages]\npytest = \">=8\"\n")
+	var got []string
+	for _, g := range groups {
+		var reqs []string
+		for _, r := range g.Requirements {
+			reqs = append(reqs, r.String())
+		}
+		got = append(got, g.String()+": "+strings.Join(reqs, ", "))
+	}
+	expected := []string{
+		"main: django==4.2.0, flask, legacy==1.0, mylib @ git+https://github.com/me/mylib.git@v1.0, requests[socks]==2.30.0",
+		"group:dev: pytest>=8",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("ParsePipfile() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+	if groups := ParsePipfile("[packages"); groups != nil {
+		t.Errorf("ParsePipfile(invalid) = %+v, want nil", groups)
+	}
+}
+
+func TestRewritePipfile(t *testing.T) {
+	groups := ParsePipfile(testPipfile)
+	main := groups[0].Requirements
+	var reqs []Requirement
+	for _, r := range main {
+		switch r.Key() {
+		case "flask":
+			continue // removed
+		case "django":
+			r = Pinned(r.Name, "4.2.7")
+		case "requests", "legacy":
+			r.Specifiers = Pinned(r.Name, "2.31.0").Specifiers
+		}
+		reqs = append(reqs, r)
+	}
+	rich, _ := ParseRequirement("rich>=13")
+	reqs = append(reqs, rich)
+	pytest, _ := ParseRequirement(`pytest==8.0.0; python_version >= "3.9"`)
+
+	got, err := RewritePipfile(testPipfile, []DependencyGroup{
+		{Group: MainGroup, Requirements: reqs},
+		{Group: PipfileDevGroup, Requirements: []Requirement{pytest}},
+	})
+	if err != nil {
+		t.Fatalf("RewritePipfile() error = %v", err)
+	}
+	expected := `[[source]]
+url = "https://pypi.org/simple"
+verify_ssl = true
+name = "pypi"
+
+[packages]
+requests = {version = "==2.31.0", extras = ["socks"]}
+django = '==4.2.7'  # LTS
+mylib = {git = "https://github.com/me/mylib.git", ref = "v1.0"}
+legacy = {version = "==2.31.0", index = "internal"}
+rich = ">=13"
+
+[requires]
+python_version = "3.11"
+
+[dev-packages]
+pytest = {version = "==8.0.0", markers = "python_version >= \"3.9\""}
+`
+	if got != expected {
+		t.Errorf("RewritePipfile() =\n%s\nwant:\n%s", got, expected)
+	}
+	if again := ParsePipfile(got); len(again) != 2 || len(again[0].Requirements) != 5 || again[1].Requirements[0].String() != pytest.String() {
+		t.Errorf("rewritten Pipfile parses as %+v", again)
+	}
+
+	if _, err := RewritePipfile(testPipfile, []DependencyGroup{{Group: Group{Kind: GroupOptional, Name: "cli"}}}); err == nil {
+		t.Error("RewritePipfile(optional group): expected an error")
+	}
+}
+
+func TestPipfileLock_Hashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Pipfile.lock")
+	content := `{
+    "_meta": {"hash": {"sha256": "abc"}},
+    "default": {
+        "requests": {"hashes": ["sha256:aaa", "sha256:bbb"], "version": "==2.31.0"},
+        "mylib": {"git": "https://github.com/me/mylib.git", "ref": "0123abc"}
+    },
+    "develop": {
+        "pytest": {"hashes": ["sha256:ccc"], "version": "==8.0.0"}
+    }
+}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := ReadPipfileLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, version string
+		expected      []string
+	}{
+		{"Requests", "2.31.0", []string{"sha256:aaa", "sha256:bbb"}},
+		{"requests", "2.30.0", nil},
+		{"pytest", "8.0.0", []string{"sha256:ccc"}},
+		{"mylib", "1.0", nil},
+		{"rich", "13.7.0", nil},
+	}
+	for _, tt := range tests {
+		if got := lock.Hashes(tt.name, tt.version); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Hashes(%s, %s) = %v, want %v", tt.name, tt.version, got, tt.expected)
+		}
+	}
+
+	if _, err := ReadPipfileLock(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("ReadPipfileLock(missing): expected an error")
+	}
+}
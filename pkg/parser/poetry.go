@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eslam/depman/pkg/pyname"
+	"github.com/eslam/depman/pkg/version"
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// poetryData matches the Poetry tables of a pyproject.toml file.
+type poetryData struct {
+	Tool struct {
+		Poetry struct {
+			Dependencies    map[string]any `toml:"dependencies"`
+			DevDependencies map[string]any `toml:"dev-dependencies"` // before Poetry 1.2
+			Group           map[string]struct {
+				Dependencies map[string]any `toml:"dependencies"`
+			} `toml:"group"`
+		} `toml:"poetry"`
+	} `toml:"tool"`
+}
+
+// ParsePoetryGroups extracts every dependency group from the pyproject.toml
+// file of a Poetry project: the groups of [project], as Poetry 2 writes
+// them, merged with [tool.poetry.dependencies] in the main group and
+// [tool.poetry.group.<name>.dependencies] in a dependency group of that
+// name. The legacy [tool.poetry.dev-dependencies] table is the "dev"
+// group. Poetry constraints are kept in Requirement.Constraint.
+func ParsePoetryGroups(content string) []DependencyGroup {
+	groups := ParsePyprojectGroups(content)
+	var data poetryData
+	if err := toml.Unmarshal([]byte(content), &data); err != nil || groups == nil {
+		return nil
+	}
+	poetry := data.Tool.Poetry
+
+	add := func(g Group, deps map[string]any) {
+		groups = mergeGroup(groups, g, parsePoetryDependencies(deps))
+	}
+	add(MainGroup, poetry.Dependencies)
+	for _, name := range sortedKeys(poetry.Group) {
+		add(Group{Kind: GroupDependency, Name: name}, poetry.Group[name].Dependencies)
+	}
+	if len(poetry.DevDependencies) > 0 {
+		add(Group{Kind: GroupDependency, Name: "dev"}, poetry.DevDependencies)
+	}
+	return groups
+}
+
+// parsePoetryDependencies converts a Poetry dependency table, sorted by
+// name. The python entry, which constrains the interpreter, is skipped,
+// as are entries that cannot be parsed.
+func parsePoetryDependencies(deps map[string]any) []Requirement {
+	var reqs []Requirement
+	for _, name := range sortedKeys(deps) {
+		if strings.EqualFold(name, "python") {
+			continue
+		}
+		r, err := parsePoetryDependency(name, deps[name])
+		if err != nil {
+			continue
+		}
+		reqs = append(reqs, r)
+	}
+	return reqs
+}
+
+// parsePoetryDependency converts one Poetry dependency, which is either a
+// constraint string or a table such as {version = "^2.0", extras =
+// ["socks"]}. Of a list of tables, one per environment, the first is used.
+func parsePoetryDependency(name string, value any) (Requirement, error) {
+	if pyname.FromSpec(name) == "" {
+		return Requirement{}, fmt.Errorf("parser: poetry dependency %q: invalid name", name)
+	}
+	r := Requirement{Name: name}
+	if list, ok := value.([]any); ok && len(list) > 0 {
+		value = list[0]
+	}
+	switch v := value.(type) {
+	case string:
+		r.Constraint = v
+	case map[string]any:
+		r.Constraint, _ = v["version"].(string)
+		r.Extras = anyStrings(v["extras"])
+		r.Marker, _ = v["markers"].(string)
+		switch {
+		case v["git"] != nil:
+			r.URL = "git+" + fmt.Sprint(v["git"])
+			for _, ref := range []string{"rev", "tag", "branch"} {
+				if s, ok := v[ref].(string); ok {
+					r.URL += "@" + s
+					break
+				}
+			}
+		case v["url"] != nil:
+			r.URL = fmt.Sprint(v["url"])
+		case v["path"] != nil:
+			r.URL = "file:" + fmt.Sprint(v["path"])
+		}
+	default:
+		return Requirement{}, fmt.Errorf("parser: poetry dependency %q: unexpected %T", name, value)
+	}
+	if r.URL != "" {
+		r.Constraint = ""
+		return r, nil
+	}
+	specs, err := ParsePoetryConstraint(r.Constraint)
+	if err != nil {
+		return Requirement{}, fmt.Errorf("parser: poetry dependency %q: %w", name, err)
+	}
+	r.Specifiers = specs
+	return r, nil
+}
+
+func anyStrings(v any) []string {
+	list, _ := v.([]any)
+	var out []string
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ParsePoetryConstraint converts a Poetry version constraint to PEP 440
+// clauses. Besides PEP 440 operators it accepts "*", bare versions,
+// wildcards such as "1.2.*", caret ranges ("^1.2" is >=1.2,<2.0) and tilde
+// ranges ("~1.2" is >=1.2,<1.3), separated by commas or spaces. A union
+// ("^1.0 || ^2.0") has no PEP 440 form and yields an empty set, which
+// matches every version.
+func ParsePoetryConstraint(s string) (version.SpecifierSet, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "*" || strings.Contains(s, "||") {
+		return nil, nil
+	}
+
+	// ">= 1.2, < 1.5" and ">=1.2 <1.5" both list two clauses
+	var clauses []string
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if n := len(clauses); n > 0 && strings.Trim(clauses[n-1], "<>=!~^") == "" {
+			clauses[n-1] += field
+			continue
+		}
+		clauses = append(clauses, field)
+	}
+
+	var set version.SpecifierSet
+	for _, clause := range clauses {
+		specs, err := parsePoetryClause(clause)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, specs...)
+	}
+	return set, nil
+}
+
+// parsePoetryClause converts a single Poetry constraint clause.
+func parsePoetryClause(clause string) ([]version.Specifier, error) {
+	var op string
+	switch {
+	case clause == "*":
+		return nil, nil
+	case strings.HasPrefix(clause, "^"):
+		op = "^"
+	case strings.HasPrefix(clause, "~") && !strings.HasPrefix(clause, "~="):
+		op = "~"
+	case strings.TrimLeft(clause, "<>=!~") != clause:
+		spec, err := version.ParseSpecifier(clause)
+		return []version.Specifier{spec}, err
+	default:
+		// A bare version or wildcard is an exact match
+		spec, err := version.ParseSpecifier("==" + clause)
+		return []version.Specifier{spec}, err
+	}
+
+	lower := strings.TrimSpace(clause[len(op):])
+	v, err := version.Parse(lower)
+	if err != nil {
+		return nil, fmt.Errorf("constraint %q: %w", clause, err)
+	}
+	release := v.Release
+	// The segment bumped for the exclusive upper bound: the first non-zero
+	// one for a caret, the minor (or the major, if that is all there is)
+	// for a tilde
+	fixed := min(1, len(release)-1)
+	if op == "^" {
+		fixed = len(release) - 1
+		for i, n := range release {
+			if n != 0 {
+				fixed = i
+				break
+			}
+		}
+	}
+	upper := make([]string, len(release))
+	for i := range release {
+		switch {
+		case i < fixed:
+			upper[i] = strconv.Itoa(release[i])
+		case i == fixed:
+			upper[i] = strconv.Itoa(release[i] + 1)
+		default:
+			upper[i] = "0"
+		}
+	}
+	return []version.Specifier{
+		{Op: ">=", Version: lower},
+		{Op: "<", Version: strings.Join(upper, ".")},
+	}, nil
+}
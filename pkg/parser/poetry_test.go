@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePoetryConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		expected   string
+	}{
+		{"^1.2.3", ">=1.2.3,<2.0.0"},
+		{"^1.2", ">=1.2,<2.0"},
+		{"^1", ">=1,<2"},
+		{"^0.2.3", ">=0.2.3,<0.3.0"},
+		{"^0.0.3", ">=0.0.3,<0.0.4"},
+		{"^0.0", ">=0.0,<0.1"},
+		{"~1.2.3", ">=1.2.3,<1.3.0"},
+		{"~1.2", ">=1.2,<1.3"},
+		{"~1", ">=1,<2"},
+		{"~=1.2", "~=1.2"},
+		{"1.2.3", "==1.2.3"},
+		{"1.2.*", "==1.2.*"},
+		{"*", ""},
+		{"", ""},
+		{">= 1.2, < 1.5", ">=1.2,<1.5"},
+		{">=1.2 <1.5 !=1.4.1", ">=1.2,<1.5,!=1.4.1"},
+		{"^2.0b1", ">=2.0b1,<3.0"},
+		{"^1.0 || ^2.0", ""},
+	}
+	for _, tt := range tests {
+		got, err := ParsePoetryConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParsePoetryConstraint(%q) error = %v", tt.constraint, err)
+			continue
+		}
+		if got.String() != tt.expected {
+			t.Errorf("ParsePoetryConstraint(%q) = %q, want %q", tt.constraint, got.String(), tt.expected)
+		}
+	}
+
+	for _, bad := range []string{"^x", "~", ">=", "1.2 junk"} {
+		if _, err := ParsePoetryConstraint(bad); err == nil {
+			t.Errorf("ParsePoetryConstraint(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestParsePoetryGroups(t *testing.T) {
+	content := `[tool.poetry]
+name = "demo"
+
+[tool.poetry.dependencies]
+python = "^3.10"
+requests = "^2.31"
+httpx = { version = "~0.27", extras = ["http2"] }
+mylib = { git = "https://github.com/me/mylib.git", tag = "v1.0" }
+numpy = [
+    { version = "^1.26", python = ">=3.10" },
+    { version = "^1.24", python = "<3.10" },
+]
+
+[tool.poetry.group.dev.dependencies]
+pytest = ">=8,<9"
+
+[tool.poetry.group.docs.dependencies]
+mkdocs = "*"
+`
+	groups := ParsePoetryGroups(content)
+	var got []string
+	for _, g := range groups {
+		var reqs []string
+		for _, r := range g.Requirements {
+			reqs = append(reqs, r.String()+" ("+r.ConstraintString()+")")
+		}
+		got = append(got, g.String()+": "+strings.Join(reqs, ", "))
+	}
+	expected := []string{
+		"main: httpx[http2]>=0.27,<0.28 (~0.27), mylib @ git+https://github.com/me/mylib.git@v1.0 (), numpy>=1.26,<2.0 (^1.26), requests>=2.31,<3.0 (^2.31)",
+		"group:dev: pytest>=8,<9 (>=8,<9)",
+		"group:docs: mkdocs (*)",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("ParsePoetryGroups() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	// Poetry 2 declares dependencies in [project]; the legacy
+	// dev-dependencies table is the dev group
+	content = `[project]
+name = "demo"
+dependencies = ["requests>=2.31"]
+
+[tool.poetry.dev-dependencies]
+black = "^24.1"
+`
+	groups = ParsePoetryGroups(content)
+	if len(groups) != 2 || groups[0].Requirements[0].String() != "requests>=2.31" ||
+		groups[1].String() != "group:dev" || groups[1].Requirements[0].ConstraintString() != "^24.1" {
+		t.Errorf("ParsePoetryGroups(poetry 2) = %+v", groups)
+	}
+}
@@ -1,151 +1,444 @@
 package parser
 
 import (
+	"fmt"
+	"regexp"
 	"strings"
 
-	toml "github.com/pelletier/go-toml/v2"
+	"github.com/eslam/depman/pkg/pyname"
 )
 
-// pyprojectData is used for TOML unmarshaling of the relevant sections.
-type pyprojectData struct {
-	Project struct {
-		Dependencies []string `toml:"dependencies"`
-	} `toml:"project"`
+// ParsePyprojectTOML extracts the main dependencies from a pyproject.toml
+// file. Entries that are not valid PEP 508 requirements are skipped.
+func ParsePyprojectTOML(content string) []Requirement {
+	groups := ParsePyprojectGroups(content)
+	if len(groups) == 0 {
+		return nil
+	}
+	return groups[0].Requirements
 }
 
-// ParsePyprojectTOML extracts dependencies from a pyproject.toml file.
-func ParsePyprojectTOML(content string) []Dep {
-	var data pyprojectData
-	if err := toml.Unmarshal([]byte(content), &data); err != nil {
-		return nil
+// RewritePyprojectDependencies updates the [project] dependencies array
+// of a pyproject.toml file to match reqs. See RewritePyprojectGroups.
+func RewritePyprojectDependencies(content string, reqs []Requirement) (string, error) {
+	return RewritePyprojectGroups(content, []DependencyGroup{{Group: MainGroup, Requirements: reqs}})
+}
+
+// RewritePyprojectGroups updates the arrays of the given dependency groups
+// to match their requirements with the smallest possible edit: entries whose
+// requirement is unchanged are kept byte-for-byte, updated pins keep their
+// spacing and quoting, and comments, ordering, other groups and all other
+// content are preserved. Entries that are not valid requirements, such as
+// PEP 735 include-group tables, are left alone. A group without an array is
+// added to its table, creating the table if needed.
+func RewritePyprojectGroups(content string, groups []DependencyGroup) (string, error) {
+	doc, err := scanTOML(content)
+	if err != nil {
+		return "", fmt.Errorf("parser: pyproject.toml: %w", err)
 	}
 
-	var deps []Dep
-	for _, entry := range data.Project.Dependencies {
-		d := parsePyprojectDep(entry)
-		if d.Name != "" {
-			deps = append(deps, d)
+	var edits []textEdit
+	var missing []DependencyGroup
+	for _, g := range groups {
+		path := g.path()
+		if arr, ok := doc.arrays[path]; ok {
+			edits = append(edits, requirementArrayEdits(content, arr, g.Requirements)...)
+			continue
+		}
+		if doc.keys[path] {
+			return "", fmt.Errorf("parser: pyproject.toml: %s is not an array", path)
+		}
+		if len(g.Requirements) > 0 {
+			missing = append(missing, g)
 		}
 	}
-	return deps
+	content = applyEdits(content, edits)
+
+	// Each insertion shifts the offsets after it, so rescan in between
+	for _, g := range missing {
+		if doc, err = scanTOML(content); err != nil {
+			return "", fmt.Errorf("parser: pyproject.toml: %w", err)
+		}
+		if content, err = insertGroup(doc, g); err != nil {
+			return "", err
+		}
+	}
+	return content, nil
 }
 
-// parsePyprojectDep parses a PEP 508 dependency string like "requests==2.31.0".
-func parsePyprojectDep(s string) Dep {
-	s = strings.TrimSpace(s)
-	s = strings.Trim(s, "\"'")
+// requirementArrayEdits returns the edits that turn the string array arr
+// into reqs. Existing entries are matched to reqs by normalized name, in
+// order, so duplicates with different markers are kept apart.
+func requirementArrayEdits(src string, arr tomlArray, reqs []Requirement) []textEdit {
+	pending := make(map[string][]Requirement)
+	for _, r := range reqs {
+		pending[r.Key()] = append(pending[r.Key()], r)
+	}
+
+	multiline := strings.Contains(src[arr.open:arr.close], "\n")
 
-	// Try == first (exact pin)
-	if parts := strings.SplitN(s, "==", 2); len(parts) == 2 {
-		return Dep{
-			Name:    normalizePackageName(parts[0]),
-			Version: strings.TrimSpace(parts[1]),
+	var edits []textEdit
+	var kept []keptItem
+	for _, item := range arr.items {
+		old, err := requirementItem(item)
+		if err != nil {
+			kept = append(kept, keptItem{tomlItem: item, text: src[item.start:item.end]})
+			continue
 		}
-	}
 
-	// Handle other specifiers
-	for _, sep := range []string{">=", "<=", "!=", "~=", ">"} {
-		if idx := strings.Index(s, sep); idx > 0 {
-			return Dep{
-				Name:    normalizePackageName(s[:idx]),
-				Version: strings.TrimSpace(s[idx+len(sep):]),
+		queue := pending[old.Key()]
+		if len(queue) == 0 {
+			if multiline {
+				edits = append(edits, deleteItem(src, item))
 			}
+			continue
+		}
+		want := queue[0]
+		pending[old.Key()] = queue[1:]
+
+		k := keptItem{tomlItem: item, key: old.Key(), text: src[item.start:item.end]}
+		if want.String() != old.String() {
+			k.text = quoteLike(item, updateEntry(item.value, old, want))
+			edits = append(edits, textEdit{start: item.start, end: item.end, text: k.text})
+		}
+		kept = append(kept, k)
+	}
+
+	// Requirements that matched no existing entry, in the order given
+	var added []Requirement
+	for _, r := range reqs {
+		if q := pending[r.Key()]; len(q) > 0 {
+			added = append(added, q[0])
+			pending[r.Key()] = q[1:]
 		}
 	}
 
-	// Package name without version
-	return Dep{Name: normalizePackageName(s)}
+	if !multiline {
+		return inlineArrayEdits(src, arr, kept, added, edits)
+	}
+	return append(edits, multilineInsertEdits(src, arr, kept, added)...)
 }
 
-// RewritePyprojectDependencies replaces the [project.dependencies] array in
-// a pyproject.toml file while preserving all other content byte-for-byte.
-func RewritePyprojectDependencies(originalContent string, deps []Dep) string {
-	lines := strings.Split(originalContent, "\n")
-	var result []string
-	inDeps := false
-	depsWritten := false
+// keptItem is an array element that survives the rewrite, with its
+// possibly updated text.
+type keptItem struct {
+	tomlItem
+	key  string // normalized name, or "" if the entry is not a requirement
+	text string
+}
 
-	for i := 0; i < len(lines); i++ {
-		trimmed := strings.TrimSpace(lines[i])
+func requirementItem(item tomlItem) (Requirement, error) {
+	if !item.str {
+		return Requirement{}, fmt.Errorf("parser: array element is not a string")
+	}
+	return ParseRequirement(item.value)
+}
 
-		// Detect the start of dependencies array
-		if strings.HasPrefix(trimmed, "dependencies") && strings.Contains(trimmed, "[") {
-			inDeps = true
-			// Write our replacement
-			result = append(result, "dependencies = [")
-			result = append(result, "    # Generated by depman")
-			for _, d := range deps {
-				if d.Version != "" {
-					result = append(result, "    \""+d.Name+"=="+d.Version+"\",")
-				} else {
-					result = append(result, "    \""+d.Name+"\",")
+// updateEntry returns the new text for an entry. When only an exact pin
+// changed, the version is replaced in place so the entry keeps its spacing,
+// e.g. "Django == 4.2.0" becomes "Django == 4.2.7".
+func updateEntry(raw string, old, want Requirement) string {
+	oldPin, newPin := old.PinnedVersion(), want.PinnedVersion()
+	if oldPin != "" && newPin != "" {
+		repinned := old
+		repinned.Specifiers = want.Specifiers
+		if repinned.String() == want.String() {
+			if op := strings.Index(raw, "=="); op >= 0 {
+				if i := strings.Index(raw[op:], oldPin); i >= 0 {
+					i += op
+					return raw[:i] + newPin + raw[i+len(oldPin):]
 				}
 			}
-			// If the opening [ and ] are on the same line (inline array)
-			if strings.Contains(trimmed, "]") {
-				result = append(result, "]")
-				inDeps = false
-				depsWritten = true
+		}
+	}
+	return want.String()
+}
+
+// quoteLike quotes s in the same style as item where possible.
+func quoteLike(item tomlItem, s string) string {
+	if item.literal && !item.multiline && !strings.ContainsAny(s, "'\n") {
+		return "'" + s + "'"
+	}
+	return tomlString(s)
+}
+
+// deleteItem removes an element of a multi-line array. An element on a line
+// of its own is removed with its line, including any trailing comment.
+func deleteItem(src string, item tomlItem) textEdit {
+	ls, le := lineStart(src, item.start), lineEnd(src, item.end)
+	after := strings.TrimLeft(src[item.end:le], " \t")
+	rest := strings.TrimLeft(strings.TrimPrefix(after, ","), " \t")
+	if strings.TrimSpace(src[ls:item.start]) == "" && (rest == "" || strings.HasPrefix(rest, "#")) {
+		return textEdit{start: ls, end: nextLine(src, le)}
+	}
+
+	// Shares its line with other elements: remove it and a following comma
+	end := item.end
+	if strings.HasPrefix(after, ",") {
+		end = le - len(after) + 1
+		for end < le && (src[end] == ' ' || src[end] == '\t') {
+			end++
+		}
+	}
+	return textEdit{start: item.start, end: end}
+}
+
+// nextLine returns the offset just past the line break at off.
+func nextLine(src string, off int) int {
+	if off < len(src) && src[off] == '\r' {
+		off++
+	}
+	if off < len(src) && src[off] == '\n' {
+		off++
+	}
+	return off
+}
+
+// inlineArrayEdits rewrites a single-line array as a whole, which keeps the
+// one-line style without having to juggle separators.
+func inlineArrayEdits(src string, arr tomlArray, kept []keptItem, added []Requirement, edits []textEdit) []textEdit {
+	if len(kept) == len(arr.items) && len(added) == 0 {
+		return edits
+	}
+
+	var parts []string
+	for _, k := range kept {
+		parts = append(parts, k.text)
+	}
+	style := firstString(arr)
+	for _, r := range added {
+		parts = append(parts, quoteLike(style, r.String()))
+	}
+
+	text := strings.Join(parts, ", ")
+	if len(parts) > 0 && strings.HasPrefix(src[arr.open+1:arr.close], " ") {
+		text = " " + text + " "
+	}
+	return []textEdit{{start: arr.open + 1, end: arr.close, text: text}}
+}
+
+// multilineInsertEdits adds entries to a multi-line array. If the existing
+// entries are sorted by name each new entry goes in its sorted position,
+// otherwise new entries are appended.
+func multilineInsertEdits(src string, arr tomlArray, kept []keptItem, added []Requirement) []textEdit {
+	if len(added) == 0 {
+		return nil
+	}
+
+	nl := newline(src)
+	indent := "    "
+	for _, k := range kept {
+		if prefix := src[lineStart(src, k.start):k.start]; strings.TrimSpace(prefix) == "" {
+			indent = prefix
+			break
+		}
+	}
+	style := firstString(arr)
+
+	sorted := true
+	for i, k := range kept {
+		if k.key == "" || (i > 0 && k.key < kept[i-1].key) {
+			sorted = false
+			break
+		}
+	}
+
+	var edits []textEdit
+	var appended []string
+	for _, r := range added {
+		entry := quoteLike(style, r.String())
+		if sorted {
+			if anchor, ok := sortedAnchor(src, kept, r.Key()); ok {
+				edits = append(edits, textEdit{start: anchor, end: anchor, text: indent + entry + "," + nl})
 				continue
 			}
-			depsWritten = true
-			continue
 		}
+		appended = append(appended, entry)
+	}
+	if len(appended) == 0 {
+		return edits
+	}
 
-		// Skip lines inside the old dependencies array
-		if inDeps {
-			if strings.Contains(trimmed, "]") {
-				result = append(result, "]")
-				inDeps = false
-			}
-			continue
+	if len(kept) == 0 {
+		pos := lineStart(src, arr.close)
+		var b strings.Builder
+		if strings.TrimSpace(src[pos:arr.close]) != "" {
+			// The closing bracket follows other content on its line
+			pos = arr.close
+			b.WriteString(nl)
+		}
+		for _, entry := range appended {
+			b.WriteString(indent + entry + "," + nl)
 		}
+		return append(edits, textEdit{start: pos, end: pos, text: b.String()})
+	}
+
+	last := kept[len(kept)-1]
+	after := strings.TrimLeft(src[last.end:arr.close], " \t")
+	hasComma := strings.HasPrefix(after, ",")
+	if !hasComma {
+		edits = append(edits, textEdit{start: last.end, end: last.end, text: ","})
+	}
+
+	if !strings.Contains(src[last.end:arr.close], "\n") {
+		// The closing bracket shares the last entry's line: `"b"]`
+		pos := last.end
+		if hasComma {
+			pos = arr.close - len(after) + 1
+		}
+		var b strings.Builder
+		for _, entry := range appended {
+			b.WriteString(" " + entry + ",")
+		}
+		return append(edits, textEdit{start: pos, end: pos, text: b.String()})
+	}
+
+	var b strings.Builder
+	for _, entry := range appended {
+		b.WriteString(nl + indent + entry + ",")
+	}
+	pos := lineEnd(src, last.end)
+	return append(edits, textEdit{start: pos, end: pos, text: b.String()})
+}
+
+// sortedAnchor returns the start of the line of the first kept entry whose
+// name sorts after key, provided that entry is on a line of its own.
+func sortedAnchor(src string, kept []keptItem, key string) (int, bool) {
+	for _, k := range kept {
+		if k.key > key {
+			ls := lineStart(src, k.start)
+			return ls, strings.TrimSpace(src[ls:k.start]) == ""
+		}
+	}
+	return 0, false
+}
+
+func firstString(arr tomlArray) tomlItem {
+	for _, item := range arr.items {
+		if item.str {
+			return item
+		}
+	}
+	return tomlItem{}
+}
+
+// insertGroup adds a new array for g at the end of the table it belongs
+// in. If the table does not exist, the array goes into the nearest existing
+// parent table as a dotted key when the document already uses dotted keys
+// for it, and otherwise a new table is created: after [project] for
+// optional dependencies, or at the end of the document.
+func insertGroup(doc *tomlDocument, g DependencyGroup) (string, error) {
+	table, key := g.parent()
+	if doc.keys[table] {
+		return "", fmt.Errorf("parser: pyproject.toml: %s is an inline table; add %s by hand", table, g.Group)
+	}
+
+	nl := newline(doc.src)
+	var b strings.Builder
+	b.WriteString(tomlKey(key) + " = [" + nl)
+	b.WriteString("    # Generated by depman" + nl)
+	for _, r := range g.Requirements {
+		b.WriteString("    " + tomlString(r.String()) + "," + nl)
+	}
+	b.WriteString("]" + nl)
+	body := b.String()
 
-		result = append(result, lines[i])
+	if tbl, ok := doc.tables[table]; ok {
+		return insertAt(doc.src, tbl.end, body), nil
 	}
 
-	// If no dependencies section was found, append one under [project]
-	if !depsWritten {
-		var finalResult []string
-		projectFound := false
-		for _, line := range result {
-			finalResult = append(finalResult, line)
-			if strings.TrimSpace(line) == "[project]" {
-				projectFound = true
+	if usesDottedKeys(doc, table) {
+		for parent := parentKey(table); ; parent = parentKey(parent) {
+			if tbl, ok := doc.tables[parent]; ok {
+				rel := strings.TrimPrefix(strings.TrimPrefix(table, parent), ".")
+				return insertAt(doc.src, tbl.end, dottedKey(rel)+"."+body), nil
 			}
-			// Insert after [project] section header, before next section
-			if projectFound && strings.HasPrefix(strings.TrimSpace(line), "[") && strings.TrimSpace(line) != "[project]" {
-				insertLines := []string{
-					"dependencies = [",
-					"    # Generated by depman",
-				}
-				for _, d := range deps {
-					if d.Version != "" {
-						insertLines = append(insertLines, "    \""+d.Name+"=="+d.Version+"\",")
-					} else {
-						insertLines = append(insertLines, "    \""+d.Name+"\",")
-					}
-				}
-				insertLines = append(insertLines, "]", "")
-				// Insert before the current section header
-				n := len(finalResult)
-				last := finalResult[n-1]
-				finalResult = finalResult[:n-1]
-				finalResult = append(finalResult, insertLines...)
-				finalResult = append(finalResult, last)
-				projectFound = false // only insert once
+			if parent == "" {
+				break
 			}
 		}
-		result = finalResult
 	}
 
-	return strings.Join(result, "\n")
+	header := "[" + dottedKey(table) + "]" + nl
+	if tbl, ok := doc.tables["project"]; ok && g.Kind == GroupOptional {
+		return insertAt(doc.src, tbl.end, nl+header+body), nil
+	}
+
+	content := doc.src
+	if content != "" {
+		if !strings.HasSuffix(content, "\n") {
+			content += nl
+		}
+		content += nl
+	}
+	return content + header + body, nil
+}
+
+// insertAt inserts text at the start of the line at pos, adding a line break
+// first when pos is the end of a file without a trailing one.
+func insertAt(src string, pos int, text string) string {
+	if pos > 0 && src[pos-1] != '\n' {
+		text = newline(src) + text
+	}
+	return src[:pos] + text + src[pos:]
+}
+
+// usesDottedKeys reports whether any key below table was defined, which
+// without a table header means dotted keys such as
+// optional-dependencies.docs = [...] inside [project].
+func usesDottedKeys(doc *tomlDocument, table string) bool {
+	for k := range doc.keys {
+		if strings.HasPrefix(k, table+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func parentKey(path string) string {
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// tomlKey returns key as a bare key if possible, quoted otherwise.
+func tomlKey(key string) string {
+	if bareKeyPattern.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+// dottedKey formats a dotted path of bare keys.
+func dottedKey(path string) string {
+	parts := strings.Split(path, ".")
+	for i, p := range parts {
+		parts[i] = tomlKey(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+var bareKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// newline returns the line ending used by src.
+func newline(src string) string {
+	if strings.Contains(src, "\r\n") {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// tomlString quotes s as a TOML basic string. Markers commonly contain
+// double quotes, which must be escaped.
+func tomlString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
 }
 
-func normalizePackageName(name string) string {
-	name = strings.TrimSpace(name)
-	// PEP 503: normalize to lowercase, replace .-_ with -
-	name = strings.ToLower(name)
-	name = strings.ReplaceAll(name, "_", "-")
-	return name
+// NormalizeName returns the PEP 503 normalized form of a package name, so
+// that "Foo_Bar", "foo.bar" and "foo-bar" compare equal.
+func NormalizeName(name string) string {
+	return pyname.Normalize(name)
 }
@@ -0,0 +1,465 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+func TestRewritePyprojectDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		reqs     []string
+		expected string
+	}{
+		{
+			name: "unchanged file is untouched",
+			content: `[project]
+name = "demo"
+dependencies = [
+  # web
+  "requests >= 2.31",   # keep me
+  'Django==4.2.7',
+]
+`,
+			reqs: []string{"Django==4.2.7", "requests>=2.31"},
+			expected: `[project]
+name = "demo"
+dependencies = [
+  # web
+  "requests >= 2.31",   # keep me
+  'Django==4.2.7',
+]
+`,
+		},
+		{
+			name: "pin update keeps spacing, quoting and comments",
+			content: `[project]
+dependencies = [
+    'Django == 4.2.0',  # LTS
+    "requests>=2,<3",
+]
+`,
+			reqs: []string{"Django==4.2.7", "requests>=2,<3"},
+			expected: `[project]
+dependencies = [
+    'Django == 4.2.7',  # LTS
+    "requests>=2,<3",
+]
+`,
+		},
+		{
+			name: "brackets inside markers and comments",
+			content: `[project]
+dependencies = [
+    "requests[socks]==2.30.0; python_version < \"3.12\"",  # see [issue]
+    "attrs==23.1.0",
+]
+`,
+			reqs: []string{"attrs==23.1.0", `requests[socks]==2.31.0; python_version < "3.12"`},
+			expected: `[project]
+dependencies = [
+    "requests[socks]==2.31.0; python_version < \"3.12\"",  # see [issue]
+    "attrs==23.1.0",
+]
+`,
+		},
+		{
+			name: "removed entry takes its line and comment",
+			content: `[project]
+dependencies = [
+    "attrs==23.1.0",
+    "removed==1.0",  # no longer needed
+    "requests>=2",
+]
+`,
+			reqs: []string{"attrs==23.1.0", "requests>=2"},
+			expected: `[project]
+dependencies = [
+    "attrs==23.1.0",
+    "requests>=2",
+]
+`,
+		},
+		{
+			name: "added entry goes in sorted position",
+			content: `[project]
+dependencies = [
+  "attrs==23.1.0",
+  "requests>=2",
+]
+`,
+			reqs: []string{"attrs==23.1.0", "click==8.1.7", "requests>=2", "zipp==3.17.0"},
+			expected: `[project]
+dependencies = [
+  "attrs==23.1.0",
+  "click==8.1.7",
+  "requests>=2",
+  "zipp==3.17.0",
+]
+`,
+		},
+		{
+			name: "added entry appended to unsorted array",
+			content: `[project]
+dependencies = [
+    "requests>=2",
+    "attrs==23.1.0"
+]
+`,
+			reqs: []string{"attrs==23.1.0", "click==8.1.7", "requests>=2"},
+			expected: `[project]
+dependencies = [
+    "requests>=2",
+    "attrs==23.1.0",
+    "click==8.1.7",
+]
+`,
+		},
+		{
+			name:     "inline array",
+			content:  "[project]\ndependencies = [\"attrs==23.1.0\", \"old==1.0\"]  # inline\n",
+			reqs:     []string{"attrs==23.2.0", "click==8.1.7"},
+			expected: "[project]\ndependencies = [\"attrs==23.2.0\", \"click==8.1.7\"]  # inline\n",
+		},
+		{
+			name:     "empty inline array",
+			content:  "[project]\ndependencies = []\n",
+			reqs:     []string{"click==8.1.7"},
+			expected: "[project]\ndependencies = [\"click==8.1.7\"]\n",
+		},
+		{
+			name: "entries that are not requirements are kept",
+			content: `[project]
+dependencies = [
+    "attrs==23.1.0",
+    "not a requirement!",
+]
+`,
+			reqs: []string{"attrs==23.2.0"},
+			expected: `[project]
+dependencies = [
+    "attrs==23.2.0",
+    "not a requirement!",
+]
+`,
+		},
+		{
+			name: "other dependencies keys are ignored",
+			content: `[tool.poetry.dependencies]
+python = "^3.11"
+
+[project]
+name = "demo"
+dependencies = ["attrs==23.1.0"]
+
+[tool.hatch.envs.default]
+dependencies = ["pytest"]
+`,
+			reqs: []string{"attrs==23.2.0"},
+			expected: `[tool.poetry.dependencies]
+python = "^3.11"
+
+[project]
+name = "demo"
+dependencies = ["attrs==23.2.0"]
+
+[tool.hatch.envs.default]
+dependencies = ["pytest"]
+`,
+		},
+		{
+			name: "missing array is added to the project table",
+			content: `[project]
+name = "demo"
+version = "0.1.0"
+
+[tool.ruff]
+line-length = 100
+`,
+			reqs: []string{"click==8.1.7"},
+			expected: `[project]
+name = "demo"
+version = "0.1.0"
+dependencies = [
+    # Generated by depman
+    "click==8.1.7",
+]
+
+[tool.ruff]
+line-length = 100
+`,
+		},
+		{
+			name:    "missing project table is appended",
+			content: "[tool.ruff]\nline-length = 100",
+			reqs:    []string{"click==8.1.7"},
+			expected: `[tool.ruff]
+line-length = 100
+
+[project]
+dependencies = [
+    # Generated by depman
+    "click==8.1.7",
+]
+`,
+		},
+		{
+			name:     "CRLF line endings",
+			content:  "[project]\r\ndependencies = [\r\n    \"attrs==23.1.0\",\r\n]\r\n",
+			reqs:     []string{"attrs==23.1.0", "click==8.1.7"},
+			expected: "[project]\r\ndependencies = [\r\n    \"attrs==23.1.0\",\r\n    \"click==8.1.7\",\r\n]\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reqs []Requirement
+			for _, s := range tt.reqs {
+				reqs = append(reqs, mustParse(t, s))
+			}
+			got, err := RewritePyprojectDependencies(tt.content, reqs)
+			if err != nil {
+				t.Fatalf("RewritePyprojectDependencies() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("RewritePyprojectDependencies() =\n%s\nwant:\n%s", got, tt.expected)
+			}
+			var v map[string]any
+			if err := toml.Unmarshal([]byte(got), &v); err != nil {
+				t.Errorf("result is not valid TOML: %v\n%s", err, got)
+			}
+		})
+	}
+}
+
+func TestRewritePyprojectDependencies_Invalid(t *testing.T) {
+	inputs := []string{
+		"[project\ndependencies = []\n",
+		"[project]\ndependencies = [\"unterminated\n",
+		"[project]\ndependencies = \"requests\"\n",
+	}
+	for _, input := range inputs {
+		if got, err := RewritePyprojectDependencies(input, nil); err == nil {
+			t.Errorf("RewritePyprojectDependencies(%q) = %q, want error", input, got)
+		}
+	}
+}
+
+func TestScanTOML(t *testing.T) {
+	content := `# top
+title = "x"
+date = 1979-05-27 07:32:00
+[project]
+"name" = 'demo'
+authors = [{ name = "A, B", email = "a@b.c" }]
+dependencies = [
+    """multi
+line""",
+    'literal\path',
+    "esc\"apedé",
+]
+[[tool.items]]
+x.y = [1, [2, 3]]
+`
+	doc, err := scanTOML(content)
+	if err != nil {
+		t.Fatalf("scanTOML() error = %v", err)
+	}
+
+	for _, key := range []string{"title", "date", "project.name", "project.authors", "project.dependencies", "tool.items.x.y"} {
+		if !doc.keys[key] {
+			t.Errorf("key %q not found", key)
+		}
+	}
+
+	deps := doc.arrays["project.dependencies"]
+	expected := []string{"multi\nline", `literal\path`, "esc\"apedé"}
+	if len(deps.items) != len(expected) {
+		t.Fatalf("dependencies has %d items, want %d", len(deps.items), len(expected))
+	}
+	for i, item := range deps.items {
+		if item.value != expected[i] {
+			t.Errorf("item[%d] = %q, want %q", i, item.value, expected[i])
+		}
+	}
+	if !strings.HasPrefix(content[deps.open:], "[\n") || content[deps.close] != ']' {
+		t.Errorf("array bounds = %d..%d", deps.open, deps.close)
+	}
+}
+
+func TestRewritePyprojectGroups(t *testing.T) {
+	dev := Group{Kind: GroupDependency, Name: "dev"}
+	docs := Group{Kind: GroupOptional, Name: "docs"}
+
+	tests := []struct {
+		name     string
+		content  string
+		groups   map[Group][]string
+		expected string
+	}{
+		{
+			name: "only the changed group is touched",
+			content: `[project]
+dependencies = ["requests==2.30.0"]
+
+[project.optional-dependencies]
+docs = ["sphinx==7.2.0"]  # docs
+
+[dependency-groups]
+dev = [
+    {include-group = "test"},
+    "pytest==7.4.0",
+]
+test = ["pytest==7.4.0"]
+`,
+			groups: map[Group][]string{
+				MainGroup: {"requests==2.30.0"},
+				docs:      {"sphinx==7.2.0"},
+				dev:       {"pytest==8.0.0", "ruff==0.3.0"},
+			},
+			expected: `[project]
+dependencies = ["requests==2.30.0"]
+
+[project.optional-dependencies]
+docs = ["sphinx==7.2.0"]  # docs
+
+[dependency-groups]
+dev = [
+    {include-group = "test"},
+    "pytest==8.0.0",
+    "ruff==0.3.0",
+]
+test = ["pytest==7.4.0"]
+`,
+		},
+		{
+			name: "new group goes at the end of its table",
+			content: `[project.optional-dependencies]
+cli = ["click"]
+
+[tool.ruff]
+line-length = 100
+`,
+			groups: map[Group][]string{docs: {"sphinx==7.2.6"}},
+			expected: `[project.optional-dependencies]
+cli = ["click"]
+docs = [
+    # Generated by depman
+    "sphinx==7.2.6",
+]
+
+[tool.ruff]
+line-length = 100
+`,
+		},
+		{
+			name: "optional dependencies table is created after project",
+			content: `[project]
+name = "demo"
+
+[tool.ruff]
+line-length = 100
+`,
+			groups: map[Group][]string{docs: {"sphinx==7.2.6"}},
+			expected: `[project]
+name = "demo"
+
+[project.optional-dependencies]
+docs = [
+    # Generated by depman
+    "sphinx==7.2.6",
+]
+
+[tool.ruff]
+line-length = 100
+`,
+		},
+		{
+			name:    "dependency groups table is appended",
+			content: "[project]\nname = \"demo\"\n",
+			groups:  map[Group][]string{dev: {"pytest==8.0.0"}},
+			expected: `[project]
+name = "demo"
+
+[dependency-groups]
+dev = [
+    # Generated by depman
+    "pytest==8.0.0",
+]
+`,
+		},
+		{
+			name: "dotted keys stay dotted",
+			content: `[project]
+name = "demo"
+optional-dependencies.cli = ["click"]
+`,
+			groups: map[Group][]string{docs: {"sphinx==7.2.6"}},
+			expected: `[project]
+name = "demo"
+optional-dependencies.cli = ["click"]
+optional-dependencies.docs = [
+    # Generated by depman
+    "sphinx==7.2.6",
+]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var groups []DependencyGroup
+			for _, g := range []Group{MainGroup, docs, dev} {
+				specs, ok := tt.groups[g]
+				if !ok {
+					continue
+				}
+				dg := DependencyGroup{Group: g}
+				for _, s := range specs {
+					dg.Requirements = append(dg.Requirements, mustParse(t, s))
+				}
+				groups = append(groups, dg)
+			}
+			got, err := RewritePyprojectGroups(tt.content, groups)
+			if err != nil {
+				t.Fatalf("RewritePyprojectGroups() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("RewritePyprojectGroups() =\n%s\nwant:\n%s", got, tt.expected)
+			}
+			var v map[string]any
+			if err := toml.Unmarshal([]byte(got), &v); err != nil {
+				t.Errorf("result is not valid TOML: %v\n%s", err, got)
+			}
+		})
+	}
+}
+
+func TestRewritePyprojectGroups_InlineTable(t *testing.T) {
+	content := "[project]\noptional-dependencies = { cli = [\"click==8.1.0\"] }\n"
+	cli := DependencyGroup{
+		Group:        Group{Kind: GroupOptional, Name: "cli"},
+		Requirements: []Requirement{mustParse(t, "click==8.1.7")},
+	}
+
+	// Arrays inside the inline table are edited in place
+	got, err := RewritePyprojectGroups(content, []DependencyGroup{cli})
+	if err != nil {
+		t.Fatalf("RewritePyprojectGroups() error = %v", err)
+	}
+	if expected := "[project]\noptional-dependencies = { cli = [\"click==8.1.7\"] }\n"; got != expected {
+		t.Errorf("RewritePyprojectGroups() = %q, want %q", got, expected)
+	}
+
+	// but a new group cannot be added to it
+	docs := DependencyGroup{
+		Group:        Group{Kind: GroupOptional, Name: "docs"},
+		Requirements: []Requirement{mustParse(t, "sphinx")},
+	}
+	if got, err := RewritePyprojectGroups(content, []DependencyGroup{cli, docs}); err == nil {
+		t.Errorf("RewritePyprojectGroups() = %q, want error", got)
+	}
+}
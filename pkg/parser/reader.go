@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/eslam/depman/pkg/detector"
+)
+
+// ReadDependencyFile parses the dependencies declared in the project's
+// dependency file, including requirements files it includes with -r.
+func ReadDependencyFile(project detector.Project) ([]Requirement, error) {
+	if project.FileType == detector.FileRequirementsTXT {
+		f, err := ReadRequirementsFile(project.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		return f.Requirements(), nil
+	}
+
+	content, err := os.ReadFile(project.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("parser: read file: %w", err)
+	}
+
+	var groups []DependencyGroup
+	switch project.FileType {
+	case detector.FilePipfile:
+		groups = ParsePipfile(string(content))
+	case detector.FileEnvironmentYML:
+		groups = ParseCondaEnvironment(string(content))
+	case detector.FileSetupCfg:
+		groups = ParseSetupCfg(string(content))
+	case detector.FileSetupPy:
+		groups = ParseSetupPy(string(content))
+	case detector.FilePyprojectTOML:
+		groups = parseProjectGroups(project.Tool, string(content))
+	default:
+		return nil, fmt.Errorf("parser: read file: unknown file type %v", project.FileType)
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+	return groups[0].Requirements, nil
+}
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/eslam/depman/pkg/pyname"
+	"github.com/eslam/depman/pkg/version"
+)
+
+// Requirement is a PEP 508 dependency specification such as
+// `requests[socks]>=2,<3; python_version < "3.12"` or `pkg @ https://...`.
+type Requirement struct {
+	Name       string               // Project name as written, e.g. "Requests"
+	Extras     []string             // Optional extras, e.g. ["socks"]
+	Specifiers version.SpecifierSet // Version clauses, e.g. >=2 and <3
+	URL        string               // Direct reference after "@", mutually exclusive with Specifiers
+	Marker     string               // Environment marker after ";", kept verbatim
+	Constraint string               // Version constraint in the tool's own notation, e.g. Poetry's "^2.31"; empty for PEP 508
+}
+
+var extraNamePattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?$`)
+
+// ParseRequirement parses a PEP 508 requirement string.
+func ParseRequirement(s string) (Requirement, error) {
+	var r Requirement
+	rest := strings.TrimSpace(s)
+
+	name := pyname.FromSpec(rest)
+	if name == "" {
+		return r, fmt.Errorf("parser: requirement %q: missing project name", s)
+	}
+	r.Name = name
+	rest = strings.TrimSpace(rest[len(name):])
+
+	// Extras: name[extra1, extra2]
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return r, fmt.Errorf("parser: requirement %q: unterminated extras", s)
+		}
+		for _, e := range strings.Split(rest[1:end], ",") {
+			e = strings.TrimSpace(e)
+			if e == "" {
+				continue
+			}
+			if !extraNamePattern.MatchString(e) {
+				return r, fmt.Errorf("parser: requirement %q: invalid extra %q", s, e)
+			}
+			r.Extras = append(r.Extras, e)
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+
+	// Direct reference: name @ url [; marker]
+	if strings.HasPrefix(rest, "@") {
+		rest = strings.TrimSpace(rest[1:])
+		url := rest
+		if idx := strings.IndexAny(rest, " \t"); idx >= 0 {
+			url = rest[:idx]
+		}
+		if url == "" {
+			return r, fmt.Errorf("parser: requirement %q: missing URL after @", s)
+		}
+		r.URL = url
+		rest = strings.TrimSpace(rest[len(url):])
+		if rest != "" && !strings.HasPrefix(rest, ";") {
+			return r, fmt.Errorf("parser: requirement %q: unexpected %q after URL", s, rest)
+		}
+		return r, parseMarker(&r, s, rest)
+	}
+
+	// Version specifiers, optionally parenthesized: name (>=1,<2)
+	spec := rest
+	if idx := strings.Index(rest, ";"); idx >= 0 {
+		spec = rest[:idx]
+		rest = rest[idx:]
+	} else {
+		rest = ""
+	}
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "(") {
+		if !strings.HasSuffix(spec, ")") {
+			return r, fmt.Errorf("parser: requirement %q: unterminated version specifier", s)
+		}
+		spec = strings.TrimSpace(spec[1 : len(spec)-1])
+	}
+	if spec != "" {
+		for _, clause := range strings.Split(spec, ",") {
+			vs, err := version.ParseSpecifier(clause)
+			if err != nil {
+				return r, fmt.Errorf("parser: requirement %q: %w", s, err)
+			}
+			r.Specifiers = append(r.Specifiers, vs)
+		}
+	}
+
+	return r, parseMarker(&r, s, rest)
+}
+
+// parseMarker stores the environment marker from rest, which is either
+// empty or starts with ";".
+func parseMarker(r *Requirement, original, rest string) error {
+	if rest == "" {
+		return nil
+	}
+	marker := strings.TrimSpace(strings.TrimPrefix(rest, ";"))
+	if marker == "" {
+		return fmt.Errorf("parser: requirement %q: empty environment marker", original)
+	}
+	r.Marker = marker
+	return nil
+}
+
+// String formats the requirement in PEP 508 syntax. Parsing the result
+// yields an equal Requirement.
+func (r Requirement) String() string {
+	var b strings.Builder
+	b.WriteString(r.Name)
+	if len(r.Extras) > 0 {
+		b.WriteString("[" + strings.Join(r.Extras, ",") + "]")
+	}
+	if r.URL != "" {
+		b.WriteString(" @ " + r.URL)
+		if r.Marker != "" {
+			// A space is required so the ";" is not read as part of the URL
+			b.WriteString(" ")
+		}
+	} else {
+		b.WriteString(r.SpecifierString())
+	}
+	if r.Marker != "" {
+		b.WriteString("; " + r.Marker)
+	}
+	return b.String()
+}
+
+// SpecifierString returns the comma-separated version clauses, e.g. ">=2,<3".
+func (r Requirement) SpecifierString() string {
+	return r.Specifiers.String()
+}
+
+// ConstraintString returns the version constraint as the dependency file
+// writes it: in the tool's own notation if it has one, else the PEP 440
+// clauses.
+func (r Requirement) ConstraintString() string {
+	if r.Constraint != "" {
+		return r.Constraint
+	}
+	return r.SpecifierString()
+}
+
+// Key returns the normalized project name used to match requirements with
+// installed packages.
+func (r Requirement) Key() string {
+	return NormalizeName(r.Name)
+}
+
+// PinnedVersion returns the version of an exact "==" pin, or "" if the
+// requirement is not pinned to a single version.
+func (r Requirement) PinnedVersion() string {
+	if len(r.Specifiers) == 1 && r.Specifiers[0].Op == "==" && !strings.Contains(r.Specifiers[0].Version, "*") {
+		return r.Specifiers[0].Version
+	}
+	return ""
+}
+
+// Pinned returns a requirement for name pinned to exactly version.
+func Pinned(name, ver string) Requirement {
+	r := Requirement{Name: name}
+	if ver != "" {
+		r.Specifiers = version.SpecifierSet{{Op: "==", Version: ver}}
+	}
+	return r
+}
@@ -0,0 +1,204 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eslam/depman/pkg/version"
+)
+
+func TestParseRequirement(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Requirement
+	}{
+		{
+			name:     "bare name",
+			input:    "requests",
+			expected: Requirement{Name: "requests"},
+		},
+		{
+			name:  "exact pin",
+			input: "requests==2.31.0",
+			expected: Requirement{
+				Name:       "requests",
+				Specifiers: version.SpecifierSet{{Op: "==", Version: "2.31.0"}},
+			},
+		},
+		{
+			name:  "extras, range and marker",
+			input: `requests[socks]>=2,<3; python_version<"3.12"`,
+			expected: Requirement{
+				Name:       "requests",
+				Extras:     []string{"socks"},
+				Specifiers: version.SpecifierSet{{Op: ">=", Version: "2"}, {Op: "<", Version: "3"}},
+				Marker:     `python_version<"3.12"`,
+			},
+		},
+		{
+			name:  "multiple extras with whitespace",
+			input: "uvicorn [ standard , http2 ] >= 0.20 , != 0.21.0",
+			expected: Requirement{
+				Name:       "uvicorn",
+				Extras:     []string{"standard", "http2"},
+				Specifiers: version.SpecifierSet{{Op: ">=", Version: "0.20"}, {Op: "!=", Version: "0.21.0"}},
+			},
+		},
+		{
+			name:  "parenthesized specifier",
+			input: "Django (>=4.2, <5)",
+			expected: Requirement{
+				Name:       "Django",
+				Specifiers: version.SpecifierSet{{Op: ">=", Version: "4.2"}, {Op: "<", Version: "5"}},
+			},
+		},
+		{
+			name:  "compatible release and wildcard",
+			input: "numpy~=1.26.0,!=1.26.1.*",
+			expected: Requirement{
+				Name:       "numpy",
+				Specifiers: version.SpecifierSet{{Op: "~=", Version: "1.26.0"}, {Op: "!=", Version: "1.26.1.*"}},
+			},
+		},
+		{
+			name:  "arbitrary equality",
+			input: "legacy===1.0-custom",
+			expected: Requirement{
+				Name:       "legacy",
+				Specifiers: version.SpecifierSet{{Op: "===", Version: "1.0-custom"}},
+			},
+		},
+		{
+			name:  "direct URL reference",
+			input: "pip @ https://github.com/pypa/pip/archive/1.3.1.zip#sha1=da9234ee",
+			expected: Requirement{
+				Name: "pip",
+				URL:  "https://github.com/pypa/pip/archive/1.3.1.zip#sha1=da9234ee",
+			},
+		},
+		{
+			name:  "direct URL with extras and marker",
+			input: `mypkg[cli] @ file:///src/mypkg ; sys_platform == "linux"`,
+			expected: Requirement{
+				Name:   "mypkg",
+				Extras: []string{"cli"},
+				URL:    "file:///src/mypkg",
+				Marker: `sys_platform == "linux"`,
+			},
+		},
+		{
+			name:  "marker without specifier",
+			input: `tomli; python_version < "3.11"`,
+			expected: Requirement{
+				Name:   "tomli",
+				Marker: `python_version < "3.11"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRequirement(tt.input)
+			if err != nil {
+				t.Fatalf("ParseRequirement(%q) error = %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(r, tt.expected) {
+				t.Errorf("ParseRequirement(%q) = %+v, want %+v", tt.input, r, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseRequirement_Invalid(t *testing.T) {
+	inputs := []string{
+		"",
+		">=1.0",
+		"requests[socks",
+		"requests>=",
+		"requests =2",
+		"requests @",
+		"requests @ https://example.com/x.whl extra",
+		"requests (>=1",
+		"requests;",
+		"requests[bad extra]",
+		"requests>=2.*",
+		"requests~=2",
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			if r, err := ParseRequirement(input); err == nil {
+				t.Errorf("ParseRequirement(%q) = %+v, want error", input, r)
+			}
+		})
+	}
+}
+
+func TestRequirement_RoundTrip(t *testing.T) {
+	inputs := []string{
+		"requests",
+		"requests==2.31.0",
+		`requests[socks]>=2,<3; python_version<"3.12"`,
+		"uvicorn[standard,http2]>=0.20,!=0.21.0",
+		"pip @ https://github.com/pypa/pip/archive/1.3.1.zip",
+		`mypkg[cli] @ file:///src/mypkg ; sys_platform == "linux"`,
+		"legacy===1.0-custom",
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			r, err := ParseRequirement(input)
+			if err != nil {
+				t.Fatalf("ParseRequirement(%q) error = %v", input, err)
+			}
+			if got := r.String(); got != input {
+				t.Errorf("String() = %q, want %q", got, input)
+			}
+			again, err := ParseRequirement(r.String())
+			if err != nil {
+				t.Fatalf("ParseRequirement(String()) error = %v", err)
+			}
+			if !reflect.DeepEqual(again, r) {
+				t.Errorf("round trip = %+v, want %+v", again, r)
+			}
+		})
+	}
+}
+
+func TestRequirement_PinnedVersion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"requests==2.31.0", "2.31.0"},
+		{"requests==2.*", ""},
+		{"requests>=2", ""},
+		{"requests==2,<3", ""},
+		{"requests", ""},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseRequirement(tt.input)
+		if err != nil {
+			t.Fatalf("ParseRequirement(%q) error = %v", tt.input, err)
+		}
+		if got := r.PinnedVersion(); got != tt.expected {
+			t.Errorf("PinnedVersion(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := map[string]string{
+		"Requests":       "requests",
+		"Foo_Bar":        "foo-bar",
+		"zope.interface": "zope-interface",
+		"a--b__c..d":     "a-b-c-d",
+	}
+	for input, expected := range tests {
+		if got := NormalizeName(input); got != expected {
+			t.Errorf("NormalizeName(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
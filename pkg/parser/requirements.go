@@ -1,65 +1,516 @@
 package parser
 
 import (
-	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 )
 
-// Dep represents a single dependency entry.
-type Dep struct {
-	Name    string
-	Version string // pinned version, e.g. "1.2.3"
+// ReqLineKind classifies a logical line of a requirements file.
+type ReqLineKind int
+
+const (
+	ReqLineBlank       ReqLineKind = iota // blank or comment only
+	ReqLineRequirement                    // a PEP 508 requirement, with optional per-requirement options
+	ReqLineEditable                       // -e / --editable
+	ReqLineInclude                        // -r / --requirement
+	ReqLineConstraint                     // -c / --constraint
+	ReqLineOption                         // any other option, e.g. --index-url
+	ReqLineInvalid                        // text that is not a valid requirement
+)
+
+// ReqLine is one logical line of a requirements file: a physical line plus
+// any following lines joined to it by a trailing backslash.
+type ReqLine struct {
+	Kind        ReqLineKind
+	Number      int         // 1-based number of the first physical line
+	Text        string      // joined text without the comment
+	Requirement Requirement // for ReqLineRequirement
+	Options     []string    // per-requirement options, e.g. "--hash=sha256:..."
+	Value       string      // argument of -r, -c, -e and other options
+	// File is the parsed target of -r and -c. It is nil for remote files,
+	// which are not fetched, and for files already being read (a cycle).
+	File *RequirementsFile
+
+	comment          string // trailing "# ..." comment, if any
+	start, end       int    // span of the physical lines, without the final line break
+	reqStart, reqEnd int    // span of the requirement text
+}
+
+// Hashes returns the values of the line's --hash options.
+func (l ReqLine) Hashes() []string {
+	var hashes []string
+	for i, opt := range l.Options {
+		if v, ok := strings.CutPrefix(opt, "--hash="); ok {
+			hashes = append(hashes, v)
+		} else if opt == "--hash" && i+1 < len(l.Options) {
+			hashes = append(hashes, l.Options[i+1])
+		}
+	}
+	return hashes
+}
+
+// RequirementsFile is a parsed pip requirements file. Files it includes
+// with -r and -c are parsed too, and hang off the including line.
+type RequirementsFile struct {
+	Path  string
+	Lines []ReqLine
+	src   string
 }
 
 // ParseRequirementsTxt parses a requirements.txt file content.
-// It extracts package==version entries, skipping comments and blank lines.
-func ParseRequirementsTxt(content string) []Dep {
-	var deps []Dep
-	scanner := bufio.NewScanner(strings.NewReader(content))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
-			continue
+// It extracts PEP 508 requirements, skipping comments, blank lines, option
+// lines and entries that cannot be parsed. Includes are not followed.
+func ParseRequirementsTxt(content string) []Requirement {
+	var reqs []Requirement
+	for _, l := range ParseRequirementsFile("", content).Lines {
+		if l.Kind == ReqLineRequirement {
+			reqs = append(reqs, l.Requirement)
 		}
+	}
+	return reqs
+}
+
+// ReadRequirementsFile reads and parses the requirements file at path and,
+// recursively, the local files it includes with -r and -c. Relative paths
+// are resolved against the directory of the including file, as pip does.
+func ReadRequirementsFile(path string) (*RequirementsFile, error) {
+	return readRequirementsFile(path, make(map[string]bool))
+}
+
+func readRequirementsFile(path string, reading map[string]bool) (*RequirementsFile, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("parser: resolve %s: %w", path, err)
+	}
+	reading[abs] = true
+	defer delete(reading, abs)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("parser: read file: %w", err)
+	}
 
-		// Handle == pinning (most common)
-		if parts := strings.SplitN(line, "==", 2); len(parts) == 2 {
-			deps = append(deps, Dep{
-				Name:    strings.TrimSpace(parts[0]),
-				Version: strings.TrimSpace(parts[1]),
-			})
+	f := ParseRequirementsFile(path, string(data))
+	for i := range f.Lines {
+		l := &f.Lines[i]
+		if (l.Kind != ReqLineInclude && l.Kind != ReqLineConstraint) || isRemote(l.Value) {
+			continue
+		}
+		target := l.Value
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		if abs, err := filepath.Abs(target); err == nil && reading[abs] {
 			continue
 		}
+		if l.File, err = readRequirementsFile(target, reading); err != nil {
+			return nil, fmt.Errorf("parser: %s:%d: %w", path, l.Number, err)
+		}
+	}
+	return f, nil
+}
+
+func isRemote(ref string) bool {
+	u, err := url.Parse(ref)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "file")
+}
+
+// ParseRequirementsFile parses the content of a single requirements file
+// without following includes.
+func ParseRequirementsFile(path, content string) *RequirementsFile {
+	f := &RequirementsFile{Path: path, src: content}
 
-		// Handle >= or other specifiers — extract just the name
-		for _, sep := range []string{">=", "<=", "!=", "~=", ">"} {
-			if idx := strings.Index(line, sep); idx > 0 {
-				deps = append(deps, Dep{
-					Name:    strings.TrimSpace(line[:idx]),
-					Version: strings.TrimSpace(line[idx+len(sep):]),
-				})
-				break
+	// Join continuation lines, remembering where each byte came from
+	var text []byte
+	var offs []int
+	start, number := -1, 0
+	for pos, n := 0, 0; pos < len(content); {
+		n++
+		le := lineEnd(content, pos)
+		if start < 0 {
+			start, number = pos, n
+		}
+		line := content[pos:le]
+		next := nextLine(content, le)
+		continued := strings.HasSuffix(line, `\`)
+		if continued {
+			line = line[:len(line)-1]
+		}
+		for i := 0; i < len(line); i++ {
+			text = append(text, line[i])
+			offs = append(offs, pos+i)
+		}
+		if !continued || next >= len(content) {
+			f.Lines = append(f.Lines, parseReqLine(string(text), offs, number, start, le))
+			text, offs, start = nil, nil, -1
+		}
+		pos = next
+	}
+	return f
+}
+
+// parseReqLine classifies a joined logical line. offs maps each byte of
+// text to its offset in the file.
+func parseReqLine(text string, offs []int, number, start, end int) ReqLine {
+	l := ReqLine{Kind: ReqLineBlank, Number: number, start: start, end: end}
+
+	body := text
+	if c := commentStart(text); c >= 0 {
+		l.comment = strings.TrimSpace(text[c:])
+		body = text[:c]
+	}
+	lead := len(body) - len(strings.TrimLeft(body, " \t"))
+	body = strings.TrimSpace(body)
+	l.Text = body
+	if body == "" {
+		return l
+	}
+
+	if strings.HasPrefix(body, "-") {
+		opt, value := splitOption(body)
+		l.Value = value
+		switch opt {
+		case "-r", "--requirement":
+			l.Kind = ReqLineInclude
+		case "-c", "--constraint":
+			l.Kind = ReqLineConstraint
+		case "-e", "--editable":
+			l.Kind = ReqLineEditable
+		default:
+			l.Kind = ReqLineOption
+		}
+		return l
+	}
+
+	// Per-requirement options such as --hash follow the requirement
+	reqText := body
+	if i := strings.Index(body, " --"); i >= 0 {
+		reqText = strings.TrimRight(body[:i], " \t")
+		l.Options = strings.Fields(body[i:])
+	} else if i := strings.Index(body, "\t--"); i >= 0 {
+		reqText = strings.TrimRight(body[:i], " \t")
+		l.Options = strings.Fields(body[i:])
+	}
+	r, err := ParseRequirement(reqText)
+	if err != nil {
+		l.Kind = ReqLineInvalid
+		return l
+	}
+	l.Kind = ReqLineRequirement
+	l.Requirement = r
+	l.reqStart = offs[lead]
+	l.reqEnd = offs[lead+len(reqText)-1] + 1
+	return l
+}
+
+// splitOption splits an option line into the option and its argument:
+// "-r base.txt", "-rbase.txt" and "--requirement=base.txt" all yield
+// ("-r" or "--requirement", "base.txt").
+func splitOption(body string) (opt, value string) {
+	opt, value, _ = strings.Cut(body, " ")
+	if o, v, ok := strings.Cut(opt, "="); ok && strings.HasPrefix(opt, "--") {
+		return o, strings.TrimSpace(v + " " + value)
+	}
+	if len(opt) > 2 && opt[1] != '-' {
+		return opt[:2], strings.TrimSpace(opt[2:] + " " + value)
+	}
+	return opt, strings.TrimSpace(value)
+}
+
+// commentStart returns the offset of a "# comment" in line, or -1. Per
+// pip's rules a "#" only starts a comment at the start of a line or after
+// whitespace, so URL fragments like "#egg=" are kept.
+func commentStart(line string) int {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return i
+		}
+	}
+	return -1
+}
+
+// Requirements returns every requirement the file declares, including
+// those of files it includes with -r, in pip's order. Constraint files
+// only restrict versions and are not included.
+func (f *RequirementsFile) Requirements() []Requirement {
+	var reqs []Requirement
+	f.walk(func(_ *RequirementsFile, l *ReqLine) {
+		if l.Kind == ReqLineRequirement {
+			reqs = append(reqs, l.Requirement)
+		}
+	})
+	return reqs
+}
+
+// EditableNames returns the normalized project names of editable installs
+// that name their project with an "#egg=" fragment, including those of
+// included files.
+func (f *RequirementsFile) EditableNames() []string {
+	var names []string
+	f.walk(func(_ *RequirementsFile, l *ReqLine) {
+		if l.Kind != ReqLineEditable {
+			return
+		}
+		if _, frag, ok := strings.Cut(l.Value, "#"); ok {
+			if q, err := url.ParseQuery(frag); err == nil && q.Get("egg") != "" {
+				names = append(names, NormalizeName(q.Get("egg")))
 			}
 		}
+	})
+	return names
+}
 
-		// Plain package name without version
-		if !strings.ContainsAny(line, "=<>!~") {
-			deps = append(deps, Dep{Name: line})
+// Paths returns the path of f and of every file it includes with -r, which
+// are the files Rewrite may change.
+func (f *RequirementsFile) Paths() []string {
+	paths := []string{f.Path}
+	f.walk(func(_ *RequirementsFile, l *ReqLine) {
+		if l.Kind == ReqLineInclude && l.File != nil {
+			paths = append(paths, l.File.Path)
+		}
+	})
+	return paths
+}
+
+// walk calls fn for every line of f and, in place of each -r line, the
+// lines of the included file.
+func (f *RequirementsFile) walk(fn func(*RequirementsFile, *ReqLine)) {
+	for i := range f.Lines {
+		l := &f.Lines[i]
+		fn(f, l)
+		if l.Kind == ReqLineInclude && l.File != nil {
+			l.File.walk(fn)
 		}
 	}
-	return deps
 }
 
-// FormatRequirementsTxt formats a list of dependencies as requirements.txt content.
-func FormatRequirementsTxt(deps []Dep) string {
+// HashFunc returns the --hash values, e.g. "sha256:...", of the files
+// published for a release.
+type HashFunc func(name, version string) ([]string, error)
+
+// Rewrite returns the new content of each file, f or one it includes, that
+// must change for the declared requirements to become reqs. Each
+// requirement is updated in the file and on the line that declares it:
+// options, comments and continuation lines are kept. Requirements no
+// longer wanted are removed with their line, and new ones are added to f,
+// in sorted position if its requirements are sorted. Constraint files are
+// never modified.
+//
+// In pip's hash-checking mode every requirement needs --hash options, so
+// when a hashed pin changes its hashes are replaced with those hashes
+// returns for the new version, in the line's layout, and requirements
+// added to a file with hashes get them too. When hashes is nil or finds
+// none, Rewrite returns an error rather than write a line pip would
+// reject.
+func (f *RequirementsFile) Rewrite(reqs []Requirement, hashes HashFunc) (map[string]string, error) {
+	pending := make(map[string][]Requirement)
+	for _, r := range reqs {
+		pending[r.Key()] = append(pending[r.Key()], r)
+	}
+
+	edits := make(map[*RequirementsFile][]textEdit)
+	var kept []*ReqLine // requirement lines of f that stay
+	var hashed *ReqLine // a line of f with --hash options, if any
+	var err error
+	f.walk(func(file *RequirementsFile, l *ReqLine) {
+		if l.Kind != ReqLineRequirement || err != nil {
+			return
+		}
+		// New lines copy the layout of a hashed line, preferably one
+		// spread over continuation lines as pip-compile writes them
+		if file == f && len(l.Hashes()) > 0 && (hashed == nil || hashed.hashIndent(f.src) == "" && l.hashIndent(f.src) != "") {
+			hashed = l
+		}
+		queue := pending[l.Requirement.Key()]
+		if len(queue) == 0 {
+			edits[file] = append(edits[file], textEdit{start: l.start, end: nextLine(file.src, l.end)})
+			return
+		}
+		want := queue[0]
+		pending[l.Requirement.Key()] = queue[1:]
+		if file == f {
+			kept = append(kept, l)
+		}
+		if want.String() != l.Requirement.String() {
+			var e textEdit
+			if e, err = l.updateEdit(file.src, want, hashes); err == nil {
+				edits[file] = append(edits[file], e)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var added []Requirement
+	for _, r := range reqs {
+		if q := pending[r.Key()]; len(q) > 0 {
+			added = append(added, q[0])
+			pending[r.Key()] = q[1:]
+		}
+	}
+	inserts, err := f.insertEdits(kept, added, hashed, hashes)
+	if err != nil {
+		return nil, err
+	}
+	edits[f] = append(edits[f], inserts...)
+
+	out := make(map[string]string)
+	for file, e := range edits {
+		if len(e) > 0 {
+			out[file.Path] = applyEdits(file.src, e)
+		}
+	}
+	return out, nil
+}
+
+// updateEdit replaces the line's requirement with want. A changed pin is
+// replaced in place so the line keeps its spacing. The --hash options of
+// a line whose pin changes are replaced with the new version's.
+func (l ReqLine) updateEdit(src string, want Requirement, hashes HashFunc) (textEdit, error) {
+	old := l.Requirement
+	text := updateEntry(src[l.reqStart:l.reqEnd], old, want)
+	if len(l.Hashes()) == 0 || old.PinnedVersion() == want.PinnedVersion() {
+		return textEdit{start: l.reqStart, end: l.reqEnd, text: text}, nil
+	}
+
+	values, err := pinHashes(want, hashes)
+	if err != nil {
+		return textEdit{}, err
+	}
+	var opts []string
+	for i := 0; i < len(l.Options); i++ {
+		switch {
+		case strings.HasPrefix(l.Options[i], "--hash="):
+		case l.Options[i] == "--hash":
+			i++
+		default:
+			opts = append(opts, l.Options[i])
+		}
+	}
+	if len(opts) > 0 {
+		text += " " + strings.Join(opts, " ")
+	}
+	text += hashOptions(values, l.hashIndent(src), newline(src))
+	if l.comment != "" {
+		text += "  " + l.comment
+	}
+	return textEdit{start: l.reqStart, end: l.end, text: text}, nil
+}
+
+// hashIndent returns the indentation of the line's continuation lines, or
+// "" when it is a single physical line.
+func (l ReqLine) hashIndent(src string) string {
+	_, rest, ok := strings.Cut(src[l.start:l.end], "\n")
+	if !ok {
+		return ""
+	}
+	if indent := rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]; indent != "" {
+		return indent
+	}
+	return "    "
+}
+
+// hashOptions formats --hash options to follow a requirement: on
+// continuation lines indented by indent, or on the same line when indent
+// is "".
+func hashOptions(values []string, indent, nl string) string {
 	var b strings.Builder
-	b.WriteString("# Generated by depman — do not edit manually\n")
-	for _, d := range deps {
-		if d.Version != "" {
-			b.WriteString(d.Name + "==" + d.Version + "\n")
+	for _, v := range values {
+		if indent == "" {
+			b.WriteString(" --hash=" + v)
 		} else {
-			b.WriteString(d.Name + "\n")
+			b.WriteString(" \\" + nl + indent + "--hash=" + v)
+		}
+	}
+	return b.String()
+}
+
+// pinHashes returns the hashes of the version want pins, for a line in
+// hash-checking mode.
+func pinHashes(want Requirement, hashes HashFunc) ([]string, error) {
+	ver := want.PinnedVersion()
+	if ver == "" {
+		return nil, fmt.Errorf("parser: %s needs --hash options, which only an == pin can have; pin it and regenerate the hashes", want)
+	}
+	if hashes == nil {
+		return nil, fmt.Errorf("parser: %s==%s needs --hash options; regenerate the hashes, e.g. with pip-compile --generate-hashes", want.Name, ver)
+	}
+	values, err := hashes(want.Name, ver)
+	if err != nil {
+		return nil, fmt.Errorf("parser: %s==%s needs --hash options, which could not be looked up (%w); regenerate the hashes, e.g. with pip-compile --generate-hashes", want.Name, ver, err)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("parser: %s==%s needs --hash options, but no release files were found to hash; regenerate the hashes, e.g. with pip-compile --generate-hashes", want.Name, ver)
+	}
+	return values, nil
+}
+
+// insertEdits adds new requirement lines to f. When the requirements kept
+// in f are sorted by name each goes in its sorted position, otherwise they
+// are appended. When hashed, a line of f with --hash options, is not nil,
+// each new line gets the hashes of its pin in the same layout.
+func (f *RequirementsFile) insertEdits(kept []*ReqLine, added []Requirement, hashed *ReqLine, hashes HashFunc) ([]textEdit, error) {
+	if len(added) == 0 {
+		return nil, nil
+	}
+	nl := newline(f.src)
+
+	sorted := true
+	for i := 1; i < len(kept); i++ {
+		if kept[i].Requirement.Key() < kept[i-1].Requirement.Key() {
+			sorted = false
+			break
 		}
 	}
+
+	var edits []textEdit
+	var appended strings.Builder
+	for _, r := range added {
+		line := r.String()
+		if hashed != nil {
+			values, err := pinHashes(r, hashes)
+			if err != nil {
+				return nil, err
+			}
+			line += hashOptions(values, hashed.hashIndent(f.src), nl)
+		}
+		if sorted {
+			if l := firstAfter(kept, r.Key()); l != nil {
+				edits = append(edits, textEdit{start: l.start, end: l.start, text: line + nl})
+				continue
+			}
+		}
+		appended.WriteString(line + nl)
+	}
+	if appended.Len() > 0 {
+		text := appended.String()
+		if f.src != "" && !strings.HasSuffix(f.src, "\n") {
+			text = nl + text
+		}
+		edits = append(edits, textEdit{start: len(f.src), end: len(f.src), text: text})
+	}
+	return edits, nil
+}
+
+func firstAfter(lines []*ReqLine, key string) *ReqLine {
+	for _, l := range lines {
+		if l.Requirement.Key() > key {
+			return l
+		}
+	}
+	return nil
+}
+
+// FormatRequirementsTxt formats a list of requirements as requirements.txt content.
+func FormatRequirementsTxt(reqs []Requirement) string {
+	var b strings.Builder
+	b.WriteString("# Generated by depman — do not edit manually\n")
+	for _, r := range reqs {
+		b.WriteString(r.String() + "\n")
+	}
 	return b.String()
 }
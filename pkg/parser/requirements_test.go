@@ -0,0 +1,253 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRequirementsFile(t *testing.T) {
+	content := "# pinned\n" +
+		"-r base.txt\n" +
+		"--constraint=constraints.txt\n" +
+		"-e git+https://example.com/repo.git#egg=mylib\n" +
+		"-i https://example.com/simple\n" +
+		"requests==2.31.0 \\\n" +
+		"    --hash=sha256:aaa \\\n" +
+		"    --hash sha256:bbb  # web\n" +
+		"attrs>=23 ; python_version >= \"3.8\"\r\n" +
+		"not a requirement!\n" +
+		"\n"
+
+	f := ParseRequirementsFile("requirements.txt", content)
+
+	expected := []struct {
+		kind   ReqLineKind
+		number int
+		value  string
+	}{
+		{ReqLineBlank, 1, ""},
+		{ReqLineInclude, 2, "base.txt"},
+		{ReqLineConstraint, 3, "constraints.txt"},
+		{ReqLineEditable, 4, "git+https://example.com/repo.git#egg=mylib"},
+		{ReqLineOption, 5, "https://example.com/simple"},
+		{ReqLineRequirement, 6, ""},
+		{ReqLineRequirement, 9, ""},
+		{ReqLineInvalid, 10, ""},
+		{ReqLineBlank, 11, ""},
+	}
+	if len(f.Lines) != len(expected) {
+		t.Fatalf("ParseRequirementsFile() returned %d lines, want %d: %+v", len(f.Lines), len(expected), f.Lines)
+	}
+	for i, want := range expected {
+		l := f.Lines[i]
+		if l.Kind != want.kind || l.Number != want.number || l.Value != want.value {
+			t.Errorf("line[%d] = kind %d, number %d, value %q; want %d, %d, %q", i, l.Kind, l.Number, l.Value, want.kind, want.number, want.value)
+		}
+	}
+
+	req := f.Lines[5]
+	if req.Requirement.String() != "requests==2.31.0" {
+		t.Errorf("requirement = %q", req.Requirement.String())
+	}
+	if hashes := req.Hashes(); len(hashes) != 2 || hashes[0] != "sha256:aaa" || hashes[1] != "sha256:bbb" {
+		t.Errorf("Hashes() = %v", hashes)
+	}
+	if req.comment != "# web" {
+		t.Errorf("comment = %q", req.comment)
+	}
+	if got := content[req.reqStart:req.reqEnd]; got != "requests==2.31.0" {
+		t.Errorf("requirement span = %q", got)
+	}
+
+	if names := f.EditableNames(); len(names) != 1 || names[0] != "mylib" {
+		t.Errorf("EditableNames() = %v, want [mylib]", names)
+	}
+}
+
+func TestReadRequirementsFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("requirements.txt", "-r reqs/dev.txt\n-c constraints.txt\nflask==3.0.0\n")
+	write("reqs/dev.txt", "-r ../base.txt\npytest==8.0.0\n")
+	write("base.txt", "requests==2.31.0\n-r requirements.txt\n")
+	write("constraints.txt", "urllib3<2\n")
+
+	f, err := ReadRequirementsFile(filepath.Join(dir, "requirements.txt"))
+	if err != nil {
+		t.Fatalf("ReadRequirementsFile() error = %v", err)
+	}
+
+	// Includes are expanded in place, the constraint file is not, and the
+	// cycle back from base.txt is not followed
+	var names []string
+	for _, r := range f.Requirements() {
+		names = append(names, r.Name)
+	}
+	if got := strings.Join(names, " "); got != "requests pytest flask" {
+		t.Errorf("Requirements() = %s, want requests pytest flask", got)
+	}
+	if c := f.Lines[1].File; c == nil || len(c.Lines) != 1 {
+		t.Errorf("constraint file not parsed: %+v", c)
+	}
+
+	write("broken.txt", "-r missing.txt\n")
+	if _, err := ReadRequirementsFile(filepath.Join(dir, "broken.txt")); err == nil {
+		t.Error("ReadRequirementsFile() with a missing include want error")
+	}
+}
+
+func TestRequirementsFileRewrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		reqs     []string
+		expected string
+	}{
+		{
+			name:     "unchanged file is untouched",
+			content:  "--index-url https://example.com/simple\nDjango == 4.2.7  # LTS\n",
+			reqs:     []string{"Django==4.2.7"},
+			expected: "",
+		},
+		{
+			name:     "pin update keeps spacing, options and comments",
+			content:  "-i https://example.com/simple\nDjango == 4.2.0  # LTS\nmylib==1.0 --config-settings=x=y\n",
+			reqs:     []string{"Django==4.2.7", "mylib==1.1"},
+			expected: "-i https://example.com/simple\nDjango == 4.2.7  # LTS\nmylib==1.1 --config-settings=x=y\n",
+		},
+		{
+			name:     "removed requirement takes its continuation lines",
+			content:  "attrs==23.1.0\nold==1.0 \\\n    --hash=sha256:aaa\nrequests>=2\n",
+			reqs:     []string{"attrs==23.1.0", "requests>=2"},
+			expected: "attrs==23.1.0\nrequests>=2\n",
+		},
+		{
+			name:     "added requirement goes in sorted position",
+			content:  "# deps\nattrs==23.1.0\nrequests>=2\n",
+			reqs:     []string{"attrs==23.1.0", "click==8.1.7", "requests>=2", "zipp==3.17.0"},
+			expected: "# deps\nattrs==23.1.0\nclick==8.1.7\nrequests>=2\nzipp==3.17.0\n",
+		},
+		{
+			name:     "added requirement appended to unsorted file",
+			content:  "requests>=2\nattrs==23.1.0",
+			reqs:     []string{"attrs==23.1.0", "click==8.1.7", "requests>=2"},
+			expected: "requests>=2\nattrs==23.1.0\nclick==8.1.7\n",
+		},
+		{
+			name:     "CRLF line endings",
+			content:  "attrs==23.1.0\r\nold==1.0\r\n",
+			reqs:     []string{"attrs==23.2.0", "click==8.1.7"},
+			expected: "attrs==23.2.0\r\nclick==8.1.7\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reqs []Requirement
+			for _, s := range tt.reqs {
+				reqs = append(reqs, mustParse(t, s))
+			}
+			got, err := ParseRequirementsFile("requirements.txt", tt.content).Rewrite(reqs, nil)
+			if err != nil {
+				t.Fatalf("Rewrite() error = %v", err)
+			}
+			if tt.expected == "" {
+				if len(got) != 0 {
+					t.Errorf("Rewrite() = %v, want no changes", got)
+				}
+				return
+			}
+			if got["requirements.txt"] != tt.expected {
+				t.Errorf("Rewrite() =\n%q\nwant:\n%q", got["requirements.txt"], tt.expected)
+			}
+		})
+	}
+}
+
+func TestRequirementsFileRewrite_Hashes(t *testing.T) {
+	hashes := func(name, version string) ([]string, error) {
+		switch name + "==" + version {
+		case "requests==2.31.0":
+			return []string{"sha256:new1", "sha256:new2"}, nil
+		case "idna==3.6":
+			return []string{"sha256:idna"}, nil
+		}
+		return nil, nil
+	}
+	content := "attrs==23.1.0 --hash=sha256:ccc\r\n" +
+		"requests==2.30.0 \\\r\n" +
+		"\t--hash=sha256:aaa \\\r\n" +
+		"\t--hash=sha256:bbb  # web\r\n"
+	reqs := []Requirement{mustParse(t, "attrs==23.1.0"), mustParse(t, "idna==3.6"), mustParse(t, "requests==2.31.0")}
+
+	got, err := ParseRequirementsFile("requirements.txt", content).Rewrite(reqs, hashes)
+	if err != nil {
+		t.Fatalf("Rewrite() error = %v", err)
+	}
+	expected := "attrs==23.1.0 --hash=sha256:ccc\r\n" +
+		"idna==3.6 \\\r\n" +
+		"\t--hash=sha256:idna\r\n" +
+		"requests==2.31.0 \\\r\n" +
+		"\t--hash=sha256:new1 \\\r\n" +
+		"\t--hash=sha256:new2  # web\r\n"
+	if got["requirements.txt"] != expected {
+		t.Errorf("Rewrite() =\n%q\nwant:\n%q", got["requirements.txt"], expected)
+	}
+
+	// Without hashes for the new version pip would reject the file
+	for _, h := range []HashFunc{nil, hashes} {
+		_, err := ParseRequirementsFile("requirements.txt", content).Rewrite([]Requirement{
+			mustParse(t, "attrs==23.2.0"), mustParse(t, "requests==2.30.0"),
+		}, h)
+		if err == nil || !strings.Contains(err.Error(), "attrs==23.2.0") {
+			t.Errorf("Rewrite() error = %v, want one naming attrs==23.2.0", err)
+		}
+	}
+}
+
+func TestRequirementsFileRewrite_Includes(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "requirements.txt")
+	base := filepath.Join(dir, "base.txt")
+	constraints := filepath.Join(dir, "constraints.txt")
+	os.WriteFile(root, []byte("-r base.txt\n-c constraints.txt\npytest==7.4.0\n"), 0o644)
+	os.WriteFile(base, []byte("# shared\nrequests==2.30.0\nold==1.0\n"), 0o644)
+	os.WriteFile(constraints, []byte("requests==2.30.0\n"), 0o644)
+
+	f, err := ReadRequirementsFile(root)
+	if err != nil {
+		t.Fatalf("ReadRequirementsFile() error = %v", err)
+	}
+	got, err := f.Rewrite([]Requirement{
+		mustParse(t, "click==8.1.7"),
+		mustParse(t, "pytest==7.4.0"),
+		mustParse(t, "requests==2.31.0"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("Rewrite() error = %v", err)
+	}
+
+	expected := map[string]string{
+		root: "-r base.txt\n-c constraints.txt\nclick==8.1.7\npytest==7.4.0\n",
+		base: "# shared\nrequests==2.31.0\n",
+	}
+	if len(got) != len(expected) {
+		t.Errorf("Rewrite() changed %d files, want %d: %v", len(got), len(expected), got)
+	}
+	for path, want := range expected {
+		if got[path] != want {
+			t.Errorf("%s =\n%q\nwant:\n%q", filepath.Base(path), got[path], want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package parser
+
+import (
+	"strings"
+)
+
+// pyToken is a token of Python source, as far as scanning a setup.py file
+// for literals needs.
+type pyToken struct {
+	kind  byte   // 'n' name, 's' string, 'p' punctuation, 'o' anything else
+	text  string // the name, the punctuation, or the decoded string
+	fixed bool   // a string with no f-prefix, so its value is known
+}
+
+// lexPython splits Python source into tokens. Adjacent string literals are
+// joined, as Python concatenates them.
+func lexPython(src string) []pyToken {
+	var toks []pyToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\\':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case isPyNameByte(c) && !isDigit(c):
+			start := i
+			for i < len(src) && isPyNameByte(src[i]) {
+				i++
+			}
+			name := src[start:i]
+			if i < len(src) && (src[i] == '"' || src[i] == '\'') && len(name) <= 2 && strings.Trim(strings.ToLower(name), "rbuf") == "" {
+				tok, end := lexPythonString(src, i, strings.ToLower(name))
+				toks, i = appendPyString(toks, tok), end
+				continue
+			}
+			toks = append(toks, pyToken{kind: 'n', text: name})
+		case c == '"' || c == '\'':
+			tok, end := lexPythonString(src, i, "")
+			toks, i = appendPyString(toks, tok), end
+		case isDigit(c):
+			start := i
+			for i < len(src) && (isPyNameByte(src[i]) || src[i] == '.') {
+				i++
+			}
+			toks = append(toks, pyToken{kind: 'o', text: src[start:i]})
+		default:
+			// "==", "<=", ">=" and "!=" must not read as an assignment
+			if i+1 < len(src) && src[i+1] == '=' && strings.IndexByte("=<>!", c) >= 0 {
+				toks = append(toks, pyToken{kind: 'o', text: src[i : i+2]})
+				i += 2
+				continue
+			}
+			toks = append(toks, pyToken{kind: 'p', text: src[i : i+1]})
+			i++
+		}
+	}
+	return toks
+}
+
+// lexPythonString reads the string literal whose quote is at src[i], with
+// the given prefix, and returns it with the offset past it.
+func lexPythonString(src string, i int, prefix string) (pyToken, int) {
+	quote := src[i : i+1]
+	if strings.HasPrefix(src[i:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	raw := strings.Contains(prefix, "r")
+	var b strings.Builder
+	j := i + len(quote)
+	for j < len(src) && !strings.HasPrefix(src[j:], quote) {
+		if src[j] == '\\' && !raw && j+1 < len(src) {
+			j++
+			switch src[j] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '\n':
+			default:
+				b.WriteByte(src[j])
+			}
+			j++
+			continue
+		}
+		if len(quote) == 1 && src[j] == '\n' {
+			break // unterminated
+		}
+		b.WriteByte(src[j])
+		j++
+	}
+	return pyToken{kind: 's', text: b.String(), fixed: !strings.Contains(prefix, "f")}, min(j+len(quote), len(src))
+}
+
+// appendPyString appends a string token, joining it to a string just
+// before it.
+func appendPyString(toks []pyToken, tok pyToken) []pyToken {
+	if n := len(toks); n > 0 && toks[n-1].kind == 's' {
+		toks[n-1].text += tok.text
+		toks[n-1].fixed = toks[n-1].fixed && tok.fixed
+		return toks
+	}
+	return append(toks, tok)
+}
+
+func isPyNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c >= 0x80
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// setupPy is what a static scan of a setup.py file finds in its call to
+// setup(): the keyword arguments, and the top-level assignments they may
+// refer to.
+type setupPy struct {
+	toks      []pyToken
+	kwargs    map[string]int // keyword → index of its value's first token
+	variables map[string]int // name → index of its value's first token
+}
+
+// scanSetupPy finds the keyword arguments of the setup() call and the
+// top-level assignments of a setup.py file.
+func scanSetupPy(content string) *setupPy {
+	s := &setupPy{toks: lexPython(content), kwargs: make(map[string]int), variables: make(map[string]int)}
+	depth, call := 0, -1 // call is the depth inside setup(...)
+	for i, t := range s.toks {
+		if t.kind == 'p' {
+			switch t.text {
+			case "(", "[", "{":
+				if call < 0 && t.text == "(" && i > 0 && s.toks[i-1].kind == 'n' && strings.HasSuffix("."+s.toks[i-1].text, ".setup") {
+					call = depth + 1
+				}
+				depth++
+			case ")", "]", "}":
+				if depth == call {
+					call = 0 // only the first call counts
+				}
+				depth--
+			}
+			continue
+		}
+		if t.kind != 'n' || i+2 >= len(s.toks) || s.toks[i+1].kind != 'p' || s.toks[i+1].text != "=" {
+			continue
+		}
+		switch {
+		case depth == 0:
+			s.variables[t.text] = i + 2
+		case depth == call && (s.toks[i-1].text == "(" || s.toks[i-1].text == ","):
+			s.kwargs[t.text] = i + 2
+		}
+	}
+	return s
+}
+
+// resolve returns the index of the value of the keyword argument, after
+// following a reference to a top-level variable. ok is false if the
+// argument is missing.
+func (s *setupPy) resolve(kwarg string) (int, bool) {
+	i, ok := s.kwargs[kwarg]
+	if !ok {
+		return 0, false
+	}
+	if s.toks[i].kind == 'n' && (i+1 >= len(s.toks) || s.toks[i+1].text == "," || s.toks[i+1].text == ")") {
+		if v, ok := s.variables[s.toks[i].text]; ok {
+			return v, true
+		}
+	}
+	return i, true
+}
+
+// str returns the value of a keyword argument that is a string literal.
+func (s *setupPy) str(kwarg string) (value string, static bool) {
+	i, ok := s.resolve(kwarg)
+	if !ok {
+		return "", true
+	}
+	if t := s.toks[i]; t.kind == 's' && t.fixed && s.endsValue(i+1) {
+		return t.text, true
+	}
+	return "", false
+}
+
+// list returns the value of a keyword argument that is a list or tuple of
+// string literals. static is false if it is anything else.
+func (s *setupPy) list(kwarg string) (values []string, static bool) {
+	i, ok := s.resolve(kwarg)
+	if !ok {
+		return nil, true
+	}
+	values, end, ok := s.literalList(i)
+	return values, ok && s.endsValue(end)
+}
+
+// dict returns the value of a keyword argument that is a dict literal with
+// string keys whose values are lists of string literals.
+func (s *setupPy) dict(kwarg string) (values map[string][]string, static bool) {
+	i, ok := s.resolve(kwarg)
+	if !ok {
+		return nil, true
+	}
+	if s.toks[i].text != "{" {
+		return nil, false
+	}
+	values = make(map[string][]string)
+	for i++; i < len(s.toks) && s.toks[i].text != "}"; {
+		key := s.toks[i]
+		if key.kind != 's' || !key.fixed || i+1 >= len(s.toks) || s.toks[i+1].text != ":" {
+			return nil, false
+		}
+		list, end, ok := s.literalList(i + 2)
+		if !ok {
+			return nil, false
+		}
+		values[key.text] = list
+		i = end
+		if i < len(s.toks) && s.toks[i].text == "," {
+			i++
+		}
+	}
+	return values, i < len(s.toks) && s.endsValue(i+1)
+}
+
+// literalList reads the list or tuple of string literals starting at
+// toks[i], a single string counting as a list of its lines, and returns
+// the index past it.
+func (s *setupPy) literalList(i int) (values []string, end int, ok bool) {
+	if i >= len(s.toks) {
+		return nil, i, false
+	}
+	if t := s.toks[i]; t.kind == 's' {
+		// setuptools splits a string into lines
+		for _, line := range strings.Split(t.text, "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				values = append(values, line)
+			}
+		}
+		return values, i + 1, t.fixed
+	}
+	closing := map[string]string{"[": "]", "(": ")"}[s.toks[i].text]
+	if closing == "" {
+		return nil, i, false
+	}
+	for i++; i < len(s.toks); i++ {
+		t := s.toks[i]
+		switch {
+		case t.text == closing:
+			return values, i + 1, true
+		case t.kind == 's' && t.fixed:
+			values = append(values, strings.TrimSpace(t.text))
+			if i+1 < len(s.toks) && s.toks[i+1].text == "," {
+				i++
+			}
+		default:
+			return nil, i, false
+		}
+	}
+	return nil, i, false
+}
+
+// endsValue reports whether the value ends before toks[i], rather than
+// continuing as an expression such as `base + ["extra"]`.
+func (s *setupPy) endsValue(i int) bool {
+	if i >= len(s.toks) {
+		return true
+	}
+	t := s.toks[i]
+	return t.kind == 'n' || t.kind == 'p' && strings.Contains(",)}]", t.text)
+}
+
+// ParseSetupPy statically extracts the dependency groups of a setup.py
+// file: the install_requires of its setup() call as the main group, then
+// each extra of extras_require as an optional group, sorted by name. Only
+// lists of string literals are read, given in the call or assigned to a
+// top-level variable; a list computed by code reads as empty.
+func ParseSetupPy(content string) []DependencyGroup {
+	groups, _ := setupPyGroups(scanSetupPy(content))
+	return groups
+}
+
+// setupPyGroups returns the dependency groups of a setup.py file, and
+// whether they are all static.
+func setupPyGroups(s *setupPy) ([]DependencyGroup, bool) {
+	main, static := s.list("install_requires")
+	groups := []DependencyGroup{{Group: MainGroup, Requirements: parseEntries(main)}}
+	extras, ok := s.dict("extras_require")
+	for _, name := range sortedKeys(extras) {
+		groups = append(groups, DependencyGroup{
+			Group:        Group{Kind: GroupOptional, Name: name},
+			Requirements: parseEntries(extras[name]),
+		})
+	}
+	return groups, static && ok
+}
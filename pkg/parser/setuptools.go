@@ -0,0 +1,404 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/eslam/depman/pkg/detector"
+)
+
+// iniDocument locates the sections and keys of a setup.cfg file, which
+// setuptools reads with Python's configparser.
+type iniDocument struct {
+	sections []*iniSection
+}
+
+// iniSection is a [section] of an INI file.
+type iniSection struct {
+	name string
+	end  int // offset past its last non-blank line, where keys are appended
+	keys []*iniKey
+}
+
+// iniKey is a "key = value" entry, whose value may continue on indented
+// lines.
+type iniKey struct {
+	name   string   // normalized by iniKeyName
+	line   textLine // the key's own line
+	end    int      // offset past its last value line
+	inline bool     // the value starts on the key's line
+	items  []lineItem
+}
+
+// scanINI locates the sections and keys of an INI file. Lines starting
+// with "#" or ";" are comments, even within a value, as for configparser.
+func scanINI(src string) *iniDocument {
+	doc := &iniDocument{}
+	var section *iniSection
+	var key *iniKey
+	for start := 0; start < len(src); {
+		end := strings.IndexByte(src[start:], '\n')
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += start + 1
+		}
+		line := textLine{start: start, end: end}
+		start = end
+
+		text := strings.TrimRight(src[line.start:line.end], "\r\n")
+		body := strings.TrimLeft(text, " \t")
+		line.indent = len(text) - len(body)
+		if body == "" || body[0] == '#' || body[0] == ';' {
+			continue
+		}
+
+		switch {
+		case line.indent > 0 && key != nil:
+			// A value continued on an indented line
+			value := strings.TrimRight(body, " \t")
+			item := line.start + line.indent
+			key.items = append(key.items, lineItem{line: line, start: item, end: item + len(value), value: value})
+			key.end, section.end = line.end, line.end
+		case strings.HasPrefix(body, "["):
+			name := strings.TrimSpace(strings.Trim(strings.TrimSpace(body), "[]"))
+			section = &iniSection{name: name, end: line.end}
+			doc.sections = append(doc.sections, section)
+			key = nil
+		case section != nil:
+			sep := strings.IndexAny(body, "=:")
+			if sep < 0 {
+				key = nil
+				continue
+			}
+			key = &iniKey{name: iniKeyName(body[:sep]), line: line, end: line.end}
+			valueStart := line.start + line.indent + sep + 1
+			value := strings.TrimSpace(body[sep+1:])
+			if value != "" {
+				item := valueStart + strings.Index(src[valueStart:line.end], value)
+				key.inline = true
+				key.items = append(key.items, lineItem{line: line, start: item, end: item + len(value), value: value})
+			}
+			section.keys = append(section.keys, key)
+			section.end = line.end
+		}
+	}
+	return doc
+}
+
+// iniKeyName normalizes a setup.cfg key: setuptools accepts dashes for
+// underscores, and configparser ignores case.
+func iniKeyName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "-", "_"))
+}
+
+// section returns the section called name, or nil.
+func (d *iniDocument) section(name string) *iniSection {
+	for _, s := range d.sections {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// key returns the key called name in the section, or nil.
+func (s *iniSection) key(name string) *iniKey {
+	if s == nil {
+		return nil
+	}
+	for _, k := range s.keys {
+		if k.name == iniKeyName(name) {
+			return k
+		}
+	}
+	return nil
+}
+
+// value returns the key's value: its lines joined with newlines.
+func (k *iniKey) value() string {
+	if k == nil {
+		return ""
+	}
+	lines := make([]string, len(k.items))
+	for i, item := range k.items {
+		lines[i] = item.value
+	}
+	return strings.Join(lines, "\n")
+}
+
+// requirements reads the key's value as setuptools reads a list of
+// requirements: one per line, or separated by ";" on a single line.
+// Entries that are not PEP 508 requirements, such as "file:" directives,
+// are skipped.
+func (k *iniKey) requirements() []Requirement {
+	if k == nil {
+		return nil
+	}
+	var entries []string
+	if len(k.items) == 1 {
+		entries = strings.Split(k.items[0].value, ";")
+	} else {
+		for _, item := range k.items {
+			entries = append(entries, item.value)
+		}
+	}
+	for i := range entries {
+		entries[i] = strings.TrimSpace(entries[i])
+	}
+	return parseEntries(entries)
+}
+
+// setupCfgLocation returns the section and key of setup.cfg that hold g:
+// install_requires for the main group and [options.extras_require] for
+// extras.
+func setupCfgLocation(g Group) (section, key string, ok bool) {
+	switch g.Kind {
+	case GroupMain:
+		return "options", "install_requires", true
+	case GroupOptional:
+		return "options.extras_require", g.Name, true
+	}
+	return "", "", false
+}
+
+// ParseSetupCfg extracts the dependency groups of a setup.cfg file: the
+// install_requires of [options] as the main group, then each extra of
+// [options.extras_require] as an optional group, sorted by name.
+func ParseSetupCfg(content string) []DependencyGroup {
+	doc := scanINI(content)
+	groups := []DependencyGroup{{Group: MainGroup, Requirements: doc.section("options").key("install_requires").requirements()}}
+	extras := make(map[string]*iniKey)
+	if s := doc.section("options.extras_require"); s != nil {
+		for _, k := range s.keys {
+			extras[k.name] = k
+		}
+	}
+	for _, name := range sortedKeys(extras) {
+		groups = append(groups, DependencyGroup{
+			Group:        Group{Kind: GroupOptional, Name: name},
+			Requirements: extras[name].requirements(),
+		})
+	}
+	return groups
+}
+
+// RewriteSetupCfg updates install_requires and the extras of a setup.cfg
+// file to match groups with the smallest possible edit. In a value written
+// one requirement per line, unchanged lines are kept, changed ones keep
+// their indentation, removed ones lose their line, and new ones are
+// appended with the indentation of the last. A value written on one line
+// is rewritten as a whole. Missing keys and sections are created.
+// Comments and every other key are preserved.
+func RewriteSetupCfg(content string, groups []DependencyGroup) (string, error) {
+	doc := scanINI(content)
+	nl := newline(content)
+
+	var edits []textEdit
+	missing := make(map[string]string) // section → keys to append
+	var missingOrder []string
+	for _, g := range groups {
+		sectionName, keyName, ok := setupCfgLocation(g.Group)
+		if !ok {
+			return "", fmt.Errorf("parser: setup.cfg: no key for group %s (want main or optional:<extra>)", g.Group)
+		}
+		section := doc.section(sectionName)
+		key := section.key(keyName)
+		if strings.HasPrefix(key.value(), "file:") {
+			return "", fmt.Errorf("parser: setup.cfg: %s reads its requirements from %s; edit that file instead", keyName, strings.TrimSpace(strings.TrimPrefix(key.value(), "file:")))
+		}
+		switch {
+		case key == nil && len(g.Requirements) == 0:
+		case key == nil && section != nil:
+			edits = append(edits, textEdit{start: section.end, end: section.end, text: setupCfgKey(keyName, g.Requirements, nl)})
+		case key == nil:
+			if _, ok := missing[sectionName]; !ok {
+				missingOrder = append(missingOrder, sectionName)
+			}
+			missing[sectionName] += setupCfgKey(keyName, g.Requirements, nl)
+		case key.inline && len(key.items) == 1:
+			edits = append(edits, setupCfgInlineEdit(content, key, g.Requirements, nl)...)
+		default:
+			items := slices.Clone(key.items)
+			if key.inline {
+				// Removing the value on the key's line must keep the key
+				items[0].line = textLine{start: items[0].start, end: items[0].end}
+			}
+			keyEdits, added := lineListEdits(items, g.Requirements, ParseRequirement, Requirement.String)
+			edits = append(edits, keyEdits...)
+			if len(added) == 0 {
+				continue
+			}
+			indent, at := "    ", key.end
+			if n := len(key.items); n > 0 && !(n == 1 && key.inline) {
+				last := key.items[n-1]
+				indent, at = content[last.line.start:last.start], last.line.end
+			}
+			var text strings.Builder
+			for _, spec := range added {
+				text.WriteString(indent + spec + nl)
+			}
+			edits = append(edits, textEdit{start: at, end: at, text: text.String()})
+		}
+	}
+	content = applyEdits(content, edits)
+
+	for _, name := range missingOrder {
+		if content != "" {
+			if !strings.HasSuffix(content, "\n") {
+				content += nl
+			}
+			content += nl
+		}
+		content += "[" + name + "]" + nl + missing[name]
+	}
+	return content, nil
+}
+
+// setupCfgKey formats a key whose value lists reqs one per line.
+func setupCfgKey(name string, reqs []Requirement, nl string) string {
+	var b strings.Builder
+	b.WriteString(name + " =" + nl)
+	for _, r := range reqs {
+		b.WriteString("    " + r.String() + nl)
+	}
+	return b.String()
+}
+
+// setupCfgInlineEdit rewrites a value written on the key's line. It stays
+// on one line unless a requirement has a marker, whose ";" would split it.
+func setupCfgInlineEdit(src string, key *iniKey, reqs []Requirement, nl string) []textEdit {
+	if requirementStrings(key.requirements()) == requirementStrings(reqs) {
+		return nil
+	}
+	item := key.items[0]
+	specs := make([]string, len(reqs))
+	oneLine := true
+	for i, r := range reqs {
+		specs[i] = r.String()
+		oneLine = oneLine && r.Marker == ""
+	}
+	if oneLine {
+		return []textEdit{{start: item.start, end: item.end, text: strings.Join(specs, "; ")}}
+	}
+	// Move the value to its own lines, starting right after the separator
+	start := item.start
+	for start > key.line.start && (src[start-1] == ' ' || src[start-1] == '\t') {
+		start--
+	}
+	var text strings.Builder
+	for _, spec := range specs {
+		text.WriteString(nl + "    " + spec)
+	}
+	return []textEdit{{start: start, end: item.end, text: text.String()}}
+}
+
+// requirementStrings returns reqs formatted one per line, to compare lists.
+func requirementStrings(reqs []Requirement) string {
+	specs := make([]string, len(reqs))
+	for i, r := range reqs {
+		specs[i] = r.String()
+	}
+	return strings.Join(specs, "\n")
+}
+
+// ParseSetupCfgMetadata reads the metadata of a setup.cfg file, from
+// [metadata] and the python_requires of [options].
+func ParseSetupCfgMetadata(content string) ProjectMetadata {
+	doc := scanINI(content)
+	metadata := doc.section("metadata")
+	meta := ProjectMetadata{
+		Name:           metadata.key("name").value(),
+		Version:        metadata.key("version").value(),
+		Description:    metadata.key("description").value(),
+		RequiresPython: doc.section("options").key("python_requires").value(),
+	}
+	switch {
+	case strings.HasPrefix(meta.Version, "attr:"):
+		meta.VersionAttr = strings.TrimSpace(strings.TrimPrefix(meta.Version, "attr:"))
+	case strings.HasPrefix(meta.Version, "file:"):
+		meta.VersionFile = strings.TrimSpace(strings.TrimPrefix(meta.Version, "file:"))
+	default:
+		return meta
+	}
+	meta.Version, meta.DynamicVersion = "", true
+	return meta
+}
+
+// ParseSetupPyMetadata statically reads the metadata of a setup.py file
+// from the string literals of its setup() call. A version computed by code
+// is dynamic.
+func ParseSetupPyMetadata(content string) ProjectMetadata {
+	s := scanSetupPy(content)
+	var meta ProjectMetadata
+	meta.Name, _ = s.str("name")
+	meta.Description, _ = s.str("description")
+	meta.RequiresPython, _ = s.str("python_requires")
+	version, static := s.str("version")
+	if _, ok := s.kwargs["version"]; ok && !static {
+		meta.DynamicVersion = true
+	}
+	meta.Version = version
+	return meta
+}
+
+// readSetuptoolsSource reads the metadata and dependencies of a setup.cfg
+// or setup.py project. Metadata missing from setup.cfg is looked up in
+// setup.py. Dependencies computed by code in setup.py cannot be migrated.
+func readSetuptoolsSource(project detector.Project) (migrationSource, error) {
+	content, err := os.ReadFile(project.FilePath)
+	if err != nil {
+		return migrationSource{}, fmt.Errorf("parser: read file: %w", err)
+	}
+	var src migrationSource
+	switch project.FileType {
+	case detector.FileSetupCfg:
+		src.meta, src.groups = ParseSetupCfgMetadata(string(content)), ParseSetupCfg(string(content))
+		if py, err := os.ReadFile(filepath.Join(project.Dir, "setup.py")); err == nil {
+			src.meta = mergeMetadata(src.meta, ParseSetupPyMetadata(string(py)))
+		}
+	default:
+		var static bool
+		src.meta = ParseSetupPyMetadata(string(content))
+		if src.groups, static = setupPyGroups(scanSetupPy(string(content))); !static {
+			return migrationSource{}, fmt.Errorf("parser: setup.py: install_requires or extras_require is computed by code; declare the packages by hand")
+		}
+	}
+	if src.meta.Name == "" {
+		return migrationSource{}, fmt.Errorf("parser: %s: no project name found", filepath.Base(project.FilePath))
+	}
+	if src.meta.Version == "" && !src.meta.DynamicVersion {
+		// setuptools defaults to 0.0.0, which a [project] table must spell out
+		src.meta.Version = "0.0.0"
+	}
+	src.buildSystem = setuptoolsBuildSystem
+	return src, nil
+}
+
+// setuptoolsBuildSystem is the [build-system] table of a pyproject.toml
+// file created for a setuptools project; setuptools reads [project] tables
+// since version 61.
+const setuptoolsBuildSystem = `[build-system]
+requires = ["setuptools>=61"]
+build-backend = "setuptools.build_meta"
+`
+
+// mergeMetadata fills the fields meta lacks from other.
+func mergeMetadata(meta, other ProjectMetadata) ProjectMetadata {
+	if meta.Name == "" {
+		meta.Name = other.Name
+	}
+	if meta.Version == "" && !meta.DynamicVersion {
+		meta.Version, meta.DynamicVersion = other.Version, other.DynamicVersion
+	}
+	if meta.Description == "" {
+		meta.Description = other.Description
+	}
+	if meta.RequiresPython == "" {
+		meta.RequiresPython = other.RequiresPython
+	}
+	return meta
+}
@@ -0,0 +1,232 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eslam/depman/pkg/detector"
+)
+
+const testSetupCfg = `[metadata]
+name = legacy-lib
+version = attr: legacy.__version__
+description = An old library
+
+[options]
+packages = find:
+python_requires = >=3.8
+install_requires =
+    requests>=2.28
+    # keep below 2 for now
+    click<9
+    PyYAML
+
+[options.extras_require]
+test = pytest; coverage>=7
+
+[flake8]
+max-line-length = 100
+`
+
+// groupLines formats groups one per line, for comparisons.
+func groupLines(groups []DependencyGroup) string {
+	var lines []string
+	for _, g := range groups {
+		var reqs []string
+		for _, r := range g.Requirements {
+			reqs = append(reqs, r.String())
+		}
+		lines = append(lines, g.String()+": "+strings.Join(reqs, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestParseSetupCfg(t *testing.T) {
+	got := groupLines(ParseSetupCfg(testSetupCfg))
+	expected := "main: requests>=2.28, click<9, PyYAML\noptional:test: pytest, coverage>=7"
+	if got != expected {
+		t.Errorf("ParseSetupCfg() =\n%s\nwant:\n%s", got, expected)
+	}
+
+	meta := ParseSetupCfgMetadata(testSetupCfg)
+	if meta.Name != "legacy-lib" || !meta.DynamicVersion || meta.VersionAttr != "legacy.__version__" || meta.RequiresPython != ">=3.8" {
+		t.Errorf("ParseSetupCfgMetadata() = %+v", meta)
+	}
+}
+
+func TestRewriteSetupCfg(t *testing.T) {
+	click, _ := ParseRequirement("click>=8,<9")
+	rich, _ := ParseRequirement("rich")
+	marked, _ := ParseRequirement(`tomli; python_version < "3.11"`)
+	got, err := RewriteSetupCfg(testSetupCfg, []DependencyGroup{
+		{Group: MainGroup, Requirements: []Requirement{Pinned("requests", "2.31.0"), click, rich}},
+		{Group: Group{Kind: GroupOptional, Name: "test"}, Requirements: []Requirement{{Name: "pytest"}, marked}},
+		{Group: Group{Kind: GroupOptional, Name: "docs"}, Requirements: []Requirement{{Name: "sphinx"}}},
+	})
+	if err != nil {
+		t.Fatalf("RewriteSetupCfg() error = %v", err)
+	}
+	expected := `[metadata]
+name = legacy-lib
+version = attr: legacy.__version__
+description = An old library
+
+[options]
+packages = find:
+python_requires = >=3.8
+install_requires =
+    requests==2.31.0
+    # keep below 2 for now
+    click>=8,<9
+    rich
+
+[options.extras_require]
+test =
+    pytest
+    tomli; python_version < "3.11"
+docs =
+    sphinx
+
+[flake8]
+max-line-length = 100
+`
+	if got != expected {
+		t.Errorf("RewriteSetupCfg() =\n%s\nwant:\n%s", got, expected)
+	}
+
+	// Missing keys and sections are created
+	got, err = RewriteSetupCfg("[metadata]\nname = app\n", []DependencyGroup{{Group: MainGroup, Requirements: []Requirement{rich}}})
+	if expected := "[metadata]\nname = app\n\n[options]\ninstall_requires =\n    rich\n"; err != nil || got != expected {
+		t.Errorf("RewriteSetupCfg(no options) = %q, %v, want %q", got, err, expected)
+	}
+
+	if _, err := RewriteSetupCfg("[options]\ninstall_requires = file: requirements.in\n", []DependencyGroup{{Group: MainGroup}}); err == nil {
+		t.Error("RewriteSetupCfg(file: value): expected an error")
+	}
+	if _, err := RewriteSetupCfg(testSetupCfg, []DependencyGroup{{Group: Group{Kind: GroupDependency, Name: "dev"}}}); err == nil {
+		t.Error("RewriteSetupCfg(dev group): expected an error")
+	}
+}
+
+func TestParseSetupPy(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		static   bool
+	}{
+		{
+			name: "literal lists",
+			content: `from setuptools import setup
+
+setup(
+    name="legacy",
+    install_requires=[
+        "requests>=2.28",  # HTTP
+        'click' "<9",
+    ],
+    extras_require={"test": ["pytest"], 'docs': ("sphinx",)},
+)
+`,
+			expected: "main: requests>=2.28, click<9\noptional:docs: sphinx\noptional:test: pytest",
+			static:   true,
+		},
+		{
+			name: "variable",
+			content: `REQUIRES = ["numpy", "scipy>=1.10"]
+setuptools.setup(name="x", install_requires=REQUIRES)
+`,
+			expected: "main: numpy, scipy>=1.10",
+			static:   true,
+		},
+		{
+			name: "computed",
+			content: `setup(install_requires=open("requirements.txt").read().splitlines())
+`,
+			expected: "main: ",
+			static:   false,
+		},
+		{
+			name: "concatenated",
+			content: `BASE = ["numpy"]
+setup(install_requires=BASE + ["scipy"])
+`,
+			expected: "main: ",
+			static:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, static := setupPyGroups(scanSetupPy(tt.content))
+			if got := groupLines(groups); got != tt.expected || static != tt.static {
+				t.Errorf("setupPyGroups() =\n%s (static %v)\nwant:\n%s (static %v)", got, static, tt.expected, tt.static)
+			}
+		})
+	}
+
+	meta := ParseSetupPyMetadata(`setup(name="legacy", version=get_version(), python_requires=">=3.9")`)
+	if meta.Name != "legacy" || !meta.DynamicVersion || meta.RequiresPython != ">=3.9" {
+		t.Errorf("ParseSetupPyMetadata() = %+v", meta)
+	}
+}
+
+func TestMigrateSetuptools(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "setup.cfg")
+	if err := os.WriteFile(path, []byte(testSetupCfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	project := detector.DetectProject(dir)
+	if project.FileType != detector.FileSetupCfg {
+		t.Fatalf("DetectProject() = %v, want setup.cfg", project.FileType)
+	}
+
+	m, err := MigrateProject(project, ToPyproject)
+	if err != nil {
+		t.Fatalf("MigrateProject() error = %v", err)
+	}
+	expected := `[build-system]
+requires = ["setuptools>=61"]
+build-backend = "setuptools.build_meta"
+
+[project]
+name = "legacy-lib"
+dynamic = ["version"]
+description = "An old library"
+requires-python = ">=3.8"
+dependencies = [
+    "requests>=2.28",
+    "click<9",
+    "PyYAML",
+]
+
+[project.optional-dependencies]
+test = [
+    "pytest",
+    "coverage>=7",
+]
+
+[tool.setuptools.dynamic]
+version = {attr = "legacy.__version__"}
+`
+	if len(m.Files) != 1 || m.Files[0].Content != expected {
+		t.Errorf("MigrateProject() = %+v\nwant:\n%s", m.Files, expected)
+	}
+	if err := m.Write(); err != nil {
+		t.Fatal(err)
+	}
+	migrated := detector.DetectProject(dir)
+	if migrated.FileType != detector.FilePyprojectTOML {
+		t.Fatalf("DetectProject(migrated) = %v, want pyproject.toml", migrated.FileType)
+	}
+	groups, err := ReadDependencyGroups(migrated)
+	if err != nil || groupLines(groups) != groupLines(ParseSetupCfg(testSetupCfg)) {
+		t.Errorf("ReadDependencyGroups(migrated) =\n%s, %v", groupLines(groups), err)
+	}
+
+	if _, err := MigrateProject(project, ToPyproject); err == nil {
+		t.Error("MigrateProject(existing [project]): expected an error")
+	}
+}
@@ -1,14 +1,81 @@
 package parser
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/audit"
 	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/log"
 	"github.com/eslam/depman/pkg/pip"
 )
 
+// SyncMode selects which packages are written to the dependency file.
+type SyncMode string
+
+const (
+	// SyncDeclared writes only direct dependencies: the requirements already
+	// declared in the file plus packages the user explicitly added.
+	SyncDeclared SyncMode = "declared"
+	// SyncAll writes every installed distribution, including transitive
+	// dependencies.
+	SyncAll SyncMode = "all"
+)
+
+// ParseSyncMode parses a mode name. The empty string selects SyncDeclared.
+func ParseSyncMode(s string) (SyncMode, error) {
+	switch SyncMode(s) {
+	case "", SyncDeclared:
+		return SyncDeclared, nil
+	case SyncAll:
+		return SyncAll, nil
+	}
+	return "", fmt.Errorf("parser: unknown sync mode %q (want declared or all)", s)
+}
+
+// SyncOptions controls SyncDependencyFile.
+type SyncOptions struct {
+	Mode     SyncMode
+	LockFile string   // if set, every installed package is also pinned here; relative to the project dir
+	Hashes   HashFunc // looks up the --hash values of new pins in a hashed requirements file; nil refuses to repin one
+}
+
+// SyncOptionsFromConfig converts the [sync] config section to SyncOptions.
+func SyncOptionsFromConfig(cfg config.SyncConfig) SyncOptions {
+	return SyncOptions{Mode: SyncMode(cfg.Mode), LockFile: cfg.LockFile}
+}
+
 // SyncDependencyFile runs the full sync cycle after a package operation:
-// 1. Query the full list of installed packages
-// 2. Rewrite the dependency file from scratch
-func SyncDependencyFile(project detector.Project, runner *pip.Runner) error {
+//  1. Query the full list of installed packages
+//  2. Rewrite the dependency file according to opts.Mode and the recorded
+//     intents, then clear the intents
+//  3. Pin every installed package in opts.LockFile, if set
+//
+// Rewrites that change a requirement are recorded in runner.Journal. When
+// the runner's backend owns the project's files, as uv does with a
+// uv.lock, it has already declared the change and nothing is rewritten.
+// Files owned by a tool the runner does not drive, and read-only files,
+// are never rewritten.
+func SyncDependencyFile(project detector.Project, runner *pip.Runner, opts SyncOptions) error {
+	mode, err := ParseSyncMode(string(opts.Mode))
+	if err != nil {
+		return err
+	}
+	switch {
+	case runner.ManagesProject():
+		log.Debug("dependency file managed by the package manager, not rewriting", "file", project.FilePath)
+		return ClearIntents(project.Dir)
+	case project.Managed():
+		return fmt.Errorf("parser: %s is managed by %s, which was not found on PATH", filepath.Base(project.FilePath), project.Tool)
+	case project.ReadOnly():
+		return readOnlyError(project)
+	}
+
 	listResult := runner.List()
 	if listResult.Err != nil {
 		return listResult.Err
@@ -19,5 +86,103 @@ func SyncDependencyFile(project detector.Project, runner *pip.Runner) error {
 		return err
 	}
 
-	return WriteDependencyFile(project, packages)
+	intents, err := LoadIntents(project.Dir)
+	if err != nil {
+		return err
+	}
+
+	err = journalRewrite(runner.Journal, project.FilePath, func() ([]DependencyGroup, error) {
+		return ReadDependencyGroups(project)
+	}, func() error {
+		return WriteDependencyFile(project, packages, mode, intents, opts.Hashes)
+	})
+	if err != nil {
+		return err
+	}
+	if err := ClearIntents(project.Dir); err != nil {
+		return err
+	}
+
+	if opts.LockFile != "" {
+		path := opts.LockFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(project.Dir, path)
+		}
+		return journalRewrite(runner.Journal, path, func() ([]DependencyGroup, error) {
+			if _, err := os.Stat(path); err != nil {
+				return nil, nil
+			}
+			f, err := ReadRequirementsFile(path)
+			if err != nil {
+				return nil, err
+			}
+			return []DependencyGroup{{Group: MainGroup, Requirements: f.Requirements()}}, nil
+		}, func() error {
+			return WriteLockFile(path, packages)
+		})
+	}
+	return nil
+}
+
+// journalRewrite runs write, and records in journal how it changed the
+// requirements that read returns. A rewrite that changes no requirement is
+// not recorded. The journal may be nil.
+func journalRewrite(journal *audit.Journal, path string, read func() ([]DependencyGroup, error), write func() error) error {
+	if journal == nil {
+		return write()
+	}
+	before, _ := read()
+	start := time.Now()
+	err := write()
+	rec := audit.Record{
+		Time:       start,
+		Action:     audit.ActionRewrite,
+		Target:     path,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	} else {
+		after, _ := read()
+		if rec.Changes = requirementChanges(before, after); len(rec.Changes) == 0 {
+			return nil
+		}
+	}
+	if jerr := journal.Append(rec); jerr != nil {
+		log.Warn("audit journal append failed", "action", rec.Action, "target", path, "error", jerr)
+	}
+	return err
+}
+
+// requirementChanges lists the requirements added, removed or changed
+// between two parses of a dependency file, by group.
+func requirementChanges(before, after []DependencyGroup) []audit.Change {
+	specs := func(groups []DependencyGroup) map[string]string {
+		m := make(map[string]string)
+		for _, g := range groups {
+			for _, r := range g.Requirements {
+				m[changeKey(g.Group, r.Key())] = r.String()
+			}
+		}
+		return m
+	}
+	changes := audit.Diff(specs(before), specs(after))
+	for i, c := range changes {
+		group, name, _ := strings.Cut(c.Name, "\x00")
+		if group != MainGroup.String() {
+			changes[i].Group = group
+		}
+		changes[i].Name = name
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Group != changes[j].Group {
+			return changes[i].Group < changes[j].Group
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+func changeKey(g Group, name string) string {
+	return g.String() + "\x00" + name
 }
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eslam/depman/pkg/audit"
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/env"
+	"github.com/eslam/depman/pkg/pip"
+)
+
+func TestSyncDependencyFile(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	project := detector.Project{Dir: dir, FilePath: filepath.Join(dir, "requirements.txt"), FileType: detector.FileRequirementsTXT}
+	if err := os.WriteFile(project.FilePath, []byte("# deps\nrequests==2.30.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := pip.NewFakeBackend(map[string]string{"requests": "2.30.0", "urllib3": "2.0.7"})
+	backend.Latest["requests"] = "2.31.0"
+	runner := pip.NewBackendRunner(backend, env.Virtualenv{})
+	runner.Journal = &audit.Journal{Path: filepath.Join(dir, "audit.jsonl"), Project: dir}
+
+	if result := runner.Upgrade("requests"); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if result := runner.Install("rich==13.7.0"); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if err := RecordAdded(dir, "rich==13.7.0", MainGroup); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := SyncOptions{Mode: SyncDeclared, LockFile: "constraints.txt"}
+	if err := SyncDependencyFile(project, runner, opts); err != nil {
+		t.Fatalf("SyncDependencyFile() error = %v", err)
+	}
+	if got := readFile(t, project.FilePath); got != "# deps\nrequests==2.31.0\nrich==13.7.0\n" {
+		t.Errorf("requirements.txt = %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "constraints.txt")); !strings.HasSuffix(got, "\nrequests==2.31.0\nrich==13.7.0\nurllib3==2.0.7\n") {
+		t.Errorf("constraints.txt = %q", got)
+	}
+	if in, _ := LoadIntents(dir); len(in.Added) != 0 {
+		t.Errorf("intents = %+v, want none after the sync", in)
+	}
+
+	records, _, err := audit.Read(runner.Journal.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []audit.Action
+	for _, r := range records {
+		actions = append(actions, r.Action)
+	}
+	expected := []audit.Action{audit.ActionUpgrade, audit.ActionInstall, audit.ActionRewrite, audit.ActionRewrite}
+	if len(actions) != len(expected) {
+		t.Fatalf("journal actions = %v, want %v", actions, expected)
+	}
+	for i := range expected {
+		if actions[i] != expected[i] {
+			t.Errorf("journal actions = %v, want %v", actions, expected)
+			break
+		}
+	}
+
+	// A failed package list leaves the file alone
+	backend.Fail["list"] = errors.New("pip exploded")
+	if err := SyncDependencyFile(project, runner, opts); err == nil {
+		t.Error("SyncDependencyFile() with a failing list: expected an error")
+	}
+	if got := readFile(t, project.FilePath); got != "# deps\nrequests==2.31.0\nrich==13.7.0\n" {
+		t.Errorf("requirements.txt changed after a failed sync: %q", got)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// fakeProjectBackend is a FakeBackend that owns the dependency file, as uv
+// does in a project with a uv.lock.
+type fakeProjectBackend struct {
+	*pip.FakeBackend
+}
+
+func (f fakeProjectBackend) Add(x pip.Executor, spec, group string) pip.RunResult {
+	return f.Install(x, spec)
+}
+
+func (f fakeProjectBackend) Remove(x pip.Executor, name, group string) pip.RunResult {
+	return f.Uninstall(x, name)
+}
+
+func TestSyncDependencyFile_Managed(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	project := detector.Project{Dir: dir, FilePath: filepath.Join(dir, "pyproject.toml"), FileType: detector.FilePyprojectTOML, Tool: detector.ToolUV}
+	content := "[project]\nname = \"demo\"\ndependencies = [\"requests>=2\"]\n"
+	if err := os.WriteFile(project.FilePath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backend := fakeProjectBackend{pip.NewFakeBackend(map[string]string{"requests": "2.31.0"})}
+	runner := pip.NewBackendRunner(backend, env.Virtualenv{})
+
+	if result := runner.Add("rich==13.7.0", MainGroup.String()); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if err := RecordAdded(dir, "rich==13.7.0", MainGroup); err != nil {
+		t.Fatal(err)
+	}
+	opts := SyncOptions{Mode: SyncAll, LockFile: "constraints.txt"}
+	if err := SyncDependencyFile(project, runner, opts); err != nil {
+		t.Fatalf("SyncDependencyFile() error = %v", err)
+	}
+	if got := readFile(t, project.FilePath); got != content {
+		t.Errorf("pyproject.toml rewritten in a managed project:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "constraints.txt")); !os.IsNotExist(err) {
+		t.Errorf("lock file written in a managed project: %v", err)
+	}
+	if in, _ := LoadIntents(dir); len(in.Added) != 0 {
+		t.Errorf("intents = %+v, want none after the sync", in)
+	}
+	if calls := backend.Calls(); len(calls) != 1 || calls[0] != "install rich==13.7.0" {
+		t.Errorf("calls = %v, want only the install", calls)
+	}
+}
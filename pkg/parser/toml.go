@@ -0,0 +1,568 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file implements a token-level TOML scanner. Unlike a full decoder it
+// records the byte offsets of tables, keys and array elements, so callers
+// can edit a document in place without disturbing comments or formatting.
+
+type tomlTokenKind int
+
+const (
+	tokEOF  tomlTokenKind = iota
+	tokBare               // bare key, number, boolean or date
+	tokString
+	tokPunct // one of [ ] { } = ,
+	tokNewline
+	tokComment
+)
+
+type tomlToken struct {
+	kind       tomlTokenKind
+	start, end int    // byte offsets in the source
+	value      string // decoded value for strings, raw text otherwise
+	literal    bool   // single-quoted string
+	multiline  bool   // triple-quoted string
+}
+
+func (t tomlToken) is(punct byte) bool {
+	return t.kind == tokPunct && t.value == string(punct)
+}
+
+// tomlDocument is the result of scanning a TOML document. Keys are full
+// dotted paths such as "project.dependencies".
+type tomlDocument struct {
+	src     string
+	keys    map[string]bool
+	arrays  map[string]tomlArray
+	tables  map[string]tomlTable
+	strs    map[string]tomlItem // string values, including those of inline tables
+	entries []tomlEntry         // key/value lines of table bodies, in order
+}
+
+// tomlTable records where a table's header and body live. The root table
+// has the empty path.
+type tomlTable struct {
+	start int // offset of the header line
+	end   int // offset just past the last key/value line of the table
+}
+
+// tomlEntry is a key/value line in the body of a table.
+type tomlEntry struct {
+	table, key string   // table path, and the possibly dotted key within it
+	start, end int      // offset of the line, and of the line after it
+	value      tomlItem // the value's span, decoded if it is a string
+}
+
+// tomlArray is an array value with the offsets of its brackets.
+type tomlArray struct {
+	open, close int // offsets of "[" and "]"
+	items       []tomlItem
+}
+
+// tomlItem is one element of an array.
+type tomlItem struct {
+	start, end int    // span of the element's raw text
+	value      string // decoded value for strings
+	str        bool
+	literal    bool
+	multiline  bool
+}
+
+// scanTOML parses src into a tomlDocument.
+func scanTOML(src string) (*tomlDocument, error) {
+	toks, err := lexTOML(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &tomlParser{src: src, toks: toks}
+	return p.document()
+}
+
+func lexTOML(src string) ([]tomlToken, error) {
+	var toks []tomlToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '\n':
+			toks = append(toks, tomlToken{kind: tokNewline, start: i, end: i + 1})
+			i++
+		case c == '\r' && i+1 < len(src) && src[i+1] == '\n':
+			toks = append(toks, tomlToken{kind: tokNewline, start: i, end: i + 2})
+			i += 2
+		case c == '#':
+			end := lineEnd(src, i)
+			toks = append(toks, tomlToken{kind: tokComment, start: i, end: end, value: src[i:end]})
+			i = end
+		case strings.IndexByte("[]{}=,", c) >= 0:
+			toks = append(toks, tomlToken{kind: tokPunct, start: i, end: i + 1, value: string(c)})
+			i++
+		case c == '"' || c == '\'':
+			tok, err := lexTOMLString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			i = tok.end
+		default:
+			j := i
+			for j < len(src) && strings.IndexByte(" \t\r\n#[]{}=,\"'", src[j]) < 0 {
+				j++
+			}
+			if j == i {
+				return nil, tomlError(src, i, "unexpected character %q", c)
+			}
+			toks = append(toks, tomlToken{kind: tokBare, start: i, end: j, value: src[i:j]})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+func lexTOMLString(src string, start int) (tomlToken, error) {
+	q := src[start]
+	tok := tomlToken{kind: tokString, start: start, literal: q == '\''}
+	triple := strings.Repeat(string(q), 3)
+
+	bodyStart := start + 1
+	if strings.HasPrefix(src[start:], triple) {
+		tok.multiline = true
+		bodyStart = start + 3
+	}
+
+	i := bodyStart
+	for {
+		if i >= len(src) {
+			return tok, tomlError(src, start, "unterminated string")
+		}
+		c := src[i]
+		if c == '\\' && !tok.literal {
+			i += 2
+			continue
+		}
+		if c == '\n' && !tok.multiline {
+			return tok, tomlError(src, start, "unterminated string")
+		}
+		if c == q {
+			if !tok.multiline {
+				tok.end = i + 1
+				break
+			}
+			if strings.HasPrefix(src[i:], triple) {
+				// Up to two quotes may directly precede the closing delimiter
+				end := i + 3
+				for k := 0; k < 2 && end < len(src) && src[end] == q; k++ {
+					end++
+				}
+				tok.end = end
+				i = end - 3
+				break
+			}
+		}
+		i++
+	}
+
+	body := src[bodyStart:i]
+	if tok.multiline {
+		// A newline immediately after the opening delimiter is trimmed
+		body = strings.TrimPrefix(strings.TrimPrefix(body, "\r"), "\n")
+	}
+	if tok.literal {
+		tok.value = body
+		return tok, nil
+	}
+	value, err := unescapeTOML(body)
+	if err != nil {
+		return tok, tomlError(src, start, "%v", err)
+	}
+	tok.value = value
+	return tok, nil
+}
+
+// unescapeTOML decodes the escape sequences of a basic string.
+func unescapeTOML(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("trailing backslash")
+		}
+		switch s[i] {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case 'e':
+			b.WriteByte(0x1b)
+		case '"':
+			b.WriteByte('"')
+		case '\\':
+			b.WriteByte('\\')
+		case 'u', 'U':
+			n := 4
+			if s[i] == 'U' {
+				n = 8
+			}
+			if i+1+n > len(s) {
+				return "", fmt.Errorf("short unicode escape")
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid unicode escape: %w", err)
+			}
+			b.WriteRune(rune(r))
+			i += n
+		case ' ', '\t', '\r', '\n':
+			// Line-ending backslash: skip all whitespace up to the next
+			// non-whitespace character
+			for i < len(s) && strings.IndexByte(" \t\r\n", s[i]) >= 0 {
+				i++
+			}
+			i--
+		default:
+			return "", fmt.Errorf("invalid escape \\%c", s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+type tomlParser struct {
+	src  string
+	toks []tomlToken
+	pos  int
+	doc  *tomlDocument
+}
+
+func (p *tomlParser) peek() tomlToken {
+	if p.pos >= len(p.toks) {
+		return tomlToken{kind: tokEOF, start: len(p.src), end: len(p.src)}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *tomlParser) next() tomlToken {
+	t := p.peek()
+	if p.pos < len(p.toks) {
+		p.pos++
+	}
+	return t
+}
+
+// skipBlank skips newlines and comments, which may appear freely inside
+// arrays.
+func (p *tomlParser) skipBlank() {
+	for {
+		k := p.peek().kind
+		if k != tokNewline && k != tokComment {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *tomlParser) unexpected(t tomlToken) error {
+	if t.kind == tokEOF {
+		return tomlError(p.src, t.start, "unexpected end of file")
+	}
+	return tomlError(p.src, t.start, "unexpected %q", p.src[t.start:t.end])
+}
+
+func (p *tomlParser) document() (*tomlDocument, error) {
+	doc := &tomlDocument{
+		src:    p.src,
+		keys:   make(map[string]bool),
+		arrays: make(map[string]tomlArray),
+		tables: map[string]tomlTable{"": {}},
+		strs:   make(map[string]tomlItem),
+	}
+	p.doc = doc
+
+	current := ""
+	for {
+		t := p.next()
+		switch {
+		case t.kind == tokEOF:
+			return doc, nil
+
+		case t.kind == tokNewline || t.kind == tokComment:
+
+		case t.is('['):
+			// Table header: [a.b] or [[a.b]]
+			arrayTable := false
+			if n := p.peek(); n.is('[') && n.start == t.end {
+				p.next()
+				arrayTable = true
+			}
+			path, err := p.key(']')
+			if err != nil {
+				return nil, err
+			}
+			if arrayTable {
+				if n := p.next(); !n.is(']') {
+					return nil, p.unexpected(n)
+				}
+			}
+			end, err := p.endOfLine()
+			if err != nil {
+				return nil, err
+			}
+			current = path
+			doc.tables[current] = tomlTable{start: lineStart(p.src, t.start), end: end}
+
+		case t.kind == tokBare || t.kind == tokString:
+			p.pos--
+			path, err := p.key('=')
+			if err != nil {
+				return nil, err
+			}
+			v, err := p.value(joinKey(current, path))
+			if err != nil {
+				return nil, err
+			}
+			end, err := p.endOfLine()
+			if err != nil {
+				return nil, err
+			}
+			doc.entries = append(doc.entries, tomlEntry{
+				table: current,
+				key:   path,
+				start: lineStart(p.src, t.start),
+				end:   end,
+				value: v.item(),
+			})
+			tbl := doc.tables[current]
+			tbl.end = end
+			doc.tables[current] = tbl
+
+		default:
+			return nil, p.unexpected(t)
+		}
+	}
+}
+
+// key reads a possibly dotted key up to and including the term token.
+func (p *tomlParser) key(term byte) (string, error) {
+	var parts []string
+	for {
+		t := p.next()
+		switch {
+		case t.kind == tokBare:
+			for _, part := range strings.Split(t.value, ".") {
+				if part != "" {
+					parts = append(parts, part)
+				}
+			}
+		case t.kind == tokString && !t.multiline:
+			parts = append(parts, t.value)
+		case t.is(term) && len(parts) > 0:
+			return strings.Join(parts, "."), nil
+		default:
+			return "", p.unexpected(t)
+		}
+	}
+}
+
+// endOfLine consumes an optional comment and the line break after a
+// statement, returning the offset of the following line.
+func (p *tomlParser) endOfLine() (int, error) {
+	if p.peek().kind == tokComment {
+		p.next()
+	}
+	t := p.next()
+	switch t.kind {
+	case tokNewline, tokEOF:
+		return t.end, nil
+	}
+	return 0, p.unexpected(t)
+}
+
+type tomlValue struct {
+	start, end int
+	tok        tomlToken
+	array      *tomlArray
+}
+
+// item returns the span of v, decoded if it is a string.
+func (v tomlValue) item() tomlItem {
+	item := tomlItem{start: v.start, end: v.end}
+	if v.tok.kind == tokString {
+		item.str = true
+		item.value = v.tok.value
+		item.literal = v.tok.literal
+		item.multiline = v.tok.multiline
+	}
+	return item
+}
+
+// value parses the value of the key at path, recording it in the document.
+// Values inside arrays have an empty path and are not recorded.
+func (p *tomlParser) value(path string) (tomlValue, error) {
+	if path != "" {
+		p.doc.keys[path] = true
+	}
+	t := p.next()
+	v := tomlValue{start: t.start, end: t.end, tok: t}
+	switch {
+	case t.kind == tokString:
+		if path != "" {
+			p.doc.strs[path] = v.item()
+		}
+	case t.kind == tokBare:
+		// Local date-times may contain a space: 1979-05-27 07:32:00
+		for n := p.peek(); n.kind == tokBare; n = p.peek() {
+			v.end = p.next().end
+		}
+	case t.is('['):
+		arr, err := p.array(t)
+		if err != nil {
+			return v, err
+		}
+		v.array = &arr
+		v.end = arr.close + 1
+		if path != "" {
+			p.doc.arrays[path] = arr
+		}
+	case t.is('{'):
+		end, err := p.inlineTable(path)
+		if err != nil {
+			return v, err
+		}
+		v.end = end
+	default:
+		return v, p.unexpected(t)
+	}
+	return v, nil
+}
+
+func (p *tomlParser) array(open tomlToken) (tomlArray, error) {
+	arr := tomlArray{open: open.start}
+	for {
+		p.skipBlank()
+		if t := p.peek(); t.is(']') {
+			arr.close = p.next().start
+			return arr, nil
+		}
+		v, err := p.value("")
+		if err != nil {
+			return arr, err
+		}
+		arr.items = append(arr.items, v.item())
+
+		p.skipBlank()
+		t := p.next()
+		if t.is(']') {
+			arr.close = t.start
+			return arr, nil
+		}
+		if !t.is(',') {
+			return arr, p.unexpected(t)
+		}
+	}
+}
+
+// inlineTable parses an inline table, recording its keys below path, and
+// returns the offset after its "}".
+func (p *tomlParser) inlineTable(path string) (int, error) {
+	for {
+		p.skipBlank()
+		if t := p.peek(); t.is('}') {
+			return p.next().end, nil
+		}
+		key, err := p.key('=')
+		if err != nil {
+			return 0, err
+		}
+		sub := ""
+		if path != "" {
+			sub = joinKey(path, key)
+		}
+		if _, err := p.value(sub); err != nil {
+			return 0, err
+		}
+		p.skipBlank()
+		t := p.next()
+		if t.is('}') {
+			return t.end, nil
+		}
+		if !t.is(',') {
+			return 0, p.unexpected(t)
+		}
+	}
+}
+
+func joinKey(table, key string) string {
+	if table == "" {
+		return key
+	}
+	return table + "." + key
+}
+
+func tomlError(src string, offset int, format string, args ...any) error {
+	line := strings.Count(src[:offset], "\n") + 1
+	return fmt.Errorf("parser: toml: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// lineStart returns the offset of the first byte of the line containing off.
+func lineStart(src string, off int) int {
+	return strings.LastIndexByte(src[:off], '\n') + 1
+}
+
+// lineEnd returns the offset of the line break (or end of input) at or
+// after off, excluding a carriage return.
+func lineEnd(src string, off int) int {
+	end := len(src)
+	if i := strings.IndexByte(src[off:], '\n'); i >= 0 {
+		end = off + i
+	}
+	if end > off && src[end-1] == '\r' {
+		end--
+	}
+	return end
+}
+
+// textEdit replaces src[start:end] with text.
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// applyEdits applies non-overlapping edits to src. Insertions at the same
+// offset are applied in the order given.
+func applyEdits(src string, edits []textEdit) string {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end < edits[j].end
+	})
+
+	var b strings.Builder
+	pos := 0
+	for _, e := range edits {
+		b.WriteString(src[pos:e.start])
+		b.WriteString(e.text)
+		pos = e.end
+	}
+	b.WriteString(src[pos:])
+	return b.String()
+}
@@ -8,23 +8,25 @@ import (
 
 	"github.com/eslam/depman/pkg/detector"
 	"github.com/eslam/depman/pkg/pip"
+	"github.com/eslam/depman/pkg/version"
 )
 
-// WriteDependencyFile performs an atomic full rewrite of the dependency file
-// based on the currently installed packages.
-func WriteDependencyFile(project detector.Project, packages []pip.Package) error {
-	deps := packagesToDeps(packages)
-
-	// Sort alphabetically
-	sort.Slice(deps, func(i, j int) bool {
-		return deps[i].Name < deps[j].Name
-	})
-
+// WriteDependencyFile performs an atomic rewrite of the dependency file
+// based on the currently installed packages. Existing requirements keep
+// their extras, markers, URLs and ranges. In SyncDeclared mode only direct
+// dependencies are written (see declaredRequirements); in SyncAll mode every
+// installed package is (see mergeRequirements). A pyproject.toml file,
+// Pipfile, environment.yml or setup.cfg file has every dependency group
+// updated (see groupRequirements). A setup.py file is never written. An
+// environment.yml file is always written in SyncDeclared mode: a conda
+// environment holds many libraries that are not Python packages, such as
+// openssl, which are never declared.
+func WriteDependencyFile(project detector.Project, packages []pip.Package, mode SyncMode, intents Intents, hashes HashFunc) error {
 	var content string
 
 	switch project.FileType {
 	case detector.FileRequirementsTXT:
-		content = FormatRequirementsTxt(deps)
+		return writeRequirementsTxt(project.FilePath, packages, mode, intents, hashes)
 
 	case detector.FilePyprojectTOML:
 		// Read existing file to preserve non-dependency sections
@@ -32,7 +34,59 @@ func WriteDependencyFile(project detector.Project, packages []pip.Package) error
 		if err != nil {
 			return fmt.Errorf("parser: read file: %w", err)
 		}
-		content = RewritePyprojectDependencies(string(existing), deps)
+		groups := parseProjectGroups(project.Tool, string(existing))
+		if groups == nil {
+			// Not valid TOML; let the rewriter report where
+			groups = []DependencyGroup{{Group: MainGroup}}
+		}
+		content, err = RewritePyprojectGroups(string(existing), groupRequirements(groups, packages, mode, intents))
+		if err != nil {
+			return err
+		}
+
+	case detector.FilePipfile:
+		existing, err := os.ReadFile(project.FilePath)
+		if err != nil {
+			return fmt.Errorf("parser: read file: %w", err)
+		}
+		groups := ParsePipfile(string(existing))
+		if groups == nil {
+			// Not valid TOML; let the rewriter report where
+			groups = []DependencyGroup{{Group: MainGroup}}
+		}
+		content, err = RewritePipfile(string(existing), groupRequirements(groups, packages, mode, intents))
+		if err != nil {
+			return err
+		}
+
+	case detector.FileEnvironmentYML:
+		existing, err := os.ReadFile(project.FilePath)
+		if err != nil {
+			return fmt.Errorf("parser: read file: %w", err)
+		}
+		groups := ParseCondaEnvironment(string(existing))
+		if groups == nil {
+			// Not understood; let the rewriter report where
+			groups = []DependencyGroup{{Group: MainGroup}}
+		}
+		content, err = RewriteCondaEnvironment(string(existing), groupRequirements(groups, packages, SyncDeclared, intents))
+		if err != nil {
+			return err
+		}
+
+	case detector.FileSetupCfg:
+		existing, err := os.ReadFile(project.FilePath)
+		if err != nil {
+			return fmt.Errorf("parser: read file: %w", err)
+		}
+		groups := ParseSetupCfg(string(existing))
+		content, err = RewriteSetupCfg(string(existing), groupRequirements(groups, packages, mode, intents))
+		if err != nil {
+			return err
+		}
+
+	case detector.FileSetupPy:
+		return readOnlyError(project)
 
 	default:
 		return fmt.Errorf("parser: write file: unknown file type %v", project.FileType)
@@ -41,6 +95,132 @@ func WriteDependencyFile(project detector.Project, packages []pip.Package) error
 	return atomicWrite(project.FilePath, []byte(content))
 }
 
+// readOnlyError reports that the project's dependency file cannot be
+// rewritten.
+func readOnlyError(project detector.Project) error {
+	return fmt.Errorf("parser: %s is read-only: declare packages in it by hand, or migrate it to pyproject.toml", filepath.Base(project.FilePath))
+}
+
+// groupRequirements computes the new requirements of every group. Groups
+// that additions target but that do not exist yet are appended. Each group
+// other than the main one is computed with declaredRequirements from the
+// intents that apply to it, whatever the mode. In SyncAll mode the main
+// group then takes every remaining installed package that no other group
+// declares.
+func groupRequirements(groups []DependencyGroup, packages []pip.Package, mode SyncMode, intents Intents) []DependencyGroup {
+	out := make([]DependencyGroup, len(groups))
+	copy(out, groups)
+	for _, g := range intents.AddedGroups() {
+		if FindGroup(out, g) < 0 && g.Kind != GroupMain {
+			out = append(out, DependencyGroup{Group: g})
+		}
+	}
+
+	others := make(map[string]bool)
+	main := -1
+	for i := range out {
+		if out[i].Kind == GroupMain {
+			main = i
+			continue
+		}
+		out[i].Requirements = declaredRequirements(out[i].Requirements, packages, intents.ForGroup(out[i].Group))
+		for _, r := range out[i].Requirements {
+			others[r.Key()] = true
+		}
+	}
+	if main < 0 {
+		return out
+	}
+
+	existing := out[main].Requirements
+	if mode != SyncAll {
+		out[main].Requirements = declaredRequirements(existing, packages, intents.ForGroup(MainGroup))
+		return out
+	}
+
+	// Packages declared only in other groups stay out of the main group
+	inMain := make(map[string]bool, len(existing))
+	for _, r := range existing {
+		inMain[r.Key()] = true
+	}
+	var remaining []pip.Package
+	for _, p := range packages {
+		key := NormalizeName(p.Name)
+		if inMain[key] || !others[key] {
+			remaining = append(remaining, p)
+		}
+	}
+	out[main].Requirements = mergeRequirements(existing, remaining)
+	return out
+}
+
+// writeRequirementsTxt updates a requirements file and the files it
+// includes in place (see RequirementsFile.Rewrite), looking up the hashes
+// of changed pins with hashes. A missing or empty file is written from
+// scratch.
+func writeRequirementsTxt(path string, packages []pip.Package, mode SyncMode, intents Intents, hashes HashFunc) error {
+	f := &RequirementsFile{Path: path}
+	if _, err := os.Stat(path); err == nil {
+		if f, err = ReadRequirementsFile(path); err != nil {
+			return err
+		}
+	}
+
+	// Editable installs are declared by their -e line
+	editable := make(map[string]bool)
+	for _, name := range f.EditableNames() {
+		editable[name] = true
+	}
+	var installed []pip.Package
+	for _, p := range packages {
+		if !editable[NormalizeName(p.Name)] {
+			installed = append(installed, p)
+		}
+	}
+
+	groups := []DependencyGroup{{Group: MainGroup, Requirements: f.Requirements()}}
+	reqs := groupRequirements(groups, installed, mode, intents)[0].Requirements
+
+	if len(f.Lines) == 0 {
+		return atomicWrite(path, []byte(FormatRequirementsTxt(reqs)))
+	}
+	rewritten, err := f.Rewrite(reqs, hashes)
+	if err != nil {
+		return err
+	}
+	for file, content := range rewritten {
+		if err := atomicWrite(file, []byte(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteLockFile pins every installed package, transitive dependencies
+// included, in a requirements-format file suitable for `pip install -c`.
+func WriteLockFile(path string, packages []pip.Package) error {
+	var reqs []Requirement
+	for _, p := range packages {
+		if !IsTooling(p.Name) {
+			reqs = append(reqs, Pinned(p.Name, p.InstalledVersion))
+		}
+	}
+	sortRequirements(reqs)
+	return atomicWrite(path, []byte(FormatRequirementsTxt(reqs)))
+}
+
+// RestoreFile atomically replaces the file at path with content, as it was
+// before a change, or removes it when it did not exist (content is nil).
+func RestoreFile(path string, content []byte) error {
+	if content == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("parser: remove file: %w", err)
+		}
+		return nil
+	}
+	return atomicWrite(path, content)
+}
+
 // atomicWrite writes content to a temp file then renames to the target path.
 func atomicWrite(target string, content []byte) error {
 	dir := filepath.Dir(target)
@@ -77,13 +257,118 @@ func atomicWrite(target string, content []byte) error {
 	return nil
 }
 
-func packagesToDeps(packages []pip.Package) []Dep {
-	deps := make([]Dep, len(packages))
-	for i, p := range packages {
-		deps[i] = Dep{
-			Name:    p.Name,
-			Version: p.InstalledVersion,
+// mergeRequirements combines the declared requirements with the installed
+// packages, sorted by normalized name:
+//   - an exact "==" pin is updated to the installed version
+//   - ranges, bare names and URL references are kept as written
+//   - installed packages that are not declared are added as "name==version",
+//     except installer tooling such as pip and setuptools and editable
+//     installs, which cannot be pinned
+//   - declared packages that are not installed are dropped, unless they have
+//     an environment marker and may target a different platform
+func mergeRequirements(existing []Requirement, packages []pip.Package) []Requirement {
+	installed := make(map[string]pip.Package, len(packages))
+	for _, p := range packages {
+		installed[NormalizeName(p.Name)] = p
+	}
+
+	declared := make(map[string]bool, len(existing))
+	var reqs []Requirement
+	for _, r := range existing {
+		key := r.Key()
+		p, ok := installed[key]
+		if !ok {
+			if r.Marker != "" {
+				reqs = append(reqs, r)
+			}
+			continue
+		}
+		declared[key] = true
+		if r.PinnedVersion() != "" {
+			r.Specifiers = version.SpecifierSet{{Op: "==", Version: p.InstalledVersion}}
+		}
+		reqs = append(reqs, r)
+	}
+
+	for _, p := range packages {
+		if !declared[NormalizeName(p.Name)] && !IsTooling(p.Name) && !p.Editable {
+			reqs = append(reqs, Pinned(p.Name, p.InstalledVersion))
+		}
+	}
+
+	sortRequirements(reqs)
+	return reqs
+}
+
+// declaredRequirements computes the direct dependencies of a group from
+// the intents that apply to it, sorted by normalized name:
+//   - declared requirements are kept, with exact "==" pins updated to the
+//     installed version, unless the user explicitly removed them
+//   - explicitly added packages are declared; a bare name is pinned to the
+//     installed version, while an explicit specifier replaces any declared one
+//   - other installed packages are transitive and are not written
+func declaredRequirements(existing []Requirement, packages []pip.Package, intents Intents) []Requirement {
+	installed := make(map[string]pip.Package, len(packages))
+	for _, p := range packages {
+		installed[NormalizeName(p.Name)] = p
+	}
+	removed := make(map[string]bool, len(intents.Removed))
+	for _, i := range intents.Removed {
+		removed[NormalizeName(i.Spec)] = true
+	}
+
+	index := make(map[string]int)
+	var reqs []Requirement
+	for _, r := range existing {
+		key := r.Key()
+		if removed[key] {
+			continue
 		}
+		if p, ok := installed[key]; ok && r.PinnedVersion() != "" {
+			r.Specifiers = version.SpecifierSet{{Op: "==", Version: p.InstalledVersion}}
+		}
+		if _, ok := index[key]; !ok {
+			index[key] = len(reqs)
+		}
+		reqs = append(reqs, r)
 	}
-	return deps
+
+	for _, i := range intents.Added {
+		r, err := ParseRequirement(i.Spec)
+		if err != nil {
+			continue
+		}
+		key := r.Key()
+		explicit := len(r.Specifiers) > 0 || r.URL != ""
+		if i, ok := index[key]; ok {
+			if explicit {
+				reqs[i] = r
+			}
+			continue
+		}
+		if p, ok := installed[key]; ok && !explicit {
+			r.Specifiers = version.SpecifierSet{{Op: "==", Version: p.InstalledVersion}}
+		}
+		index[key] = len(reqs)
+		reqs = append(reqs, r)
+	}
+
+	sortRequirements(reqs)
+	return reqs
+}
+
+func sortRequirements(reqs []Requirement) {
+	sort.SliceStable(reqs, func(i, j int) bool {
+		return reqs[i].Key() < reqs[j].Key()
+	})
+}
+
+// toolingPackages are installer infrastructure that is present in almost
+// every environment and never declared.
+var toolingPackages = map[string]bool{"pip": true, "setuptools": true, "wheel": true, "distribute": true}
+
+// IsTooling reports whether name is installer infrastructure such as pip
+// or setuptools, which is never written as a dependency.
+func IsTooling(name string) bool {
+	return toolingPackages[NormalizeName(name)]
 }
@@ -0,0 +1,281 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eslam/depman/pkg/audit"
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/pip"
+)
+
+func TestMergeRequirements(t *testing.T) {
+	existing := []Requirement{
+		mustParse(t, `requests[socks]>=2,<3; python_version<"3.12"`),
+		mustParse(t, "Django==4.2.0"),
+		mustParse(t, "mypkg @ https://example.com/mypkg-1.0.whl"),
+		mustParse(t, `pywin32==306; sys_platform == "win32"`),
+		mustParse(t, "removed==1.0"),
+	}
+	packages := []pip.Package{
+		{Name: "requests", InstalledVersion: "2.31.0"},
+		{Name: "django", InstalledVersion: "4.2.7"},
+		{Name: "mypkg", InstalledVersion: "1.0"},
+		{Name: "attrs", InstalledVersion: "23.1.0"},
+		{Name: "pip", InstalledVersion: "24.0"},
+	}
+
+	got := mergeRequirements(existing, packages)
+
+	expected := []string{
+		"attrs==23.1.0",
+		"Django==4.2.7",
+		"mypkg @ https://example.com/mypkg-1.0.whl",
+		`pywin32==306; sys_platform == "win32"`,
+		`requests[socks]>=2,<3; python_version<"3.12"`,
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("mergeRequirements() returned %d entries, want %d: %v", len(got), len(expected), got)
+	}
+	for i := range expected {
+		if got[i].String() != expected[i] {
+			t.Errorf("entry[%d] = %q, want %q", i, got[i].String(), expected[i])
+		}
+	}
+}
+
+func TestDeclaredRequirements(t *testing.T) {
+	existing := []Requirement{
+		mustParse(t, "Django==4.2.0"),
+		mustParse(t, "requests>=2"),
+		mustParse(t, "flask==3.0.0"),
+		mustParse(t, "notinstalled>=1"),
+	}
+	packages := []pip.Package{
+		{Name: "django", InstalledVersion: "4.2.7"},
+		{Name: "requests", InstalledVersion: "2.31.0"},
+		{Name: "urllib3", InstalledVersion: "2.1.0"}, // transitive
+		{Name: "click", InstalledVersion: "8.1.7"},
+		{Name: "httpx", InstalledVersion: "0.27.0"},
+		{Name: "pip", InstalledVersion: "24.0"},
+	}
+	intents := Intents{
+		Added:   []Intent{{Spec: "Click"}, {Spec: "httpx>=0.27"}, {Spec: "requests~=2.31"}},
+		Removed: []Intent{{Spec: "Flask"}},
+	}
+
+	got := declaredRequirements(existing, packages, intents)
+
+	expected := []string{
+		"Click==8.1.7",
+		"Django==4.2.7",
+		"httpx>=0.27",
+		"notinstalled>=1",
+		"requests~=2.31",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("declaredRequirements() returned %d entries, want %d: %v", len(got), len(expected), got)
+	}
+	for i := range expected {
+		if got[i].String() != expected[i] {
+			t.Errorf("entry[%d] = %q, want %q", i, got[i].String(), expected[i])
+		}
+	}
+}
+
+func TestIntents(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	dev := Group{Kind: GroupDependency, Name: "dev"}
+
+	steps := []func() error{
+		func() error { return RecordAdded(dir, "requests", MainGroup) },
+		func() error { return RecordAdded(dir, "Flask==3.0.0", MainGroup) },
+		func() error { return RecordRemoved(dir, "flask") },
+		func() error { return RecordAdded(dir, "requests>=2", MainGroup) },
+		func() error { return RecordRemoved(dir, "attrs") },
+		func() error { return RecordAdded(dir, "pytest", dev) },
+		func() error { return RecordRemovedFrom(dir, "mypy", dev) },
+		func() error { return RecordAdded(dir, "mypy", dev) },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	in, err := LoadIntents(dir)
+	if err != nil {
+		t.Fatalf("LoadIntents() error = %v", err)
+	}
+	main := in.ForGroup(MainGroup)
+	if len(main.Added) != 1 || main.Added[0].Spec != "requests>=2" {
+		t.Errorf("main Added = %v, want [requests>=2]", main.Added)
+	}
+	if len(main.Removed) != 2 || main.Removed[0].Spec != "flask" || main.Removed[1].Spec != "attrs" {
+		t.Errorf("main Removed = %v, want [flask attrs]", main.Removed)
+	}
+	devIntents := in.ForGroup(Group{Kind: GroupDependency, Name: "Dev"})
+	if len(devIntents.Added) != 2 || devIntents.Added[0].Spec != "pytest" || devIntents.Added[1].Spec != "mypy" {
+		t.Errorf("dev Added = %v, want [pytest mypy]", devIntents.Added)
+	}
+	// Removals from every group apply to dev too
+	if len(devIntents.Removed) != 2 {
+		t.Errorf("dev Removed = %v, want [flask attrs]", devIntents.Removed)
+	}
+
+	if err := RecordAdded(dir, "not a requirement!", MainGroup); err == nil {
+		t.Error("RecordAdded() with an invalid spec want error")
+	}
+
+	if err := ClearIntents(dir); err != nil {
+		t.Fatalf("ClearIntents() error = %v", err)
+	}
+	if in, err := LoadIntents(dir); err != nil || len(in.Added) != 0 || len(in.Removed) != 0 {
+		t.Errorf("LoadIntents() after clear = %+v, %v", in, err)
+	}
+}
+
+func TestWriteLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "constraints.txt")
+	packages := []pip.Package{
+		{Name: "urllib3", InstalledVersion: "2.1.0"},
+		{Name: "Django", InstalledVersion: "4.2.7"},
+		{Name: "setuptools", InstalledVersion: "69.0.0"},
+	}
+	if err := WriteLockFile(path, packages); err != nil {
+		t.Fatalf("WriteLockFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read lock file: %v", err)
+	}
+	reqs := ParseRequirementsTxt(string(data))
+	if len(reqs) != 2 || reqs[0].String() != "Django==4.2.7" || reqs[1].String() != "urllib3==2.1.0" {
+		t.Errorf("lock file entries = %v", reqs)
+	}
+}
+
+func TestJournalRewrite(t *testing.T) {
+	dir := t.TempDir()
+	project := detector.Project{Dir: dir, FilePath: filepath.Join(dir, "pyproject.toml"), FileType: detector.FilePyprojectTOML}
+	os.WriteFile(project.FilePath, []byte(`[project]
+name = "demo"
+dependencies = ["requests==2.30.0", "click>=8"]
+
+[dependency-groups]
+dev = ["pytest==7.4.0"]
+`), 0o644)
+	journal := &audit.Journal{Path: filepath.Join(dir, "audit.jsonl"), Project: dir}
+	packages := []pip.Package{
+		{Name: "requests", InstalledVersion: "2.31.0"},
+		{Name: "pytest", InstalledVersion: "7.4.0"},
+		{Name: "rich", InstalledVersion: "13.7.0"},
+	}
+	dev := Group{Kind: GroupDependency, Name: "dev"}
+	intents := Intents{Added: []Intent{{Spec: "rich", Group: &dev}}}
+
+	write := func() error { return WriteDependencyFile(project, packages, SyncDeclared, intents, nil) }
+	read := func() ([]DependencyGroup, error) { return ReadDependencyGroups(project) }
+	if err := journalRewrite(journal, project.FilePath, read, write); err != nil {
+		t.Fatalf("journalRewrite() error = %v", err)
+	}
+	// Nothing changes the second time, so nothing is recorded
+	if err := journalRewrite(journal, project.FilePath, read, write); err != nil {
+		t.Fatalf("journalRewrite() error = %v", err)
+	}
+
+	records, _, err := audit.Read(journal.Path)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("journal has %d records (%v), want 1", len(records), err)
+	}
+	rec := records[0]
+	if rec.Action != audit.ActionRewrite || rec.Target != project.FilePath || !rec.OK() {
+		t.Errorf("record = %+v", rec)
+	}
+	expected := []audit.Change{
+		{Name: "requests", Before: "requests==2.30.0", After: "requests==2.31.0"},
+		{Name: "rich", Group: "group:dev", After: "rich==13.7.0"},
+	}
+	if len(rec.Changes) != len(expected) {
+		t.Fatalf("Changes = %+v, want %+v", rec.Changes, expected)
+	}
+	for i := range expected {
+		if rec.Changes[i] != expected[i] {
+			t.Errorf("Changes[%d] = %+v, want %+v", i, rec.Changes[i], expected[i])
+		}
+	}
+}
+
+func TestWriteDependencyFile_PyprojectKeepsMarkers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pyproject.toml")
+	content := `[project]
+name = "demo"
+dependencies = [
+    "requests[socks]>=2,<3; python_version<\"3.12\"",
+]
+
+[tool.ruff]
+line-length = 100
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write pyproject.toml: %v", err)
+	}
+
+	project := detector.Project{FilePath: path, FileType: detector.FilePyprojectTOML, Dir: dir}
+	packages := []pip.Package{{Name: "requests", InstalledVersion: "2.31.0"}}
+	if err := WriteDependencyFile(project, packages, SyncDeclared, Intents{}, nil); err != nil {
+		t.Fatalf("WriteDependencyFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read pyproject.toml: %v", err)
+	}
+	reqs := ParsePyprojectTOML(string(data))
+	if len(reqs) != 1 || reqs[0].String() != `requests[socks]>=2,<3; python_version<"3.12"` {
+		t.Errorf("dependencies after write = %v", reqs)
+	}
+	if !strings.Contains(string(data), "[tool.ruff]\nline-length = 100") {
+		t.Errorf("other sections not preserved:\n%s", data)
+	}
+}
+
+func TestParseRequirementsTxt(t *testing.T) {
+	content := `# header comment
+requests[socks]>=2,<3 ; python_version < "3.12"  # inline comment
+Django==4.2.0
+-r other.txt
+--index-url https://example.com/simple
+
+mypkg @ https://example.com/mypkg.whl#egg=mypkg
+not a requirement!
+`
+	reqs := ParseRequirementsTxt(content)
+	expected := []string{
+		`requests[socks]>=2,<3; python_version < "3.12"`,
+		"Django==4.2.0",
+		"mypkg @ https://example.com/mypkg.whl#egg=mypkg",
+	}
+	if len(reqs) != len(expected) {
+		t.Fatalf("ParseRequirementsTxt() returned %d entries, want %d: %v", len(reqs), len(expected), reqs)
+	}
+	for i := range expected {
+		if reqs[i].String() != expected[i] {
+			t.Errorf("entry[%d] = %q, want %q", i, reqs[i].String(), expected[i])
+		}
+	}
+}
+
+func mustParse(t *testing.T, s string) Requirement {
+	t.Helper()
+	r, err := ParseRequirement(s)
+	if err != nil {
+		t.Fatalf("ParseRequirement(%q) error = %v", s, err)
+	}
+	return r
+}
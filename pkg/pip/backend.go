@@ -0,0 +1,173 @@
+package pip
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/env"
+	"github.com/eslam/depman/pkg/log"
+)
+
+// Backend performs package operations with one tool, such as pip or
+// `uv pip`. Runner adds validation, the audit journal and previews on
+// top, so a backend only has to drive its tool. Supporting a new tool
+// means adding a Backend, not editing every operation.
+type Backend interface {
+	// Name is the tool's short name, e.g. "pip" or "uv".
+	Name() string
+
+	Install(x Executor, spec string) RunResult
+	Uninstall(x Executor, name string) RunResult
+	Upgrade(x Executor, name string) RunResult
+
+	// List and Outdated print the installed and the outdated packages as
+	// JSON, in the format of `pip list --format json`.
+	List(x Executor) RunResult
+	Outdated(x Executor) RunResult
+
+	// Lock resolves the requirements in input and pins every distribution
+	// they need: in requirements format on stdout, or in the lock file of
+	// a tool that owns one.
+	Lock(x Executor, input string) RunResult
+	// Sync makes the environment match the pinned requirements file at
+	// path, installing and uninstalling as needed.
+	Sync(x Executor, path string) RunResult
+
+	// DryRun resolves installing specs, upgrading them if upgrade is set,
+	// without changing the environment. Before is only set on changes
+	// whose current version the tool reports.
+	DryRun(x Executor, specs []string, upgrade bool) ([]PlannedChange, error)
+}
+
+// ProjectBackend is a Backend whose tool owns the project's dependency
+// file and lock file, such as a uv project with a uv.lock. Add and Remove
+// declare the change in the dependency file as well as applying it, so
+// depman does not rewrite the file itself. group is a dependency group as
+// written by parser.Group: "main", "optional:<extra>" or "group:<name>".
+type ProjectBackend interface {
+	Backend
+	Add(x Executor, spec, group string) RunResult
+	Remove(x Executor, name, group string) RunResult
+}
+
+// GroupInstaller is a Backend that installs a package differently
+// depending on the dependency group that declares it, such as a conda
+// environment, whose pip: subsection is installed with pip. Runner.Add
+// uses InstallGroup instead of Install.
+type GroupInstaller interface {
+	Backend
+	InstallGroup(x Executor, spec, group string) RunResult
+}
+
+// Executor runs a backend's commands in the runner's environment, with
+// its context, timeout and output stream. Run streams the command's
+// output; Capture only collects it, for machine-readable output.
+type Executor interface {
+	Run(bin string, args ...string) RunResult
+	Capture(bin string, args ...string) RunResult
+}
+
+// ErrUnsupported is wrapped by the error of an operation the backend's
+// tool cannot perform.
+var ErrUnsupported = errors.New("not supported")
+
+// NewBackend returns the backend for a detected package manager.
+func NewBackend(mgr env.PackageManager) Backend {
+	switch mgr.Type {
+	case env.ManagerUV:
+		return UVBackend{Bin: mgr.BinPath}
+	default:
+		return PipBackend{Bin: mgr.BinPath}
+	}
+}
+
+// NewProjectBackend returns the backend for a project: the project
+// manager's own workflow when the project has one, else the backend of
+// the detected package manager. A uv project is still handled with uv when
+// another package manager is preferred, as long as uv is on PATH. Poetry
+// and PDM projects need poetry or pdm on PATH. A Hatch project has no
+// backend of its own: depman edits its environments' dependencies and
+// installs with the package manager. A Pipfile with a Pipfile.lock needs
+// pipenv on PATH.
+func NewProjectBackend(mgr env.PackageManager, project detector.Project) Backend {
+	switch project.Tool {
+	case detector.ToolUV:
+		bin := mgr.BinPath
+		if mgr.Type != env.ManagerUV {
+			path, err := exec.LookPath("uv")
+			if err != nil {
+				log.Warn("uv project but uv not found, uv.lock will not be updated", "project", project.Dir)
+				break
+			}
+			bin = path
+		}
+		return UVProjectBackend{UVBackend: UVBackend{Bin: bin}, Dir: project.Dir}
+	case detector.ToolPoetry:
+		path, err := exec.LookPath("poetry")
+		if err != nil {
+			log.Warn("poetry project but poetry not found, poetry.lock will not be updated", "project", project.Dir)
+			break
+		}
+		return PoetryBackend{Backend: NewBackend(mgr), Bin: path, Dir: project.Dir}
+	case detector.ToolPDM:
+		path, err := exec.LookPath("pdm")
+		if err != nil {
+			log.Warn("pdm project but pdm not found, pdm.lock will not be updated", "project", project.Dir)
+			break
+		}
+		return PDMBackend{Backend: NewBackend(mgr), Bin: path, Dir: project.Dir}
+	case detector.ToolPipenv:
+		path, err := exec.LookPath("pipenv")
+		if err != nil {
+			log.Warn("pipenv project but pipenv not found, Pipfile.lock will not be updated", "project", project.Dir)
+			break
+		}
+		return PipenvBackend{Backend: NewBackend(mgr), Bin: path}
+	}
+	return NewBackend(mgr)
+}
+
+// NewEnvBackend returns the backend for a project in the environment
+// venv: that of NewProjectBackend, driving a CondaBackend in a conda
+// environment unless a project manager owns the project. pip runs from the
+// conda environment when it has one, since the environment need not be
+// active. A conda environment is managed with the package manager alone
+// when conda, mamba and micromamba cannot be found.
+func NewEnvBackend(mgr env.PackageManager, project detector.Project, venv env.Virtualenv) Backend {
+	backend := NewProjectBackend(mgr, project)
+	if venv.Type != env.EnvConda || venv.Path == "" {
+		return backend
+	}
+	if _, ok := backend.(ProjectBackend); ok {
+		return backend
+	}
+	if pip := filepath.Join(venv.Path, "bin", "pip"); mgr.Type == env.ManagerPip {
+		if _, err := os.Stat(pip); err == nil {
+			backend = PipBackend{Bin: pip}
+		}
+	}
+	bin, ok := env.FindCondaTool()
+	if !ok {
+		log.Warn("conda environment but conda, mamba and micromamba not found, installing with the package manager only", "env", venv.Path)
+		return backend
+	}
+	return CondaBackend{Backend: backend, Bin: bin, Prefix: venv.Path}
+}
+
+// unsupported returns the result of an operation a tool cannot perform.
+func unsupported(tool, op string) RunResult {
+	return RunResult{Err: fmt.Errorf("%s: %s: %w", tool, op, ErrUnsupported)}
+}
+
+// commandError describes a failed command, with the last line of its
+// stderr when there is one.
+func commandError(op string, result RunResult) error {
+	if detail := result.Detail(); detail != "" {
+		return fmt.Errorf("%s: %w: %s", op, result.Err, detail)
+	}
+	return fmt.Errorf("%s: %w", op, result.Err)
+}
@@ -0,0 +1,330 @@
+package pip
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/env"
+)
+
+// argvExecutor records the commands a backend runs instead of running them.
+type argvExecutor struct {
+	commands []string
+	result   RunResult
+}
+
+func (x *argvExecutor) Run(bin string, args ...string) RunResult {
+	x.commands = append(x.commands, "run "+bin+" "+strings.Join(args, " "))
+	return x.result
+}
+
+func (x *argvExecutor) Capture(bin string, args ...string) RunResult {
+	x.commands = append(x.commands, "capture "+bin+" "+strings.Join(args, " "))
+	return x.result
+}
+
+func TestBackendCommands(t *testing.T) {
+	ops := func(b Backend, x Executor) {
+		b.Install(x, "requests>=2")
+		b.Uninstall(x, "requests")
+		b.Upgrade(x, "requests")
+		b.List(x)
+		b.Outdated(x)
+		b.Lock(x, "requirements.in")
+		b.Sync(x, "requirements.txt")
+		b.DryRun(x, []string{"httpx"}, true)
+	}
+	tests := []struct {
+		backend  Backend
+		expected []string
+	}{
+		{PipBackend{Bin: "/bin/pip"}, []string{
+			"run /bin/pip install requests>=2",
+			"run /bin/pip uninstall requests -y",
+			"run /bin/pip install --upgrade requests",
+			"capture /bin/pip list --format json",
+			"capture /bin/pip list --outdated --format json",
+			"capture /bin/pip install --dry-run --quiet --report - --upgrade httpx",
+		}},
+		{UVBackend{Bin: "/bin/uv"}, []string{
+			"run /bin/uv pip install requests>=2",
+			"run /bin/uv pip uninstall requests",
+			"run /bin/uv pip install --upgrade requests",
+			"capture /bin/uv pip list --format json",
+			"capture /bin/uv pip list --outdated --format json",
+			"capture /bin/uv pip compile --quiet requirements.in",
+			"run /bin/uv pip sync requirements.txt",
+			"capture /bin/uv pip install --dry-run --upgrade httpx",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.backend.Name(), func(t *testing.T) {
+			x := &argvExecutor{result: RunResult{Stdout: `{"install": []}`}}
+			ops(tt.backend, x)
+			if !reflect.DeepEqual(x.commands, tt.expected) {
+				t.Errorf("commands =\n%s\nwant:\n%s", strings.Join(x.commands, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+
+	if r := (PipBackend{}).Sync(&argvExecutor{}, "requirements.txt"); !errors.Is(r.Err, ErrUnsupported) {
+		t.Errorf("PipBackend.Sync() error = %v, want ErrUnsupported", r.Err)
+	}
+}
+
+func TestUVProjectBackend(t *testing.T) {
+	b := UVProjectBackend{UVBackend: UVBackend{Bin: "/bin/uv"}, Dir: "/src/app"}
+	x := &argvExecutor{}
+	b.Add(x, "httpx>=0.27", "main")
+	b.Add(x, "pytest", "group:dev")
+	b.Add(x, "rich", "optional:cli")
+	b.Remove(x, "pytest", "group:dev")
+	b.Upgrade(x, "httpx")
+	b.Install(x, "idna==3.6") // undo puts back undeclared versions
+	expected := []string{
+		"run /bin/uv add --project /src/app httpx>=0.27",
+		"run /bin/uv add --project /src/app --group dev pytest",
+		"run /bin/uv add --project /src/app --optional cli rich",
+		"run /bin/uv remove --project /src/app --group dev pytest",
+		"run /bin/uv lock --project /src/app --upgrade-package httpx",
+		"run /bin/uv sync --project /src/app",
+		"run /bin/uv pip install idna==3.6",
+	}
+	if !reflect.DeepEqual(x.commands, expected) {
+		t.Errorf("commands =\n%s\nwant:\n%s", strings.Join(x.commands, "\n"), strings.Join(expected, "\n"))
+	}
+
+	// A failed lock does not sync
+	x = &argvExecutor{result: RunResult{Err: errors.New("exit status 1")}}
+	if r := b.Upgrade(x, "httpx"); r.Err == nil || len(x.commands) != 1 {
+		t.Errorf("Upgrade() after a failed lock = %v, ran %v", r.Err, x.commands)
+	}
+}
+
+func TestPoetryBackend(t *testing.T) {
+	b := PoetryBackend{Backend: PipBackend{Bin: "/venv/bin/pip"}, Bin: "/bin/poetry", Dir: "/src/app"}
+	x := &argvExecutor{result: RunResult{Stdout: "Poetry (version 2.1.1)\n"}}
+	b.Add(x, "requests==2.31.0", "main")
+	b.Add(x, "pytest", "group:dev")
+	b.Remove(x, "pytest", "group:dev")
+	b.Remove(x, "rich", "optional:cli")
+	b.Upgrade(x, "requests")
+	b.Lock(x, "")
+	b.Sync(x, "")
+	b.Install(x, "idna==3.6")
+	expected := []string{
+		"run /bin/poetry add --directory /src/app requests==2.31.0",
+		"run /bin/poetry add --directory /src/app --group dev pytest",
+		"run /bin/poetry remove --directory /src/app --group dev pytest",
+		"run /bin/poetry remove --directory /src/app rich",
+		"run /bin/poetry update --directory /src/app requests",
+		"run /bin/poetry lock --directory /src/app",
+		"capture /bin/poetry --version",
+		"run /bin/poetry sync --directory /src/app",
+		"run /venv/bin/pip install idna==3.6",
+	}
+	if !reflect.DeepEqual(x.commands, expected) {
+		t.Errorf("commands =\n%s\nwant:\n%s", strings.Join(x.commands, "\n"), strings.Join(expected, "\n"))
+	}
+	if b.Name() != "poetry" {
+		t.Errorf("Name() = %q", b.Name())
+	}
+}
+
+func TestPoetryBackend_Version(t *testing.T) {
+	b := PoetryBackend{Backend: PipBackend{Bin: "/venv/bin/pip"}, Bin: "/bin/poetry", Dir: "/src/app"}
+
+	x := &argvExecutor{result: RunResult{Stdout: "Poetry (version 2.1.1)\n"}}
+	if r := b.Add(x, "rich", "optional:cli"); r.Err != nil {
+		t.Fatalf("Add(Poetry 2) error = %v", r.Err)
+	}
+	expected := []string{
+		"capture /bin/poetry --version",
+		"run /bin/poetry add --directory /src/app --optional cli rich",
+	}
+	if !reflect.DeepEqual(x.commands, expected) {
+		t.Errorf("commands =\n%s\nwant:\n%s", strings.Join(x.commands, "\n"), strings.Join(expected, "\n"))
+	}
+
+	// Poetry 1's --optional takes no extra name
+	x = &argvExecutor{result: RunResult{Stdout: "Poetry (version 1.8.3)\n"}}
+	if r := b.Add(x, "rich", "optional:cli"); !errors.Is(r.Err, ErrUnsupported) || len(x.commands) != 1 {
+		t.Errorf("Add(Poetry 1) = %v, ran %v; want ErrUnsupported without running poetry add", r.Err, x.commands)
+	}
+	x = &argvExecutor{result: RunResult{Stdout: "poetry: unknown"}}
+	if r := b.Add(x, "rich", "optional:cli"); r.Err == nil || len(x.commands) != 1 {
+		t.Errorf("Add(unknown version) = %v, ran %v", r.Err, x.commands)
+	}
+
+	// Poetry 1 has no sync command
+	x = &argvExecutor{result: RunResult{Stdout: "Poetry (version 1.8.3)\n"}}
+	b.Sync(x, "")
+	expected = []string{
+		"capture /bin/poetry --version",
+		"run /bin/poetry install --directory /src/app --sync",
+	}
+	if !reflect.DeepEqual(x.commands, expected) {
+		t.Errorf("commands =\n%s\nwant:\n%s", strings.Join(x.commands, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestPDMBackend(t *testing.T) {
+	b := PDMBackend{Backend: UVBackend{Bin: "/bin/uv"}, Bin: "/bin/pdm", Dir: "/src/app"}
+	x := &argvExecutor{}
+	b.Add(x, "requests>=2.31", "main")
+	b.Add(x, "pytest", "group:test")
+	b.Add(x, "rich", "optional:cli")
+	b.Remove(x, "pytest", "group:test")
+	b.Upgrade(x, "requests")
+	b.Lock(x, "")
+	b.Sync(x, "")
+	b.Uninstall(x, "idna")
+	expected := []string{
+		"run /bin/pdm add --project /src/app requests>=2.31",
+		"run /bin/pdm add --project /src/app -dG test pytest",
+		"run /bin/pdm add --project /src/app -G cli rich",
+		"run /bin/pdm remove --project /src/app -dG test pytest",
+		"run /bin/pdm update --project /src/app requests",
+		"run /bin/pdm lock --project /src/app",
+		"run /bin/pdm sync --project /src/app --clean",
+		"run /bin/uv pip uninstall idna",
+	}
+	if !reflect.DeepEqual(x.commands, expected) {
+		t.Errorf("commands =\n%s\nwant:\n%s", strings.Join(x.commands, "\n"), strings.Join(expected, "\n"))
+	}
+	if b.Name() != "pdm" {
+		t.Errorf("Name() = %q", b.Name())
+	}
+}
+
+func TestPipenvBackend(t *testing.T) {
+	b := PipenvBackend{Backend: PipBackend{Bin: "/venv/bin/pip"}, Bin: "/bin/pipenv"}
+	x := &argvExecutor{}
+	b.Add(x, "requests==2.31.0", "main")
+	b.Add(x, "pytest", "group:dev")
+	b.Remove(x, "pytest", "group:dev")
+	b.Upgrade(x, "requests")
+	b.Lock(x, "")
+	b.Sync(x, "")
+	expected := []string{
+		"run /bin/pipenv install requests==2.31.0",
+		"run /bin/pipenv install --categories dev-packages pytest",
+		"run /bin/pipenv uninstall --categories dev-packages pytest",
+		"run /bin/pipenv update requests",
+		"run /bin/pipenv lock",
+		"run /bin/pipenv sync --dev",
+		"run /bin/pipenv clean",
+	}
+	if !reflect.DeepEqual(x.commands, expected) {
+		t.Errorf("commands =\n%s\nwant:\n%s", strings.Join(x.commands, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestCondaBackend(t *testing.T) {
+	b := CondaBackend{Backend: PipBackend{Bin: "/env/bin/pip"}, Bin: "/bin/mamba", Prefix: "/env"}
+	x := &argvExecutor{result: RunResult{Stdout: `[
+		{"name": "numpy", "version": "1.26.4", "channel": "conda-forge", "build_string": "py311h64a7726_0"},
+		{"name": "requests", "version": "2.31.0", "channel": "pypi", "build_string": "pypi_0"}
+	]`}}
+	b.InstallGroup(x, "numpy=1.26", "main")
+	b.InstallGroup(x, "rich", "group:pip")
+	b.Install(x, "httpx[http2]")
+	b.Uninstall(x, "numpy")
+	b.Upgrade(x, "requests")
+	b.Sync(x, "/src/environment.yml")
+	expected := []string{
+		"run /bin/mamba install --prefix /env --yes numpy=1.26",
+		"run /env/bin/pip install rich",
+		"run /env/bin/pip install httpx[http2]",
+		"capture /bin/mamba list --prefix /env --json",
+		"run /bin/mamba remove --prefix /env --yes numpy",
+		"capture /bin/mamba list --prefix /env --json",
+		"run /env/bin/pip install --upgrade requests",
+		"run /bin/mamba env update --prefix /env --file /src/environment.yml --prune",
+	}
+	if !reflect.DeepEqual(x.commands, expected) {
+		t.Errorf("commands =\n%s\nwant:\n%s", strings.Join(x.commands, "\n"), strings.Join(expected, "\n"))
+	}
+
+	packages, err := ParsePackageList(b.List(x).Stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Package{{Name: "numpy", InstalledVersion: "1.26.4"}, {Name: "requests", InstalledVersion: "2.31.0"}}
+	if !reflect.DeepEqual(packages, want) {
+		t.Errorf("List() = %+v, want %+v", packages, want)
+	}
+}
+
+func TestParseCondaActions(t *testing.T) {
+	changes, err := parseCondaActions(`{"actions": {
+		"LINK": [{"name": "numpy", "version": "2.0.1", "channel": "conda-forge"}, {"name": "libblas", "version": "3.9.0", "channel": "conda-forge"}],
+		"UNLINK": [{"name": "numpy", "version": "1.26.4", "channel": "conda-forge"}]
+	}, "dry_run": true, "success": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []PlannedChange{{Name: "numpy", Before: "1.26.4", After: "2.0.1"}, {Name: "libblas", After: "3.9.0"}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("parseCondaActions() = %+v, want %+v", changes, expected)
+	}
+	if changes, err := parseCondaActions(`{"message": "All requested packages already installed.", "success": true}`); err != nil || len(changes) != 0 {
+		t.Errorf("parseCondaActions(nothing to do) = %+v, %v", changes, err)
+	}
+}
+
+func TestNewProjectBackend(t *testing.T) {
+	uv := env.PackageManager{Type: env.ManagerUV, BinPath: "/bin/uv"}
+	project := detector.Project{Dir: "/src/app", Tool: detector.ToolUV}
+	if b, ok := NewProjectBackend(uv, project).(UVProjectBackend); !ok || b.Bin != "/bin/uv" || b.Dir != "/src/app" {
+		t.Errorf("NewProjectBackend(uv project) = %#v", b)
+	}
+	if b := NewProjectBackend(uv, detector.Project{Dir: "/src/app"}); b != (UVBackend{Bin: "/bin/uv"}) {
+		t.Errorf("NewProjectBackend(plain project) = %#v", b)
+	}
+	hatch := detector.Project{Dir: "/src/app", Tool: detector.ToolHatch}
+	if b := NewProjectBackend(uv, hatch); b != (UVBackend{Bin: "/bin/uv"}) {
+		t.Errorf("NewProjectBackend(hatch project) = %#v", b)
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	if b := NewBackend(env.PackageManager{Type: env.ManagerUV, BinPath: "/bin/uv"}); b != (UVBackend{Bin: "/bin/uv"}) {
+		t.Errorf("NewBackend(uv) = %#v", b)
+	}
+	if b := NewBackend(env.PackageManager{Type: env.ManagerPip, BinPath: "/bin/pip3"}); b != (PipBackend{Bin: "/bin/pip3"}) {
+		t.Errorf("NewBackend(pip) = %#v", b)
+	}
+}
+
+func TestRunner_FakeBackend(t *testing.T) {
+	backend := NewFakeBackend(map[string]string{"Requests": "2.30.0"})
+	backend.Latest["requests"] = "2.31.0"
+	runner := NewBackendRunner(backend, env.Virtualenv{})
+
+	p, err := runner.PreviewUpgrade("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Changes) != 1 || p.Changes[0].Kind() != ChangeUpgrade {
+		t.Errorf("PreviewUpgrade() = %+v, want one upgrade", p.Changes)
+	}
+	runner.Upgrade("requests")
+	runner.Install("rich")
+	runner.Uninstall("missing;rm")
+
+	packages, err := ParsePackageList(runner.List().Stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packages) != 2 || packages[0].InstalledVersion != "2.31.0" || packages[1].Name != "rich" {
+		t.Errorf("List() = %+v", packages)
+	}
+	expected := []string{"list", "dry-run requests", "upgrade requests", "install rich", "list"}
+	if got := backend.Calls(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Calls() = %v, want %v (invalid names never reach the backend)", got, expected)
+	}
+}
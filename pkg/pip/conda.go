@@ -0,0 +1,292 @@
+package pip
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/eslam/depman/pkg/pyname"
+)
+
+// CondaBackend drives conda, mamba or micromamba for a conda environment.
+// Packages from conda channels are installed, removed and upgraded with
+// the conda tool. Packages conda lists on its "pypi" channel, those of an
+// environment.yml's pip: subsection ("group:pip"), and specs only pip
+// understands, with extras, a URL or a marker, go through the embedded
+// Backend, that of the environment's package manager.
+type CondaBackend struct {
+	Backend
+	Bin    string // path to conda, mamba or micromamba
+	Prefix string // the environment's directory
+}
+
+// Name returns the conda tool's name, e.g. "mamba".
+func (b CondaBackend) Name() string { return filepath.Base(b.Bin) }
+
+// Install installs spec from the environment's conda channels.
+func (b CondaBackend) Install(x Executor, spec string) RunResult {
+	return b.InstallGroup(x, spec, "main")
+}
+
+// InstallGroup installs spec with pip for the pip: subsection, else from
+// the conda channels.
+func (b CondaBackend) InstallGroup(x Executor, spec, group string) RunResult {
+	if group == "group:pip" || pipOnlySpec(spec) {
+		return b.Backend.Install(x, spec)
+	}
+	return x.Run(b.Bin, b.args("install", spec)...)
+}
+
+// Uninstall removes name with the tool that installed it.
+func (b CondaBackend) Uninstall(x Executor, name string) RunResult {
+	conda, err := b.fromConda(x, name)
+	if err != nil {
+		return RunResult{Err: err}
+	}
+	if !conda {
+		return b.Backend.Uninstall(x, name)
+	}
+	return x.Run(b.Bin, b.args("remove", name)...)
+}
+
+// Upgrade upgrades name with the tool that installed it.
+func (b CondaBackend) Upgrade(x Executor, name string) RunResult {
+	conda, err := b.fromConda(x, name)
+	if err != nil {
+		return RunResult{Err: err}
+	}
+	if !conda {
+		return b.Backend.Upgrade(x, name)
+	}
+	return x.Run(b.Bin, b.args("update", name)...)
+}
+
+// List prints every package in the environment, from conda channels and
+// from PyPI, as JSON in the format of `pip list`.
+func (b CondaBackend) List(x Executor) RunResult {
+	packages, result := b.list(x)
+	if result.Err != nil {
+		return result
+	}
+	entries := make([]pipListEntry, len(packages))
+	for i, p := range packages {
+		entries[i] = pipListEntry{Name: p.Name, Version: p.Version}
+	}
+	return marshalResult(entries, result)
+}
+
+// Outdated prints the packages with a newer release as JSON in the format
+// of `pip list --outdated`: conda packages with an update in the
+// environment's channels, and PyPI packages with a newer release on PyPI.
+// pip's view of the packages conda installed is ignored, since their
+// channels may lag behind PyPI.
+func (b CondaBackend) Outdated(x Executor) RunResult {
+	packages, result := b.list(x)
+	if result.Err != nil {
+		return result
+	}
+	pypi := make(map[string]bool)
+	for _, p := range packages {
+		if p.Channel == condaPyPIChannel {
+			pypi[pyname.Normalize(p.Name)] = true
+		}
+	}
+
+	entries := []pipOutdatedEntry{}
+	if len(pypi) > 0 {
+		result := b.Backend.Outdated(x)
+		if result.Err != nil {
+			return result
+		}
+		outdated, err := ParseOutdatedList(result.Stdout)
+		if err != nil {
+			return RunResult{Stdout: result.Stdout, Stderr: result.Stderr, Err: fmt.Errorf("pip: parse outdated list: %w", err)}
+		}
+		for _, p := range outdated {
+			if pypi[pyname.Normalize(p.Name)] {
+				entries = append(entries, pipOutdatedEntry{Name: p.Name, Version: p.InstalledVersion, LatestVersion: p.LatestVersion})
+			}
+		}
+	}
+
+	result = x.Capture(b.Bin, b.args("update", "--all", "--dry-run", "--json")...)
+	if result.Err != nil {
+		return result
+	}
+	changes, err := parseCondaActions(result.Stdout)
+	if err != nil {
+		return RunResult{Stdout: result.Stdout, Stderr: result.Stderr, Err: fmt.Errorf("%s: parse dry run: %w", b.Name(), err)}
+	}
+	for _, c := range changes {
+		if c.Before != "" && c.After != "" {
+			entries = append(entries, pipOutdatedEntry{Name: c.Name, Version: c.Before, LatestVersion: c.After})
+		}
+	}
+	return marshalResult(entries, result)
+}
+
+// Sync makes the environment match the environment.yml file at path,
+// removing the packages it does not list.
+func (b CondaBackend) Sync(x Executor, path string) RunResult {
+	if b.Name() == "micromamba" {
+		// micromamba has no `env update`; install reads environment files
+		return x.Run(b.Bin, b.args("install", "--file", path)...)
+	}
+	return x.Run(b.Bin, "env", "update", "--prefix", b.Prefix, "--file", path, "--prune")
+}
+
+// DryRun resolves specs with the tool that would install them: conda's
+// --dry-run for conda packages, the embedded Backend for PyPI ones.
+func (b CondaBackend) DryRun(x Executor, specs []string, upgrade bool) ([]PlannedChange, error) {
+	packages, result := b.list(x)
+	if result.Err != nil {
+		return nil, commandError("list packages", result)
+	}
+	pypi := make(map[string]bool)
+	for _, p := range packages {
+		if p.Channel == condaPyPIChannel {
+			pypi[pyname.Normalize(p.Name)] = true
+		}
+	}
+
+	var condaSpecs, pipSpecs []string
+	for _, spec := range specs {
+		if pypi[pyname.Normalize(pyname.FromSpec(spec))] || pipOnlySpec(spec) {
+			pipSpecs = append(pipSpecs, spec)
+		} else {
+			condaSpecs = append(condaSpecs, spec)
+		}
+	}
+
+	var changes []PlannedChange
+	if len(pipSpecs) > 0 {
+		pipChanges, err := b.Backend.DryRun(x, pipSpecs, upgrade)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, pipChanges...)
+	}
+	if len(condaSpecs) > 0 {
+		command := "install"
+		if upgrade {
+			command = "update"
+		}
+		result := x.Capture(b.Bin, b.args(command, append([]string{"--dry-run", "--json"}, condaSpecs...)...)...)
+		if result.Err != nil {
+			return nil, commandError("dry run", result)
+		}
+		condaChanges, err := parseCondaActions(result.Stdout)
+		if err != nil {
+			return nil, fmt.Errorf("%s: parse dry run: %w", b.Name(), err)
+		}
+		changes = append(changes, condaChanges...)
+	}
+	return changes, nil
+}
+
+// args returns the arguments of a conda command on the environment that
+// does not ask for confirmation.
+func (b CondaBackend) args(command string, rest ...string) []string {
+	return append([]string{command, "--prefix", b.Prefix, "--yes"}, rest...)
+}
+
+// list returns the packages of the environment, as `conda list` reports
+// them.
+func (b CondaBackend) list(x Executor) ([]condaPackage, RunResult) {
+	result := x.Capture(b.Bin, "list", "--prefix", b.Prefix, "--json")
+	if result.Err != nil {
+		return nil, result
+	}
+	var packages []condaPackage
+	if err := json.Unmarshal([]byte(result.Stdout), &packages); err != nil {
+		result.Err = fmt.Errorf("%s: parse package list: %w", b.Name(), err)
+		return nil, result
+	}
+	return packages, result
+}
+
+// fromConda reports whether name was installed from a conda channel. A
+// package conda does not list is left to pip.
+func (b CondaBackend) fromConda(x Executor, name string) (bool, error) {
+	packages, result := b.list(x)
+	if result.Err != nil {
+		return false, commandError("list packages", result)
+	}
+	key := pyname.Normalize(name)
+	for _, p := range packages {
+		if pyname.Normalize(p.Name) == key {
+			return p.Channel != condaPyPIChannel, nil
+		}
+	}
+	return false, nil
+}
+
+// condaPyPIChannel is the channel `conda list` reports for packages pip
+// installed.
+const condaPyPIChannel = "pypi"
+
+// condaPackage matches the entries of `conda list --json`, and the
+// packages of the LINK and UNLINK actions of `conda install --dry-run
+// --json`.
+type condaPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Channel string `json:"channel"`
+}
+
+// condaActions matches the output of a conda command run with --dry-run
+// --json. It has no actions when there is nothing to do.
+type condaActions struct {
+	Actions struct {
+		Link   []condaPackage `json:"LINK"`
+		Unlink []condaPackage `json:"UNLINK"`
+	} `json:"actions"`
+}
+
+// parseCondaActions turns the packages a conda dry run would unlink and
+// link into changes; an upgraded package appears in both.
+func parseCondaActions(data string) ([]PlannedChange, error) {
+	var out condaActions
+	if err := json.Unmarshal([]byte(data), &out); err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*PlannedChange)
+	var order []string
+	change := func(name string) *PlannedChange {
+		key := pyname.Normalize(name)
+		if c, ok := byName[key]; ok {
+			return c
+		}
+		c := &PlannedChange{Name: name}
+		byName[key] = c
+		order = append(order, key)
+		return c
+	}
+	for _, p := range out.Actions.Unlink {
+		change(p.Name).Before = p.Version
+	}
+	for _, p := range out.Actions.Link {
+		change(p.Name).After = p.Version
+	}
+	changes := make([]PlannedChange, 0, len(order))
+	for _, key := range order {
+		changes = append(changes, *byName[key])
+	}
+	return changes, nil
+}
+
+// pipOnlySpec reports whether spec uses PEP 508 syntax that conda match
+// specs lack: extras, a direct reference or an environment marker.
+func pipOnlySpec(spec string) bool {
+	return strings.ContainsAny(spec, "[@;")
+}
+
+// marshalResult returns v as the JSON output of result.
+func marshalResult(v any, result RunResult) RunResult {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return RunResult{Stderr: result.Stderr, Err: err}
+	}
+	return RunResult{Stdout: string(data), Stderr: result.Stderr}
+}
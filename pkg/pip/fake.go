@@ -0,0 +1,208 @@
+package pip
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/eslam/depman/pkg/pyname"
+)
+
+// FakeBackend is an in-memory Backend for tests. It runs no commands:
+// operations edit Installed and are recorded in Calls, so code that drives
+// a Runner can be tested without a package manager on PATH.
+type FakeBackend struct {
+	// Installed maps the installed distributions to their versions.
+	Installed map[string]string
+	// Latest maps distributions to the version Install and Upgrade move
+	// to. Names missing here install as "1.0" and never upgrade.
+	Latest map[string]string
+	// Fail maps a call, e.g. "upgrade requests", to the error it returns
+	// without changing anything.
+	Fail map[string]error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// NewFakeBackend returns a FakeBackend with installed as its environment.
+func NewFakeBackend(installed map[string]string) *FakeBackend {
+	if installed == nil {
+		installed = make(map[string]string)
+	}
+	return &FakeBackend{Installed: installed, Latest: make(map[string]string), Fail: make(map[string]error)}
+}
+
+// Calls returns the operations performed so far, in order, e.g.
+// "install requests==2.31.0" or "list".
+func (f *FakeBackend) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// Name returns "fake".
+func (f *FakeBackend) Name() string { return "fake" }
+
+// Install installs spec at its pinned version, or at the latest one.
+func (f *FakeBackend) Install(x Executor, spec string) RunResult {
+	return f.do("install "+spec, func() {
+		name, ver, pinned := strings.Cut(spec, "==")
+		if !pinned {
+			name, ver = pyname.FromSpec(spec), f.latest(pyname.FromSpec(spec))
+		}
+		f.Installed[f.key(strings.TrimSpace(name))] = strings.TrimSpace(ver)
+	})
+}
+
+// Uninstall removes a package.
+func (f *FakeBackend) Uninstall(x Executor, name string) RunResult {
+	return f.do("uninstall "+name, func() {
+		delete(f.Installed, f.key(name))
+	})
+}
+
+// Upgrade moves a package to its latest version.
+func (f *FakeBackend) Upgrade(x Executor, name string) RunResult {
+	return f.do("upgrade "+name, func() {
+		f.Installed[f.key(name)] = f.latest(name)
+	})
+}
+
+// List prints the installed packages as `pip list` does.
+func (f *FakeBackend) List(x Executor) RunResult {
+	var entries []pipListEntry
+	result := f.do("list", func() {
+		for _, name := range f.names() {
+			entries = append(entries, pipListEntry{Name: name, Version: f.Installed[name]})
+		}
+	})
+	return f.marshal(result, entries)
+}
+
+// Outdated prints the installed packages whose latest version differs.
+func (f *FakeBackend) Outdated(x Executor) RunResult {
+	var entries []pipOutdatedEntry
+	result := f.do("outdated", func() {
+		for _, name := range f.names() {
+			if latest := f.latest(name); latest != f.Installed[name] {
+				entries = append(entries, pipOutdatedEntry{Name: name, Version: f.Installed[name], LatestVersion: latest})
+			}
+		}
+	})
+	return f.marshal(result, entries)
+}
+
+// Lock pins the installed packages, whatever the input.
+func (f *FakeBackend) Lock(x Executor, input string) RunResult {
+	var b strings.Builder
+	result := f.do("lock "+input, func() {
+		for _, name := range f.names() {
+			fmt.Fprintf(&b, "%s==%s\n", name, f.Installed[name])
+		}
+	})
+	result.Stdout = b.String()
+	return result
+}
+
+// Sync replaces the installed packages with the name==version lines of
+// the file at path.
+func (f *FakeBackend) Sync(x Executor, path string) RunResult {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RunResult{Err: fmt.Errorf("fake: sync: %w", err)}
+	}
+	return f.do("sync "+path, func() {
+		clear(f.Installed)
+		for _, line := range strings.Split(string(data), "\n") {
+			if name, ver, ok := strings.Cut(strings.TrimSpace(line), "=="); ok {
+				f.Installed[name] = ver
+			}
+		}
+	})
+}
+
+// DryRun reports what Install, or Upgrade if upgrade is set, would do
+// to each of specs.
+func (f *FakeBackend) DryRun(x Executor, specs []string, upgrade bool) ([]PlannedChange, error) {
+	var changes []PlannedChange
+	result := f.do("dry-run "+strings.Join(specs, " "), func() {
+		for _, spec := range specs {
+			name, after, pinned := strings.Cut(spec, "==")
+			if !pinned {
+				name = pyname.FromSpec(spec)
+				after = f.latest(name)
+			}
+			before := f.Installed[f.key(name)]
+			if before != "" && !upgrade && !pinned {
+				continue // already satisfied
+			}
+			if before != after {
+				changes = append(changes, PlannedChange{Name: name, Before: before, After: after, Requested: true})
+			}
+		}
+	})
+	return changes, result.Err
+}
+
+// do records call and runs op unless the call is set to fail.
+func (f *FakeBackend) do(call string, op func()) RunResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+	if err := f.Fail[call]; err != nil {
+		return RunResult{Stderr: err.Error(), Err: err}
+	}
+	op()
+	return RunResult{}
+}
+
+func (f *FakeBackend) marshal(result RunResult, entries any) RunResult {
+	if result.Err != nil {
+		return result
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return RunResult{Err: err}
+	}
+	if string(data) == "null" {
+		data = []byte("[]")
+	}
+	return RunResult{Stdout: string(data)}
+}
+
+// key returns the name under which name is installed, matching names as
+// pip does.
+func (f *FakeBackend) key(name string) string {
+	for installed := range f.Installed {
+		if pyname.Normalize(installed) == pyname.Normalize(name) {
+			return installed
+		}
+	}
+	return name
+}
+
+func (f *FakeBackend) latest(name string) string {
+	for n, v := range f.Latest {
+		if pyname.Normalize(n) == pyname.Normalize(name) {
+			return v
+		}
+	}
+	if v, ok := f.Installed[f.key(name)]; ok {
+		return v
+	}
+	return "1.0"
+}
+
+// names returns the installed names, sorted.
+func (f *FakeBackend) names() []string {
+	names := make([]string, 0, len(f.Installed))
+	for name := range f.Installed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
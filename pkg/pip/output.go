@@ -0,0 +1,57 @@
+package pip
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+// OutputLine is one line printed by a running command.
+type OutputLine struct {
+	Stream string // "stdout" or "stderr"
+	Text   string
+}
+
+// lineWriter splits a command's output into lines and sends them to out.
+// Progress bars redraw with a carriage return, so it ends lines there too.
+// Blank lines are dropped.
+type lineWriter struct {
+	ctx    context.Context
+	out    chan<- OutputLine
+	stream string
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.emit(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush sends the last line if the command did not end it.
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+		w.buf = nil
+	}
+}
+
+// emit sends a line, giving up once the command's context is done so a
+// reader that went away cannot block the command.
+func (w *lineWriter) emit(line string) {
+	line = strings.TrimRight(line, " \t")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	select {
+	case w.out <- OutputLine{Stream: w.stream, Text: line}:
+	case <-w.ctx.Done():
+	}
+}
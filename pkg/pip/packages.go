@@ -2,10 +2,9 @@ package pip
 
 import (
 	"encoding/json"
-	"strconv"
-	"strings"
 
 	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/version"
 )
 
 // Package represents an installed Python package.
@@ -13,15 +12,18 @@ type Package struct {
 	Name             string          `json:"name"`
 	InstalledVersion string          `json:"version"`
 	LatestVersion    string          `json:"latest_version,omitempty"`
-	Description      string          `json:"-"`
-	DiffType         config.DiffType `json:"-"`
-	IsOutdated       bool            `json:"-"`
+	Description      string          `json:"description,omitempty"`
+	DiffType         config.DiffType `json:"diff_type,omitempty"`
+	IsOutdated       bool            `json:"is_outdated"`
+	Editable         bool            `json:"editable,omitempty"` // installed with pip install -e
+	Hashes           []string        `json:"hashes,omitempty"`   // hashes of the installed version recorded in the project's lock file, e.g. "sha256:..."
 }
 
 // pipListEntry matches the JSON output of `pip list --format json`.
 type pipListEntry struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
+	Name             string `json:"name"`
+	Version          string `json:"version"`
+	EditableLocation string `json:"editable_project_location"`
 }
 
 // pipOutdatedEntry matches the JSON output of `pip list --outdated --format json`.
@@ -44,6 +46,7 @@ func ParsePackageList(jsonData string) ([]Package, error) {
 		packages[i] = Package{
 			Name:             e.Name,
 			InstalledVersion: e.Version,
+			Editable:         e.EditableLocation != "",
 		}
 	}
 	return packages, nil
@@ -70,53 +73,29 @@ func ParseOutdatedList(jsonData string) ([]Package, error) {
 	return packages, nil
 }
 
-// ComputeDiff classifies the semver difference between two version strings.
+// ComputeDiff classifies the difference between two PEP 440 version
+// strings by the most significant release segment that changed. Versions
+// that share a release but differ otherwise (e.g. 2.0rc1 → 2.0) count as a
+// patch; unparseable versions yield DiffUnknown.
 func ComputeDiff(current, latest string) config.DiffType {
-	curParts := parseVersion(current)
-	latParts := parseVersion(latest)
-
-	if len(curParts) < 3 || len(latParts) < 3 {
+	cur, err := version.Parse(current)
+	if err != nil {
+		return config.DiffUnknown
+	}
+	lat, err := version.Parse(latest)
+	if err != nil {
 		return config.DiffUnknown
 	}
 
-	if curParts[0] != latParts[0] {
+	switch {
+	case cur.Epoch != lat.Epoch || cur.Major() != lat.Major():
 		return config.DiffMajor
-	}
-	if curParts[1] != latParts[1] {
+	case cur.Minor() != lat.Minor():
 		return config.DiffMinor
-	}
-	if curParts[2] != latParts[2] {
+	case cur.Micro() != lat.Micro():
+		return config.DiffPatch
+	case !version.Equal(cur, lat):
 		return config.DiffPatch
 	}
 	return config.DiffNone
 }
-
-func parseVersion(v string) []int {
-	// Strip "v" prefix if present
-	v = strings.TrimPrefix(v, "v")
-	
-	// Strip pre-release suffixes like "1.2.3rc1" → "1.2.3"
-	for i, c := range v {
-		if c != '.' && (c < '0' || c > '9') {
-			v = v[:i]
-			break
-		}
-	}
-	
-	parts := strings.Split(v, ".")
-	nums := make([]int, len(parts))
-	for i, p := range parts {
-		n, err := strconv.Atoi(p)
-		if err != nil {
-			return nil
-		}
-		nums[i] = n
-	}
-	
-	// Pad to at least 3 parts for consistent comparison
-	for len(nums) < 3 {
-		nums = append(nums, 0)
-	}
-	
-	return nums
-}
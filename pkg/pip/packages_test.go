@@ -1,119 +1,13 @@
 package pip
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
 
 	"github.com/eslam/depman/config"
 )
 
-func TestParseVersion(t *testing.T) {
-	tests := []struct {
-		name     string
-		version  string
-		expected []int
-	}{
-		{
-			name:     "standard version",
-			version:  "1.2.3",
-			expected: []int{1, 2, 3},
-		},
-		{
-			name:     "v-prefix version",
-			version:  "v2.0.1",
-			expected: []int{2, 0, 1},
-		},
-		{
-			name:     "pre-release version",
-			version:  "1.0.0rc1",
-			expected: []int{1, 0, 0},
-		},
-		{
-			name:     "short version",
-			version:  "1.0",
-			expected: []int{1, 0, 0},
-		},
-		{
-			name:     "single number version",
-			version:  "2",
-			expected: []int{2, 0, 0},
-		},
-		{
-			name:     "invalid version",
-			version:  "abc",
-			expected: nil,
-		},
-		{
-			name:     "mixed version",
-			version:  "1.2.beta3",
-			expected: nil, // dot before non-numeric fails parsing
-		},
-		{
-			name:     "alpha version",
-			version:  "3.0.0a1",
-			expected: []int{3, 0, 0},
-		},
-		{
-			name:     "beta version",
-			version:  "2.5.1b2",
-			expected: []int{2, 5, 1},
-		},
-		{
-			name:     "dev version",
-			version:  "1.2.3.dev456",
-			expected: nil, // dot before non-numeric fails parsing
-		},
-		{
-			name:     "post-release version",
-			version:  "1.0.0.post1",
-			expected: nil, // dot before non-numeric fails parsing
-		},
-		{
-			name:     "empty string",
-			version:  "",
-			expected: nil,
-		},
-		{
-			name:     "very short version",
-			version:  "v1",
-			expected: []int{1, 0, 0},
-		},
-		{
-			name:     "four-part version",
-			version:  "1.2.3.4",
-			expected: []int{1, 2, 3, 4},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := parseVersion(tt.version)
-
-			if tt.expected == nil {
-				if result != nil {
-					t.Errorf("parseVersion(%q) = %v, want nil", tt.version, result)
-				}
-				return
-			}
-
-			if result == nil {
-				t.Errorf("parseVersion(%q) = nil, want %v", tt.version, tt.expected)
-				return
-			}
-
-			if len(result) != len(tt.expected) {
-				t.Errorf("parseVersion(%q) length = %d, want %d", tt.version, len(result), len(tt.expected))
-				return
-			}
-
-			for i := range result {
-				if result[i] != tt.expected[i] {
-					t.Errorf("parseVersion(%q)[%d] = %d, want %d", tt.version, i, result[i], tt.expected[i])
-				}
-			}
-		})
-	}
-}
-
 func TestComputeDiff(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -167,7 +61,7 @@ func TestComputeDiff(t *testing.T) {
 			name:     "pre-release to release",
 			current:  "1.0.0rc1",
 			latest:   "1.0.0",
-			expected: config.DiffNone,
+			expected: config.DiffPatch,
 		},
 		{
 			name:     "with v-prefix",
@@ -205,120 +99,50 @@ func TestComputeDiff(t *testing.T) {
 			latest:   "",
 			expected: config.DiffUnknown,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := ComputeDiff(tt.current, tt.latest)
-			if result != tt.expected {
-				t.Errorf("ComputeDiff(%q, %q) = %v, want %v",
-					tt.current, tt.latest, result, tt.expected)
-			}
-		})
-	}
-}
-
-func TestCompareVersions(t *testing.T) {
-	tests := []struct {
-		name     string
-		v1       string
-		v2       string
-		expected string // "less", "equal", "greater"
-	}{
-		{
-			name:     "v1 less than v2",
-			v1:       "1.0.0",
-			v2:       "2.0.0",
-			expected: "less",
-		},
-		{
-			name:     "v1 equal to v2",
-			v1:       "1.0.0",
-			v2:       "1.0.0",
-			expected: "equal",
-		},
 		{
-			name:     "v1 greater than v2",
-			v1:       "2.0.0",
-			v2:       "1.0.0",
-			expected: "greater",
-		},
-		{
-			name:     "minor version comparison",
-			v1:       "1.5.0",
-			v2:       "1.10.0",
-			expected: "less",
+			name:     "post-release",
+			current:  "1.0.0",
+			latest:   "1.0.0.post1",
+			expected: config.DiffPatch,
 		},
 		{
-			name:     "patch version comparison",
-			v1:       "1.0.5",
-			v2:       "1.0.15",
-			expected: "less",
+			name:     "dev to release",
+			current:  "1.2.3.dev456",
+			latest:   "1.2.3",
+			expected: config.DiffPatch,
 		},
 		{
-			name:     "pre-release stripped",
-			v1:       "1.0.0rc1",
-			v2:       "1.0.0",
-			expected: "equal",
+			name:     "zero-padded equal",
+			current:  "1.0",
+			latest:   "1.0.0",
+			expected: config.DiffNone,
 		},
 		{
-			name:     "different pre-releases",
-			v1:       "1.0.0a1",
-			v2:       "1.0.0b2",
-			expected: "equal",
+			name:     "epoch bump",
+			current:  "2.0.0",
+			latest:   "1!1.0.0",
+			expected: config.DiffMajor,
 		},
 		{
-			name:     "short vs full version",
-			v1:       "1.0",
-			v2:       "1.0.0",
-			expected: "equal",
+			name:     "non-normalized spelling",
+			current:  "1.0.0-ALPHA1",
+			latest:   "1.0.0a1",
+			expected: config.DiffNone,
 		},
 		{
-			name:     "with v-prefix",
-			v1:       "v1.0.0",
-			v2:       "v1.0.1",
-			expected: "less",
+			name:     "calendar version",
+			current:  "2023.10.1",
+			latest:   "2024.1.0",
+			expected: config.DiffMajor,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			parts1 := parseVersion(tt.v1)
-			parts2 := parseVersion(tt.v2)
-
-			if parts1 == nil || parts2 == nil {
-				t.Skip("Invalid version strings")
-			}
-
-			// Compare versions lexicographically
-			minLen := len(parts1)
-			if len(parts2) < minLen {
-				minLen = len(parts2)
-			}
-
-			result := "equal"
-			for i := 0; i < minLen; i++ {
-				if parts1[i] < parts2[i] {
-					result = "less"
-					break
-				}
-				if parts1[i] > parts2[i] {
-					result = "greater"
-					break
-				}
-			}
-
-			if result == "equal" && len(parts1) != len(parts2) {
-				if len(parts1) < len(parts2) {
-					result = "less"
-				} else {
-					result = "greater"
-				}
-			}
-
+			result := ComputeDiff(tt.current, tt.latest)
 			if result != tt.expected {
-				t.Errorf("compare(%q, %q) = %s, want %s",
-					tt.v1, tt.v2, result, tt.expected)
+				t.Errorf("ComputeDiff(%q, %q) = %v, want %v",
+					tt.current, tt.latest, result, tt.expected)
 			}
 		})
 	}
@@ -343,6 +167,16 @@ func TestParsePackageList(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "editable install",
+			jsonData: `[
+				{"name": "mylib", "version": "0.1.0", "editable_project_location": "/src/mylib"}
+			]`,
+			expected: []Package{
+				{Name: "mylib", InstalledVersion: "0.1.0", Editable: true},
+			},
+			expectError: false,
+		},
 		{
 			name:        "empty list",
 			jsonData:    `[]`,
@@ -512,7 +346,7 @@ func TestParseOutdatedList(t *testing.T) {
 					Name:             "mypackage",
 					InstalledVersion: "1.0.0rc1",
 					LatestVersion:    "1.0.0",
-					DiffType:         config.DiffNone,
+					DiffType:         config.DiffPatch,
 					IsOutdated:       true,
 				},
 			},
@@ -605,3 +439,54 @@ func TestParseOutdatedList(t *testing.T) {
 		})
 	}
 }
+
+func TestPackageJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		pkg      Package
+		expected string
+	}{
+		{
+			name:     "installed package",
+			pkg:      Package{Name: "requests", InstalledVersion: "2.31.0"},
+			expected: `{"name":"requests","version":"2.31.0","is_outdated":false}`,
+		},
+		{
+			name:     "locked package",
+			pkg:      Package{Name: "requests", InstalledVersion: "2.31.0", Hashes: []string{"sha256:aaa"}},
+			expected: `{"name":"requests","version":"2.31.0","is_outdated":false,"hashes":["sha256:aaa"]}`,
+		},
+		{
+			name: "outdated package",
+			pkg: Package{
+				Name:             "django",
+				InstalledVersion: "4.2.0",
+				LatestVersion:    "5.0.1",
+				Description:      "A high-level Python web framework",
+				DiffType:         config.DiffMajor,
+				IsOutdated:       true,
+			},
+			expected: `{"name":"django","version":"4.2.0","latest_version":"5.0.1","description":"A high-level Python web framework","diff_type":"major","is_outdated":true}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.pkg)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("json.Marshal() = %s, want %s", data, tt.expected)
+			}
+
+			var decoded Package
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(decoded, tt.pkg) {
+				t.Errorf("round trip = %+v, want %+v", decoded, tt.pkg)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package pip
+
+import "strings"
+
+// PDMBackend drives PDM for a PDM project: pdm add, pdm remove, pdm
+// update, pdm lock and pdm sync. PDM edits pyproject.toml and pdm.lock
+// itself. Listing packages, previews and operations on undeclared
+// distributions go through the embedded Backend, that of the
+// environment's package manager.
+type PDMBackend struct {
+	Backend
+	Bin string // path to pdm
+	Dir string // project directory, holding pyproject.toml and pdm.lock
+}
+
+// Name returns "pdm".
+func (b PDMBackend) Name() string { return "pdm" }
+
+// Add declares spec in group and installs it with `pdm add`.
+func (b PDMBackend) Add(x Executor, spec, group string) RunResult {
+	args := append(b.project("add"), pdmGroupArgs(group)...)
+	return x.Run(b.Bin, append(args, spec)...)
+}
+
+// Remove drops name from group and uninstalls it with `pdm remove`.
+func (b PDMBackend) Remove(x Executor, name, group string) RunResult {
+	args := append(b.project("remove"), pdmGroupArgs(group)...)
+	return x.Run(b.Bin, append(args, name)...)
+}
+
+// Upgrade updates name to the latest version its constraint allows with
+// `pdm update`.
+func (b PDMBackend) Upgrade(x Executor, name string) RunResult {
+	return x.Run(b.Bin, append(b.project("update"), name)...)
+}
+
+// Lock updates pdm.lock from pyproject.toml. input is ignored: PDM always
+// locks the whole project.
+func (b PDMBackend) Lock(x Executor, input string) RunResult {
+	return x.Run(b.Bin, b.project("lock")...)
+}
+
+// Sync makes the environment match pdm.lock, uninstalling what it does not
+// list. path is ignored: PDM always installs from its lock file.
+func (b PDMBackend) Sync(x Executor, path string) RunResult {
+	return x.Run(b.Bin, append(b.project("sync"), "--clean")...)
+}
+
+// project returns the arguments of a PDM command run on b.Dir.
+func (b PDMBackend) project(command string) []string {
+	return []string{command, "--project", b.Dir}
+}
+
+// pdmGroupArgs returns the flags that select group for `pdm add` and
+// `pdm remove`: -G for an optional dependency group, -dG for a
+// development one.
+func pdmGroupArgs(group string) []string {
+	kind, name, _ := strings.Cut(group, ":")
+	switch kind {
+	case "optional":
+		return []string{"-G", name}
+	case "group":
+		return []string{"-dG", name}
+	default:
+		return nil
+	}
+}
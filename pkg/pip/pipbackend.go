@@ -0,0 +1,67 @@
+package pip
+
+import "fmt"
+
+// PipBackend drives pip.
+type PipBackend struct {
+	Bin string // path to pip or pip3
+}
+
+// Name returns "pip".
+func (b PipBackend) Name() string { return "pip" }
+
+// Install installs a package.
+func (b PipBackend) Install(x Executor, spec string) RunResult {
+	return x.Run(b.Bin, "install", spec)
+}
+
+// Uninstall removes a package without asking for confirmation.
+func (b PipBackend) Uninstall(x Executor, name string) RunResult {
+	return x.Run(b.Bin, "uninstall", name, "-y")
+}
+
+// Upgrade upgrades a package to its latest version.
+func (b PipBackend) Upgrade(x Executor, name string) RunResult {
+	return x.Run(b.Bin, "install", "--upgrade", name)
+}
+
+// List prints the installed packages as JSON.
+func (b PipBackend) List(x Executor) RunResult {
+	return x.Capture(b.Bin, "list", "--format", "json")
+}
+
+// Outdated prints the packages with a newer release as JSON.
+func (b PipBackend) Outdated(x Executor) RunResult {
+	return x.Capture(b.Bin, "list", "--outdated", "--format", "json")
+}
+
+// Lock is not supported: pip cannot write a pinned requirements file
+// without installing.
+func (b PipBackend) Lock(x Executor, input string) RunResult {
+	return unsupported("pip", "lock")
+}
+
+// Sync is not supported: pip installs from a requirements file but never
+// uninstalls what the file leaves out.
+func (b PipBackend) Sync(x Executor, path string) RunResult {
+	return unsupported("pip", "sync")
+}
+
+// DryRun reads the changes from pip's installation report (pip 22.2 and
+// later), which lists what would be installed. pip does not report the
+// versions it would replace.
+func (b PipBackend) DryRun(x Executor, specs []string, upgrade bool) ([]PlannedChange, error) {
+	args := []string{"install", "--dry-run", "--quiet", "--report", "-"}
+	if upgrade {
+		args = append(args, "--upgrade")
+	}
+	result := x.Capture(b.Bin, append(args, specs...)...)
+	if result.Err != nil {
+		return nil, commandError("dry run", result)
+	}
+	changes, err := parseInstallReport(result.Stdout)
+	if err != nil {
+		return nil, fmt.Errorf("pip: parse installation report: %w", err)
+	}
+	return changes, nil
+}
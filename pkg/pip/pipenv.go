@@ -0,0 +1,58 @@
+package pip
+
+// PipenvBackend drives pipenv for a Pipfile with a Pipfile.lock: pipenv
+// install, pipenv uninstall, pipenv update, pipenv lock and pipenv sync.
+// pipenv edits the Pipfile and Pipfile.lock itself; it finds them by
+// searching up from the working directory, which is the project
+// directory depman runs in. Listing packages, previews and operations on
+// undeclared distributions go through the embedded Backend, that of the
+// environment's package manager.
+type PipenvBackend struct {
+	Backend
+	Bin string // path to pipenv
+}
+
+// Name returns "pipenv".
+func (b PipenvBackend) Name() string { return "pipenv" }
+
+// Add declares spec in group and installs it with `pipenv install`.
+func (b PipenvBackend) Add(x Executor, spec, group string) RunResult {
+	return x.Run(b.Bin, append(append([]string{"install"}, pipenvCategoryArgs(group)...), spec)...)
+}
+
+// Remove drops name from group and uninstalls it with `pipenv uninstall`.
+func (b PipenvBackend) Remove(x Executor, name, group string) RunResult {
+	return x.Run(b.Bin, append(append([]string{"uninstall"}, pipenvCategoryArgs(group)...), name)...)
+}
+
+// Upgrade updates name in Pipfile.lock to the latest version the Pipfile
+// allows, and installs it, with `pipenv update`.
+func (b PipenvBackend) Upgrade(x Executor, name string) RunResult {
+	return x.Run(b.Bin, "update", name)
+}
+
+// Lock updates Pipfile.lock from the Pipfile. input is ignored: pipenv
+// always locks the whole Pipfile.
+func (b PipenvBackend) Lock(x Executor, input string) RunResult {
+	return x.Run(b.Bin, "lock")
+}
+
+// Sync installs the packages and dev packages of Pipfile.lock, then
+// uninstalls what it does not list with `pipenv clean`. path is ignored:
+// pipenv always installs from its lock file.
+func (b PipenvBackend) Sync(x Executor, path string) RunResult {
+	if result := x.Run(b.Bin, "sync", "--dev"); result.Err != nil {
+		return result
+	}
+	return x.Run(b.Bin, "clean")
+}
+
+// pipenvCategoryArgs returns the flags that select the Pipfile table of
+// group, written as by parser.Group: [dev-packages] for "group:dev",
+// [packages] otherwise.
+func pipenvCategoryArgs(group string) []string {
+	if group == "group:dev" {
+		return []string{"--categories", "dev-packages"}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package pip
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PoetryBackend drives Poetry for a Poetry project: poetry add, poetry
+// remove, poetry update, poetry lock and poetry sync. Poetry
+// edits pyproject.toml and poetry.lock itself. Listing packages, previews
+// and operations on undeclared distributions go through the embedded
+// Backend, that of the environment's package manager.
+type PoetryBackend struct {
+	Backend
+	Bin string // path to poetry
+	Dir string // project directory, holding pyproject.toml and poetry.lock
+}
+
+// Name returns "poetry".
+func (b PoetryBackend) Name() string { return "poetry" }
+
+// Add declares spec in group and installs it with `poetry add`.
+func (b PoetryBackend) Add(x Executor, spec, group string) RunResult {
+	flags, err := b.addArgs(x, group)
+	if err != nil {
+		return RunResult{Err: err}
+	}
+	args := append(b.project("add"), flags...)
+	return x.Run(b.Bin, append(args, spec)...)
+}
+
+// addArgs returns the flags of `poetry add` that select group, written as
+// by parser.Group. Poetry 2 names the extra with `--optional <extra>`;
+// in Poetry 1 --optional takes no value and the extra has to be declared
+// by hand, so adding to an extra needs Poetry 2. The version is only
+// asked for then.
+func (b PoetryBackend) addArgs(x Executor, group string) ([]string, error) {
+	kind, name, _ := strings.Cut(group, ":")
+	switch kind {
+	case "optional":
+		major, err := b.majorVersion(x)
+		if err != nil {
+			return nil, err
+		}
+		if major < 2 {
+			return nil, fmt.Errorf("poetry: add to extra %s: needs Poetry 2 or later, found Poetry %d: %w", name, major, ErrUnsupported)
+		}
+		return []string{"--optional", name}, nil
+	case "group":
+		return []string{"--group", name}, nil
+	default:
+		return nil, nil
+	}
+}
+
+var poetryVersionPattern = regexp.MustCompile(`(\d+)\.\d+`)
+
+// majorVersion returns the major version of b.Bin, from output such as
+// "Poetry (version 2.1.1)".
+func (b PoetryBackend) majorVersion(x Executor) (int, error) {
+	result := x.Capture(b.Bin, "--version")
+	if result.Err != nil {
+		return 0, commandError("poetry --version", result)
+	}
+	m := poetryVersionPattern.FindStringSubmatch(result.Stdout)
+	if m == nil {
+		return 0, fmt.Errorf("poetry: unrecognized version %q", strings.TrimSpace(result.Stdout))
+	}
+	return strconv.Atoi(m[1])
+}
+
+// Remove drops name from group and uninstalls it with `poetry remove`.
+// Optional dependencies are declared with the main ones, so only a
+// dependency group needs naming.
+func (b PoetryBackend) Remove(x Executor, name, group string) RunResult {
+	args := b.project("remove")
+	if kind, g, _ := strings.Cut(group, ":"); kind == "group" {
+		args = append(args, "--group", g)
+	}
+	return x.Run(b.Bin, append(args, name)...)
+}
+
+// Upgrade updates name to the latest version its constraint allows with
+// `poetry update`.
+func (b PoetryBackend) Upgrade(x Executor, name string) RunResult {
+	return x.Run(b.Bin, append(b.project("update"), name)...)
+}
+
+// Lock updates poetry.lock from pyproject.toml. input is ignored: Poetry
+// always locks the whole project.
+func (b PoetryBackend) Lock(x Executor, input string) RunResult {
+	return x.Run(b.Bin, b.project("lock")...)
+}
+
+// Sync makes the environment match poetry.lock, uninstalling what it does
+// not list: `poetry sync` in Poetry 2, which deprecates `poetry install
+// --sync`, the command of Poetry 1. path is ignored: Poetry always installs
+// from its lock file.
+func (b PoetryBackend) Sync(x Executor, path string) RunResult {
+	major, err := b.majorVersion(x)
+	if err != nil {
+		return RunResult{Err: err}
+	}
+	if major >= 2 {
+		return x.Run(b.Bin, b.project("sync")...)
+	}
+	return x.Run(b.Bin, append(b.project("install"), "--sync")...)
+}
+
+// project returns the arguments of a Poetry command run on b.Dir.
+func (b PoetryBackend) project(command string) []string {
+	return []string{command, "--directory", b.Dir}
+}
@@ -0,0 +1,299 @@
+package pip
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eslam/depman/pkg/pyname"
+	"github.com/eslam/depman/pkg/version"
+)
+
+// ChangeKind classifies what an operation would do to one distribution.
+type ChangeKind int
+
+const (
+	ChangeInstall   ChangeKind = iota // not installed yet
+	ChangeUpgrade                     // replaced by a newer version
+	ChangeDowngrade                   // replaced by an older version
+	ChangeReinstall                   // reinstalled at the same version
+	ChangeRemove                      // uninstalled
+)
+
+// String returns the lower-case name of the kind, as used in output.
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeInstall:
+		return "install"
+	case ChangeUpgrade:
+		return "upgrade"
+	case ChangeDowngrade:
+		return "downgrade"
+	case ChangeReinstall:
+		return "reinstall"
+	case ChangeRemove:
+		return "remove"
+	default:
+		return "unknown"
+	}
+}
+
+// Symbol returns a one-character marker for the kind.
+func (k ChangeKind) Symbol() string {
+	switch k {
+	case ChangeInstall:
+		return "+"
+	case ChangeUpgrade:
+		return "↑"
+	case ChangeDowngrade:
+		return "↓"
+	case ChangeRemove:
+		return "-"
+	default:
+		return "="
+	}
+}
+
+// PlannedChange is one distribution an operation would add, remove or
+// replace. Before is empty for an install and After for a removal.
+type PlannedChange struct {
+	Name      string `json:"name"`
+	Before    string `json:"before,omitempty"`
+	After     string `json:"after,omitempty"`
+	Requested bool   `json:"requested"` // named on the command line, not pulled in as a dependency
+}
+
+// Kind classifies the change by comparing its versions.
+func (c PlannedChange) Kind() ChangeKind {
+	switch {
+	case c.Before == "":
+		return ChangeInstall
+	case c.After == "":
+		return ChangeRemove
+	}
+	switch cmp := version.CompareStrings(c.After, c.Before); {
+	case cmp > 0:
+		return ChangeUpgrade
+	case cmp < 0:
+		return ChangeDowngrade
+	default:
+		return ChangeReinstall
+	}
+}
+
+// Versions describes the versions involved, e.g. "2.30.0 → 2.31.0".
+func (c PlannedChange) Versions() string {
+	switch c.Kind() {
+	case ChangeInstall:
+		return c.After
+	case ChangeRemove, ChangeReinstall:
+		return c.Before
+	default:
+		return c.Before + " → " + c.After
+	}
+}
+
+// Preview is the full set of distributions an operation would change, as
+// worked out by the package manager's resolver without touching the
+// environment.
+type Preview struct {
+	Changes []PlannedChange `json:"changes"` // sorted by name
+}
+
+// Empty reports whether the operation would change nothing.
+func (p Preview) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// TransitiveDowngrades returns the downgrades the user did not ask for:
+// dependencies the resolver would move to an older version to satisfy
+// the requested packages.
+func (p Preview) TransitiveDowngrades() []PlannedChange {
+	var out []PlannedChange
+	for _, c := range p.Changes {
+		if c.Kind() == ChangeDowngrade && !c.Requested {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Summary counts the changes by kind, e.g. "2 to install, 1 to downgrade".
+func (p Preview) Summary() string {
+	counts := make(map[ChangeKind]int)
+	for _, c := range p.Changes {
+		counts[c.Kind()]++
+	}
+	var parts []string
+	for _, k := range []ChangeKind{ChangeInstall, ChangeUpgrade, ChangeDowngrade, ChangeReinstall, ChangeRemove} {
+		if n := counts[k]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d to %s", n, k))
+		}
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PreviewInstall resolves installing specs without installing anything.
+func (r *Runner) PreviewInstall(specs ...string) (Preview, error) {
+	for _, spec := range specs {
+		if err := ValidatePackageSpec(spec); err != nil {
+			return Preview{}, fmt.Errorf("invalid package: %w", err)
+		}
+	}
+	return r.dryRun(specs, false)
+}
+
+// PreviewUpgrade resolves upgrading the named packages to their latest
+// versions without installing anything.
+func (r *Runner) PreviewUpgrade(names ...string) (Preview, error) {
+	for _, name := range names {
+		if err := ValidatePackageName(name); err != nil {
+			return Preview{}, fmt.Errorf("invalid package: %w", err)
+		}
+	}
+	return r.dryRun(names, true)
+}
+
+// PreviewUninstall lists the installed packages among names. Uninstalling
+// never touches dependencies, so no resolver is involved.
+func (r *Runner) PreviewUninstall(names ...string) (Preview, error) {
+	installed, err := r.installedVersions()
+	if err != nil {
+		return Preview{}, err
+	}
+	var changes []PlannedChange
+	for _, name := range names {
+		if v, ok := installed[pyname.Normalize(name)]; ok {
+			changes = append(changes, PlannedChange{Name: name, Before: v, Requested: true})
+		}
+	}
+	return newPreview(changes), nil
+}
+
+// dryRun runs the backend's dry-run install and fills in the installed
+// versions it does not report.
+func (r *Runner) dryRun(specs []string, upgrade bool) (Preview, error) {
+	installed, err := r.installedVersions()
+	if err != nil {
+		return Preview{}, err
+	}
+	changes, err := r.Backend.DryRun(r, specs, upgrade)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	requested := make(map[string]bool)
+	for _, spec := range specs {
+		requested[pyname.Normalize(pyname.FromSpec(spec))] = true
+	}
+	for i := range changes {
+		key := pyname.Normalize(changes[i].Name)
+		if changes[i].Before == "" {
+			changes[i].Before = installed[key]
+		}
+		changes[i].Requested = changes[i].Requested || requested[key]
+	}
+	return newPreview(changes), nil
+}
+
+// installedVersions returns the installed distributions as normalized
+// name → version, from site-packages when possible and from `pip list`
+// otherwise.
+func (r *Runner) installedVersions() (map[string]string, error) {
+	if versions := InstalledVersions(r.Venv); versions != nil {
+		return versions, nil
+	}
+	result := r.List()
+	if result.Err != nil {
+		return nil, fmt.Errorf("list packages: %w", result.Err)
+	}
+	packages, err := ParsePackageList(result.Stdout)
+	if err != nil {
+		return nil, fmt.Errorf("pip: parse package list: %w", err)
+	}
+	versions := make(map[string]string, len(packages))
+	for _, p := range packages {
+		versions[pyname.Normalize(p.Name)] = p.InstalledVersion
+	}
+	return versions, nil
+}
+
+// installReport matches the parts of pip's installation report
+// (`pip install --report`) that depman uses.
+type installReport struct {
+	Install []struct {
+		Requested bool `json:"requested"`
+		Metadata  struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"metadata"`
+	} `json:"install"`
+}
+
+// parseInstallReport turns a pip installation report into changes. pip
+// only reports what it would install, so Before is left empty.
+func parseInstallReport(data string) ([]PlannedChange, error) {
+	var report installReport
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, err
+	}
+	changes := make([]PlannedChange, 0, len(report.Install))
+	for _, item := range report.Install {
+		changes = append(changes, PlannedChange{
+			Name:      item.Metadata.Name,
+			After:     item.Metadata.Version,
+			Requested: item.Requested,
+		})
+	}
+	return changes, nil
+}
+
+// parseUVDryRun reads the changes from the output of `uv pip install
+// --dry-run`, which lists each removed and added distribution as
+// " - name==version" and " + name==version"; a replaced one appears twice.
+func parseUVDryRun(output string) []PlannedChange {
+	byName := make(map[string]*PlannedChange)
+	var order []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		op, rest, ok := strings.Cut(line, " ")
+		if !ok || (op != "+" && op != "-") {
+			continue
+		}
+		name, ver, found := strings.Cut(strings.TrimSpace(rest), "==")
+		if !found {
+			// Direct references read "name @ url"
+			name, ver, _ = strings.Cut(rest, " @ ")
+		}
+		name, ver = strings.TrimSpace(name), strings.TrimSpace(ver)
+		key := pyname.Normalize(name)
+		c, seen := byName[key]
+		if !seen {
+			c = &PlannedChange{Name: name}
+			byName[key] = c
+			order = append(order, key)
+		}
+		if op == "-" {
+			c.Before = ver
+		} else {
+			c.After = ver
+		}
+	}
+	changes := make([]PlannedChange, 0, len(order))
+	for _, key := range order {
+		changes = append(changes, *byName[key])
+	}
+	return changes
+}
+
+// newPreview sorts changes by name into a Preview.
+func newPreview(changes []PlannedChange) Preview {
+	sort.Slice(changes, func(i, j int) bool {
+		return pyname.Normalize(changes[i].Name) < pyname.Normalize(changes[j].Name)
+	})
+	return Preview{Changes: changes}
+}
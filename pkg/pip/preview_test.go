@@ -0,0 +1,136 @@
+package pip
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/eslam/depman/pkg/env"
+)
+
+func TestPlannedChange_Kind(t *testing.T) {
+	tests := []struct {
+		before, after string
+		expected      ChangeKind
+	}{
+		{"", "1.0", ChangeInstall},
+		{"1.0", "", ChangeRemove},
+		{"1.0", "1.1", ChangeUpgrade},
+		{"2.0", "2.0rc1", ChangeDowngrade},
+		{"1.0", "1.0.0", ChangeReinstall},
+	}
+	for _, tt := range tests {
+		c := PlannedChange{Name: "x", Before: tt.before, After: tt.after}
+		if got := c.Kind(); got != tt.expected {
+			t.Errorf("Kind(%q → %q) = %v, want %v", tt.before, tt.after, got, tt.expected)
+		}
+	}
+}
+
+func TestParseInstallReport(t *testing.T) {
+	report := `{"version": "1", "install": [
+		{"requested": true, "metadata": {"name": "httpx", "version": "0.27.0"}},
+		{"requested": false, "metadata": {"name": "idna", "version": "3.6"}},
+		{"requested": false, "metadata": {"name": "anyio", "version": "4.3.0"}}
+	]}`
+	got, err := parseInstallReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []PlannedChange{
+		{Name: "httpx", After: "0.27.0", Requested: true},
+		{Name: "idna", After: "3.6"},
+		{Name: "anyio", After: "4.3.0"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseInstallReport() = %+v, want %+v", got, expected)
+	}
+
+	if _, err := parseInstallReport("not json"); err == nil {
+		t.Error("parseInstallReport() of invalid JSON: expected an error")
+	}
+}
+
+func TestParseUVDryRun(t *testing.T) {
+	output := `Resolved 5 packages in 12ms
+Would download 2 packages
+Would uninstall 1 package
+Would install 3 packages
+ - idna==3.7
+ + idna==3.6
+ + httpx==0.27.0
+ + mylib @ file:///src/mylib
+`
+	expected := []PlannedChange{
+		{Name: "idna", Before: "3.7", After: "3.6"},
+		{Name: "httpx", After: "0.27.0"},
+		{Name: "mylib", After: "file:///src/mylib"},
+	}
+	if got := parseUVDryRun(output); !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseUVDryRun() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	p := newPreview([]PlannedChange{
+		{Name: "requests", Before: "2.31.0", After: "2.30.0", Requested: true},
+		{Name: "urllib3", Before: "2.2.0", After: "1.26.18"},
+		{Name: "Certifi", After: "2024.2.2"},
+	})
+	if names := []string{p.Changes[0].Name, p.Changes[1].Name, p.Changes[2].Name}; !reflect.DeepEqual(names, []string{"Certifi", "requests", "urllib3"}) {
+		t.Errorf("Changes not sorted by name: %v", names)
+	}
+	if got := p.Summary(); got != "1 to install, 2 to downgrade" {
+		t.Errorf("Summary() = %q", got)
+	}
+	if got := p.TransitiveDowngrades(); len(got) != 1 || got[0].Name != "urllib3" {
+		t.Errorf("TransitiveDowngrades() = %+v, want urllib3 only", got)
+	}
+	if got := (Preview{}).Summary(); got != "no changes" {
+		t.Errorf("empty Summary() = %q", got)
+	}
+}
+
+func TestRunner_Preview(t *testing.T) {
+	venv := t.TempDir()
+	site := filepath.Join(venv, "lib", "python3.12", "site-packages")
+	for _, name := range []string{"requests-2.30.0.dist-info", "idna-3.7.dist-info"} {
+		if err := os.MkdirAll(filepath.Join(site, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A fake pip that prints an installation report for any dry run
+	bin := filepath.Join(t.TempDir(), "pip")
+	script := `#!/bin/sh
+echo '{"install": [{"metadata": {"name": "requests", "version": "2.31.0"}}, {"metadata": {"name": "idna", "version": "3.6"}}]}'
+`
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runner := NewRunner(env.PackageManager{Type: env.ManagerPip, BinPath: bin}, env.Virtualenv{Type: env.EnvVirtualenv, Path: venv})
+
+	p, err := runner.PreviewUpgrade("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []PlannedChange{
+		{Name: "idna", Before: "3.7", After: "3.6"},
+		{Name: "requests", Before: "2.30.0", After: "2.31.0", Requested: true},
+	}
+	if !reflect.DeepEqual(p.Changes, expected) {
+		t.Errorf("PreviewUpgrade() = %+v, want %+v", p.Changes, expected)
+	}
+
+	p, err = runner.PreviewUninstall("Requests", "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Changes) != 1 || p.Changes[0].Kind() != ChangeRemove || p.Changes[0].Before != "2.30.0" {
+		t.Errorf("PreviewUninstall() = %+v, want requests 2.30.0 removed", p.Changes)
+	}
+
+	if _, err := runner.PreviewInstall("bad;name"); err == nil {
+		t.Error("PreviewInstall() of an invalid spec: expected an error")
+	}
+}
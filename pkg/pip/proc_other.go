@@ -0,0 +1,9 @@
+//go:build !unix
+
+package pip
+
+import "os/exec"
+
+// killProcessGroup is a no-op where process groups are not available:
+// cancelling cmd kills the process itself.
+func killProcessGroup(cmd *exec.Cmd) {}
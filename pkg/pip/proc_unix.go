@@ -0,0 +1,18 @@
+//go:build unix
+
+package pip
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup starts cmd in its own process group and makes
+// cancelling it kill the whole group, so the build backends and compilers
+// pip spawns die with it.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
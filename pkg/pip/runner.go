@@ -2,27 +2,80 @@ package pip
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/eslam/depman/pkg/audit"
 	"github.com/eslam/depman/pkg/env"
 	"github.com/eslam/depman/pkg/log"
+	"github.com/eslam/depman/pkg/queue"
 )
 
-// Runner executes pip/uv commands scoped to a specific environment.
+// Runner executes package operations scoped to a specific environment,
+// through its Backend.
 type Runner struct {
-	Manager env.PackageManager
+	Backend Backend
 	Venv    env.Virtualenv
+	Journal *audit.Journal    // if set, records every install, uninstall and upgrade
+	Timeout time.Duration     // if set, kills a command that runs longer
+	Output  chan<- OutputLine // if set, receives each line a command prints, as it prints it
+
+	ctx     context.Context
+	running *running
 }
 
 // NewRunner creates a runner for the given manager and virtualenv.
 func NewRunner(mgr env.PackageManager, venv env.Virtualenv) *Runner {
-	return &Runner{Manager: mgr, Venv: venv}
+	return NewBackendRunner(NewBackend(mgr), venv)
+}
+
+// NewBackendRunner creates a runner that performs its operations with
+// backend, e.g. a FakeBackend in tests.
+func NewBackendRunner(backend Backend, venv env.Virtualenv) *Runner {
+	return &Runner{Backend: backend, Venv: venv, running: &running{}}
+}
+
+// WithContext returns a copy of the runner whose commands are killed when
+// ctx is done. Cancel still reaches them.
+func (r *Runner) WithContext(ctx context.Context) *Runner {
+	c := *r
+	c.ctx = ctx
+	return &c
+}
+
+// Cancel kills every command running on this runner or a copy of it made
+// by WithContext. Their results report ErrCancelled.
+func (r *Runner) Cancel() {
+	if r.running != nil {
+		r.running.cancelAll()
+	}
+}
+
+// Queue returns the job queue of the runner's environment. Operations
+// that change the environment should run on it, so they never overlap.
+func (r *Runner) Queue() *queue.Queue {
+	return queue.For(r.Venv.Path)
 }
 
+// ErrTimeout and ErrCancelled are wrapped by the error of a command that
+// was killed because it ran past Runner.Timeout or was cancelled.
+var (
+	ErrTimeout   = errors.New("timed out")
+	ErrCancelled = errors.New("cancelled")
+)
+
+// waitDelay bounds how long a killed command may keep its output pipes
+// open, e.g. through a build backend it spawned.
+const waitDelay = 5 * time.Second
+
 // RunResult holds the result of a pip/uv command.
 type RunResult struct {
 	Stdout string
@@ -30,17 +83,71 @@ type RunResult struct {
 	Err    error
 }
 
-// Run executes a pip/uv command with the resolved environment.
+// Detail returns the last line of the command's stderr, which usually says
+// why it failed, or "" if it wrote nothing there.
+func (r RunResult) Detail() string {
+	lines := strings.Split(strings.TrimSpace(r.Stderr), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// Run executes a pip/uv command with the resolved environment, streaming
+// its output to r.Output.
 func (r *Runner) Run(bin string, args ...string) RunResult {
+	return r.run(true, bin, args...)
+}
+
+// Capture executes a command with the resolved environment, only
+// collecting its output. It suits machine-readable output such as
+// `pip list`.
+func (r *Runner) Capture(bin string, args ...string) RunResult {
+	return r.run(false, bin, args...)
+}
+
+// run executes a command. Unless stream is set, its output is only
+// captured, which suits machine-readable output such as `pip list`.
+func (r *Runner) run(stream bool, bin string, args ...string) RunResult {
 	log.Info("executing package manager command", "bin", bin, "args", strings.Join(args, " "))
-	cmd := exec.Command(bin, args...)
+	parent := r.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	if r.running != nil {
+		defer r.running.add(cancel)()
+	}
+	runCtx := ctx
+	if r.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		runCtx, cancelTimeout = context.WithTimeout(ctx, r.Timeout)
+		defer cancelTimeout()
+	}
+
+	cmd := exec.CommandContext(runCtx, bin, args...)
 	cmd.Env = r.buildEnv()
+	cmd.WaitDelay = waitDelay
+	killProcessGroup(cmd)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	if stream && r.Output != nil {
+		outLines := &lineWriter{ctx: runCtx, out: r.Output, stream: "stdout"}
+		errLines := &lineWriter{ctx: runCtx, out: r.Output, stream: "stderr"}
+		cmd.Stdout = io.MultiWriter(&stdout, outLines)
+		cmd.Stderr = io.MultiWriter(&stderr, errLines)
+		defer outLines.flush()
+		defer errLines.flush()
+	}
 
 	err := cmd.Run()
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		err = fmt.Errorf("%w: %w", ErrCancelled, err)
+	case runCtx.Err() != nil:
+		err = fmt.Errorf("%w after %s: %w", ErrTimeout, r.Timeout, err)
+	}
 	if err != nil {
 		log.Warn("package manager command failed", "bin", bin, "error", err, "stderr", stderr.String())
 	} else {
@@ -53,6 +160,39 @@ func (r *Runner) Run(bin string, args ...string) RunResult {
 	}
 }
 
+// running tracks the commands in flight so Cancel can reach them.
+type running struct {
+	mu      sync.Mutex
+	next    int
+	cancels map[int]context.CancelFunc
+}
+
+// add registers the cancel func of a command and returns its
+// deregistration.
+func (rs *running) add(cancel context.CancelFunc) func() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.cancels == nil {
+		rs.cancels = make(map[int]context.CancelFunc)
+	}
+	id := rs.next
+	rs.next++
+	rs.cancels[id] = cancel
+	return func() {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		delete(rs.cancels, id)
+	}
+}
+
+func (rs *running) cancelAll() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for _, cancel := range rs.cancels {
+		cancel()
+	}
+}
+
 // Install installs a package.
 func (r *Runner) Install(pkg string) RunResult {
 	// Validate package name before execution
@@ -61,8 +201,9 @@ func (r *Runner) Install(pkg string) RunResult {
 		return RunResult{Err: fmt.Errorf("invalid package: %w", err)}
 	}
 
-	bin, args := r.Manager.InstallCmd(pkg)
-	return r.Run(bin, args...)
+	return r.mutate(audit.ActionInstall, pkg, func(x Executor) RunResult {
+		return r.Backend.Install(x, pkg)
+	})
 }
 
 // Uninstall removes a package.
@@ -73,8 +214,9 @@ func (r *Runner) Uninstall(pkg string) RunResult {
 		return RunResult{Err: fmt.Errorf("invalid package: %w", err)}
 	}
 
-	bin, args := r.Manager.UninstallCmd(pkg)
-	return r.Run(bin, args...)
+	return r.mutate(audit.ActionUninstall, pkg, func(x Executor) RunResult {
+		return r.Backend.Uninstall(x, pkg)
+	})
 }
 
 // Upgrade upgrades a package to its latest version.
@@ -85,34 +227,160 @@ func (r *Runner) Upgrade(pkg string) RunResult {
 		return RunResult{Err: fmt.Errorf("invalid package: %w", err)}
 	}
 
-	bin, args := r.Manager.UpgradeCmd(pkg)
-	return r.Run(bin, args...)
+	return r.mutate(audit.ActionUpgrade, pkg, func(x Executor) RunResult {
+		return r.Backend.Upgrade(x, pkg)
+	})
+}
+
+// Add installs a package and declares it in group, e.g. "group:dev". It
+// only differs from Install for a ProjectBackend, which writes the
+// declaration itself, elsewhere the dependency file sync does, and for a
+// GroupInstaller.
+func (r *Runner) Add(pkg, group string) RunResult {
+	var add func(x Executor) RunResult
+	switch b := r.Backend.(type) {
+	case ProjectBackend:
+		add = func(x Executor) RunResult { return b.Add(x, pkg, group) }
+	case GroupInstaller:
+		add = func(x Executor) RunResult { return b.InstallGroup(x, pkg, group) }
+	default:
+		return r.Install(pkg)
+	}
+	if err := ValidatePackageSpec(pkg); err != nil {
+		log.Warn("package validation failed", "package", pkg, "error", err)
+		return RunResult{Err: fmt.Errorf("invalid package: %w", err)}
+	}
+	return r.mutate(audit.ActionInstall, pkg, add)
+}
+
+// Remove uninstalls a package and drops it from group, like Add.
+func (r *Runner) Remove(pkg, group string) RunResult {
+	project, ok := r.Backend.(ProjectBackend)
+	if !ok {
+		return r.Uninstall(pkg)
+	}
+	if err := ValidatePackageName(pkg); err != nil {
+		log.Warn("package validation failed", "package", pkg, "error", err)
+		return RunResult{Err: fmt.Errorf("invalid package: %w", err)}
+	}
+	return r.mutate(audit.ActionUninstall, pkg, func(x Executor) RunResult {
+		return project.Remove(x, pkg, group)
+	})
+}
+
+// ManagesProject reports whether the backend owns the project's dependency
+// file, so it must not be rewritten after Add and Remove.
+func (r *Runner) ManagesProject() bool {
+	_, ok := r.Backend.(ProjectBackend)
+	return ok
+}
+
+// Sync makes the environment match the pinned requirements in path.
+func (r *Runner) Sync(path string) RunResult {
+	return r.mutate(audit.ActionSync, path, func(x Executor) RunResult {
+		return r.Backend.Sync(x, path)
+	})
+}
+
+// Lock resolves the requirements in path and returns them pinned, in
+// requirements format, without changing the environment.
+func (r *Runner) Lock(path string) RunResult {
+	return r.Backend.Lock(r, path)
+}
+
+// mutate runs an operation that changes the environment and records it in
+// the journal, with the command it ran and the versions it changed.
+func (r *Runner) mutate(action audit.Action, target string, op func(Executor) RunResult) RunResult {
+	if r.Journal == nil {
+		return op(r)
+	}
+
+	before := InstalledVersions(r.Venv)
+	start := time.Now()
+	rec := &recorder{Runner: r}
+	result := op(rec)
+	manager := r.Backend.Name()
+	if len(rec.argv) > 0 {
+		manager = filepath.Base(rec.argv[0])
+	}
+	entry := audit.Record{
+		Time:       start,
+		Action:     action,
+		Target:     target,
+		Venv:       r.Venv.Path,
+		Manager:    manager,
+		Argv:       rec.argv,
+		ExitCode:   ExitCode(result.Err),
+		DurationMS: time.Since(start).Milliseconds(),
+		Changes:    audit.Diff(before, InstalledVersions(r.Venv)),
+	}
+	if result.Err != nil {
+		entry.Error = result.Err.Error()
+	}
+	if err := r.Journal.Append(entry); err != nil {
+		log.Warn("audit journal append failed", "action", action, "target", target, "error", err)
+	}
+	return result
+}
+
+// recorder is the Executor of a journaled operation. It remembers the
+// last command the backend ran, for the record.
+type recorder struct {
+	*Runner
+	argv []string
+}
+
+func (rc *recorder) Run(bin string, args ...string) RunResult {
+	rc.argv = append([]string{bin}, args...)
+	return rc.Runner.Run(bin, args...)
+}
+
+func (rc *recorder) Capture(bin string, args ...string) RunResult {
+	rc.argv = append([]string{bin}, args...)
+	return rc.Runner.Capture(bin, args...)
+}
+
+// ExitCode returns the exit status of a command that failed with err: 0
+// for nil, the process's status if it ran, and -1 if it could not start.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
 }
 
 // List returns the raw JSON output of installed packages.
 func (r *Runner) List() RunResult {
-	bin, args := r.Manager.ListCmd()
-	return r.Run(bin, args...)
+	return r.Backend.List(r)
 }
 
 // Outdated returns the raw JSON output of outdated packages.
 func (r *Runner) Outdated() RunResult {
-	bin, args := r.Manager.OutdatedCmd()
-	return r.Run(bin, args...)
+	return r.Backend.Outdated(r)
 }
 
 // buildEnv constructs the environment variables for subprocess calls.
-// It sets VIRTUAL_ENV and prepends the venv bin directory to PATH.
+// It sets VIRTUAL_ENV, or CONDA_PREFIX for a conda environment, and
+// prepends the environment's bin directory to PATH.
 func (r *Runner) buildEnv() []string {
 	environ := os.Environ()
+	if r.Venv.Path == "" {
+		return environ
+	}
 
-	if r.Venv.Type == env.EnvVirtualenv && r.Venv.Path != "" {
-		venvBin := filepath.Join(r.Venv.Path, "bin")
+	switch r.Venv.Type {
+	case env.EnvVirtualenv:
 		environ = setEnv(environ, "VIRTUAL_ENV", r.Venv.Path)
-		environ = prependPath(environ, venvBin)
+	case env.EnvConda:
+		environ = setEnv(environ, "CONDA_PREFIX", r.Venv.Path)
+	default:
+		return environ
 	}
-
-	return environ
+	return prependPath(environ, filepath.Join(r.Venv.Path, "bin"))
 }
 
 func setEnv(environ []string, key, value string) []string {
@@ -0,0 +1,102 @@
+package pip
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eslam/depman/pkg/env"
+)
+
+// scriptRunner returns a runner whose package manager is a shell script.
+func scriptRunner(t *testing.T, script string) *Runner {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "pip")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return NewRunner(env.PackageManager{Type: env.ManagerPip, BinPath: bin}, env.Virtualenv{})
+}
+
+func TestRunner_Output(t *testing.T) {
+	r := scriptRunner(t, `printf 'Collecting requests\n\nDownloading 10%%\rDownloading 100%%\n'; echo 'WARNING: old pip' >&2; printf 'done'`)
+	out := make(chan OutputLine, 10)
+	r.Output = out
+
+	result := r.Install("requests")
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	close(out)
+	var stdout, stderr []string
+	for l := range out {
+		if l.Stream == "stderr" {
+			stderr = append(stderr, l.Text)
+		} else {
+			stdout = append(stdout, l.Text)
+		}
+	}
+	if expected := []string{"Collecting requests", "Downloading 10%", "Downloading 100%", "done"}; !reflect.DeepEqual(stdout, expected) {
+		t.Errorf("stdout lines = %q, want %q", stdout, expected)
+	}
+	if expected := []string{"WARNING: old pip"}; !reflect.DeepEqual(stderr, expected) {
+		t.Errorf("stderr lines = %q, want %q", stderr, expected)
+	}
+	if result.Stderr != "WARNING: old pip\n" {
+		t.Errorf("Stderr = %q, still expected in the result", result.Stderr)
+	}
+
+	// Machine-readable output is not streamed
+	r = scriptRunner(t, `echo '[]'`)
+	out = make(chan OutputLine, 10)
+	r.Output = out
+	if result := r.List(); result.Err != nil || result.Stdout != "[]\n" {
+		t.Fatalf("List() = %+v", result)
+	}
+	if len(out) != 0 {
+		t.Errorf("List() streamed %d lines, want none", len(out))
+	}
+}
+
+func TestRunner_Timeout(t *testing.T) {
+	// The background sleep holds the output pipe open: unless its process
+	// group is killed too, Run waits for waitDelay
+	r := scriptRunner(t, "sleep 30 &\nsleep 30\n")
+	r.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	result := r.Install("requests")
+	if !errors.Is(result.Err, ErrTimeout) {
+		t.Errorf("Install() error = %v, want ErrTimeout", result.Err)
+	}
+	if elapsed := time.Since(start); elapsed > waitDelay/2 {
+		t.Errorf("Install() took %v after the timeout", elapsed)
+	}
+}
+
+func TestRunner_Cancel(t *testing.T) {
+	r := scriptRunner(t, "sleep 30\n")
+
+	done := make(chan RunResult)
+	go func() { done <- r.Install("requests") }()
+	time.Sleep(100 * time.Millisecond)
+	r.Cancel()
+	select {
+	case result := <-done:
+		if !errors.Is(result.Err, ErrCancelled) {
+			t.Errorf("Install() error = %v, want ErrCancelled", result.Err)
+		}
+	case <-time.After(waitDelay / 2):
+		t.Fatal("Cancel() did not stop the command")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if result := r.WithContext(ctx).Install("requests"); !errors.Is(result.Err, ErrCancelled) {
+		t.Errorf("Install() with a cancelled context: error = %v, want ErrCancelled", result.Err)
+	}
+}
@@ -0,0 +1,156 @@
+package pip
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eslam/depman/pkg/env"
+	"github.com/eslam/depman/pkg/pyname"
+)
+
+// InstalledVersions returns the distributions installed in a virtualenv as
+// normalized name → version, read from the *.dist-info and *.egg-info
+// metadata directories in its site-packages. It is much cheaper than
+// running `pip list`, and is used to record what an operation changed. It
+// returns nil for environments other than a virtualenv or conda
+// environment.
+func InstalledVersions(venv env.Virtualenv) map[string]string {
+	dirs := sitePackages(venv)
+	if dirs == nil {
+		return nil
+	}
+	versions := make(map[string]string)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if name, version, ok := parseMetadataDir(e.Name()); ok {
+				versions[name] = version
+			}
+		}
+	}
+	return versions
+}
+
+// InstalledRequirements returns the requirements each distribution installed
+// in a virtualenv declares, as normalized name → PEP 508 strings, read from
+// the Requires-Dist fields of *.dist-info/METADATA and from
+// *.egg-info/requires.txt. Requirements of an extra carry an
+// `extra == "name"` marker. Like InstalledVersions, it returns nil for
+// environments other than a virtualenv or conda environment.
+func InstalledRequirements(venv env.Virtualenv) map[string][]string {
+	dirs := sitePackages(venv)
+	if dirs == nil {
+		return nil
+	}
+	requires := make(map[string][]string)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, _, ok := parseMetadataDir(e.Name())
+			if !ok {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if strings.HasSuffix(e.Name(), ".dist-info") {
+				requires[name] = readRequiresDist(filepath.Join(path, "METADATA"))
+			} else {
+				requires[name] = readEggRequires(filepath.Join(path, "requires.txt"))
+			}
+		}
+	}
+	return requires
+}
+
+// sitePackages returns the site-packages directories of a virtualenv or
+// conda environment, or nil for other environments.
+func sitePackages(venv env.Virtualenv) []string {
+	if venv.Type != env.EnvVirtualenv && venv.Type != env.EnvConda || venv.Path == "" {
+		return nil
+	}
+	dirs := []string{}
+	for _, pattern := range []string{"lib/python*/site-packages", "lib64/python*/site-packages", "Lib/site-packages"} {
+		matches, _ := filepath.Glob(filepath.Join(venv.Path, filepath.FromSlash(pattern)))
+		dirs = append(dirs, matches...)
+	}
+	return dirs
+}
+
+// readRequiresDist returns the Requires-Dist values of a core metadata
+// file. The headers end at the first blank line, where the description
+// starts.
+func readRequiresDist(path string) []string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var reqs []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "Requires-Dist:"); ok {
+			reqs = append(reqs, strings.TrimSpace(value))
+		}
+	}
+	return reqs
+}
+
+// readEggRequires returns the requirements of an egg-info requires.txt,
+// whose "[extra]", "[:marker]" and "[extra:marker]" sections become
+// markers.
+func readEggRequires(path string) []string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var reqs []string
+	marker := ""
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			extra, cond, _ := strings.Cut(line[1:len(line)-1], ":")
+			var parts []string
+			if cond != "" {
+				parts = append(parts, "("+cond+")")
+			}
+			if extra != "" {
+				parts = append(parts, `extra == "`+extra+`"`)
+			}
+			marker = strings.Join(parts, " and ")
+		case marker != "":
+			reqs = append(reqs, line+"; "+marker)
+		default:
+			reqs = append(reqs, line)
+		}
+	}
+	return reqs
+}
+
+// parseMetadataDir splits a metadata directory name such as
+// "typing_extensions-4.9.0.dist-info" or "six-1.16.0-py3.11.egg-info" into
+// a normalized name and version.
+func parseMetadataDir(base string) (name, version string, ok bool) {
+	stem, found := strings.CutSuffix(base, ".dist-info")
+	if !found {
+		if stem, found = strings.CutSuffix(base, ".egg-info"); !found {
+			return "", "", false
+		}
+	}
+	parts := strings.Split(stem, "-")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	// Wheel metadata escapes each run of -_. in the name as a single _
+	name = pyname.Normalize(parts[0])
+	return name, parts[1], true
+}
@@ -0,0 +1,83 @@
+package pip
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eslam/depman/pkg/env"
+)
+
+func TestInstalledVersions(t *testing.T) {
+	venv := t.TempDir()
+	site := filepath.Join(venv, "lib", "python3.12", "site-packages")
+	for _, name := range []string{
+		"requests-2.31.0.dist-info",
+		"typing_extensions-4.9.0.dist-info",
+		"Zope.Interface-6.1.dist-info",
+		"six-1.16.0-py3.12.egg-info",
+		"requests",
+		"__pycache__",
+	} {
+		if err := os.MkdirAll(filepath.Join(site, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := InstalledVersions(env.Virtualenv{Type: env.EnvVirtualenv, Path: venv})
+	expected := map[string]string{
+		"requests":          "2.31.0",
+		"typing-extensions": "4.9.0",
+		"zope-interface":    "6.1",
+		"six":               "1.16.0",
+	}
+	if len(got) != len(expected) {
+		t.Errorf("InstalledVersions() = %v, want %v", got, expected)
+	}
+	for name, version := range expected {
+		if got[name] != version {
+			t.Errorf("InstalledVersions()[%q] = %q, want %q", name, got[name], version)
+		}
+	}
+
+	if got := InstalledVersions(env.Virtualenv{Type: env.EnvSystem, Path: venv}); got != nil {
+		t.Errorf("InstalledVersions() of the system environment = %v, want nil", got)
+	}
+}
+
+func TestInstalledRequirements(t *testing.T) {
+	venv := t.TempDir()
+	site := filepath.Join(venv, "lib", "python3.12", "site-packages")
+	files := map[string]string{
+		"requests-2.31.0.dist-info/METADATA": "Metadata-Version: 2.1\nName: requests\nVersion: 2.31.0\n" +
+			"Requires-Dist: charset-normalizer (<4,>=2)\nRequires-Dist: idna<4,>=2.5\n" +
+			"Requires-Dist: PySocks!=1.5.7,>=1.5.6; extra == \"socks\"\n\nRequires-Dist: in the description\n",
+		"six-1.16.0-py3.12.egg-info/requires.txt": "attrs\n\n[test]\npytest\n\n[:python_version < \"3.8\"]\nimportlib-metadata\n",
+		"idna-3.6.dist-info/METADATA":             "Metadata-Version: 2.1\r\nName: idna\r\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(site, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := InstalledRequirements(env.Virtualenv{Type: env.EnvVirtualenv, Path: venv})
+	expected := map[string][]string{
+		"requests": {"charset-normalizer (<4,>=2)", "idna<4,>=2.5", `PySocks!=1.5.7,>=1.5.6; extra == "socks"`},
+		"six":      {"attrs", `pytest; extra == "test"`, `importlib-metadata; (python_version < "3.8")`},
+		"idna":     nil,
+	}
+	if len(got) != len(expected) {
+		t.Errorf("InstalledRequirements() = %q, want %q", got, expected)
+	}
+	for name, reqs := range expected {
+		if strings.Join(got[name], "|") != strings.Join(reqs, "|") {
+			t.Errorf("InstalledRequirements()[%q] = %q, want %q", name, got[name], reqs)
+		}
+	}
+}
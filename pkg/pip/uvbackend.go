@@ -0,0 +1,59 @@
+package pip
+
+// UVBackend drives uv's pip interface, `uv pip`.
+type UVBackend struct {
+	Bin string // path to uv
+}
+
+// Name returns "uv".
+func (b UVBackend) Name() string { return "uv" }
+
+// Install installs a package.
+func (b UVBackend) Install(x Executor, spec string) RunResult {
+	return x.Run(b.Bin, "pip", "install", spec)
+}
+
+// Uninstall removes a package.
+func (b UVBackend) Uninstall(x Executor, name string) RunResult {
+	return x.Run(b.Bin, "pip", "uninstall", name)
+}
+
+// Upgrade upgrades a package to its latest version.
+func (b UVBackend) Upgrade(x Executor, name string) RunResult {
+	return x.Run(b.Bin, "pip", "install", "--upgrade", name)
+}
+
+// List prints the installed packages as JSON.
+func (b UVBackend) List(x Executor) RunResult {
+	return x.Capture(b.Bin, "pip", "list", "--format", "json")
+}
+
+// Outdated prints the packages with a newer release as JSON.
+func (b UVBackend) Outdated(x Executor) RunResult {
+	return x.Capture(b.Bin, "pip", "list", "--outdated", "--format", "json")
+}
+
+// Lock pins the requirements in input with `uv pip compile`.
+func (b UVBackend) Lock(x Executor, input string) RunResult {
+	return x.Capture(b.Bin, "pip", "compile", "--quiet", input)
+}
+
+// Sync makes the environment match path with `uv pip sync`.
+func (b UVBackend) Sync(x Executor, path string) RunResult {
+	return x.Run(b.Bin, "pip", "sync", path)
+}
+
+// DryRun reads the changes from the output of `uv pip install --dry-run`,
+// which lists both the removed and the added version of a replaced
+// distribution.
+func (b UVBackend) DryRun(x Executor, specs []string, upgrade bool) ([]PlannedChange, error) {
+	args := []string{"pip", "install", "--dry-run"}
+	if upgrade {
+		args = append(args, "--upgrade")
+	}
+	result := x.Capture(b.Bin, append(args, specs...)...)
+	if result.Err != nil {
+		return nil, commandError("dry run", result)
+	}
+	return parseUVDryRun(result.Stderr), nil
+}
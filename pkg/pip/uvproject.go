@@ -0,0 +1,65 @@
+package pip
+
+import "strings"
+
+// UVProjectBackend drives uv's project interface for a project with a
+// uv.lock: uv add, uv remove, uv lock and uv sync. uv edits pyproject.toml
+// and uv.lock itself. Operations on single distributions that are not
+// declared, such as undo putting back a version, still go through
+// `uv pip`.
+type UVProjectBackend struct {
+	UVBackend
+	Dir string // project directory, holding pyproject.toml and uv.lock
+}
+
+// Add declares spec in group and installs it with `uv add`.
+func (b UVProjectBackend) Add(x Executor, spec, group string) RunResult {
+	return x.Run(b.Bin, b.project("add", groupArgs(group), spec)...)
+}
+
+// Remove drops name from group and uninstalls it with `uv remove`.
+func (b UVProjectBackend) Remove(x Executor, name, group string) RunResult {
+	return x.Run(b.Bin, b.project("remove", groupArgs(group), name)...)
+}
+
+// Upgrade moves name to its latest version in uv.lock and syncs the
+// environment to the lock.
+func (b UVProjectBackend) Upgrade(x Executor, name string) RunResult {
+	if result := x.Run(b.Bin, b.project("lock", []string{"--upgrade-package", name})...); result.Err != nil {
+		return result
+	}
+	return b.Sync(x, "")
+}
+
+// Lock updates uv.lock from pyproject.toml. input is ignored: uv always
+// locks the whole project.
+func (b UVProjectBackend) Lock(x Executor, input string) RunResult {
+	return x.Run(b.Bin, b.project("lock", nil)...)
+}
+
+// Sync makes the environment match uv.lock. path is ignored: uv always
+// syncs from the project's lock file.
+func (b UVProjectBackend) Sync(x Executor, path string) RunResult {
+	return x.Run(b.Bin, b.project("sync", nil)...)
+}
+
+// project returns the arguments of a uv project command run on b.Dir.
+func (b UVProjectBackend) project(command string, flags []string, args ...string) []string {
+	out := []string{command, "--project", b.Dir}
+	out = append(out, flags...)
+	return append(out, args...)
+}
+
+// groupArgs returns the flags that select group, written as by
+// parser.Group, for `uv add` and `uv remove`.
+func groupArgs(group string) []string {
+	kind, name, _ := strings.Cut(group, ":")
+	switch kind {
+	case "optional":
+		return []string{"--optional", name}
+	case "group":
+		return []string{"--group", name}
+	default:
+		return nil
+	}
+}
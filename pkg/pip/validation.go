@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+
+	"github.com/eslam/depman/pkg/version"
 )
 
 // Valid package name pattern: starts with alphanumeric, followed by alphanumerics, dots, hyphens, or underscores
@@ -69,71 +71,70 @@ func SanitizePackageName(name string) string {
 	return sanitized
 }
 
-// ValidateVersionSpec validates a version specifier (e.g., ">=1.0.0", "==2.0.0").
-// Returns an error if the specifier is invalid.
+// ValidateVersionSpec validates a PEP 440 specifier list (e.g., ">=1.0.0",
+// "==2.0.*" or ">=1.0,<2"). Returns an error if the specifier is invalid.
 func ValidateVersionSpec(spec string) error {
 	if spec == "" {
 		return nil // Empty is valid (means any version)
 	}
 
-	// Valid operators: ==, !=, <=, <, >=, >, ~=
-	validOperators := []string{"==", "!=", "<=", "<", ">=", ">", "~="}
-
-	hasValidOperator := false
-	for _, op := range validOperators {
-		if strings.HasPrefix(spec, op) {
-			hasValidOperator = true
-			break
-		}
-	}
-
-	if !hasValidOperator {
+	if _, err := version.ParseSpecifierSet(spec); err != nil {
 		return fmt.Errorf("invalid version specifier: %s", spec)
 	}
 
 	return nil
 }
 
-// ValidatePackageSpec validates a complete package specification (e.g., "package>=1.0.0").
+// ValidatePackageSpec validates a complete package specification
+// (e.g., "package>=1.0.0", "package[extra]~=2.1" or "package @ url").
 // Returns an error if the specification is invalid.
 func ValidatePackageSpec(spec string) error {
 	if spec == "" {
 		return fmt.Errorf("package spec cannot be empty")
 	}
 
-	// Split on common separators to get package name
-	// Handle: package>=1.0.0, package==1.0.0, package~=1.0.0, etc.
-	parts := splitPackageSpec(spec)
-	if len(parts) == 0 {
-		return fmt.Errorf("invalid package spec: %s", spec)
+	name, extras, rest := splitPackageSpec(strings.TrimSpace(spec))
+	if err := ValidatePackageName(name); err != nil {
+		return fmt.Errorf("invalid package name in spec: %w", err)
 	}
 
-	packageName := parts[0]
-	if err := ValidatePackageName(packageName); err != nil {
-		return fmt.Errorf("invalid package name in spec: %w", err)
+	for _, extra := range extras {
+		if err := ValidatePackageName(extra); err != nil {
+			return fmt.Errorf("invalid extra in spec: %w", err)
+		}
 	}
 
-	// Validate version spec if present
-	if len(parts) > 1 {
-		if err := ValidateVersionSpec(parts[1]); err != nil {
-			return err
+	// Direct references are passed to pip as-is
+	if strings.HasPrefix(rest, "@") {
+		if invalidCharsPattern.MatchString(rest) {
+			return fmt.Errorf("invalid package spec: %s", spec)
 		}
+		return nil
 	}
 
-	return nil
+	return ValidateVersionSpec(rest)
 }
 
-// splitPackageSpec splits a package spec into name and version parts.
-func splitPackageSpec(spec string) []string {
-	// Try common operators
-	operators := []string{"==", "!=", "<=", "<", ">=", ">", "~=", "@"}
+// splitPackageSpec splits a package spec into its name, extras and the
+// remaining version specifier or direct reference.
+func splitPackageSpec(spec string) (name string, extras []string, rest string) {
+	end := strings.IndexAny(spec, "[=!<>~@ ")
+	if end < 0 {
+		return spec, nil, ""
+	}
+	name, rest = spec[:end], strings.TrimSpace(spec[end:])
 
-	for _, op := range operators {
-		if idx := strings.Index(spec, op); idx > 0 {
-			return []string{spec[:idx], spec[idx:]}
+	if strings.HasPrefix(rest, "[") {
+		close := strings.Index(rest, "]")
+		if close < 0 {
+			// Keep the bracket so name validation reports the spec as invalid
+			return name + rest, nil, ""
+		}
+		for _, extra := range strings.Split(rest[1:close], ",") {
+			extras = append(extras, strings.TrimSpace(extra))
 		}
+		rest = strings.TrimSpace(rest[close+1:])
 	}
 
-	// No operator found, treat entire string as name
-	return []string{spec}
+	return name, extras, rest
 }
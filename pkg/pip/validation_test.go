@@ -0,0 +1,65 @@
+package pip
+
+import "testing"
+
+func TestValidateVersionSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"", false},
+		{">=1.0.0", false},
+		{"==2.0.*", false},
+		{"~=1.4.5", false},
+		{">=1.0,<2", false},
+		{"===1.0-custom", false},
+		{"!=1.0+local", false},
+		{"1.0.0", true},
+		{"=>1.0", true},
+		{">=1.0.*", true},
+		{"~=1", true},
+		{">=1.0,", true},
+		{">=abc", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			err := ValidateVersionSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateVersionSpec(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePackageSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"requests", false},
+		{"requests==2.31.0", false},
+		{"requests>=2,<3", false},
+		{"requests[socks,security]>=2.0", false},
+		{"Django~=4.2", false},
+		{"numpy==1.26.*", false},
+		{"mypkg @ https://example.com/mypkg-1.0.whl", false},
+		{"", true},
+		{"requests==", true},
+		{"requests=2.0", true},
+		{"requests[socks", true},
+		{"requests[-bad]", true},
+		{"requests;rm -rf /", true},
+		{"mypkg @ https://example.com/$(whoami)", true},
+		{"-rrequirements.txt", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			err := ValidatePackageSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePackageSpec(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+// Package pyname handles Python distribution names: PEP 503 normalization
+// and finding the name at the start of a requirement spec. It has no
+// dependencies so that every package can share one implementation.
+package pyname
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// specPattern matches a PEP 508 project name, which starts and ends with
+	// a letter or digit.
+	specPattern      = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?`)
+	separatorPattern = regexp.MustCompile(`[-_.]+`)
+)
+
+// Normalize returns the PEP 503 normalized form of a distribution name, so
+// that "Foo_Bar", "foo.bar" and "foo-bar" compare equal: lowercase, with
+// each run of "-", "_" and "." collapsed into a single "-".
+func Normalize(name string) string {
+	return separatorPattern.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), "-")
+}
+
+// FromSpec returns the project name at the start of a requirement spec such
+// as "requests[socks]>=2.31", or "" if the spec does not start with one.
+func FromSpec(spec string) string {
+	return specPattern.FindString(strings.TrimSpace(spec))
+}
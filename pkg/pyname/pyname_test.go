@@ -0,0 +1,43 @@
+package pyname
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"requests", "requests"},
+		{"Foo_Bar", "foo-bar"},
+		{"foo.bar", "foo-bar"},
+		{"foo__bar", "foo-bar"},
+		{"foo._bar", "foo-bar"},
+		{"Zope.Interface", "zope-interface"},
+		{"  padded  ", "padded"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.name); got != tt.expected {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestFromSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected string
+	}{
+		{"requests", "requests"},
+		{"requests[socks]>=2.31", "requests"},
+		{" typing_extensions==4.9.0", "typing_extensions"},
+		{"zope.interface; python_version > '3'", "zope.interface"},
+		{"pkg-==1", "pkg"},
+		{"-e .", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := FromSpec(tt.spec); got != tt.expected {
+			t.Errorf("FromSpec(%q) = %q, want %q", tt.spec, got, tt.expected)
+		}
+	}
+}
@@ -6,14 +6,13 @@ import (
 	"fmt"
 	"math"
 	"net/http"
-	"regexp"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/eslam/depman/pkg/log"
+	"github.com/eslam/depman/pkg/version"
 )
 
 const maxDisplayVersions = MaxDisplayVersions
@@ -256,6 +255,121 @@ func (c *Client) GetPackageDetailWithContext(ctx context.Context, name string) (
 	}, nil
 }
 
+// ReleaseFile is a wheel or sdist published for a release.
+type ReleaseFile struct {
+	Filename   string
+	URL        string
+	Size       int64
+	UploadTime time.Time
+	SHA256     string
+	Sdist      bool
+}
+
+// Release identifies one version of a package.
+type Release struct {
+	Name    string
+	Version string
+}
+
+// releaseInfo matches the PyPI JSON API response for a single release.
+type releaseInfo struct {
+	URLs []struct {
+		Filename    string    `json:"filename"`
+		URL         string    `json:"url"`
+		Size        int64     `json:"size"`
+		UploadTime  time.Time `json:"upload_time_iso_8601"`
+		PackageType string    `json:"packagetype"`
+		Digests     struct {
+			SHA256 string `json:"sha256"`
+		} `json:"digests"`
+	} `json:"urls"`
+}
+
+// GetReleaseFiles fetches the files of one release of a package.
+func (c *Client) GetReleaseFiles(name, version string) ([]ReleaseFile, error) {
+	return c.GetReleaseFilesWithContext(context.Background(), name, version)
+}
+
+// GetReleaseFilesWithContext fetches the files of one release with context
+// support. It returns nil when PyPI does not know the release.
+func (c *Client) GetReleaseFilesWithContext(ctx context.Context, name, version string) ([]ReleaseFile, error) {
+	url := fmt.Sprintf("%s/pypi/%s/%s/json", c.BaseURL, name, version)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("pypi: create request: %w", err)
+	}
+	resp, err := c.httpClient.DoWithRetry(ctx, req)
+	if err != nil {
+		log.Error("failed to fetch release from pypi", "package", name, "version", version, "error", err)
+		return nil, fmt.Errorf("pypi: fetch release: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != StatusOK {
+		return nil, fmt.Errorf("pypi: fetch release: status %d", resp.StatusCode)
+	}
+
+	var rel releaseInfo
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return nil, fmt.Errorf("pypi: parse response: %w", err)
+	}
+	files := make([]ReleaseFile, 0, len(rel.URLs))
+	for _, u := range rel.URLs {
+		files = append(files, ReleaseFile{
+			Filename:   u.Filename,
+			URL:        u.URL,
+			Size:       u.Size,
+			UploadTime: u.UploadTime,
+			SHA256:     u.Digests.SHA256,
+			Sdist:      u.PackageType == "sdist",
+		})
+	}
+	return files, nil
+}
+
+// FetchReleaseFiles fetches the files of every release, at most
+// MaxConcurrentRequests at a time. Releases PyPI does not know map to nil.
+// The first error stops the remaining requests.
+func (c *Client) FetchReleaseFiles(ctx context.Context, releases []Release) (map[Release][]ReleaseFile, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	files := make(map[Release][]ReleaseFile, len(releases))
+	slots := make(chan struct{}, MaxConcurrentRequests)
+	for _, r := range releases {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			got, err := c.GetReleaseFilesWithContext(ctx, r.Name, r.Version)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s %s: %w", r.Name, r.Version, err)
+					cancel()
+				}
+				return
+			}
+			files[r] = got
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return files, nil
+}
+
 // Search queries PyPI for packages matching the given query.
 func (c *Client) Search(query string) ([]SearchResult, error) {
 	return c.SearchWithContext(context.Background(), query)
@@ -339,59 +453,21 @@ func (c *Client) SearchWithContext(ctx context.Context, query string) ([]SearchR
 	return results, nil
 }
 
-// isStableVersion checks if a version string represents a stable release.
-// Uses regex to match pre-release markers at appropriate positions.
-var preReleasePattern = regexp.MustCompile(`(^|[.\-_\d])(a|alpha|b|beta|rc|dev|post)(\d|$|[.\-_])`)
-
+// isStableVersion reports whether v is a valid PEP 440 version that is
+// neither a pre-release nor a dev-release. Post-releases count as stable.
 func isStableVersion(v string) bool {
-	// First check for obvious pre-release patterns
-	lower := strings.ToLower(v)
-	for _, pre := range []string{"rc", "dev", "alpha", "beta", "post"} {
-		if strings.Contains(lower, pre) {
-			// Check if it's a proper pre-release marker (not just a word containing it)
-			if preReleasePattern.MatchString(lower) {
-				return false
-			}
-		}
-	}
-	// Check single letter pre-release markers with boundary
-	if strings.Contains(lower, "a") || strings.Contains(lower, "b") {
-		if preReleasePattern.MatchString(lower) {
-			return false
-		}
-	}
-	return true
+	parsed, err := version.Parse(v)
+	return err == nil && !parsed.IsPrerelease()
 }
 
-// sortVersionsDesc sorts semver-like strings in descending order.
+// sortVersionsDesc sorts version strings in descending PEP 440 order.
+// Unparseable versions sort last.
 func sortVersionsDesc(versions []string) {
-	sort.Slice(versions, func(i, j int) bool {
+	sort.SliceStable(versions, func(i, j int) bool {
 		return compareVersions(versions[i], versions[j]) > 0
 	})
 }
 
 func compareVersions(a, b string) int {
-	// Strip 'v' prefix if present
-	a = strings.TrimPrefix(a, "v")
-	b = strings.TrimPrefix(b, "v")
-
-	pa := strings.Split(a, ".")
-	pb := strings.Split(b, ".")
-	maxLen := len(pa)
-	if len(pb) > maxLen {
-		maxLen = len(pb)
-	}
-	for i := 0; i < maxLen; i++ {
-		var na, nb int
-		if i < len(pa) {
-			na, _ = strconv.Atoi(pa[i])
-		}
-		if i < len(pb) {
-			nb, _ = strconv.Atoi(pb[i])
-		}
-		if na != nb {
-			return na - nb
-		}
-	}
-	return 0
+	return version.CompareStrings(a, b)
 }
@@ -1,6 +1,7 @@
 package pypi
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -165,6 +166,60 @@ func TestClient_GetPackageDetail_Success(t *testing.T) {
 	}
 }
 
+func TestClient_FetchReleaseFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pypi/idna/3.6/json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{
+			"info": {"name": "idna", "version": "3.6"},
+			"urls": [
+				{
+					"filename": "idna-3.6-py3-none-any.whl",
+					"url": "https://files.example.com/idna-3.6-py3-none-any.whl",
+					"packagetype": "bdist_wheel",
+					"size": 61567,
+					"upload_time_iso_8601": "2023-11-25T15:40:52.604064Z",
+					"digests": {"md5": "x", "sha256": "c05567e9c24a6b9faaa835c4821bad0590fbb9d5779e7caa6e1cc4978e7eb24f"}
+				},
+				{
+					"filename": "idna-3.6.tar.gz",
+					"url": "https://files.example.com/idna-3.6.tar.gz",
+					"packagetype": "sdist",
+					"size": 175426,
+					"upload_time_iso_8601": "2023-11-25T15:40:54.902345Z",
+					"digests": {"sha256": "9ecdbbd083b06798ae1e86adcbfe8ab1479cf864e4ee30fe4e46a003d12491ca"}
+				}
+			]
+		}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	idna := Release{Name: "idna", Version: "3.6"}
+	missing := Release{Name: "private-lib", Version: "1.0"}
+	files, err := client.FetchReleaseFiles(context.Background(), []Release{idna, missing})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	got := files[idna]
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 files, got %+v", got)
+	}
+	if got[0].Sdist || got[0].Size != 61567 || got[0].SHA256 != "c05567e9c24a6b9faaa835c4821bad0590fbb9d5779e7caa6e1cc4978e7eb24f" {
+		t.Errorf("Unexpected wheel %+v", got[0])
+	}
+	if !got[1].Sdist || got[1].Filename != "idna-3.6.tar.gz" || got[1].UploadTime.Year() != 2023 {
+		t.Errorf("Unexpected sdist %+v", got[1])
+	}
+	if f, ok := files[missing]; !ok || f != nil {
+		t.Errorf("Expected unknown release to map to nil, got %+v", f)
+	}
+}
+
 func TestClient_Search_ExactMatch(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(r.URL.Path, "/pypi/flask/json") {
@@ -253,7 +308,12 @@ func TestIsStableVersion(t *testing.T) {
 		{"1.0.0beta1", false},
 		{"1.0.0rc1", false},
 		{"1.0.0dev", false},
-		{"1.0.0post1", false},
+		{"1.0.0post1", true},
+		{"1.0.0.post1.dev1", false},
+		{"1.0.0.dev3", false},
+		{"1.0.0c1", false},
+		{"1.0.0+local", true},
+		{"not-a-version", false},
 		{"3.0.0", true},
 		{"1.0", true},
 		{"10.2.5", true},
@@ -270,10 +330,10 @@ func TestIsStableVersion(t *testing.T) {
 }
 
 func TestSortVersionsDesc(t *testing.T) {
-	versions := []string{"1.0.0", "3.0.0", "2.0.0", "2.1.0", "1.5.0"}
+	versions := []string{"1.0.0", "3.0.0", "2.0.0", "2.1.0", "1.5.0", "1.10.0", "2.0.0rc1", "2.0.0.post1"}
 	sortVersionsDesc(versions)
 
-	expected := []string{"3.0.0", "2.1.0", "2.0.0", "1.5.0", "1.0.0"}
+	expected := []string{"3.0.0", "2.1.0", "2.0.0.post1", "2.0.0", "2.0.0rc1", "1.10.0", "1.5.0", "1.0.0"}
 	if len(versions) != len(expected) {
 		t.Fatalf("Expected %d versions, got %d", len(expected), len(versions))
 	}
@@ -297,6 +357,13 @@ func TestCompareVersions(t *testing.T) {
 		{"b greater", "1.0.0", "2.0.0", -1},
 		{"minor diff", "1.2.0", "1.1.0", 1},
 		{"patch diff", "1.0.5", "1.0.3", 1},
+		{"numeric not lexical", "1.10.0", "1.9.0", 1},
+		{"pre-release before final", "1.0.0rc1", "1.0.0", -1},
+		{"dev before alpha", "1.0.0.dev1", "1.0.0a1", -1},
+		{"post after final", "1.0.0.post1", "1.0.0", 1},
+		{"zero padding", "1.0", "1.0.0", 0},
+		{"epoch wins", "1!0.1", "9.0", 1},
+		{"invalid sorts first", "bogus", "0.0.1", -1},
 	}
 
 	for _, tt := range tests {
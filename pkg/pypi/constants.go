@@ -12,6 +12,10 @@ const (
 
 	// MaxLicenseLength is the maximum length of license text before truncation
 	MaxLicenseLength = 40
+
+	// MaxConcurrentRequests is the maximum number of requests FetchReleaseFiles
+	// runs at the same time
+	MaxConcurrentRequests = 8
 )
 
 // HTTP retry configuration constants
@@ -0,0 +1,153 @@
+// Package queue runs package operations one at a time per environment.
+// Two pip processes writing the same site-packages at once can leave it
+// broken, so the adds, removes and upgrades of the CLI and the TUI go
+// through the queue of their environment. Queues live in one process:
+// they do not coordinate separate depman runs.
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// Job is one operation in a batch, such as upgrading a single package.
+type Job struct {
+	Name string // what the job acts on, shown in progress reports
+	Run  func(ctx context.Context) error
+}
+
+// Progress describes a running batch. It is reported before each job
+// starts and once more when the batch ends, with Current empty.
+type Progress struct {
+	Done    int    // jobs finished, successfully or not
+	Total   int    // jobs in the batch
+	Current string // name of the job now running
+	Failed  int    // jobs that returned an error so far
+}
+
+// Failure is a job that returned an error.
+type Failure struct {
+	Name string
+	Err  error
+}
+
+// Result is the outcome of a batch.
+type Result struct {
+	Succeeded []string
+	Failed    []Failure
+	Skipped   []string // not run because the batch was cancelled or stopped
+	Cancelled bool     // the context was cancelled before the batch ended
+}
+
+// FailedNames returns the names of the failed jobs, in order.
+func (r Result) FailedNames() []string {
+	names := make([]string, len(r.Failed))
+	for i, f := range r.Failed {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Options control how a batch runs.
+type Options struct {
+	StopOnError bool           // skip the remaining jobs after the first failure
+	Progress    func(Progress) // called on the goroutine running the batch
+}
+
+// Queue runs jobs one at a time. The zero value is ready to use, but
+// callers normally share the queue of their environment through For.
+type Queue struct {
+	once sync.Once
+	slot chan struct{} // holds a token while a job runs
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]*Queue)
+)
+
+// For returns the queue of the environment at path, the same one for
+// every caller, so jobs for one environment never overlap even when they
+// come from different batches.
+func For(path string) *Queue {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	q, ok := registry[path]
+	if !ok {
+		q = &Queue{}
+		registry[path] = q
+	}
+	return q
+}
+
+// Do runs a single job on the queue and returns its error, or the
+// context's error if it was cancelled before the job could start.
+func (q *Queue) Do(ctx context.Context, job Job) error {
+	r := q.Run(ctx, []Job{job}, Options{})
+	if len(r.Failed) > 0 {
+		return r.Failed[0].Err
+	}
+	if r.Cancelled {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Run runs jobs in order and waits for them to finish. A failed job does
+// not stop the rest unless opts.StopOnError is set. Cancelling ctx skips
+// the jobs that have not started; the running job gets ctx to stop early.
+func (q *Queue) Run(ctx context.Context, jobs []Job, opts Options) Result {
+	q.once.Do(func() { q.slot = make(chan struct{}, 1) })
+
+	var r Result
+	report := func(current string) {
+		if opts.Progress != nil {
+			opts.Progress(Progress{
+				Done:    len(r.Succeeded) + len(r.Failed),
+				Total:   len(jobs),
+				Current: current,
+				Failed:  len(r.Failed),
+			})
+		}
+	}
+
+	for i, job := range jobs {
+		stopped := opts.StopOnError && len(r.Failed) > 0
+		if stopped || !q.acquire(ctx) {
+			for _, j := range jobs[i:] {
+				r.Skipped = append(r.Skipped, j.Name)
+			}
+			break
+		}
+		report(job.Name)
+		if err := q.run(ctx, job); err != nil {
+			r.Failed = append(r.Failed, Failure{Name: job.Name, Err: err})
+		} else {
+			r.Succeeded = append(r.Succeeded, job.Name)
+		}
+	}
+	r.Cancelled = ctx.Err() != nil
+	report("")
+	return r
+}
+
+// run runs job in the slot acquired for it, and frees the slot even if the
+// job panics.
+func (q *Queue) run(ctx context.Context, job Job) error {
+	defer func() { <-q.slot }()
+	return job.Run(ctx)
+}
+
+// acquire waits for the queue to be free, and reports false if ctx was
+// cancelled first.
+func (q *Queue) acquire(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case q.slot <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,155 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jobs returns one job per name that records its name in ran; names in
+// fail return an error.
+func jobs(ran *[]string, fail map[string]bool, names ...string) []Job {
+	var out []Job
+	for _, name := range names {
+		out = append(out, Job{Name: name, Run: func(context.Context) error {
+			*ran = append(*ran, name)
+			if fail[name] {
+				return errors.New(name + " failed")
+			}
+			return nil
+		}})
+	}
+	return out
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		stopOnError bool
+		ran         []string
+		succeeded   []string
+		failed      []string
+		skipped     []string
+	}{
+		{
+			name:      "failures do not stop the batch",
+			ran:       []string{"a", "b", "c"},
+			succeeded: []string{"a", "c"},
+			failed:    []string{"b"},
+		},
+		{
+			name:        "stop on error skips the rest",
+			stopOnError: true,
+			ran:         []string{"a", "b"},
+			succeeded:   []string{"a"},
+			failed:      []string{"b"},
+			skipped:     []string{"c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran []string
+			var progress []Progress
+			r := (&Queue{}).Run(context.Background(), jobs(&ran, map[string]bool{"b": true}, "a", "b", "c"), Options{
+				StopOnError: tt.stopOnError,
+				Progress:    func(p Progress) { progress = append(progress, p) },
+			})
+			if !reflect.DeepEqual(ran, tt.ran) {
+				t.Errorf("ran %v, want %v", ran, tt.ran)
+			}
+			if !reflect.DeepEqual(r.Succeeded, tt.succeeded) || !reflect.DeepEqual(r.FailedNames(), tt.failed) || !reflect.DeepEqual(r.Skipped, tt.skipped) {
+				t.Errorf("Run() = %+v", r)
+			}
+			if r.Cancelled {
+				t.Error("Cancelled = true without a cancelled context")
+			}
+			// One report per job that ran, then a final one
+			if len(progress) != len(tt.ran)+1 {
+				t.Fatalf("got %d progress reports, want %d", len(progress), len(tt.ran)+1)
+			}
+			if p := progress[1]; p != (Progress{Done: 1, Total: 3, Current: "b"}) {
+				t.Errorf("second report = %+v", p)
+			}
+			last := progress[len(progress)-1]
+			if last.Current != "" || last.Done != len(tt.ran) || last.Failed != 1 {
+				t.Errorf("final report = %+v", last)
+			}
+		})
+	}
+}
+
+func TestRun_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var ran []string
+	batch := []Job{
+		{Name: "a", Run: func(context.Context) error { ran = append(ran, "a"); cancel(); return nil }},
+		{Name: "b", Run: func(context.Context) error { ran = append(ran, "b"); return nil }},
+	}
+	r := (&Queue{}).Run(ctx, batch, Options{})
+	if !reflect.DeepEqual(ran, []string{"a"}) {
+		t.Errorf("ran %v, want [a]", ran)
+	}
+	if !r.Cancelled || !reflect.DeepEqual(r.Skipped, []string{"b"}) {
+		t.Errorf("Run() = %+v, want b skipped and cancelled", r)
+	}
+
+	err := (&Queue{}).Do(ctx, Job{Name: "c", Run: func(context.Context) error { return nil }})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() with a cancelled context = %v, want context.Canceled", err)
+	}
+}
+
+func TestRun_Panic(t *testing.T) {
+	q := &Queue{}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Do() did not pass on the job's panic")
+			}
+		}()
+		q.Do(context.Background(), Job{Name: "a", Run: func(context.Context) error { panic("boom") }})
+	}()
+
+	// The panicking job freed the queue for the next one
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := q.Do(ctx, Job{Name: "b", Run: func(context.Context) error { return nil }}); err != nil {
+		t.Errorf("Do() after a panic = %v, want the queue to be free", err)
+	}
+}
+
+func TestFor_Serializes(t *testing.T) {
+	if For("/venv/a") != For("/venv/a") {
+		t.Error("For() returned different queues for the same environment")
+	}
+	if For("/venv/a") == For("/venv/b") {
+		t.Error("For() returned the same queue for different environments")
+	}
+
+	q := For(t.TempDir())
+	var running, overlaps atomic.Int32
+	job := Job{Name: "x", Run: func(context.Context) error {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		return nil
+	}}
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.Run(context.Background(), []Job{job, job, job}, Options{})
+		}()
+	}
+	wg.Wait()
+	if n := overlaps.Load(); n > 0 {
+		t.Errorf("%d jobs overlapped on one queue", n)
+	}
+}
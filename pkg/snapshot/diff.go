@@ -0,0 +1,110 @@
+package snapshot
+
+import "strings"
+
+// maxDiffCells bounds the size of the table used to diff two files; larger
+// files are shown as a full replacement.
+const maxDiffCells = 4 << 20
+
+// DiffLine is one line of a file diff. Op is ' ' for an unchanged line,
+// '-' for a line that is removed, '+' for a line that is added, and '…' for
+// a run of unchanged lines left out.
+type DiffLine struct {
+	Op   rune
+	Text string
+}
+
+// Diff returns the lines that restoring f changes, going from its current
+// content to its previous one, with context unchanged lines around each
+// change.
+func (f FileRestore) Diff(context int) []DiffLine {
+	lines := diffLines(splitLines(string(f.Current)), splitLines(string(f.Previous)))
+
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.Op == ' ' {
+			continue
+		}
+		for j := max(0, i-context); j <= min(len(lines)-1, i+context); j++ {
+			keep[j] = true
+		}
+	}
+	var out []DiffLine
+	skipped := false
+	for i, l := range lines {
+		if !keep[i] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			out = append(out, DiffLine{Op: '…'})
+			skipped = false
+		}
+		out = append(out, l)
+	}
+	if skipped && len(out) > 0 {
+		out = append(out, DiffLine{Op: '…'})
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes a line diff from a to b with a longest common
+// subsequence table.
+func diffLines(a, b []string) []DiffLine {
+	if len(a)*len(b) > maxDiffCells {
+		var out []DiffLine
+		for _, l := range a {
+			out = append(out, DiffLine{Op: '-', Text: l})
+		}
+		for _, l := range b {
+			out = append(out, DiffLine{Op: '+', Text: l})
+		}
+		return out
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []DiffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, DiffLine{Op: ' ', Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, DiffLine{Op: '-', Text: a[i]})
+			i++
+		default:
+			out = append(out, DiffLine{Op: '+', Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, DiffLine{Op: '-', Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, DiffLine{Op: '+', Text: b[j]})
+	}
+	return out
+}
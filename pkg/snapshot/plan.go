@@ -0,0 +1,144 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/eslam/depman/pkg/audit"
+	"github.com/eslam/depman/pkg/log"
+	"github.com/eslam/depman/pkg/parser"
+	"github.com/eslam/depman/pkg/pip"
+)
+
+// FileRestore is a file whose content differs from the snapshot.
+type FileRestore struct {
+	Path     string
+	Current  []byte // nil if the file does not exist now
+	Previous []byte // nil if the file did not exist then
+}
+
+// Plan is what restoring a snapshot would change.
+type Plan struct {
+	Snapshot *Snapshot
+	// Packages to put back, by normalized name: Before is the version
+	// installed now and After the version in the snapshot. An empty After
+	// means the package is uninstalled, an empty Before that it is
+	// reinstalled.
+	Packages []audit.Change
+	Files    []FileRestore
+	Intents  bool // the pending intents are restored
+}
+
+// NewPlan compares a snapshot with the packages installed now and the
+// files on disk. Editable installs are left alone, since they cannot be
+// reinstalled from a version pin.
+func NewPlan(s *Snapshot, installed []pip.Package) (*Plan, error) {
+	p := &Plan{Snapshot: s}
+
+	before := make(map[string]string)
+	for _, pkg := range installed {
+		if !pkg.Editable {
+			before[parser.NormalizeName(pkg.Name)] = pkg.InstalledVersion
+		}
+	}
+	after := make(map[string]string)
+	for _, pkg := range s.Packages {
+		key := parser.NormalizeName(pkg.Name)
+		if pkg.Editable {
+			delete(before, key)
+			continue
+		}
+		after[key] = pkg.Version
+	}
+	for _, pkg := range installed {
+		if pkg.Editable {
+			delete(after, parser.NormalizeName(pkg.Name))
+		}
+	}
+	p.Packages = audit.Diff(before, after)
+
+	for _, f := range s.Files {
+		current, err := os.ReadFile(f.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			current = nil
+		} else if err != nil {
+			return nil, fmt.Errorf("snapshot: read %s: %w", f.Path, err)
+		}
+		var previous []byte
+		if !f.Missing {
+			previous = []byte(f.Content)
+		}
+		if (current == nil) != (previous == nil) || !bytes.Equal(current, previous) {
+			p.Files = append(p.Files, FileRestore{Path: f.Path, Current: current, Previous: previous})
+		}
+	}
+	sort.Slice(p.Files, func(i, j int) bool { return p.Files[i].Path < p.Files[j].Path })
+
+	if s.Project != "" {
+		intents, err := parser.LoadIntents(s.Project)
+		if err != nil {
+			return nil, err
+		}
+		cur, _ := json.Marshal(intents)
+		prev, _ := json.Marshal(s.Intents)
+		p.Intents = !bytes.Equal(cur, prev)
+	}
+	return p, nil
+}
+
+// Empty reports whether the snapshot matches the current state.
+func (p *Plan) Empty() bool {
+	return len(p.Packages) == 0 && len(p.Files) == 0 && !p.Intents
+}
+
+// Apply restores the snapshot: packages installed since are uninstalled,
+// the others are reinstalled at their snapshot version, and files are put
+// back with their previous content. Every step is attempted; the errors
+// are joined.
+func (p *Plan) Apply(runner *pip.Runner) error {
+	var errs []error
+	for _, c := range p.Packages {
+		if c.After == "" {
+			if r := runner.Uninstall(c.Name); r.Err != nil {
+				errs = append(errs, fmt.Errorf("uninstall %s: %w", c.Name, r.Err))
+			}
+		}
+	}
+	for _, c := range p.Packages {
+		if c.After != "" {
+			if r := runner.Install(c.Name + "==" + c.After); r.Err != nil {
+				errs = append(errs, fmt.Errorf("install %s==%s: %w", c.Name, c.After, r.Err))
+			}
+		}
+	}
+
+	for _, f := range p.Files {
+		start := time.Now()
+		err := parser.RestoreFile(f.Path, f.Previous)
+		rec := audit.Record{
+			Time:       start,
+			Action:     audit.ActionRestore,
+			Target:     f.Path,
+			DurationMS: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			rec.Error = err.Error()
+			errs = append(errs, err)
+		}
+		if jerr := runner.Journal.Append(rec); jerr != nil {
+			log.Warn("audit journal append failed", "action", rec.Action, "target", f.Path, "error", jerr)
+		}
+	}
+
+	if p.Intents {
+		if err := parser.SaveIntents(p.Snapshot.Project, p.Snapshot.Intents); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
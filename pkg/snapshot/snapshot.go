@@ -0,0 +1,230 @@
+// Package snapshot saves the installed packages and dependency files of a
+// project before each change, so that `depman undo` can put them back.
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/parser"
+	"github.com/eslam/depman/pkg/pip"
+	"github.com/eslam/depman/pkg/store"
+)
+
+// MaxSnapshots is the number of snapshots kept per project; older ones are
+// discarded as new ones are taken.
+const MaxSnapshots = 20
+
+// Package is an installed distribution as recorded in a snapshot.
+type Package struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Editable bool   `json:"editable,omitempty"`
+}
+
+// File is the content of a dependency file when the snapshot was taken.
+type File struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	Missing bool   `json:"missing,omitempty"` // the file did not exist
+}
+
+// Snapshot is the state of a project before an action.
+type Snapshot struct {
+	ID       string         `json:"id"`
+	Time     time.Time      `json:"time"`
+	Action   string         `json:"action"` // what was about to happen, e.g. "upgrade requests"
+	Project  string         `json:"project"`
+	Packages []Package      `json:"packages"`
+	Files    []File         `json:"files"`
+	Intents  parser.Intents `json:"intents"`
+}
+
+// Take records the installed packages, the project's dependency files and
+// its pending intents before action, and saves the snapshot. lockFile is
+// the configured [sync] lock file, if any, relative to the project.
+func Take(project detector.Project, runner *pip.Runner, action, lockFile string) (*Snapshot, error) {
+	result := runner.List()
+	if result.Err != nil {
+		return nil, fmt.Errorf("snapshot: list packages: %w", result.Err)
+	}
+	installed, err := pip.ParsePackageList(result.Stdout)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+
+	now := time.Now().UTC()
+	s := &Snapshot{
+		ID:      now.Format("20060102T150405.000000000Z"),
+		Time:    now,
+		Action:  action,
+		Project: project.Dir,
+	}
+	for _, p := range installed {
+		s.Packages = append(s.Packages, Package{Name: p.Name, Version: p.InstalledVersion, Editable: p.Editable})
+	}
+	for _, path := range trackedFiles(project, lockFile) {
+		content, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			s.Files = append(s.Files, File{Path: path, Missing: true})
+		case err != nil:
+			return nil, fmt.Errorf("snapshot: read %s: %w", path, err)
+		default:
+			s.Files = append(s.Files, File{Path: path, Content: string(content)})
+		}
+	}
+	if project.Detected() {
+		if s.Intents, err = parser.LoadIntents(project.Dir); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := save(project.Dir, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// trackedFiles returns the files an action may rewrite: the dependency
+// file, the requirements files it includes, the lock file of the tool
+// that manages the project, and the configured lock file.
+func trackedFiles(project detector.Project, lockFile string) []string {
+	var paths []string
+	if project.Detected() {
+		paths = append(paths, project.FilePath)
+		if project.FileType == detector.FileRequirementsTXT {
+			if f, err := parser.ReadRequirementsFile(project.FilePath); err == nil {
+				paths = f.Paths()
+			}
+		}
+		if lock := project.LockPath(); lock != "" {
+			paths = append(paths, lock)
+		}
+	}
+	if lockFile != "" {
+		if !filepath.IsAbs(lockFile) {
+			lockFile = filepath.Join(project.Dir, lockFile)
+		}
+		paths = append(paths, lockFile)
+	}
+
+	seen := make(map[string]bool)
+	var out []string
+	for _, p := range paths {
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// dir returns the directory holding the project's snapshots.
+func dir(projectDir string) (string, error) {
+	base, err := store.ProjectDir(projectDir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "snapshots"), nil
+}
+
+func save(projectDir string, s *Snapshot) error {
+	d, err := dir(projectDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d, 0o755); err != nil {
+		return fmt.Errorf("snapshot: create directory: %w", err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("snapshot: encode: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(d, s.ID+".json"), data, 0o644); err != nil {
+		return fmt.Errorf("snapshot: write: %w", err)
+	}
+	return prune(d)
+}
+
+// prune removes all but the newest MaxSnapshots snapshots.
+func prune(d string) error {
+	ids, err := ids(d)
+	if err != nil {
+		return err
+	}
+	for len(ids) > MaxSnapshots {
+		if err := os.Remove(filepath.Join(d, ids[0]+".json")); err != nil {
+			return fmt.Errorf("snapshot: prune: %w", err)
+		}
+		ids = ids[1:]
+	}
+	return nil
+}
+
+// ids returns the snapshot IDs in d, oldest first.
+func ids(d string) ([]string, error) {
+	entries, err := os.ReadDir(d)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: list: %w", err)
+	}
+	var out []string
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// List returns the project's snapshots, newest first.
+func List(projectDir string) ([]*Snapshot, error) {
+	d, err := dir(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := ids(d)
+	if err != nil {
+		return nil, err
+	}
+	var out []*Snapshot
+	for i := len(ids) - 1; i >= 0; i-- {
+		data, err := os.ReadFile(filepath.Join(d, ids[i]+".json"))
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: read: %w", err)
+		}
+		var s Snapshot
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("snapshot: parse %s: %w", ids[i], err)
+		}
+		out = append(out, &s)
+	}
+	return out, nil
+}
+
+// Discard deletes a snapshot once it has been restored or found to match
+// the current state.
+func Discard(projectDir, id string) error {
+	d, err := dir(projectDir)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(d, id+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("snapshot: discard: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/env"
+	"github.com/eslam/depman/pkg/parser"
+	"github.com/eslam/depman/pkg/pip"
+)
+
+// fakePip returns a runner whose pip lists the JSON in list.json and logs
+// every other invocation to calls.log, both in dir.
+func fakePip(t *testing.T, dir string) *pip.Runner {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pip is a shell script")
+	}
+	script := filepath.Join(dir, "pip")
+	content := "#!/bin/sh\n" +
+		"if [ \"$1\" = list ]; then cat '" + filepath.Join(dir, "list.json") + "'; exit 0; fi\n" +
+		"echo \"$@\" >> '" + filepath.Join(dir, "calls.log") + "'\n"
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return pip.NewRunner(env.PackageManager{Type: env.ManagerPip, BinPath: script}, env.Virtualenv{})
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTakeAndUndo(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	tools := t.TempDir()
+	runner := fakePip(t, tools)
+
+	dir := t.TempDir()
+	project := detector.Project{Dir: dir, FilePath: filepath.Join(dir, "requirements.txt"), FileType: detector.FileRequirementsTXT}
+	writeFile(t, project.FilePath, "-r base.txt\nrequests==2.30.0\n")
+	writeFile(t, filepath.Join(dir, "base.txt"), "click==8.1.7\n")
+	writeFile(t, filepath.Join(tools, "list.json"), `[
+		{"name": "requests", "version": "2.30.0"},
+		{"name": "click", "version": "8.1.7"},
+		{"name": "urllib3", "version": "1.26.18"},
+		{"name": "mylib", "version": "0.1.0", "editable_project_location": "/src/mylib"}
+	]`)
+
+	snap, err := Take(project, runner, "upgrade requests", "constraints.txt")
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	if len(snap.Files) != 3 || !snap.Files[2].Missing {
+		t.Errorf("Take() files = %+v, want requirements.txt, base.txt and a missing constraints.txt", snap.Files)
+	}
+
+	// The upgrade: requests and urllib3 move, certifi arrives, the file and
+	// lock file are rewritten
+	writeFile(t, filepath.Join(tools, "list.json"), `[
+		{"name": "requests", "version": "2.31.0"},
+		{"name": "click", "version": "8.1.7"},
+		{"name": "urllib3", "version": "2.1.0"},
+		{"name": "certifi", "version": "2024.2.2"},
+		{"name": "mylib", "version": "0.2.0", "editable_project_location": "/src/mylib"}
+	]`)
+	writeFile(t, project.FilePath, "-r base.txt\nrequests==2.31.0\n")
+	writeFile(t, filepath.Join(dir, "constraints.txt"), "requests==2.31.0\n")
+	if err := parser.RecordAdded(dir, "requests", parser.MainGroup); err != nil {
+		t.Fatal(err)
+	}
+
+	installed, err := pip.ParsePackageList(mustRead(t, filepath.Join(tools, "list.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := List(dir)
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("List() = %d snapshots, %v; want 1", len(snapshots), err)
+	}
+	plan, err := NewPlan(snapshots[0], installed)
+	if err != nil {
+		t.Fatalf("NewPlan() error = %v", err)
+	}
+
+	var changes []string
+	for _, c := range plan.Packages {
+		changes = append(changes, c.Name+":"+c.Before+">"+c.After)
+	}
+	if got := strings.Join(changes, " "); got != "certifi:2024.2.2> requests:2.31.0>2.30.0 urllib3:2.1.0>1.26.18" {
+		t.Errorf("plan packages = %s", got)
+	}
+	if len(plan.Files) != 2 || filepath.Base(plan.Files[0].Path) != "constraints.txt" || plan.Files[0].Previous != nil {
+		t.Errorf("plan files = %+v, want constraints.txt deleted and requirements.txt restored", plan.Files)
+	}
+	if !plan.Intents {
+		t.Error("plan.Intents = false, want the pending add dropped")
+	}
+
+	if err := plan.Apply(runner); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	calls := mustRead(t, filepath.Join(tools, "calls.log"))
+	if want := "uninstall certifi -y\ninstall requests==2.30.0\ninstall urllib3==1.26.18\n"; calls != want {
+		t.Errorf("pip calls =\n%s\nwant:\n%s", calls, want)
+	}
+	if got := mustRead(t, project.FilePath); got != "-r base.txt\nrequests==2.30.0\n" {
+		t.Errorf("requirements.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "constraints.txt")); !os.IsNotExist(err) {
+		t.Errorf("constraints.txt not removed: %v", err)
+	}
+	if in, _ := parser.LoadIntents(dir); len(in.Added) != 0 {
+		t.Errorf("intents = %+v, want none", in)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	runner := fakePip(t, t.TempDir())
+	writeFile(t, filepath.Join(filepath.Dir(runner.Backend.(pip.PipBackend).Bin), "list.json"), "[]")
+	dir := t.TempDir()
+
+	for i := 0; i < MaxSnapshots+3; i++ {
+		if _, err := Take(detector.Project{Dir: dir}, runner, "add x", ""); err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+	}
+	snapshots, err := List(dir)
+	if err != nil || len(snapshots) != MaxSnapshots {
+		t.Fatalf("List() = %d snapshots, %v; want %d", len(snapshots), err, MaxSnapshots)
+	}
+	if !snapshots[0].Time.After(snapshots[len(snapshots)-1].Time) {
+		t.Error("List() is not newest first")
+	}
+	if err := Discard(dir, snapshots[0].ID); err != nil {
+		t.Fatalf("Discard() error = %v", err)
+	}
+	if snapshots, _ = List(dir); len(snapshots) != MaxSnapshots-1 {
+		t.Errorf("after Discard() List() = %d snapshots, want %d", len(snapshots), MaxSnapshots-1)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	f := FileRestore{
+		Current:  []byte("a\nb\nc\nd\ne\nf\ng\nh\n"),
+		Previous: []byte("a\nb\nc\nD\ne\nf\ng\nh\ni\n"),
+	}
+	var got []string
+	for _, l := range f.Diff(1) {
+		got = append(got, string(l.Op)+l.Text)
+	}
+	want := []string{"…", " c", "-d", "+D", " e", "…", " h", "+i"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Diff() = %q, want %q", got, want)
+	}
+}
+
+func mustRead(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,41 @@
+// Package store locates depman's persistent state, such as per-project
+// bookkeeping, under the XDG state directory.
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Dir returns depman's state directory: $XDG_STATE_HOME/depman, or
+// ~/.local/state/depman when XDG_STATE_HOME is unset.
+func Dir() (string, error) {
+	if xdg := os.Getenv("XDG_STATE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "depman"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("store: locate home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "state", "depman"), nil
+}
+
+// ProjectDir returns the state directory for the project rooted at
+// projectDir. The directory name combines the project's base name with a
+// hash of its absolute path, so it is stable and unique per checkout.
+func ProjectDir(projectDir string) (string, error) {
+	base, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(projectDir)
+	if err != nil {
+		return "", fmt.Errorf("store: resolve project path: %w", err)
+	}
+	sum := sha256.Sum256([]byte(abs))
+	name := filepath.Base(abs) + "-" + hex.EncodeToString(sum[:6])
+	return filepath.Join(base, "projects", name), nil
+}
@@ -0,0 +1,222 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Specifier is a single PEP 440 version clause such as ">=2.0" or "==1.4.*".
+type Specifier struct {
+	Op      string // "==", "!=", "<=", ">=", "<", ">", "~=" or "==="
+	Version string // as written, e.g. "2.0" or "1.4.*"
+}
+
+var specifierPattern = regexp.MustCompile(`^\s*(===|==|!=|<=|>=|~=|<|>)\s*(\S+?)\s*$`)
+
+// ParseSpecifier parses and validates a single version clause.
+func ParseSpecifier(s string) (Specifier, error) {
+	m := specifierPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Specifier{}, fmt.Errorf("version: invalid specifier %q", s)
+	}
+	spec := Specifier{Op: m[1], Version: m[2]}
+	if err := spec.validate(); err != nil {
+		return Specifier{}, err
+	}
+	return spec, nil
+}
+
+func (s Specifier) validate() error {
+	if s.Op == "===" {
+		// Arbitrary equality accepts any string without whitespace
+		return nil
+	}
+
+	ver := s.Version
+	wildcard := strings.HasSuffix(ver, ".*")
+	if wildcard {
+		if s.Op != "==" && s.Op != "!=" {
+			return fmt.Errorf("version: specifier %q: wildcards are only allowed with == and !=", s)
+		}
+		ver = strings.TrimSuffix(ver, ".*")
+	}
+
+	v, err := Parse(ver)
+	if err != nil {
+		return fmt.Errorf("version: specifier %q: %w", s, err)
+	}
+	if wildcard && (v.Local != "" || v.PreL != "" || v.Post >= 0 || v.Dev >= 0) {
+		return fmt.Errorf("version: specifier %q: wildcard must follow a release segment", s)
+	}
+	switch s.Op {
+	case "~=":
+		if len(v.Release) < 2 {
+			return fmt.Errorf("version: specifier %q: ~= needs at least two release segments", s)
+		}
+		fallthrough
+	case "<", ">", "<=", ">=":
+		if v.Local != "" {
+			return fmt.Errorf("version: specifier %q: local versions are not allowed with %s", s, s.Op)
+		}
+	}
+	return nil
+}
+
+// String formats the clause, e.g. ">=2.0".
+func (s Specifier) String() string {
+	return s.Op + s.Version
+}
+
+// Prerelease reports whether the clause explicitly names a pre-release,
+// which opts the whole specifier set into matching pre-releases.
+func (s Specifier) Prerelease() bool {
+	if s.Op == "!=" {
+		return false
+	}
+	v, err := Parse(strings.TrimSuffix(s.Version, ".*"))
+	return err == nil && v.IsPrerelease()
+}
+
+// Contains reports whether v satisfies the clause, ignoring pre-release
+// exclusion rules (see SpecifierSet.Contains).
+func (s Specifier) Contains(v Version) bool {
+	if s.Op == "===" {
+		return strings.EqualFold(strings.TrimSpace(v.Original()), s.Version) ||
+			strings.EqualFold(v.String(), s.Version)
+	}
+
+	if strings.HasSuffix(s.Version, ".*") {
+		prefix, err := Parse(strings.TrimSuffix(s.Version, ".*"))
+		if err != nil {
+			return false
+		}
+		match := prefixMatch(v, prefix)
+		if s.Op == "!=" {
+			return !match
+		}
+		return match
+	}
+
+	spec, err := Parse(s.Version)
+	if err != nil {
+		return false
+	}
+
+	switch s.Op {
+	case "==":
+		// A specifier without a local label ignores the candidate's label
+		if spec.Local == "" {
+			v = v.Public()
+		}
+		return Equal(v, spec)
+	case "!=":
+		if spec.Local == "" {
+			v = v.Public()
+		}
+		return !Equal(v, spec)
+	case "<=":
+		return Compare(v.Public(), spec) <= 0
+	case ">=":
+		return Compare(v.Public(), spec) >= 0
+	case "<":
+		if Compare(v, spec) >= 0 {
+			return false
+		}
+		// <V excludes pre-releases of V unless V is itself a pre-release
+		return spec.IsPrerelease() || !v.IsPrerelease() || !Equal(v.Base(), spec.Base())
+	case ">":
+		if Compare(v.Public(), spec) <= 0 {
+			return false
+		}
+		// >V excludes post-releases of V unless V is itself a post-release
+		if !spec.IsPostrelease() && v.IsPostrelease() && Equal(v.Base(), spec.Base()) {
+			return false
+		}
+		// ...and local versions of V
+		return !(v.Local != "" && Equal(v.Public(), spec))
+	case "~=":
+		upper := Version{Epoch: spec.Epoch, Release: spec.Release[:len(spec.Release)-1], Post: -1, Dev: -1}
+		return Compare(v.Public(), spec) >= 0 && prefixMatch(v, upper)
+	}
+	return false
+}
+
+// prefixMatch reports whether v's epoch and release start with prefix's,
+// padding v's release with zeros as needed.
+func prefixMatch(v, prefix Version) bool {
+	if v.Epoch != prefix.Epoch {
+		return false
+	}
+	for i, n := range prefix.Release {
+		if v.segment(i) != n {
+			return false
+		}
+	}
+	return true
+}
+
+// SpecifierSet is a comma-separated list of clauses that must all match.
+type SpecifierSet []Specifier
+
+// ParseSpecifierSet parses a clause list such as ">=2,<3". An empty string
+// yields an empty set, which matches every version.
+func ParseSpecifierSet(s string) (SpecifierSet, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var set SpecifierSet
+	for _, clause := range strings.Split(s, ",") {
+		spec, err := ParseSpecifier(clause)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, spec)
+	}
+	return set, nil
+}
+
+// String formats the set, e.g. ">=2,<3".
+func (ss SpecifierSet) String() string {
+	clauses := make([]string, len(ss))
+	for i, s := range ss {
+		clauses[i] = s.String()
+	}
+	return strings.Join(clauses, ",")
+}
+
+// Contains reports whether v satisfies every clause. Pre-releases only
+// match when prereleases is true or a clause explicitly names one.
+func (ss SpecifierSet) Contains(v Version, prereleases bool) bool {
+	if v.IsPrerelease() && !prereleases && !ss.Prerelease() {
+		return false
+	}
+	for _, s := range ss {
+		if !s.Contains(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Prerelease reports whether any clause explicitly names a pre-release.
+func (ss SpecifierSet) Prerelease() bool {
+	for _, s := range ss {
+		if s.Prerelease() {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the versions in vs that satisfy the set, in order.
+func (ss SpecifierSet) Filter(vs []Version, prereleases bool) []Version {
+	var out []Version
+	for _, v := range vs {
+		if ss.Contains(v, prereleases) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,218 @@
+package version
+
+import "testing"
+
+func TestParseSpecifier(t *testing.T) {
+	valid := []string{
+		"==1.0",
+		"== 1.0",
+		"!=1.0",
+		"<=1.0",
+		">=1.0",
+		"<1.0",
+		">1.0",
+		"~=1.0",
+		"~=1.4.5",
+		"==1.0.*",
+		"!=1.0.*",
+		"==1.0+local",
+		"!=1.0+local",
+		"===foobar",
+		">=1.0.dev0",
+		"~=2.2.post3",
+	}
+	for _, input := range valid {
+		t.Run(input, func(t *testing.T) {
+			if _, err := ParseSpecifier(input); err != nil {
+				t.Errorf("ParseSpecifier(%q) error = %v", input, err)
+			}
+		})
+	}
+
+	invalid := []string{
+		"",
+		"1.0",
+		"=1.0",
+		"=>1.0",
+		">=",
+		"~=1",
+		"~=1.0.*",
+		">=1.0.*",
+		"<1.0+local",
+		">=1.0+local",
+		"==1.0a1.*",
+		"==1.0+local.*",
+		">=not-a-version",
+		"== 1.0 extra",
+	}
+	for _, input := range invalid {
+		t.Run("invalid "+input, func(t *testing.T) {
+			if s, err := ParseSpecifier(input); err == nil {
+				t.Errorf("ParseSpecifier(%q) = %v, want error", input, s)
+			}
+		})
+	}
+}
+
+func TestSpecifier_Contains(t *testing.T) {
+	tests := []struct {
+		spec     string
+		version  string
+		expected bool
+	}{
+		// Exact equality, with zero padding and local labels
+		{"==1.0", "1.0", true},
+		{"==1.0", "1.0.0", true},
+		{"==1.0.0", "1.0", true},
+		{"==1.0", "1.0+local", true},
+		{"==1.0+local", "1.0+local", true},
+		{"==1.0+local", "1.0", false},
+		{"==1.0+local", "1.0+other", false},
+		{"==1.0", "1.0.post1", false},
+		{"==1.0", "1.1", false},
+
+		// Prefix matching
+		{"==1.0.*", "1.0", true},
+		{"==1.0.*", "1.0.5", true},
+		{"==1.0.*", "1.0rc1", true},
+		{"==1.0.*", "1.0.post1", true},
+		{"==1.0.*", "1.1", false},
+		{"==1.*", "1.99", true},
+		{"==1.*", "2.0", false},
+		{"==1!1.*", "1.0", false},
+		{"==1!1.*", "1!1.5", true},
+		{"!=1.0.*", "1.0.3", false},
+		{"!=1.0.*", "1.1", true},
+
+		// Inequality
+		{"!=1.0", "1.0", false},
+		{"!=1.0", "1.0.0", false},
+		{"!=1.0", "1.0+local", false},
+		{"!=1.0", "1.0.1", true},
+
+		// Inclusive ordered comparison
+		{"<=1.0", "1.0", true},
+		{"<=1.0", "1.0+local", true},
+		{"<=1.0", "1.0.post1", false},
+		{">=1.0", "1.0", true},
+		{">=1.0", "0.9", false},
+		{">=1.0", "1.0rc1", false},
+
+		// Exclusive ordered comparison
+		{"<2.0", "1.9", true},
+		{"<2.0", "2.0", false},
+		{"<2.0", "2.0rc1", false},
+		{"<2.0", "2.0.dev1", false},
+		{"<2.0rc2", "2.0rc1", true},
+		{"<2.0", "1.9rc1", true},
+		{">1.0", "1.1", true},
+		{">1.0", "1.0", false},
+		{">1.0", "1.0.post1", false},
+		{">1.0", "1.0+local", false},
+		{">1.0.post1", "1.0.post2", true},
+		{">1.0", "1.0.1", true},
+
+		// Compatible release
+		{"~=2.2", "2.2", true},
+		{"~=2.2", "2.9", true},
+		{"~=2.2", "3.0", false},
+		{"~=2.2", "2.1", false},
+		{"~=1.4.5", "1.4.5", true},
+		{"~=1.4.5", "1.4.9", true},
+		{"~=1.4.5", "1.5.0", false},
+		{"~=2.2.post3", "2.2.post3", true},
+		{"~=2.2.post3", "2.3", true},
+		{"~=2.2.post3", "2.2", false},
+		{"~=1!2.2", "2.5", false},
+
+		// Arbitrary equality
+		{"===1.0", "1.0", true},
+		{"===1.0", "v1.0", true},
+		{"===1.0", "1.0.0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec+" "+tt.version, func(t *testing.T) {
+			spec, err := ParseSpecifier(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseSpecifier(%q) error = %v", tt.spec, err)
+			}
+			v, err := Parse(tt.version)
+			if err != nil {
+				if tt.expected {
+					t.Fatalf("Parse(%q) error = %v", tt.version, err)
+				}
+				return
+			}
+			if got := spec.Contains(v); got != tt.expected {
+				t.Errorf("%q.Contains(%q) = %v, want %v", tt.spec, tt.version, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSpecifierSet_Contains(t *testing.T) {
+	tests := []struct {
+		set         string
+		version     string
+		prereleases bool
+		expected    bool
+	}{
+		{"", "1.0", false, true},
+		{"", "1.0rc1", false, false},
+		{"", "1.0rc1", true, true},
+		{">=2,<3", "2.5", false, true},
+		{">=2,<3", "3.0", false, false},
+		{">=2,<3", "1.9", false, false},
+		{">=2,<3", "2.5rc1", false, false},
+		{">=2,<3", "2.5rc1", true, true},
+		{">=2.0rc1", "2.0rc2", false, true},
+		{">=1.0.dev0", "1.0.dev5", false, true},
+		{"!=1.0rc1", "1.0rc2", false, false},
+		{"~=1.4, !=1.4.3", "1.4.3", false, false},
+		{"~=1.4, !=1.4.3", "1.4.4", false, true},
+		{"~=1.4, !=1.4.3", "1.5", false, true},
+		{"~=1.4, !=1.4.3", "2.0", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.set+" "+tt.version, func(t *testing.T) {
+			set, err := ParseSpecifierSet(tt.set)
+			if err != nil {
+				t.Fatalf("ParseSpecifierSet(%q) error = %v", tt.set, err)
+			}
+			if got := set.Contains(MustParse(tt.version), tt.prereleases); got != tt.expected {
+				t.Errorf("%q.Contains(%q, %v) = %v, want %v", tt.set, tt.version, tt.prereleases, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseSpecifierSet(t *testing.T) {
+	set, err := ParseSpecifierSet(" >= 2 , <3 ,!=2.5.*")
+	if err != nil {
+		t.Fatalf("ParseSpecifierSet() error = %v", err)
+	}
+	if got := set.String(); got != ">=2,<3,!=2.5.*" {
+		t.Errorf("String() = %q, want %q", got, ">=2,<3,!=2.5.*")
+	}
+
+	if _, err := ParseSpecifierSet(">=2,,<3"); err == nil {
+		t.Error("ParseSpecifierSet(\">=2,,<3\") want error for empty clause")
+	}
+}
+
+func TestSpecifierSet_Filter(t *testing.T) {
+	set, err := ParseSpecifierSet(">=1.1,<2")
+	if err != nil {
+		t.Fatalf("ParseSpecifierSet() error = %v", err)
+	}
+	var vs []Version
+	for _, s := range []string{"1.0", "1.1", "1.5rc1", "1.9", "2.0"} {
+		vs = append(vs, MustParse(s))
+	}
+	got := set.Filter(vs, false)
+	if len(got) != 2 || got[0].String() != "1.1" || got[1].String() != "1.9" {
+		t.Errorf("Filter() = %v, want [1.1 1.9]", got)
+	}
+}
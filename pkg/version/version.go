@@ -0,0 +1,354 @@
+// Package version implements PEP 440 version parsing, normalization,
+// ordering and specifier matching.
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed PEP 440 version such as "1!2.0.0rc1.post2.dev3+local.7".
+type Version struct {
+	Epoch   int
+	Release []int  // e.g. [2, 0, 0]; always at least one element
+	PreL    string // normalized pre-release label: "a", "b", "rc" or ""
+	PreN    int
+	Post    int    // post-release number, or -1 if not a post-release
+	Dev     int    // dev-release number, or -1 if not a dev-release
+	Local   string // normalized local label, e.g. "ubuntu.1", or ""
+	raw     string
+}
+
+// versionPattern is the canonical PEP 440 regular expression, matching both
+// normalized and non-normalized spellings.
+var versionPattern = regexp.MustCompile(`(?i)^\s*v?` +
+	`(?:(?P<epoch>[0-9]+)!)?` +
+	`(?P<release>[0-9]+(?:\.[0-9]+)*)` +
+	`(?P<pre>[-_.]?(?P<pre_l>alpha|beta|preview|pre|a|b|c|rc)[-_.]?(?P<pre_n>[0-9]+)?)?` +
+	`(?P<post>(?:-(?P<post_n1>[0-9]+))|(?:[-_.]?(?P<post_l>post|rev|r)[-_.]?(?P<post_n2>[0-9]+)?))?` +
+	`(?P<dev>[-_.]?(?P<dev_l>dev)[-_.]?(?P<dev_n>[0-9]+)?)?` +
+	`(?:\+(?P<local>[a-z0-9]+(?:[-_.][a-z0-9]+)*))?` +
+	`\s*$`)
+
+// Parse parses a PEP 440 version string.
+func Parse(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("version: invalid version %q", s)
+	}
+	group := func(name string) string {
+		return m[versionPattern.SubexpIndex(name)]
+	}
+
+	v := Version{Post: -1, Dev: -1, raw: strings.TrimSpace(s)}
+
+	var err error
+	if e := group("epoch"); e != "" {
+		if v.Epoch, err = strconv.Atoi(e); err != nil {
+			return Version{}, fmt.Errorf("version: invalid epoch in %q: %w", s, err)
+		}
+	}
+
+	for _, part := range strings.Split(group("release"), ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("version: invalid release segment in %q: %w", s, err)
+		}
+		v.Release = append(v.Release, n)
+	}
+
+	if group("pre") != "" {
+		v.PreL = normalizePreLabel(group("pre_l"))
+		v.PreN = atoiOrZero(group("pre_n"))
+	}
+
+	if group("post") != "" {
+		if n := group("post_n1"); n != "" {
+			v.Post = atoiOrZero(n)
+		} else {
+			v.Post = atoiOrZero(group("post_n2"))
+		}
+	}
+
+	if group("dev") != "" {
+		v.Dev = atoiOrZero(group("dev_n"))
+	}
+
+	if local := group("local"); local != "" {
+		v.Local = strings.ToLower(localSeparator.ReplaceAllString(local, "."))
+	}
+
+	return v, nil
+}
+
+// MustParse is like Parse but panics on invalid input. It is intended for
+// constants and tests.
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Valid reports whether s is a valid PEP 440 version.
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// Normalize returns the canonical spelling of a version string, e.g.
+// "1.0-ALPHA_1" becomes "1.0a1". Invalid versions are returned unchanged.
+func Normalize(s string) string {
+	v, err := Parse(s)
+	if err != nil {
+		return s
+	}
+	return v.String()
+}
+
+var localSeparator = regexp.MustCompile(`[-_]`)
+
+func normalizePreLabel(l string) string {
+	switch strings.ToLower(l) {
+	case "a", "alpha":
+		return "a"
+	case "b", "beta":
+		return "b"
+	default: // "c", "rc", "pre", "preview"
+		return "rc"
+	}
+}
+
+func atoiOrZero(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// String returns the normalized form of the version.
+func (v Version) String() string {
+	var b strings.Builder
+	if v.Epoch != 0 {
+		fmt.Fprintf(&b, "%d!", v.Epoch)
+	}
+	b.WriteString(v.releaseString())
+	if v.PreL != "" {
+		fmt.Fprintf(&b, "%s%d", v.PreL, v.PreN)
+	}
+	if v.Post >= 0 {
+		fmt.Fprintf(&b, ".post%d", v.Post)
+	}
+	if v.Dev >= 0 {
+		fmt.Fprintf(&b, ".dev%d", v.Dev)
+	}
+	if v.Local != "" {
+		b.WriteString("+" + v.Local)
+	}
+	return b.String()
+}
+
+// Original returns the version exactly as it was parsed.
+func (v Version) Original() string {
+	return v.raw
+}
+
+func (v Version) releaseString() string {
+	parts := make([]string, len(v.Release))
+	for i, n := range v.Release {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+// IsPrerelease reports whether v is an alpha, beta, release candidate or
+// development release.
+func (v Version) IsPrerelease() bool {
+	return v.PreL != "" || v.Dev >= 0
+}
+
+// IsPostrelease reports whether v has a post-release segment.
+func (v Version) IsPostrelease() bool {
+	return v.Post >= 0
+}
+
+// IsDevrelease reports whether v has a dev-release segment.
+func (v Version) IsDevrelease() bool {
+	return v.Dev >= 0
+}
+
+// Public returns v without its local version label.
+func (v Version) Public() Version {
+	v.Local = ""
+	return v
+}
+
+// Base returns the epoch and release segments of v only, e.g. "1.2.0" for
+// "1.2.0rc1.post1+abc".
+func (v Version) Base() Version {
+	return Version{Epoch: v.Epoch, Release: v.Release, Post: -1, Dev: -1}
+}
+
+// Major, Minor and Micro return the first three release segments, treating
+// missing segments as zero.
+func (v Version) Major() int { return v.segment(0) }
+func (v Version) Minor() int { return v.segment(1) }
+func (v Version) Micro() int { return v.segment(2) }
+
+func (v Version) segment(i int) int {
+	if i < len(v.Release) {
+		return v.Release[i]
+	}
+	return 0
+}
+
+// Compare returns -1, 0 or +1 depending on whether a sorts before, equal to
+// or after b under PEP 440 ordering.
+func Compare(a, b Version) int {
+	if c := cmpInt(a.Epoch, b.Epoch); c != 0 {
+		return c
+	}
+	if c := compareRelease(a.Release, b.Release); c != 0 {
+		return c
+	}
+	if c := cmpInt(a.preKey(), b.preKey()); c != 0 {
+		return c
+	}
+	if a.PreL != "" && b.PreL != "" {
+		if c := cmpInt(a.PreN, b.PreN); c != 0 {
+			return c
+		}
+	}
+	// A missing post segment sorts before any post-release
+	if c := cmpInt(a.Post, b.Post); c != 0 {
+		return c
+	}
+	// A missing dev segment sorts after any dev-release
+	if c := cmpInt(devKey(a.Dev), devKey(b.Dev)); c != 0 {
+		return c
+	}
+	return compareLocal(a.Local, b.Local)
+}
+
+// Less reports whether a sorts before b.
+func Less(a, b Version) bool {
+	return Compare(a, b) < 0
+}
+
+// Equal reports whether a and b are equal under PEP 440, e.g. "1.0" == "1.0.0".
+func Equal(a, b Version) bool {
+	return Compare(a, b) == 0
+}
+
+// CompareStrings compares two version strings. Valid versions sort after
+// invalid ones; two invalid versions compare as strings.
+func CompareStrings(a, b string) int {
+	va, errA := Parse(a)
+	vb, errB := Parse(b)
+	switch {
+	case errA == nil && errB == nil:
+		return Compare(va, vb)
+	case errA == nil:
+		return 1
+	case errB == nil:
+		return -1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+const (
+	preDevOnly = -2 // X.Y.devN sorts before X.YaN
+	preAlpha   = 0
+	preBeta    = 1
+	preRC      = 2
+	preFinal   = 3
+)
+
+// preKey orders the pre-release phase of a version.
+func (v Version) preKey() int {
+	switch v.PreL {
+	case "a":
+		return preAlpha
+	case "b":
+		return preBeta
+	case "rc":
+		return preRC
+	}
+	if v.Post < 0 && v.Dev >= 0 {
+		return preDevOnly
+	}
+	return preFinal
+}
+
+func devKey(dev int) int {
+	if dev < 0 {
+		return int(^uint(0) >> 1)
+	}
+	return dev
+}
+
+func compareRelease(a, b []int) int {
+	n := max(len(a), len(b))
+	for i := 0; i < n; i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if c := cmpInt(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareLocal orders local labels: no label sorts first, numeric segments
+// sort after alphanumeric ones, and a shorter label sorts first when it is
+// a prefix of the longer one.
+func compareLocal(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+	if b == "" {
+		return 1
+	}
+	pa := strings.Split(a, ".")
+	pb := strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		switch {
+		case errA == nil && errB == nil:
+			if c := cmpInt(na, nb); c != 0 {
+				return c
+			}
+		case errA == nil:
+			return 1
+		case errB == nil:
+			return -1
+		default:
+			if c := strings.Compare(pa[i], pb[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmpInt(len(pa), len(pb))
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
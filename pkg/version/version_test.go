@@ -0,0 +1,271 @@
+package version
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input      string
+		normalized string
+	}{
+		// Release segments
+		{"1", "1"},
+		{"1.0", "1.0"},
+		{"1.2.3.4.5", "1.2.3.4.5"},
+		{"v1.0", "1.0"},
+		{"V2.0.1", "2.0.1"},
+		{"  1.0  ", "1.0"},
+		{"01.002", "1.2"},
+
+		// Epochs
+		{"1!1.0", "1!1.0"},
+		{"0!1.0", "1.0"},
+
+		// Pre-releases
+		{"1.0a1", "1.0a1"},
+		{"1.0alpha1", "1.0a1"},
+		{"1.0.alpha.1", "1.0a1"},
+		{"1.0-ALPHA_1", "1.0a1"},
+		{"1.0a", "1.0a0"},
+		{"1.0b2", "1.0b2"},
+		{"1.0beta2", "1.0b2"},
+		{"1.0c1", "1.0rc1"},
+		{"1.0pre1", "1.0rc1"},
+		{"1.0preview1", "1.0rc1"},
+		{"1.0rc1", "1.0rc1"},
+		{"1.0-rc-1", "1.0rc1"},
+
+		// Post-releases
+		{"1.0.post1", "1.0.post1"},
+		{"1.0post1", "1.0.post1"},
+		{"1.0-post1", "1.0.post1"},
+		{"1.0-1", "1.0.post1"},
+		{"1.0.rev1", "1.0.post1"},
+		{"1.0.r1", "1.0.post1"},
+		{"1.0.post", "1.0.post0"},
+
+		// Dev-releases
+		{"1.0.dev1", "1.0.dev1"},
+		{"1.0dev1", "1.0.dev1"},
+		{"1.0-dev", "1.0.dev0"},
+
+		// Combinations
+		{"1.0a1.post2.dev3", "1.0a1.post2.dev3"},
+		{"1!2.0rc1.post1.dev2+abc.5", "1!2.0rc1.post1.dev2+abc.5"},
+
+		// Local versions
+		{"1.0+ubuntu-1", "1.0+ubuntu.1"},
+		{"1.0+Ubuntu_1.A", "1.0+ubuntu.1.a"},
+		{"1.0+5", "1.0+5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			v, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			if got := v.String(); got != tt.normalized {
+				t.Errorf("Parse(%q).String() = %q, want %q", tt.input, got, tt.normalized)
+			}
+			if got := Normalize(tt.input); got != tt.normalized {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.normalized)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"abc",
+		"1.0.",
+		".1.0",
+		"1..0",
+		"1.0+",
+		"1.0+abc+def",
+		"1.0-",
+		"1.0a1b2",
+		"1.0 beta",
+		"1!",
+		"1.2.beta3x",
+		"1.0.0-SNAPSHOT",
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			if v, err := Parse(input); err == nil {
+				t.Errorf("Parse(%q) = %v, want error", input, v)
+			}
+			if Valid(input) {
+				t.Errorf("Valid(%q) = true, want false", input)
+			}
+			if got := Normalize(input); got != input {
+				t.Errorf("Normalize(%q) = %q, want input unchanged", input, got)
+			}
+		})
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	tests := []struct {
+		input      string
+		prerelease bool
+		postrelase bool
+		devrelease bool
+	}{
+		{"1.0", false, false, false},
+		{"1.0a1", true, false, false},
+		{"1.0rc1", true, false, false},
+		{"1.0.dev1", true, false, true},
+		{"1.0.post1", false, true, false},
+		{"1.0.post1.dev1", true, true, true},
+		{"1.0+local", false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			v := MustParse(tt.input)
+			if v.IsPrerelease() != tt.prerelease {
+				t.Errorf("IsPrerelease() = %v, want %v", v.IsPrerelease(), tt.prerelease)
+			}
+			if v.IsPostrelease() != tt.postrelase {
+				t.Errorf("IsPostrelease() = %v, want %v", v.IsPostrelease(), tt.postrelase)
+			}
+			if v.IsDevrelease() != tt.devrelease {
+				t.Errorf("IsDevrelease() = %v, want %v", v.IsDevrelease(), tt.devrelease)
+			}
+		})
+	}
+}
+
+func TestSegments(t *testing.T) {
+	v := MustParse("2.7")
+	if v.Major() != 2 || v.Minor() != 7 || v.Micro() != 0 {
+		t.Errorf("segments of 2.7 = %d.%d.%d, want 2.7.0", v.Major(), v.Minor(), v.Micro())
+	}
+	if got := MustParse("1!2.0rc1.post1+abc").Base().String(); got != "1!2.0" {
+		t.Errorf("Base() = %q, want %q", got, "1!2.0")
+	}
+	if got := MustParse("2.0+abc").Public().String(); got != "2.0" {
+		t.Errorf("Public() = %q, want %q", got, "2.0")
+	}
+}
+
+// orderedVersions is sorted in ascending PEP 440 order.
+var orderedVersions = []string{
+	"1.0.dev456",
+	"1.0a1",
+	"1.0a2.dev456",
+	"1.0a12.dev456",
+	"1.0a12",
+	"1.0b1.dev456",
+	"1.0b2",
+	"1.0b2.post345.dev456",
+	"1.0b2.post345",
+	"1.0b2-346",
+	"1.0c1.dev456",
+	"1.0c1",
+	"1.0rc2",
+	"1.0c3",
+	"1.0",
+	"1.0.post456.dev34",
+	"1.0.post456",
+	"1.1.dev1",
+	"1.2",
+	"1.2+123abc",
+	"1.2+123abc456",
+	"1.2+abc",
+	"1.2+abc123",
+	"1.2+abc123def",
+	"1.2+1234.abc",
+	"1.2+123456",
+	"1.2.r32+123456",
+	"1.2.rev33+123456",
+	"1.10",
+	"1!0.1",
+	"1!1.0b2.post345.dev456",
+	"1!1.0",
+}
+
+func TestCompare_Ordering(t *testing.T) {
+	for i := range orderedVersions {
+		for j := range orderedVersions {
+			a := MustParse(orderedVersions[i])
+			b := MustParse(orderedVersions[j])
+			want := cmpInt(i, j)
+			if got := Compare(a, b); got != want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", orderedVersions[i], orderedVersions[j], got, want)
+			}
+		}
+	}
+}
+
+func TestCompare_Sort(t *testing.T) {
+	shuffled := []string{"1.0", "1!0.1", "1.0a1", "1.10", "1.2", "1.0.post456", "1.0.dev456", "1.0rc2"}
+	sort.Slice(shuffled, func(i, j int) bool {
+		return Less(MustParse(shuffled[i]), MustParse(shuffled[j]))
+	})
+	expected := []string{"1.0.dev456", "1.0a1", "1.0rc2", "1.0", "1.0.post456", "1.2", "1.10", "1!0.1"}
+	for i := range expected {
+		if shuffled[i] != expected[i] {
+			t.Errorf("sorted[%d] = %q, want %q", i, shuffled[i], expected[i])
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"1.0", "1.0.0", true},
+		{"1.0", "1.0.0.0", true},
+		{"1.0a1", "1.0alpha1", true},
+		{"1.0.post0", "1.0-0", true},
+		{"v1.0", "1.0", true},
+		{"0!1.0", "1.0", true},
+		{"1.0", "1.0.post0", false},
+		{"1.0", "1.0+local", false},
+		{"1.0a0", "1.0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"=="+tt.b, func(t *testing.T) {
+			if got := Equal(MustParse(tt.a), MustParse(tt.b)); got != tt.expected {
+				t.Errorf("Equal(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompareStrings(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0", "2.0", -1},
+		{"1.0rc1", "1.0", -1},
+		{"1.0", "1.0.0", 0},
+		{"1.0", "not-a-version", 1},
+		{"not-a-version", "1.0", -1},
+		{"aaa", "bbb", -1},
+	}
+
+	for _, tt := range tests {
+		if got := CompareStrings(tt.a, tt.b); got != tt.expected {
+			t.Errorf("CompareStrings(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestMustParse_Panics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("MustParse(\"bogus\") did not panic")
+		}
+	}()
+	MustParse("bogus")
+}
@@ -93,6 +93,33 @@ const (
 
 	// DetailDescOffset is the width offset for description truncation in package detail view
 	DetailDescOffset = 20
+
+	// LogsHeaderLines is the number of lines around the entries in the log viewer
+	LogsHeaderLines = 7
+
+	// HistoryReservedLines is the number of lines the history screen keeps for its title, detail pane and footer
+	HistoryReservedLines = 20
+
+	// UndoReservedLines is the number of lines the undo screen keeps for its title and footer
+	UndoReservedLines = 8
+
+	// MigrateReservedLines is the number of lines the migration screen keeps for its title, note and footer
+	MigrateReservedLines = 9
+
+	// PreviewReservedLines is the number of lines the preview screen keeps for its title, warning and footer
+	PreviewReservedLines = 9
+
+	// OutputMaxLines is the number of command output lines kept for the live output pane
+	OutputMaxLines = 200
+
+	// OutputBufferLines is the number of output lines a command can print ahead of the TUI
+	OutputBufferLines = 64
+
+	// OutputPaneLines is the number of lines the live output pane shows while loading
+	OutputPaneLines = 10
+
+	// HistoryMaxChanges is the number of changes shown for the selected history record
+	HistoryMaxChanges = 8
 )
 
 // File Permissions
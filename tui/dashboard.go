@@ -1,12 +1,16 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
 
 	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/parser"
 	"github.com/eslam/depman/pkg/pip"
+	"github.com/eslam/depman/pkg/queue"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -20,9 +24,6 @@ type DashboardModel struct {
 	outdatedScroll  int
 	width           int
 	height          int
-	showConfirm     bool
-	confirmAction   string
-	confirmPkg      string
 	addMode         bool
 	addInput        string
 	waitingForG     bool
@@ -74,9 +75,6 @@ func ensureVisible(cursor, scroll, viewH int) int {
 func (d DashboardModel) Update(msg tea.Msg, state *AppState, runner *pip.Runner) (DashboardModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		if d.showConfirm {
-			return d.handleConfirm(msg, state, runner)
-		}
 		if d.addMode {
 			return d.handleAddMode(msg, state, runner)
 		}
@@ -99,7 +97,7 @@ func (d DashboardModel) Update(msg tea.Msg, state *AppState, runner *pip.Runner)
 		switch key {
 		case "j", "down":
 			if state.ActivePanel == PanelInstalled {
-				if d.installedCursor < len(state.Installed)-1 {
+				if d.installedCursor < len(state.VisibleInstalled())-1 {
 					d.installedCursor++
 				}
 			} else {
@@ -123,7 +121,7 @@ func (d DashboardModel) Update(msg tea.Msg, state *AppState, runner *pip.Runner)
 			d.waitingForG = true
 		case "G":
 			if state.ActivePanel == PanelInstalled {
-				d.installedCursor = max(0, len(state.Installed)-1)
+				d.installedCursor = max(0, len(state.VisibleInstalled())-1)
 			} else {
 				d.outdatedCursor = max(0, len(state.Outdated)-1)
 			}
@@ -131,7 +129,7 @@ func (d DashboardModel) Update(msg tea.Msg, state *AppState, runner *pip.Runner)
 		case "ctrl+d":
 			half := d.viewableHeight() / HalfPageDivisor
 			if state.ActivePanel == PanelInstalled {
-				d.installedCursor = min(d.installedCursor+half, max(0, len(state.Installed)-1))
+				d.installedCursor = min(d.installedCursor+half, max(0, len(state.VisibleInstalled())-1))
 			} else {
 				d.outdatedCursor = min(d.outdatedCursor+half, max(0, len(state.Outdated)-1))
 			}
@@ -150,6 +148,16 @@ func (d DashboardModel) Update(msg tea.Msg, state *AppState, runner *pip.Runner)
 			} else {
 				state.ActivePanel = PanelInstalled
 			}
+		case "[", "]":
+			// Cycle the group selector: every package, then each group
+			n := len(state.Groups) + 1
+			if key == "]" {
+				state.GroupIndex = (state.GroupIndex + 1) % n
+			} else {
+				state.GroupIndex = (state.GroupIndex + n - 1) % n
+			}
+			d.installedCursor = 0
+			d.installedScroll = 0
 
 		// Actions
 		case "a", "/", "s":
@@ -164,24 +172,37 @@ func (d DashboardModel) Update(msg tea.Msg, state *AppState, runner *pip.Runner)
 		case "d", "x":
 			pkg := d.selectedPackage(state)
 			if pkg != nil {
-				d.showConfirm = true
-				d.confirmAction = "remove"
-				d.confirmPkg = pkg.Name
+				name := pkg.Name
+				title := "Remove " + name
+				if g, ok := state.SelectedGroup(); ok {
+					title += " from " + g.String()
+				}
+				state.IsLoading = true
+				return d, previewAction(title, func() (pip.Preview, error) {
+					return runner.PreviewUninstall(name)
+				}, d.actionCmd("remove", name, state, runner))
 			}
 		case "u":
 			if state.ActivePanel == PanelOutdated {
 				pkg := d.selectedOutdated(state)
 				if pkg != nil {
-					d.showConfirm = true
-					d.confirmAction = "update"
-					d.confirmPkg = pkg.Name
+					name := pkg.Name
+					state.IsLoading = true
+					return d, previewAction("Update "+name, func() (pip.Preview, error) {
+						return runner.PreviewUpgrade(name)
+					}, d.actionCmd("update", name, state, runner))
 				}
 			}
 		case "U":
 			if len(state.Outdated) > 0 {
-				d.showConfirm = true
-				d.confirmAction = "update-all"
-				d.confirmPkg = fmt.Sprintf("%d packages", len(state.Outdated))
+				names := make([]string, len(state.Outdated))
+				for i, p := range state.Outdated {
+					names[i] = p.Name
+				}
+				state.IsLoading = true
+				return d, previewAction(fmt.Sprintf("Update %d packages", len(names)), func() (pip.Preview, error) {
+					return runner.PreviewUpgrade(names...)
+				}, d.actionCmd("update-all", "", state, runner))
 			}
 		}
 	}
@@ -197,82 +218,68 @@ func (d *DashboardModel) syncScroll(state *AppState) {
 	}
 }
 
-func (d DashboardModel) handleConfirm(msg tea.KeyMsg, state *AppState, runner *pip.Runner) (DashboardModel, tea.Cmd) {
-	switch msg.String() {
-	case "y", "enter":
-		d.showConfirm = false
-		state.IsLoading = true
-		action := d.confirmAction
-		pkg := d.confirmPkg
-		outdated := make([]pip.Package, len(state.Outdated))
-		copy(outdated, state.Outdated)
-		switch action {
-		case "remove":
-			return d, func() tea.Msg {
-				result := runner.Uninstall(pkg)
-				return PackageActionMsg{Action: "uninstalled", Package: pkg, Err: result.Err}
+// actionCmd returns the Cmd that performs a confirmed dashboard action:
+// "remove" or "update" of pkg, or "update-all".
+func (d DashboardModel) actionCmd(action, pkg string, state *AppState, runner *pip.Runner) tea.Cmd {
+	project := state.Project
+	lockFile := state.Config.Sync.LockFile
+	outdated := make([]pip.Package, len(state.Outdated))
+	copy(outdated, state.Outdated)
+	switch action {
+	case "remove":
+		group := state.GroupFor(pkg)
+		return func() tea.Msg {
+			err := runQueued(runner, pkg, func() pip.RunResult {
+				takeSnapshot(project, runner, "remove "+pkg, lockFile)
+				return runner.Remove(pkg, group.String())
+			})
+			if err == nil {
+				parser.NoteRemoved(project, pkg)
 			}
-		case "update":
-			return d, func() tea.Msg {
-				result := runner.Upgrade(pkg)
-				return PackageActionMsg{Action: "updated", Package: pkg, Err: result.Err}
-			}
-		case "update-all":
-			return d, func() tea.Msg {
-				var wg sync.WaitGroup
-				var mu sync.Mutex // Protect shared slices
-				var failed []string
-				succeeded := 0
-
-				// Create buffered channel to collect results
-				results := make(chan struct {
-					name string
-					err  error
-				}, len(outdated))
-
-				for _, p := range outdated {
-					wg.Add(1)
-					go func(pkgName string) {
-						defer wg.Done()
-						result := runner.Upgrade(pkgName)
-						results <- struct {
-							name string
-							err  error
-						}{pkgName, result.Err}
-					}(p.Name)
-				}
-
-				// Wait for all goroutines and close channel
-				go func() {
-					wg.Wait()
-					close(results)
-				}()
-
-				// Collect results
-				for r := range results {
-					if r.err != nil {
-						mu.Lock()
-						failed = append(failed, r.name)
-						mu.Unlock()
-					} else {
-						mu.Lock()
-						succeeded++
-						mu.Unlock()
-					}
-				}
+			return PackageActionMsg{Action: "uninstalled", Package: pkg, Changed: err == nil, Err: err}
+		}
+	case "update":
+		return func() tea.Msg {
+			err := runQueued(runner, pkg, func() pip.RunResult {
+				takeSnapshot(project, runner, "upgrade "+pkg, lockFile)
+				return runner.Upgrade(pkg)
+			})
+			return PackageActionMsg{Action: "updated", Package: pkg, Changed: err == nil, Err: err}
+		}
+	case "update-all":
+		// The snapshot is taken by whichever job runs first, once the
+		// queue is free, so it holds the state the batch starts from.
+		var snapshotOnce sync.Once
+		jobs := make([]queue.Job, len(outdated))
+		for i, p := range outdated {
+			jobs[i] = queue.Job{Name: p.Name, Run: func(ctx context.Context) error {
+				snapshotOnce.Do(func() { takeSnapshot(project, runner, "upgrade --all", lockFile) })
+				return runner.WithContext(ctx).Upgrade(p.Name).Err
+			}}
+		}
+		opts := queue.Options{StopOnError: state.Config.Queue.StopOnError}
+		return runBatch("Update all", runner, jobs, opts, updateAllResult)
+	}
+	return nil
+}
 
-				if len(failed) > 0 {
-					err := fmt.Errorf("packages: update failed: %s", strings.Join(failed, ", "))
-					msg := fmt.Sprintf("updated %d, failed %d", succeeded, len(failed))
-					return PackageActionMsg{Action: msg, Package: "", Err: err}
-				}
-				return PackageActionMsg{Action: fmt.Sprintf("updated %d", succeeded), Package: ""}
-			}
+// updateAllResult reports a finished update-all batch.
+func updateAllResult(r queue.Result) tea.Msg {
+	action := fmt.Sprintf("updated %d", len(r.Succeeded))
+	if len(r.Failed) > 0 {
+		action += fmt.Sprintf(", failed %d", len(r.Failed))
+	}
+	if len(r.Skipped) > 0 {
+		action += fmt.Sprintf(", skipped %d", len(r.Skipped))
+		if r.Cancelled {
+			action += " (cancelled)"
 		}
-	case "n", "esc", "q":
-		d.showConfirm = false
 	}
-	return d, nil
+	var err error
+	if len(r.Failed) > 0 {
+		err = fmt.Errorf("packages: update failed: %s", strings.Join(r.FailedNames(), ", "))
+	}
+	return PackageActionMsg{Action: action, Changed: len(r.Succeeded) > 0, Err: err}
 }
 
 func (d DashboardModel) handleAddMode(msg tea.KeyMsg, state *AppState, runner *pip.Runner) (DashboardModel, tea.Cmd) {
@@ -285,11 +292,22 @@ func (d DashboardModel) handleAddMode(msg tea.KeyMsg, state *AppState, runner *p
 			d.addMode = false
 			pkg := d.addInput
 			d.addInput = ""
+			project := state.Project
+			group := state.GroupFor(pkg)
+			lockFile := state.Config.Sync.LockFile
 			state.IsLoading = true
-			return d, func() tea.Msg {
-				result := runner.Install(pkg)
-				return PackageActionMsg{Action: "installed", Package: pkg, Err: result.Err}
-			}
+			return d, previewAction("Install "+pkg, func() (pip.Preview, error) {
+				return runner.PreviewInstall(pkg)
+			}, func() tea.Msg {
+				err := runQueued(runner, pkg, func() pip.RunResult {
+					takeSnapshot(project, runner, "add "+pkg, lockFile)
+					return runner.Add(pkg, group.String())
+				})
+				if err == nil {
+					parser.NoteAdded(project, pkg, group)
+				}
+				return PackageActionMsg{Action: "installed", Package: pkg, Changed: err == nil, Err: err}
+			})
 		}
 	case "backspace":
 		if len(d.addInput) > 0 {
@@ -313,12 +331,12 @@ func (d DashboardModel) View(state AppState) string {
 	}
 
 	if state.IsLoading {
-		return d.renderLoading(w, h)
+		return d.renderLoading(state, w, h)
 	}
 
 	// Reserve lines: 1 status bar + 1 overlay (optional)
 	overlayLines := 0
-	if d.showConfirm || d.addMode {
+	if d.addMode {
 		overlayLines = 1
 	}
 
@@ -340,9 +358,7 @@ func (d DashboardModel) View(state AppState) string {
 	statusBar := d.renderStatusBar(state, w)
 
 	var overlay string
-	if d.showConfirm {
-		overlay = d.renderConfirmDialog(w)
-	} else if d.addMode {
+	if d.addMode {
 		overlay = d.renderAddInput(w)
 	}
 
@@ -366,19 +382,20 @@ func (d DashboardModel) renderInstalledPanel(state AppState, width, height int)
 		Width(width).
 		Height(height)
 
-	titleStr := fmt.Sprintf("Installed (%d)", len(state.Installed))
+	installed := state.VisibleInstalled()
+	titleStr := fmt.Sprintf("Installed (%d)", len(installed))
 	title := lipgloss.NewStyle().Bold(true).Foreground(config.ColorFG).Render(titleStr)
 
 	var lines []string
 	lines = append(lines, title)
-	lines = append(lines, "")
+	lines = append(lines, d.renderGroupSelector(state))
 
 	// Apply viewport scrolling
 	viewH := d.viewableHeight()
 	scrollStart := d.installedScroll
 	scrollEnd := scrollStart + viewH
-	if scrollEnd > len(state.Installed) {
-		scrollEnd = len(state.Installed)
+	if scrollEnd > len(installed) {
+		scrollEnd = len(installed)
 	}
 
 	// Scroll indicator at top
@@ -386,10 +403,14 @@ func (d DashboardModel) renderInstalledPanel(state AppState, width, height int)
 		lines = append(lines, lipgloss.NewStyle().Foreground(config.ColorFGDim).Render("  ↑ more"))
 	}
 
+	constraints := state.Constraints()
 	for i := scrollStart; i < scrollEnd; i++ {
-		p := state.Installed[i]
+		p := installed[i]
 		name := lipgloss.NewStyle().Foreground(config.ColorPurple).Render(p.Name)
 		ver := lipgloss.NewStyle().Foreground(config.ColorCyan).Render(p.InstalledVersion)
+		if c := constraints[parser.NormalizeName(p.Name)]; c != "" {
+			ver += " " + lipgloss.NewStyle().Foreground(config.ColorFGDim).Render(c)
+		}
 
 		if focused && i == d.installedCursor {
 			indicator := lipgloss.NewStyle().Foreground(config.ColorBlue).Render("▶ ")
@@ -402,17 +423,32 @@ func (d DashboardModel) renderInstalledPanel(state AppState, width, height int)
 	}
 
 	// Scroll indicator at bottom
-	if scrollEnd < len(state.Installed) {
+	if scrollEnd < len(installed) {
 		lines = append(lines, lipgloss.NewStyle().Foreground(config.ColorFGDim).Render("  ↓ more"))
 	}
 
-	if len(state.Installed) == 0 {
+	if len(installed) == 0 {
 		lines = append(lines, lipgloss.NewStyle().Foreground(config.ColorFGDim).Render("  No packages installed"))
 	}
 
 	return style.Render(strings.Join(lines, "\n"))
 }
 
+// renderGroupSelector shows which dependency group filters the installed
+// panel; [ and ] cycle through them.
+func (d DashboardModel) renderGroupSelector(state AppState) string {
+	if len(state.Groups) == 0 {
+		return ""
+	}
+	name := "all"
+	if g, ok := state.SelectedGroup(); ok {
+		name = g.String()
+	}
+	selected := lipgloss.NewStyle().Foreground(config.ColorYellow).Render(name)
+	return lipgloss.NewStyle().Foreground(config.ColorFGDim).
+		Render(fmt.Sprintf("◂ %s ▸ %d/%d", selected, state.GroupIndex+1, len(state.Groups)+1))
+}
+
 func (d DashboardModel) renderOutdatedPanel(state AppState, width, height int) string {
 	focused := state.ActivePanel == PanelOutdated
 	borderColor := config.ColorBorder
@@ -489,6 +525,17 @@ func (d DashboardModel) renderStatusBar(state AppState, w int) string {
 		mgrStyle = lipgloss.NewStyle().Foreground(config.ColorFGDim)
 	}
 	mgr := mgrStyle.Render(state.Manager.String())
+	switch {
+	case state.Project.Managed():
+		// The tool owns the dependency file and lock file
+		mgr += lipgloss.NewStyle().Foreground(config.ColorFGDim).Render(" · " + state.Project.Tool.LockFile())
+	case state.Project.Tool != detector.ToolNone:
+		mgr += lipgloss.NewStyle().Foreground(config.ColorFGDim).Render(" · " + state.Project.Tool.String())
+	case state.Project.ReadOnly():
+		mgr += lipgloss.NewStyle().Foreground(config.ColorFGDim).Render(" · " + state.Project.FileType.String() + " (read-only, M to migrate)")
+	case setuptoolsProject(state.Project):
+		mgr += lipgloss.NewStyle().Foreground(config.ColorFGDim).Render(" · " + state.Project.FileType.String() + " (M to migrate)")
+	}
 
 	pkgCount := fmt.Sprintf("%d pkgs", len(state.Installed))
 
@@ -506,18 +553,15 @@ func (d DashboardModel) renderStatusBar(state AppState, w int) string {
 		status = " │ " + lipgloss.NewStyle().Foreground(config.ColorOrange).Render(status)
 	}
 
-	bar := fmt.Sprintf(" depman │ %s │ %s │ %s │ %s │ %s%s",
-		venvName, mgr, pkgCount, outdatedCount, help, status)
+	syncStatus := state.SyncStatus
+	if syncStatus != "" {
+		syncStatus = " │ " + lipgloss.NewStyle().Foreground(config.ColorRed).Render(syncStatus)
+	}
 
-	return style.Render(bar)
-}
+	bar := fmt.Sprintf(" depman │ %s │ %s │ %s │ %s │ %s%s%s",
+		venvName, mgr, pkgCount, outdatedCount, help, status, syncStatus)
 
-func (d DashboardModel) renderConfirmDialog(w int) string {
-	style := lipgloss.NewStyle().
-		Foreground(config.ColorYellow).
-		Width(w).
-		Padding(0, 1)
-	return style.Render(fmt.Sprintf("  %s %s? [y/N] ", d.confirmAction, d.confirmPkg))
+	return style.Render(bar)
 }
 
 func (d DashboardModel) renderAddInput(w int) string {
@@ -529,22 +573,100 @@ func (d DashboardModel) renderAddInput(w int) string {
 	return style.Render(fmt.Sprintf("  Add package: %s%s", d.addInput, cursor))
 }
 
-func (d DashboardModel) renderLoading(w, h int) string {
+func (d DashboardModel) renderLoading(state AppState, w, h int) string {
 	style := lipgloss.NewStyle().
 		Foreground(config.ColorFGDim).
 		Width(w).
 		Height(h).
 		Align(lipgloss.Center, lipgloss.Center)
-	return style.Render("Loading packages...")
+	title := "Loading packages..."
+	if state.Job != nil {
+		title = lipgloss.NewStyle().Foreground(config.ColorBlue).Render(state.Job.String())
+	}
+	hint := "Esc to cancel"
+	if state.Job != nil && state.Job.Cancelling {
+		hint = "Cancelling..."
+	}
+	if len(state.Output) == 0 {
+		return style.Render(title + "\n\n" + hint)
+	}
+	return style.Render(lipgloss.JoinVertical(lipgloss.Center, title, "", d.renderOutput(state, w), "", hint))
+}
+
+// renderOutput renders the live output pane: the latest lines printed by
+// the running command.
+func (d DashboardModel) renderOutput(state AppState, w int) string {
+	paneWidth := max(MinPanelWidth, w-2*InitPanelPadding-PanelBorderLines)
+	lines := state.Output[max(0, len(state.Output)-OutputPaneLines):]
+	var b strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(truncate(l, paneWidth-2))
+	}
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(config.ColorBorder).
+		Foreground(config.ColorFG).
+		Width(paneWidth).
+		Align(lipgloss.Left).
+		Render(b.String())
 }
 
 func (d DashboardModel) selectedPackage(state *AppState) *pip.Package {
-	if state.ActivePanel == PanelInstalled && len(state.Installed) > 0 && d.installedCursor < len(state.Installed) {
-		return &state.Installed[d.installedCursor]
+	installed := state.VisibleInstalled()
+	if state.ActivePanel == PanelInstalled && len(installed) > 0 && d.installedCursor < len(installed) {
+		return &installed[d.installedCursor]
 	}
 	return nil
 }
 
+// VisibleInstalled returns the installed packages shown in the dashboard:
+// all of them, or those declared in the selected group.
+func (s AppState) VisibleInstalled() []pip.Package {
+	if _, ok := s.SelectedGroup(); !ok {
+		return s.Installed
+	}
+	declared := make(map[string]bool)
+	for _, r := range s.Groups[s.GroupIndex-1].Requirements {
+		declared[r.Key()] = true
+	}
+	var out []pip.Package
+	for _, p := range s.Installed {
+		if declared[parser.NormalizeName(p.Name)] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Constraints returns the declared version constraint of each package, by
+// normalized name, in the dependency file's own notation, e.g. "^2.31" in
+// a Poetry project.
+func (s AppState) Constraints() map[string]string {
+	constraints := make(map[string]string)
+	for _, r := range parser.AllRequirements(s.Groups) {
+		if c := r.ConstraintString(); c != "" && constraints[r.Key()] == "" {
+			constraints[r.Key()] = c
+		}
+	}
+	return constraints
+}
+
+// SetGroups replaces the dependency groups, keeping the selected group
+// selected if it still exists.
+func (s *AppState) SetGroups(groups []parser.DependencyGroup) {
+	selected, ok := s.SelectedGroup()
+	s.Groups = groups
+	s.GroupIndex = 0
+	if ok {
+		if i := parser.FindGroup(groups, selected); i >= 0 {
+			s.GroupIndex = i + 1
+		}
+	}
+}
+
 func (d DashboardModel) selectedOutdated(state *AppState) *pip.Package {
 	if len(state.Outdated) > 0 && d.outdatedCursor < len(state.Outdated) {
 		return &state.Outdated[d.outdatedCursor]
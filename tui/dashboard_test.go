@@ -0,0 +1,87 @@
+package tui
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/env"
+	"github.com/eslam/depman/pkg/parser"
+	"github.com/eslam/depman/pkg/pip"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestDashboard_UpdateAll(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	backend := pip.NewFakeBackend(map[string]string{"click": "8.1.0", "requests": "2.30.0", "rich": "13.0.0"})
+	backend.Latest = map[string]string{"click": "8.1.7", "requests": "2.31.0", "rich": "13.7.0"}
+	backend.Fail["upgrade requests"] = errors.New("no matching distribution")
+	runner := pip.NewBackendRunner(backend, env.Virtualenv{})
+
+	state := NewAppState(detector.Project{Dir: t.TempDir()}, env.Virtualenv{}, env.PackageManager{}, config.DefaultConfig())
+	outdated, err := pip.ParseOutdatedList(runner.Outdated().Stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Outdated = outdated
+
+	d := NewDashboardModel(state)
+	_, cmd := d.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("U")}, &state, runner)
+	if cmd == nil || !state.IsLoading {
+		t.Fatal("U did not start a preview")
+	}
+	preview, ok := cmd().(PreviewMsg)
+	if !ok || preview.Err != nil {
+		t.Fatalf("U produced %#v, want a PreviewMsg", preview)
+	}
+	if got := preview.Preview.Summary(); got != "3 to upgrade" {
+		t.Errorf("preview summary = %q, want 3 to upgrade", got)
+	}
+
+	// Apply the preview and follow the batch to its end
+	msg := preview.Apply()
+	started, ok := msg.(JobStartedMsg)
+	if !ok {
+		t.Fatalf("Apply() = %#v, want a JobStartedMsg", msg)
+	}
+	var result PackageActionMsg
+	for result.Action == "" {
+		switch msg := waitForJob(started.events)().(type) {
+		case JobProgressMsg:
+		case PackageActionMsg:
+			result = msg
+		default:
+			t.Fatalf("unexpected batch event %#v", msg)
+		}
+	}
+	if result.Action != "updated 2, failed 1" || !result.Changed || result.Err == nil {
+		t.Errorf("batch result = %+v", result)
+	}
+
+	expected := map[string]string{"click": "8.1.7", "requests": "2.30.0", "rich": "13.7.0"}
+	if !reflect.DeepEqual(backend.Installed, expected) {
+		t.Errorf("installed = %v, want %v", backend.Installed, expected)
+	}
+	calls := backend.Calls()
+	upgrades := calls[len(calls)-3:]
+	if want := []string{"upgrade click", "upgrade requests", "upgrade rich"}; !reflect.DeepEqual(upgrades, want) {
+		t.Errorf("calls = %v, want the upgrades in order at the end", calls)
+	}
+}
+
+func TestAppState_Constraints(t *testing.T) {
+	state := AppState{Groups: parser.ParsePoetryGroups(`[tool.poetry.dependencies]
+requests = "^2.31"
+
+[tool.poetry.group.dev.dependencies]
+pytest = ">=8"
+Requests = "~2.32"
+`)}
+	expected := map[string]string{"requests": "^2.31", "pytest": ">=8"}
+	if got := state.Constraints(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Constraints() = %v, want %v", got, expected)
+	}
+}
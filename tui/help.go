@@ -71,6 +71,7 @@ func (hm HelpModel) View(state AppState) string {
 		{"Ctrl+d", "Half-page down"},
 		{"Ctrl+u", "Half-page up"},
 		{"Tab", "Switch panel"},
+		{"[ / ]", "Previous / next dependency group"},
 	}
 	for _, bind := range nav {
 		b.WriteString(keyStyle.Render(bind.key))
@@ -85,8 +86,9 @@ func (hm HelpModel) View(state AppState) string {
 		{"d / x", "Remove selected package"},
 		{"u", "Update selected package"},
 		{"U", "Update all outdated"},
+		{"Ctrl+z", "Undo the last change"},
 		{"/ or s", "Search PyPI"},
-		{"Enter", "Confirm action"},
+		{"Enter / y", "Apply the previewed changes"},
 		{"Esc", "Cancel / go back"},
 	}
 	for _, act := range actions {
@@ -99,6 +101,9 @@ func (hm HelpModel) View(state AppState) string {
 	b.WriteString("\n")
 	general := []struct{ key, desc string }{
 		{"?", "Toggle help"},
+		{"L", "Toggle log viewer"},
+		{"H", "Toggle history of package changes"},
+		{"M", "Migrate setup.cfg / setup.py to pyproject.toml"},
 		{"q", "Quit"},
 		{"Ctrl+c", "Force quit"},
 	}
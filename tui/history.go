@@ -0,0 +1,256 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/audit"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// HistoryLoadedMsg is sent when the audit journal has been read.
+type HistoryLoadedMsg struct {
+	Records []audit.Record // newest first
+	Err     error
+}
+
+// HistoryModel is the history screen, which browses the audit journal of
+// package and dependency file changes.
+type HistoryModel struct {
+	records  []audit.Record // newest first
+	cursor   int
+	all      bool // show every project, not just the current one
+	loading  bool
+	err      error
+	pendingG bool
+}
+
+// NewHistoryModel creates a new history screen.
+func NewHistoryModel() HistoryModel {
+	return HistoryModel{}
+}
+
+// load returns a Cmd that reads the journal.
+func (h HistoryModel) load(state AppState) tea.Cmd {
+	project := ""
+	if !h.all {
+		project, _ = filepath.Abs(state.Project.Dir)
+	}
+	return func() tea.Msg {
+		path, err := audit.DefaultPath()
+		if err != nil {
+			return HistoryLoadedMsg{Err: err}
+		}
+		records, _, err := audit.Read(path)
+		if err != nil {
+			return HistoryLoadedMsg{Err: err}
+		}
+		records = audit.Filter{Project: project}.Apply(records)
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+		return HistoryLoadedMsg{Records: records}
+	}
+}
+
+// Open resets the screen and starts loading the journal.
+func (h HistoryModel) Open(state AppState) (HistoryModel, tea.Cmd) {
+	h = HistoryModel{all: h.all, loading: true}
+	return h, h.load(state)
+}
+
+func (h HistoryModel) Update(msg tea.Msg, state *AppState) (HistoryModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case HistoryLoadedMsg:
+		h.loading = false
+		h.records, h.err = msg.Records, msg.Err
+		h.cursor = min(h.cursor, max(0, len(h.records)-1))
+		return h, nil
+
+	case tea.KeyMsg:
+		if msg.String() != "g" {
+			h.pendingG = false
+		}
+		page := max(1, h.visibleRows(*state))
+		switch msg.String() {
+		case "j", "down":
+			h.cursor++
+		case "k", "up":
+			h.cursor--
+		case "ctrl+d":
+			h.cursor += page / HalfPageDivisor
+		case "ctrl+u":
+			h.cursor -= page / HalfPageDivisor
+		case "g":
+			if h.pendingG {
+				h.cursor = 0
+				h.pendingG = false
+			} else {
+				h.pendingG = true
+			}
+		case "G":
+			h.cursor = len(h.records) - 1
+		case "a":
+			h.all = !h.all
+			return h.Open(*state)
+		case "r":
+			h.loading = true
+			return h, h.load(*state)
+		}
+		h.cursor = max(0, min(h.cursor, len(h.records)-1))
+	}
+	return h, nil
+}
+
+// visibleRows returns how many records fit above the detail pane.
+func (h HistoryModel) visibleRows(state AppState) int {
+	th := state.Height
+	if th == 0 {
+		th = DefaultHeight
+	}
+	return max(MinVisibleResults, th-HistoryReservedLines)
+}
+
+func (h HistoryModel) View(state AppState) string {
+	tw := state.Width
+	if tw == 0 {
+		tw = DefaultWidth
+	}
+	th := state.Height
+	if th == 0 {
+		th = DefaultHeight
+	}
+
+	container := lipgloss.NewStyle().
+		Width(tw).
+		Height(th).
+		Padding(1, 2)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(config.ColorBlue)
+
+	dimStyle := lipgloss.NewStyle().
+		Foreground(config.ColorFGDim)
+
+	selectedStyle := lipgloss.NewStyle().
+		Background(config.ColorBGHighlight).
+		Foreground(config.ColorFG)
+
+	var b strings.Builder
+	scope := "this project"
+	if h.all {
+		scope = "all projects"
+	}
+	b.WriteString(titleStyle.Render("depman — History"))
+	b.WriteString(dimStyle.Render("  " + scope))
+	b.WriteString("\n\n")
+
+	switch {
+	case h.loading:
+		b.WriteString(dimStyle.Render("Loading…"))
+		b.WriteString("\n")
+	case h.err != nil:
+		b.WriteString(lipgloss.NewStyle().Foreground(config.ColorRed).Render("Failed to read history: " + h.err.Error()))
+		b.WriteString("\n")
+	case len(h.records) == 0:
+		b.WriteString(dimStyle.Render("No recorded operations."))
+		b.WriteString("\n")
+	default:
+		width := tw - InitPanelPadding*2
+		rows := h.visibleRows(state)
+		start := max(0, min(h.cursor-rows/2, len(h.records)-rows))
+		end := min(len(h.records), start+rows)
+		for i := start; i < end; i++ {
+			line := truncate(h.renderRow(h.records[i]), width)
+			if i == h.cursor {
+				b.WriteString(selectedStyle.Render(line))
+			} else {
+				b.WriteString(line)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+		b.WriteString(h.renderDetail(h.records[h.cursor], width))
+	}
+
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render("j/k move · a all projects · r reload · H or Esc to close"))
+
+	return container.Render(b.String())
+}
+
+// renderRow renders one record as a plain single line.
+func (h HistoryModel) renderRow(r audit.Record) string {
+	mark := "✓"
+	if !r.OK() {
+		mark = "✗"
+	}
+	target := r.Target
+	if r.Action == audit.ActionRewrite || r.Action == audit.ActionRestore || r.Action == audit.ActionSync || r.Action == audit.ActionVenvCreate || r.Action == audit.ActionVenvRecreate {
+		target = filepath.Base(target)
+	}
+	row := fmt.Sprintf("%s %s %-13s %s", mark, r.Time.Local().Format(time.DateTime), r.Action, target)
+	if h.all {
+		row += "  (" + filepath.Base(r.Project) + ")"
+	}
+	return row
+}
+
+// renderDetail renders the selected record's command, outcome and changes.
+func (h HistoryModel) renderDetail(r audit.Record, width int) string {
+	labelStyle := lipgloss.NewStyle().Foreground(config.ColorCyan).Width(10)
+	valueStyle := lipgloss.NewStyle().Foreground(config.ColorFG)
+
+	var b strings.Builder
+	field := func(label, value string) {
+		if value == "" {
+			return
+		}
+		b.WriteString(labelStyle.Render(label))
+		b.WriteString(valueStyle.Render(truncate(value, max(0, width-10))))
+		b.WriteString("\n")
+	}
+	field("project", r.Project)
+	field("venv", r.Venv)
+	field("command", strings.Join(r.Argv, " "))
+	status := "ok"
+	if !r.OK() {
+		status = fmt.Sprintf("failed (exit %d)", r.ExitCode)
+	}
+	field("status", status+", "+(time.Duration(r.DurationMS)*time.Millisecond).String())
+	field("error", r.Error)
+
+	limit := HistoryMaxChanges
+	for i, c := range r.Changes {
+		if i == limit {
+			b.WriteString(lipgloss.NewStyle().Foreground(config.ColorFGDim).Render(fmt.Sprintf("  … %d more", len(r.Changes)-limit)))
+			b.WriteString("\n")
+			break
+		}
+		b.WriteString(renderChange(c))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// renderChange renders one version change, colored by direction.
+func renderChange(c audit.Change) string {
+	name := c.Name
+	if c.Group != "" {
+		name = c.Group + " " + name
+	}
+	switch {
+	case c.Before == "":
+		return lipgloss.NewStyle().Foreground(config.ColorGreen).Render("  + " + name + " " + c.After)
+	case c.After == "":
+		return lipgloss.NewStyle().Foreground(config.ColorRed).Render("  - " + name + " " + c.Before)
+	default:
+		return lipgloss.NewStyle().Foreground(config.ColorYellow).Render("  ~ " + name + " " + c.Before + " → " + c.After)
+	}
+}
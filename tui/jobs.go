@@ -0,0 +1,89 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eslam/depman/pkg/pip"
+	"github.com/eslam/depman/pkg/queue"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// JobStartedMsg is sent when a batch of package operations starts on the
+// environment's queue. Its events deliver a JobProgressMsg before each
+// operation and finally the batch's PackageActionMsg.
+type JobStartedMsg struct {
+	Title  string
+	Cancel context.CancelFunc
+	events <-chan tea.Msg
+}
+
+// JobProgressMsg reports the progress of the running batch.
+type JobProgressMsg struct {
+	queue.Progress
+}
+
+// OutputLineMsg carries a line printed by the running package manager
+// command.
+type OutputLineMsg pip.OutputLine
+
+// waitForOutput returns a Cmd that delivers the next line printed by a
+// command of the runner that writes to output.
+func waitForOutput(output <-chan pip.OutputLine) tea.Cmd {
+	return func() tea.Msg {
+		return OutputLineMsg(<-output)
+	}
+}
+
+// JobStatus is the batch shown while the dashboard is busy.
+type JobStatus struct {
+	Title      string
+	Progress   queue.Progress
+	Cancelling bool
+}
+
+// runBatch returns a Cmd that starts jobs on the runner's queue in the
+// background. done turns the batch's result into its PackageActionMsg.
+func runBatch(title string, runner *pip.Runner, jobs []queue.Job, opts queue.Options, done func(queue.Result) tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithCancel(context.Background())
+		events := make(chan tea.Msg)
+		opts.Progress = func(p queue.Progress) {
+			events <- JobProgressMsg{Progress: p}
+		}
+		go func() {
+			defer cancel()
+			events <- done(runner.Queue().Run(ctx, jobs, opts))
+		}()
+		return JobStartedMsg{Title: title, Cancel: cancel, events: events}
+	}
+}
+
+// waitForJob returns a Cmd that delivers the next event of a batch.
+func waitForJob(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+// runQueued runs a single package operation on the runner's queue, so it
+// waits for any batch still running in the same environment.
+func runQueued(runner *pip.Runner, name string, op func() pip.RunResult) error {
+	return runner.Queue().Do(context.Background(), queue.Job{Name: name, Run: func(context.Context) error {
+		return op().Err
+	}})
+}
+
+// String describes the batch, e.g. "Update all 3/12: requests".
+func (j JobStatus) String() string {
+	p := j.Progress
+	s := fmt.Sprintf("%s %d/%d", j.Title, min(p.Done+1, p.Total), p.Total)
+	if p.Current != "" {
+		s += ": " + p.Current
+	}
+	if p.Failed > 0 {
+		s += fmt.Sprintf(" (%d failed)", p.Failed)
+	}
+	return s
+}
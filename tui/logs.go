@@ -0,0 +1,164 @@
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/log"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// LogsRefreshInterval is how often the log viewer re-reads recent entries.
+const LogsRefreshInterval = time.Second
+
+// logsTickMsg refreshes the log viewer while it is open.
+type logsTickMsg struct{}
+
+func logsTick() tea.Cmd {
+	return tea.Tick(LogsRefreshInterval, func(time.Time) tea.Msg { return logsTickMsg{} })
+}
+
+// LogsModel is the log viewer screen. It tails the most recent log entries
+// of this session; the full history is in the log file.
+type LogsModel struct {
+	offset   int  // entries scrolled up from the newest
+	pendingG bool // waiting for the second g of gg
+}
+
+// NewLogsModel creates a new log viewer.
+func NewLogsModel() LogsModel {
+	return LogsModel{}
+}
+
+func (l LogsModel) Update(msg tea.Msg, state *AppState) (LogsModel, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return l, nil
+	}
+	page := l.visibleLines(*state)
+	total := len(log.Recent(0))
+
+	if key.String() != "g" {
+		l.pendingG = false
+	}
+	switch key.String() {
+	case "k", "up":
+		l.offset++
+	case "j", "down":
+		l.offset--
+	case "ctrl+u":
+		l.offset += page / HalfPageDivisor
+	case "ctrl+d":
+		l.offset -= page / HalfPageDivisor
+	case "g":
+		if l.pendingG {
+			l.offset = total
+			l.pendingG = false
+		} else {
+			l.pendingG = true
+		}
+	case "G":
+		l.offset = 0
+	}
+	l.offset = max(0, min(l.offset, total-page))
+	return l, nil
+}
+
+// visibleLines returns how many entries fit on screen.
+func (l LogsModel) visibleLines(state AppState) int {
+	th := state.Height
+	if th == 0 {
+		th = DefaultHeight
+	}
+	return max(ViewportMinHeight, th-LogsHeaderLines)
+}
+
+func (l LogsModel) View(state AppState) string {
+	tw := state.Width
+	if tw == 0 {
+		tw = DefaultWidth
+	}
+	th := state.Height
+	if th == 0 {
+		th = DefaultHeight
+	}
+
+	container := lipgloss.NewStyle().
+		Width(tw).
+		Height(th).
+		Padding(1, 2)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(config.ColorBlue)
+
+	dimStyle := lipgloss.NewStyle().
+		Foreground(config.ColorFGDim)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("depman — Logs"))
+	b.WriteString("\n")
+	if path := log.Path(); path != "" {
+		b.WriteString(dimStyle.Render(path))
+	} else {
+		b.WriteString(dimStyle.Render("not writing a log file"))
+	}
+	b.WriteString("\n\n")
+
+	page := l.visibleLines(state)
+	entries := log.Recent(0)
+	end := max(0, len(entries)-l.offset)
+	start := max(0, end-page)
+	if len(entries) == 0 {
+		b.WriteString(dimStyle.Render("No log entries yet. Set log_level = \"debug\" for more detail."))
+		b.WriteString("\n")
+	}
+	width := tw - InitPanelPadding*2
+	for _, e := range entries[start:end] {
+		b.WriteString(renderLogEntry(e, width))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	status := fmt.Sprintf("%d–%d of %d", start+min(1, end), end, len(entries))
+	if l.offset == 0 {
+		status += " · following"
+	}
+	b.WriteString(dimStyle.Render(status + " · j/k scroll · G follow · L or Esc to close"))
+
+	return container.Render(b.String())
+}
+
+// renderLogEntry renders one entry on a single line, truncated to width.
+func renderLogEntry(e log.Entry, width int) string {
+	levelStyle := lipgloss.NewStyle().Width(6)
+	switch {
+	case e.Level >= slog.LevelError:
+		levelStyle = levelStyle.Foreground(config.ColorRed).Bold(true)
+	case e.Level >= slog.LevelWarn:
+		levelStyle = levelStyle.Foreground(config.ColorYellow)
+	case e.Level >= slog.LevelInfo:
+		levelStyle = levelStyle.Foreground(config.ColorBlue)
+	default:
+		levelStyle = levelStyle.Foreground(config.ColorFGDim)
+	}
+	timeStyle := lipgloss.NewStyle().Foreground(config.ColorFGDim)
+	msgStyle := lipgloss.NewStyle().Foreground(config.ColorFG)
+	attrStyle := lipgloss.NewStyle().Foreground(config.ColorCyan)
+
+	stamp := e.Time.Format("15:04:05")
+	level := e.Level.String()
+	// Plain-text budget: time, space, level column, message, attributes
+	room := width - len(stamp) - 1 - 6
+	msg := truncate(e.Message, max(0, room))
+	attrs := ""
+	if rest := room - len(msg) - 1; e.Attrs != "" && rest > 0 {
+		attrs = " " + truncate(e.Attrs, rest)
+	}
+	return timeStyle.Render(stamp) + " " + levelStyle.Render(level) + msgStyle.Render(msg) + attrStyle.Render(attrs)
+}
@@ -0,0 +1,161 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/detector"
+	"github.com/eslam/depman/pkg/parser"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// MigrationPlanMsg is sent when the pyproject.toml equivalent to a
+// setuptools project has been generated.
+type MigrationPlanMsg struct {
+	Migration parser.Migration
+	Err       error
+}
+
+// MigratedMsg is sent when the migrated pyproject.toml has been written.
+// Project is the project detected afterwards.
+type MigratedMsg struct {
+	Project detector.Project
+	Err     error
+}
+
+// MigrateModel is the migration screen, which previews the pyproject.toml
+// that replaces a setup.cfg or setup.py, with anything that could not be
+// translated, and writes it on confirmation.
+type MigrateModel struct {
+	migration parser.Migration
+	from      string // the setuptools file migrated from
+	scroll    int
+}
+
+// NewMigrateModel creates a migration screen for migration.
+func NewMigrateModel(migration parser.Migration, from string) MigrateModel {
+	return MigrateModel{migration: migration, from: from}
+}
+
+// setuptoolsProject reports whether project declares its dependencies in
+// setup.cfg or setup.py, which can be migrated to pyproject.toml.
+func setuptoolsProject(project detector.Project) bool {
+	return project.FileType == detector.FileSetupCfg || project.FileType == detector.FileSetupPy
+}
+
+// planMigration returns a Cmd that generates the migration of project.
+func planMigration(project detector.Project) tea.Cmd {
+	return func() tea.Msg {
+		migration, err := parser.MigrateProject(project, parser.ToPyproject)
+		return MigrationPlanMsg{Migration: migration, Err: err}
+	}
+}
+
+func (mm MigrateModel) Update(msg tea.Msg, state *AppState) (MigrateModel, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return mm, nil
+	}
+	switch key.String() {
+	case "j", "down":
+		mm.scroll = min(mm.scroll+1, max(0, len(mm.lines())-mm.visibleLines(*state)))
+	case "k", "up":
+		mm.scroll = max(0, mm.scroll-1)
+	case "y", "enter":
+		migration, dir := mm.migration, state.Project.Dir
+		state.Screen = ScreenDashboard
+		return mm, func() tea.Msg {
+			if err := migration.Write(); err != nil {
+				return MigratedMsg{Err: err}
+			}
+			return MigratedMsg{Project: detector.DetectProject(dir)}
+		}
+	case "n", "esc", "q":
+		state.Screen = ScreenDashboard
+	}
+	return mm, nil
+}
+
+func (mm MigrateModel) visibleLines(state AppState) int {
+	th := state.Height
+	if th == 0 {
+		th = DefaultHeight
+	}
+	return max(ViewportMinHeight, th-MigrateReservedLines)
+}
+
+// lines renders the new pyproject.toml, with TOML headers highlighted,
+// then the notes.
+func (mm MigrateModel) lines() []string {
+	header := lipgloss.NewStyle().Bold(true).Foreground(config.ColorCyan)
+	add := lipgloss.NewStyle().Foreground(config.ColorGreen)
+	warn := lipgloss.NewStyle().Foreground(config.ColorYellow)
+
+	var out []string
+	for _, f := range mm.migration.Files {
+		for _, l := range strings.Split(strings.TrimRight(f.Content, "\n"), "\n") {
+			if strings.HasPrefix(l, "[") {
+				out = append(out, header.Render("  "+l))
+			} else {
+				out = append(out, add.Render("  "+l))
+			}
+		}
+	}
+	if len(mm.migration.Notes) > 0 {
+		out = append(out, "", header.Render("Not translated"))
+	}
+	for _, n := range mm.migration.Notes {
+		out = append(out, warn.Render("  ! "+n))
+	}
+	return out
+}
+
+func (mm MigrateModel) View(state AppState) string {
+	tw := state.Width
+	if tw == 0 {
+		tw = DefaultWidth
+	}
+	th := state.Height
+	if th == 0 {
+		th = DefaultHeight
+	}
+
+	container := lipgloss.NewStyle().
+		Width(tw).
+		Height(th).
+		Padding(1, 2)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(config.ColorBlue)
+
+	dimStyle := lipgloss.NewStyle().
+		Foreground(config.ColorFGDim)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("depman — Migrate " + mm.from + " to pyproject.toml"))
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render("A [project] table declares the same metadata and dependencies"))
+	b.WriteString("\n\n")
+
+	lines := mm.lines()
+	end := min(len(lines), mm.scroll+mm.visibleLines(state))
+	for _, l := range lines[mm.scroll:end] {
+		b.WriteString(l)
+		b.WriteString("\n")
+	}
+	if end < len(lines) {
+		b.WriteString(dimStyle.Render(fmt.Sprintf("  … %d more lines (j/k to scroll)", len(lines)-end)))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render(mm.from + " is kept: remove its install_requires and extras_require once you've checked the result"))
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render("y/Enter write pyproject.toml · n/Esc cancel"))
+
+	return container.Render(b.String())
+}
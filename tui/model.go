@@ -1,12 +1,19 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
+
 	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/audit"
 	"github.com/eslam/depman/pkg/detector"
 	"github.com/eslam/depman/pkg/env"
 	"github.com/eslam/depman/pkg/log"
+	"github.com/eslam/depman/pkg/parser"
 	"github.com/eslam/depman/pkg/pip"
+	"github.com/eslam/depman/pkg/snapshot"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -19,6 +26,11 @@ const (
 	ScreenDashboard
 	ScreenSearch
 	ScreenHelp
+	ScreenLogs
+	ScreenHistory
+	ScreenUndo
+	ScreenPreview
+	ScreenMigrate
 )
 
 // Panel represents which dashboard panel is focused.
@@ -40,10 +52,36 @@ type AppState struct {
 	Outdated         []pip.Package
 	ActivePanel      Panel
 	StatusMsg        string
+	SyncStatus       string // last dependency file sync error, empty when in sync
 	IsLoading        bool
 	Width            int
 	Height           int
 	VersionChangePkg string // set when user wants to change version of installed pkg
+	Groups           []parser.DependencyGroup
+	GroupIndex       int        // 0 shows every installed package, i > 0 selects Groups[i-1]
+	Job              *JobStatus // the batch of package operations running, if any
+	Output           []string   // the latest lines printed by the running command
+}
+
+// SelectedGroup returns the dependency group chosen in the dashboard, if any.
+func (s AppState) SelectedGroup() (parser.Group, bool) {
+	if s.GroupIndex > 0 && s.GroupIndex <= len(s.Groups) {
+		return s.Groups[s.GroupIndex-1].Group, true
+	}
+	return parser.Group{}, false
+}
+
+// GroupFor returns the group an install of spec is recorded in: the
+// selected group, else the first group that already declares it, else the
+// main group.
+func (s AppState) GroupFor(spec string) parser.Group {
+	if g, ok := s.SelectedGroup(); ok {
+		return g
+	}
+	if g, ok := parser.GroupOf(s.Groups, spec); ok {
+		return g
+	}
+	return parser.MainGroup
 }
 
 // NewAppState creates the initial application state from detection results.
@@ -73,20 +111,46 @@ type Model struct {
 	initView  InitModel
 	search    SearchModel
 	help      HelpModel
+	logs      LogsModel
+	history   HistoryModel
+	undo      UndoModel
+	preview   PreviewModel
+	migrate   MigrateModel
+	cancelJob context.CancelFunc // cancels the running batch
+	jobEvents <-chan tea.Msg
+	output    chan pip.OutputLine
 	Err       error
 }
 
 // NewModel creates the root model from the initial state.
 func NewModel(state AppState) Model {
-	runner := pip.NewRunner(state.Manager, state.Venv)
+	output := make(chan pip.OutputLine, OutputBufferLines)
 	return Model{
 		state:     state,
-		runner:    runner,
+		runner:    newRunner(state, output),
+		output:    output,
 		dashboard: NewDashboardModel(state),
 		initView:  NewInitModel(state),
 		search:    NewSearchModel(),
 		help:      NewHelpModel(),
+		logs:      NewLogsModel(),
+		history:   NewHistoryModel(),
+	}
+}
+
+// newRunner creates a runner for the state's environment that records
+// package operations in the project's audit journal and streams the lines
+// its commands print to output.
+func newRunner(state AppState, output chan<- pip.OutputLine) *pip.Runner {
+	runner := pip.NewBackendRunner(pip.NewEnvBackend(state.Manager, state.Project, state.Venv), state.Venv)
+	runner.Timeout = time.Duration(state.Config.PackageManager.Timeout)
+	runner.Output = output
+	journal, err := audit.Open(state.Project.Dir)
+	if err != nil {
+		log.Warn("audit journal disabled", "error", err)
 	}
+	runner.Journal = journal
+	return runner
 }
 
 // -- Messages --
@@ -95,6 +159,7 @@ func NewModel(state AppState) Model {
 type PackagesLoadedMsg struct {
 	Installed []pip.Package
 	Outdated  []pip.Package
+	Groups    []parser.DependencyGroup
 	Err       error
 }
 
@@ -102,9 +167,16 @@ type PackagesLoadedMsg struct {
 type PackageActionMsg struct {
 	Action  string // "install", "uninstall", "upgrade"
 	Package string
+	Changed bool // true if the environment was modified, even on partial failure
 	Err     error
 }
 
+// DependencyFileSyncedMsg is sent when the dependency file has been rewritten
+// to match the environment after a package action.
+type DependencyFileSyncedMsg struct {
+	Err error
+}
+
 // StatusNotification sets a temporary status notification.
 type StatusNotification struct {
 	Text    string
@@ -116,9 +188,9 @@ type StatusNotification struct {
 func (m Model) Init() tea.Cmd {
 	log.Debug("tui initialized", "screen", m.state.Screen)
 	if m.state.Screen == ScreenDashboard {
-		return m.loadPackages()
+		return tea.Batch(waitForOutput(m.output), m.loadPackages())
 	}
-	return nil
+	return waitForOutput(m.output)
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -130,7 +202,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "ctrl+c":
 				return m, tea.Quit
 			case "q":
-				if m.state.Screen == ScreenDashboard && !m.dashboard.showConfirm {
+				if m.state.Screen == ScreenDashboard {
 					return m, tea.Quit
 				}
 			case "?":
@@ -143,18 +215,154 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			log.Debug("screen changed", "from", m.state.Screen, "to", ScreenHelp)
 					return m, nil
 				}
+			case "L":
+				if m.state.Screen == ScreenLogs {
+					m.state.Screen = ScreenDashboard
+					return m, nil
+				}
+				if m.state.Screen == ScreenDashboard {
+					m.state.Screen = ScreenLogs
+					m.logs = NewLogsModel()
+					log.Debug("screen changed", "from", ScreenDashboard, "to", ScreenLogs)
+					return m, logsTick()
+				}
+			case "ctrl+z":
+				if m.state.Screen == ScreenDashboard && !m.state.IsLoading {
+					m.state.IsLoading = true
+					return m, planUndo(m.state.Project.Dir, m.runner)
+				}
+			case "H":
+				if m.state.Screen == ScreenHistory {
+					m.state.Screen = ScreenDashboard
+					return m, nil
+				}
+				if m.state.Screen == ScreenDashboard {
+					m.state.Screen = ScreenHistory
+					log.Debug("screen changed", "from", ScreenDashboard, "to", ScreenHistory)
+					var cmd tea.Cmd
+					m.history, cmd = m.history.Open(m.state)
+					return m, cmd
+				}
+			case "M":
+				if m.state.Screen == ScreenDashboard && !m.state.IsLoading && setuptoolsProject(m.state.Project) {
+					return m, planMigration(m.state.Project)
+				}
 			case "esc":
 				switch m.state.Screen {
 				case ScreenHelp:
 					m.state.Screen = ScreenDashboard
 				log.Debug("screen changed", "from", ScreenHelp, "to", ScreenDashboard)
 					return m, nil
+				case ScreenLogs, ScreenHistory:
+					m.state.Screen = ScreenDashboard
+					return m, nil
+				case ScreenDashboard:
+					if m.state.Job != nil && !m.state.Job.Cancelling {
+						// Skip the rest of the batch
+						m.cancelJob()
+						m.state.Job.Cancelling = true
+					}
+					if m.state.IsLoading {
+						log.Info("cancelling the running command")
+						m.runner.Cancel()
+						return m, nil
+					}
 				}
 			}
 		} else if msg.String() == "ctrl+c" {
 			return m, tea.Quit
 		}
 
+	case UndoPlanMsg:
+		m.state.IsLoading = false
+		m.state.Output = nil
+		switch {
+		case msg.Err != nil:
+			m.state.StatusMsg = "Undo failed: " + msg.Err.Error()
+		case msg.Plan == nil:
+			m.state.StatusMsg = "Nothing to undo"
+		default:
+			m.undo = NewUndoModel(msg.Plan)
+			m.state.Screen = ScreenUndo
+		}
+		return m, nil
+
+	case MigrationPlanMsg:
+		if msg.Err != nil {
+			m.state.StatusMsg = "Migration failed: " + msg.Err.Error()
+			return m, nil
+		}
+		m.migrate = NewMigrateModel(msg.Migration, m.state.Project.FileType.String())
+		m.state.Screen = ScreenMigrate
+		return m, nil
+
+	case MigratedMsg:
+		if msg.Err != nil {
+			log.Warn("migration failed", "error", msg.Err)
+			m.state.StatusMsg = "Migration failed: " + msg.Err.Error()
+			return m, nil
+		}
+		log.Info("migrated to pyproject.toml", "path", msg.Project.FilePath)
+		m.state.Project = msg.Project
+		m.state.StatusMsg = "migrated to pyproject.toml ✓"
+		m.runner = newRunner(m.state, m.output)
+		return m, m.loadPackages()
+
+	case OutputLineMsg:
+		m.state.Output = append(m.state.Output, msg.Text)
+		if n := len(m.state.Output); n > OutputMaxLines {
+			m.state.Output = m.state.Output[n-OutputMaxLines:]
+		}
+		return m, waitForOutput(m.output)
+
+	case JobStartedMsg:
+		m.state.Job = &JobStatus{Title: msg.Title}
+		m.cancelJob = msg.Cancel
+		m.jobEvents = msg.events
+		return m, waitForJob(m.jobEvents)
+
+	case JobProgressMsg:
+		if m.state.Job != nil {
+			m.state.Job.Progress = msg.Progress
+		}
+		return m, waitForJob(m.jobEvents)
+
+	case PreviewMsg:
+		m.state.IsLoading = false
+		m.state.Output = nil
+		if errors.Is(msg.Err, pip.ErrCancelled) {
+			m.state.StatusMsg = "Cancelled"
+			return m, nil
+		}
+		if msg.Err != nil {
+			log.Warn("dry run failed", "action", msg.Title, "error", msg.Err)
+		}
+		m.preview = NewPreviewModel(msg)
+		m.state.Screen = ScreenPreview
+		return m, nil
+
+	case UndoAppliedMsg:
+		m.state.IsLoading = false
+		m.state.Output = nil
+		if msg.Err != nil {
+			log.Warn("undo failed", "action", msg.Action, "error", msg.Err)
+			m.state.StatusMsg = "Undo failed: " + msg.Err.Error()
+		} else {
+			m.state.StatusMsg = "undid " + msg.Action + " ✓"
+		}
+		return m, m.loadPackages()
+
+	case HistoryLoadedMsg:
+		m.history, _ = m.history.Update(msg, &m.state)
+		return m, nil
+
+	case logsTickMsg:
+		// Keep refreshing while the log viewer is open
+		if m.state.Screen == ScreenLogs {
+			return m, logsTick()
+		}
+		return m, nil
+
 	case tea.WindowSizeMsg:
 		m.state.Width = msg.Width
 		m.state.Height = msg.Height
@@ -163,24 +371,45 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case PackagesLoadedMsg:
 		m.state.IsLoading = false
+		m.state.Output = nil
 		log.Debug("packages loaded", "installed", len(msg.Installed), "outdated", len(msg.Outdated))
 		if msg.Err != nil {
 			m.state.StatusMsg = "Failed to load packages: " + msg.Err.Error()
 		} else {
 			m.state.Installed = msg.Installed
 			m.state.Outdated = msg.Outdated
-			m.dashboard.UpdatePackages(msg.Installed, msg.Outdated)
+			m.state.SetGroups(msg.Groups)
+			m.dashboard.UpdatePackages(m.state.VisibleInstalled(), msg.Outdated)
 		}
 		return m, nil
 
 	case PackageActionMsg:
 		m.state.IsLoading = false
+		m.state.Output = nil
+		m.state.Job = nil
+		m.cancelJob = nil
+		m.jobEvents = nil
 		log.Debug("package action completed", "action", msg.Action, "package", msg.Package, "success", msg.Err == nil)
 		if msg.Err != nil {
 			m.state.StatusMsg = "Failed: " + msg.Err.Error()
 		} else {
 			m.state.StatusMsg = msg.Action + " " + msg.Package + " ✓"
-			return m, m.loadPackages()
+		}
+		if !msg.Changed {
+			return m, nil
+		}
+		if m.state.Config.Sync.OnChange && m.state.Project.Detected() {
+			return m, tea.Sequence(m.syncDependencyFile(), m.loadPackages())
+		}
+		return m, m.loadPackages()
+
+	case DependencyFileSyncedMsg:
+		if msg.Err != nil {
+			log.Warn("dependency file sync failed", "path", m.state.Project.FilePath, "error", msg.Err)
+			m.state.SyncStatus = "sync failed: " + msg.Err.Error()
+		} else {
+			log.Debug("dependency file synced", "path", m.state.Project.FilePath)
+			m.state.SyncStatus = ""
 		}
 		return m, nil
 
@@ -190,7 +419,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.state.Screen == ScreenDashboard {
 			// Project was created, reload runner and load packages
 		log.Debug("project created, switching to dashboard", "manager", m.state.Manager, "venv", m.state.Venv.Path)
-			m.runner = pip.NewRunner(m.state.Manager, m.state.Venv)
+			m.runner = newRunner(m.state, m.output)
 			return m, m.loadPackages()
 		}
 		return m, nil
@@ -230,6 +459,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	case ScreenHelp:
 		m.help, cmd = m.help.Update(msg)
+	case ScreenLogs:
+		m.logs, cmd = m.logs.Update(msg, &m.state)
+	case ScreenHistory:
+		m.history, cmd = m.history.Update(msg, &m.state)
+	case ScreenUndo:
+		m.undo, cmd = m.undo.Update(msg, &m.state, m.runner)
+	case ScreenPreview:
+		m.preview, cmd = m.preview.Update(msg, &m.state)
+	case ScreenMigrate:
+		m.migrate, cmd = m.migrate.Update(msg, &m.state)
 	}
 
 	return m, cmd
@@ -241,6 +480,16 @@ func (m Model) View() string {
 		return m.initView.View(m.state)
 	case ScreenHelp:
 		return m.help.View(m.state)
+	case ScreenLogs:
+		return m.logs.View(m.state)
+	case ScreenHistory:
+		return m.history.View(m.state)
+	case ScreenUndo:
+		return m.undo.View(m.state)
+	case ScreenPreview:
+		return m.preview.View(m.state)
+	case ScreenMigrate:
+		return m.migrate.View(m.state)
 	case ScreenSearch:
 		return m.search.View(m.state)
 	case ScreenDashboard:
@@ -253,6 +502,7 @@ func (m Model) View() string {
 // loadPackages returns a Cmd that fetches installed and outdated packages.
 func (m Model) loadPackages() tea.Cmd {
 	runner := m.runner
+	project := m.state.Project
 	return func() tea.Msg {
 		listResult := runner.List()
 		if listResult.Err != nil {
@@ -272,6 +522,33 @@ func (m Model) loadPackages() tea.Cmd {
 			return PackagesLoadedMsg{Err: fmt.Errorf("pip: parse outdated list: %w", err)}
 		}
 
-		return PackagesLoadedMsg{Installed: installed, Outdated: outdated}
+		var groups []parser.DependencyGroup
+		if project.Detected() {
+			if groups, err = parser.ReadDependencyGroups(project); err != nil {
+				log.Warn("read dependency groups", "path", project.FilePath, "error", err)
+			}
+		}
+
+		return PackagesLoadedMsg{Installed: installed, Outdated: outdated, Groups: groups}
+	}
+}
+
+// syncDependencyFile returns a Cmd that rewrites the project's dependency
+// file from the packages currently installed in the environment.
+func (m Model) syncDependencyFile() tea.Cmd {
+	project := m.state.Project
+	runner := m.runner
+	opts := parser.SyncOptionsFromConfig(m.state.Config.Sync)
+	opts.Hashes = parser.PyPIHashes(context.Background(), m.state.Config.PyPI.Mirror)
+	return func() tea.Msg {
+		return DependencyFileSyncedMsg{Err: parser.SyncDependencyFile(project, runner, opts)}
+	}
+}
+
+// takeSnapshot saves the installed packages and dependency files before
+// action so it can be undone with ctrl+z. Failures are logged, not fatal.
+func takeSnapshot(project detector.Project, runner *pip.Runner, action, lockFile string) {
+	if _, err := snapshot.Take(project, runner, action, lockFile); err != nil {
+		log.Warn("snapshot failed, undo will not be available", "action", action, "error", err)
 	}
 }
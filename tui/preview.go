@@ -0,0 +1,181 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/pip"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// PreviewMsg is sent when the dry run of a pending action finishes. Apply
+// performs the action; it is still offered when the dry run failed.
+type PreviewMsg struct {
+	Title   string
+	Preview pip.Preview
+	Err     error
+	Apply   tea.Cmd
+}
+
+// PreviewModel is the preview screen, which lists every distribution a
+// pending action would change and runs it on confirmation.
+type PreviewModel struct {
+	msg    PreviewMsg
+	scroll int
+}
+
+// NewPreviewModel creates a preview screen for a finished dry run.
+func NewPreviewModel(msg PreviewMsg) PreviewModel {
+	return PreviewModel{msg: msg}
+}
+
+// previewAction returns a Cmd that runs compute, the dry run of an action,
+// and hands its result and apply, the action itself, to the preview screen.
+func previewAction(title string, compute func() (pip.Preview, error), apply tea.Cmd) tea.Cmd {
+	return func() tea.Msg {
+		p, err := compute()
+		return PreviewMsg{Title: title, Preview: p, Err: err, Apply: apply}
+	}
+}
+
+func (p PreviewModel) Update(msg tea.Msg, state *AppState) (PreviewModel, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return p, nil
+	}
+	switch key.String() {
+	case "j", "down":
+		p.scroll = min(p.scroll+1, max(0, len(p.lines())-p.visibleLines(*state)))
+	case "k", "up":
+		p.scroll = max(0, p.scroll-1)
+	case "y", "enter":
+		state.Screen = ScreenDashboard
+		state.IsLoading = true
+		return p, p.msg.Apply
+	case "n", "esc", "q":
+		state.Screen = ScreenDashboard
+	}
+	return p, nil
+}
+
+func (p PreviewModel) visibleLines(state AppState) int {
+	th := state.Height
+	if th == 0 {
+		th = DefaultHeight
+	}
+	return max(ViewportMinHeight, th-PreviewReservedLines)
+}
+
+// lines renders one styled line per change. Downgrades the user did not
+// ask for are flagged, since they can break other installed packages.
+func (p PreviewModel) lines() []string {
+	add := lipgloss.NewStyle().Foreground(config.ColorGreen)
+	del := lipgloss.NewStyle().Foreground(config.ColorRed)
+	up := lipgloss.NewStyle().Foreground(config.ColorCyan)
+	down := lipgloss.NewStyle().Foreground(config.ColorYellow)
+	warn := lipgloss.NewStyle().Bold(true).Foreground(config.ColorRed)
+	dim := lipgloss.NewStyle().Foreground(config.ColorFGDim)
+
+	var out []string
+	for _, c := range p.msg.Preview.Changes {
+		line := fmt.Sprintf("  %s %s %s", c.Kind().Symbol(), c.Name, c.Versions())
+		suffix := ""
+		if !c.Requested {
+			suffix = dim.Render("  dependency")
+		}
+		switch c.Kind() {
+		case pip.ChangeInstall:
+			line = add.Render(line)
+		case pip.ChangeRemove:
+			line = del.Render(line)
+		case pip.ChangeUpgrade:
+			line = up.Render(line)
+		case pip.ChangeDowngrade:
+			if c.Requested {
+				line = down.Render(line)
+			} else {
+				line = warn.Render(line)
+				suffix = warn.Render("  ⚠ dependency downgrade")
+			}
+		default:
+			line = dim.Render(line)
+		}
+		out = append(out, line+suffix)
+	}
+	return out
+}
+
+func (p PreviewModel) View(state AppState) string {
+	tw := state.Width
+	if tw == 0 {
+		tw = DefaultWidth
+	}
+	th := state.Height
+	if th == 0 {
+		th = DefaultHeight
+	}
+
+	container := lipgloss.NewStyle().
+		Width(tw).
+		Height(th).
+		Padding(1, 2)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(config.ColorBlue)
+
+	dimStyle := lipgloss.NewStyle().
+		Foreground(config.ColorFGDim)
+
+	warnStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(config.ColorRed)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("depman — " + p.msg.Title))
+	b.WriteString("\n")
+	switch {
+	case p.msg.Err != nil:
+		b.WriteString(warnStyle.Render("Could not preview the changes: " + p.msg.Err.Error()))
+	case p.msg.Preview.Empty():
+		b.WriteString(dimStyle.Render("Nothing would change"))
+	default:
+		b.WriteString(dimStyle.Render(p.msg.Preview.Summary()))
+	}
+	b.WriteString("\n")
+	if n := len(p.msg.Preview.TransitiveDowngrades()); n > 0 {
+		b.WriteString(warnStyle.Render(fmt.Sprintf("⚠ %d %s would be downgraded", n, plural(n, "dependency", "dependencies"))))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	lines := p.lines()
+	end := min(len(lines), p.scroll+p.visibleLines(state))
+	for _, l := range lines[p.scroll:end] {
+		b.WriteString(l)
+		b.WriteString("\n")
+	}
+	if end < len(lines) {
+		b.WriteString(dimStyle.Render(fmt.Sprintf("  … %d more (j/k to scroll)", len(lines)-end)))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	if p.msg.Err != nil {
+		b.WriteString(dimStyle.Render("y/Enter apply anyway · n/Esc cancel"))
+	} else {
+		b.WriteString(dimStyle.Render("y/Enter apply · n/Esc cancel"))
+	}
+
+	return container.Render(b.String())
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
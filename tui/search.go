@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/parser"
 	"github.com/eslam/depman/pkg/pip"
 	"github.com/eslam/depman/pkg/pypi"
 
@@ -157,12 +158,23 @@ func (s SearchModel) updateDetail(msg tea.KeyMsg, state *AppState, runner *pip.R
 			pkg := s.detail.Name
 			ver := s.detail.Versions[s.versionCursor]
 			installStr := pkg + "==" + ver
+			project := state.Project
+			group := state.GroupFor(pkg)
+			lockFile := state.Config.Sync.LockFile
 			state.Screen = ScreenDashboard
 			state.IsLoading = true
-			return NewSearchModel(), func() tea.Msg {
-				result := runner.Install(installStr)
-				return PackageActionMsg{Action: "installed", Package: pkg + "@" + ver, Err: result.Err}
-			}
+			return NewSearchModel(), previewAction("Install "+installStr, func() (pip.Preview, error) {
+				return runner.PreviewInstall(installStr)
+			}, func() tea.Msg {
+				err := runQueued(runner, installStr, func() pip.RunResult {
+					takeSnapshot(project, runner, "add "+installStr, lockFile)
+					return runner.Add(installStr, group.String())
+				})
+				if err == nil {
+					parser.NoteAdded(project, pkg, group)
+				}
+				return PackageActionMsg{Action: "installed", Package: pkg + "@" + ver, Changed: err == nil, Err: err}
+			})
 		}
 	}
 	return s, nil
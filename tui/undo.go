@@ -0,0 +1,209 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eslam/depman/config"
+	"github.com/eslam/depman/pkg/pip"
+	"github.com/eslam/depman/pkg/snapshot"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// UndoPlanMsg is sent when the undo of the last change has been worked
+// out. A nil Plan means there is nothing to undo.
+type UndoPlanMsg struct {
+	Plan *snapshot.Plan
+	Err  error
+}
+
+// UndoAppliedMsg is sent when an undo has been applied.
+type UndoAppliedMsg struct {
+	Action string
+	Err    error
+}
+
+// UndoModel is the undo screen, which previews what restoring the last
+// snapshot changes and applies it on confirmation.
+type UndoModel struct {
+	plan   *snapshot.Plan
+	scroll int
+}
+
+// NewUndoModel creates an undo screen for plan.
+func NewUndoModel(plan *snapshot.Plan) UndoModel {
+	return UndoModel{plan: plan}
+}
+
+// planUndo returns a Cmd that finds the newest snapshot that differs from
+// the current state. Snapshots that match it, such as those of a failed
+// action, are discarded on the way.
+func planUndo(project string, runner *pip.Runner) tea.Cmd {
+	return func() tea.Msg {
+		snapshots, err := snapshot.List(project)
+		if err != nil {
+			return UndoPlanMsg{Err: err}
+		}
+		if len(snapshots) == 0 {
+			return UndoPlanMsg{}
+		}
+		result := runner.List()
+		if result.Err != nil {
+			return UndoPlanMsg{Err: result.Err}
+		}
+		installed, err := pip.ParsePackageList(result.Stdout)
+		if err != nil {
+			return UndoPlanMsg{Err: err}
+		}
+		for _, snap := range snapshots {
+			plan, err := snapshot.NewPlan(snap, installed)
+			if err != nil {
+				return UndoPlanMsg{Err: err}
+			}
+			if !plan.Empty() {
+				return UndoPlanMsg{Plan: plan}
+			}
+			snapshot.Discard(project, snap.ID)
+		}
+		return UndoPlanMsg{}
+	}
+}
+
+func (u UndoModel) Update(msg tea.Msg, state *AppState, runner *pip.Runner) (UndoModel, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return u, nil
+	}
+	switch key.String() {
+	case "j", "down":
+		u.scroll = min(u.scroll+1, max(0, len(u.lines(*state))-u.visibleLines(*state)))
+	case "k", "up":
+		u.scroll = max(0, u.scroll-1)
+	case "y", "enter":
+		plan := u.plan
+		state.Screen = ScreenDashboard
+		state.IsLoading = true
+		return u, func() tea.Msg {
+			err := plan.Apply(runner)
+			if err == nil {
+				err = snapshot.Discard(plan.Snapshot.Project, plan.Snapshot.ID)
+			}
+			return UndoAppliedMsg{Action: plan.Snapshot.Action, Err: err}
+		}
+	case "n", "esc", "q":
+		state.Screen = ScreenDashboard
+	}
+	return u, nil
+}
+
+func (u UndoModel) visibleLines(state AppState) int {
+	th := state.Height
+	if th == 0 {
+		th = DefaultHeight
+	}
+	return max(ViewportMinHeight, th-UndoReservedLines)
+}
+
+// lines renders the plan, one styled line per entry.
+func (u UndoModel) lines(state AppState) []string {
+	add := lipgloss.NewStyle().Foreground(config.ColorGreen)
+	del := lipgloss.NewStyle().Foreground(config.ColorRed)
+	change := lipgloss.NewStyle().Foreground(config.ColorYellow)
+	header := lipgloss.NewStyle().Bold(true).Foreground(config.ColorCyan)
+	dim := lipgloss.NewStyle().Foreground(config.ColorFGDim)
+
+	var out []string
+	if len(u.plan.Packages) > 0 {
+		out = append(out, header.Render("Packages"))
+	}
+	for _, c := range u.plan.Packages {
+		switch {
+		case c.After == "":
+			out = append(out, del.Render(fmt.Sprintf("  - %s %s (uninstall)", c.Name, c.Before)))
+		case c.Before == "":
+			out = append(out, add.Render(fmt.Sprintf("  + %s %s (reinstall)", c.Name, c.After)))
+		default:
+			out = append(out, change.Render(fmt.Sprintf("  ~ %s %s → %s", c.Name, c.Before, c.After)))
+		}
+	}
+	for _, f := range u.plan.Files {
+		name := f.Path
+		if rel, err := filepath.Rel(state.Project.Dir, f.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		switch {
+		case f.Previous == nil:
+			out = append(out, header.Render(name)+dim.Render(" (delete)"))
+			continue
+		case f.Current == nil:
+			out = append(out, header.Render(name)+dim.Render(" (recreate)"))
+		default:
+			out = append(out, header.Render(name))
+		}
+		for _, l := range f.Diff(2) {
+			switch l.Op {
+			case '-':
+				out = append(out, del.Render("  - "+l.Text))
+			case '+':
+				out = append(out, add.Render("  + "+l.Text))
+			case '…':
+				out = append(out, dim.Render("    …"))
+			default:
+				out = append(out, dim.Render("    "+l.Text))
+			}
+		}
+	}
+	if u.plan.Intents {
+		out = append(out, dim.Render("Pending adds and removes not yet synced are restored"))
+	}
+	return out
+}
+
+func (u UndoModel) View(state AppState) string {
+	tw := state.Width
+	if tw == 0 {
+		tw = DefaultWidth
+	}
+	th := state.Height
+	if th == 0 {
+		th = DefaultHeight
+	}
+
+	container := lipgloss.NewStyle().
+		Width(tw).
+		Height(th).
+		Padding(1, 2)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(config.ColorBlue)
+
+	dimStyle := lipgloss.NewStyle().
+		Foreground(config.ColorFGDim)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("depman — Undo " + u.plan.Snapshot.Action))
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render("Restore the state from " + u.plan.Snapshot.Time.Local().Format(time.DateTime)))
+	b.WriteString("\n\n")
+
+	lines := u.lines(state)
+	end := min(len(lines), u.scroll+u.visibleLines(state))
+	for _, l := range lines[u.scroll:end] {
+		b.WriteString(l)
+		b.WriteString("\n")
+	}
+	if end < len(lines) {
+		b.WriteString(dimStyle.Render(fmt.Sprintf("  … %d more lines (j/k to scroll)", len(lines)-end)))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render("y/Enter apply · n/Esc cancel"))
+
+	return container.Render(b.String())
+}